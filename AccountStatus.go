@@ -0,0 +1,84 @@
+package acccore
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// AccountActive is enum account status of an account accepting both DEBIT and CREDIT transactions
+	AccountActive AccountStatus = iota
+	// AccountFrozen is enum account status of a frozen account, it accepts no transaction
+	AccountFrozen
+	// AccountDebitFrozen is enum account status of a frozen account that still accepts CREDIT transactions
+	AccountDebitFrozen
+	// AccountCreditFrozen is enum account status of a frozen account that still accepts DEBIT transactions
+	AccountCreditFrozen
+	// AccountClosed is enum account status of a closed account, it accepts no transaction and can never be reopened
+	AccountClosed
+)
+
+// AccountStatus is the enum type of account lifecycle status, AccountActive, the frozen statuses and AccountClosed
+type AccountStatus int
+
+// String returns the status name
+func (status AccountStatus) String() string {
+	switch status {
+	case AccountActive:
+		return "ACTIVE"
+	case AccountFrozen:
+		return "FROZEN"
+	case AccountDebitFrozen:
+		return "DEBIT_FROZEN"
+	case AccountCreditFrozen:
+		return "CREDIT_FROZEN"
+	case AccountClosed:
+		return "CLOSED"
+	}
+	return fmt.Sprintf("AccountStatus(%d)", int(status))
+}
+
+// Accepts returns true if an account in this status accepts transactions of the alignment.
+func (status AccountStatus) Accepts(alignment Alignment) bool {
+	switch status {
+	case AccountActive:
+		return true
+	case AccountDebitFrozen:
+		return alignment == CREDIT
+	case AccountCreditFrozen:
+		return alignment == DEBIT
+	}
+	return false
+}
+
+// canTransit checks if an account in this status may change into the new status.
+// Active and frozen accounts may change into each other or be closed, a closed account can not change.
+func (status AccountStatus) canTransit(newStatus AccountStatus) bool {
+	if status == AccountClosed || status == newStatus {
+		return false
+	}
+	return newStatus >= AccountActive && newStatus <= AccountClosed
+}
+
+// AccountStatusChange records an account status change, with its reason and author.
+type AccountStatusChange struct {
+	AccountNumber string        `json:"account_number"`
+	From          AccountStatus `json:"from"`
+	To            AccountStatus `json:"to"`
+	Reason        string        `json:"reason"`
+	ChangeTime    time.Time     `json:"change_time"`
+	ChangeBy      string        `json:"change_by"`
+}
+
+// checkAccountStatusAccepts returns the error of persisting the journal transaction into an account in the status, nil if it is accepted.
+func checkAccountStatusAccepts(journal Journal, trx Transaction, status AccountStatus) error {
+	if status.Accepts(trx.GetAlignment()) {
+		return nil
+	}
+	logrus.Errorf("error persisting journal %s. account %s is %s", journal.GetJournalID(), trx.GetAccountNumber(), status.String())
+	if status == AccountClosed {
+		return ErrJournalAccountClosed
+	}
+	return ErrJournalAccountFrozen
+}
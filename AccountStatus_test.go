@@ -0,0 +1,91 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInMemoryAccountManager_ChangeAccountStatus(t *testing.T) {
+	store := NewInMemoryStore()
+	testAccountStatus(t, NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}))
+}
+
+func TestSQLAccountManager_ChangeAccountStatus(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testAccountStatus(t, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}))
+}
+
+func testAccountStatus(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	am := acc.GetAccountManager()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Point Reserve", "Point issued by the system", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2001", "User Point", "Point owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+	wallet, err := am.GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.Equal(t, AccountActive, wallet.GetStatus())
+
+	// grant credits the wallet, spend debits it
+	grant := func(amount int64) error {
+		_, err := acc.CreateNewJournal(ctx, "Grant", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		return err
+	}
+	spend := func(amount int64) error {
+		_, err := acc.CreateNewJournal(ctx, "Spend", []TransactionInfo{
+			{AccountNumber: "2001", Description: "Spend", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "1001", Description: "Redeem", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		return err
+	}
+	assert.NoError(t, grant(100))
+
+	assert.Equal(t, ErrAccountStatusNoAuthor, am.ChangeAccountStatus(ctx, "2001", AccountFrozen, "fraud check", ""))
+	assert.Equal(t, ErrAccountIDNotFound, am.ChangeAccountStatus(ctx, "9999", AccountFrozen, "fraud check", "officer"))
+	assert.Equal(t, ErrAccountStatusInvalid, am.ChangeAccountStatus(ctx, "2001", AccountActive, "already active", "officer"))
+
+	assert.NoError(t, am.ChangeAccountStatus(ctx, "2001", AccountFrozen, "fraud check", "officer"))
+	assert.Equal(t, ErrJournalAccountFrozen, grant(10))
+	assert.Equal(t, ErrJournalAccountFrozen, spend(10))
+
+	// the user may still receive points, but not spend them
+	assert.NoError(t, am.ChangeAccountStatus(ctx, "2001", AccountDebitFrozen, "partial release", "officer"))
+	assert.NoError(t, grant(10))
+	assert.Equal(t, ErrJournalAccountFrozen, spend(10))
+
+	// updating the account keeps its status
+	wallet, err = am.GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.NoError(t, am.UpdateAccount(ctx, wallet.SetName("User Wallet").SetStatus(AccountActive)))
+	wallet, err = am.GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.Equal(t, AccountDebitFrozen, wallet.GetStatus())
+
+	assert.NoError(t, am.ChangeAccountStatus(ctx, "2001", AccountActive, "cleared", "officer"))
+	assert.Equal(t, ErrAccountCloseNonZero, am.ChangeAccountStatus(ctx, "2001", AccountClosed, "user request", "officer"))
+	assert.NoError(t, spend(110))
+	assert.NoError(t, am.ChangeAccountStatus(ctx, "2001", AccountClosed, "user request", "officer"))
+	assert.Equal(t, ErrJournalAccountClosed, grant(10))
+	assert.Equal(t, ErrAccountStatusInvalid, am.ChangeAccountStatus(ctx, "2001", AccountActive, "reopen", "officer"))
+
+	changes, err := am.ListAccountStatusChanges(ctx, "2001")
+	assert.NoError(t, err)
+	expected := []AccountStatus{AccountFrozen, AccountDebitFrozen, AccountActive, AccountClosed}
+	if assert.Len(t, changes, len(expected)) {
+		from := AccountActive
+		for idx, change := range changes {
+			assert.Equal(t, from, change.From)
+			assert.Equal(t, expected[idx], change.To)
+			assert.Equal(t, "officer", change.ChangeBy)
+			from = change.To
+		}
+		assert.Equal(t, "fraud check", changes[0].Reason)
+	}
+	_, err = am.ListAccountStatusChanges(ctx, "9999")
+	assert.Equal(t, ErrAccountIDNotFound, err)
+}
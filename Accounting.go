@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"sort"
 	"time"
 )
 
@@ -23,6 +25,19 @@ type Accounting struct {
 	transactionManager TransactionManager
 	journalManager     JournalManager
 	uniqueIDGenerator  UniqueIDGenerator
+	txManager          TxManager
+	chartOfAccounts    *ChartOfAccounts
+	periodManager      PeriodManager
+	exchangeManager    ExchangeManager
+	fxClearingAccounts map[string]string
+	// fxRevaluationConfig configures RevalueFX
+	fxRevaluationConfig *FXRevaluationConfig
+	holdManager         HoldManager
+	scheduleManager     ScheduleManager
+	lotManager          LotManager
+	lotPolicies         map[string]LotPolicy
+	// clock decides what is due, the SystemClock if nil
+	clock Clock
 }
 
 // GetAccountManager returns account manager
@@ -45,6 +60,51 @@ func (acc *Accounting) GetUniqueIDGenerator() UniqueIDGenerator {
 	return acc.uniqueIDGenerator
 }
 
+// GetTxManager returns the unit of work manager
+func (acc *Accounting) GetTxManager() TxManager {
+	return acc.txManager
+}
+
+// SetTxManager sets the unit of work manager, it must work on the same storage as the other managers.
+func (acc *Accounting) SetTxManager(txManager TxManager) *Accounting {
+	acc.txManager = txManager
+	return acc
+}
+
+// InUnitOfWork runs the function within a unit of work. All manager operations the function does using the
+// provided context are committed if the function returns nil, or rolled back if it returns error or panics.
+// eg. opening an account and funding it, either both happens or none.
+func (acc *Accounting) InUnitOfWork(context context.Context, work func(context context.Context) error) (err error) {
+	if acc.GetTxManager() == nil {
+		return ErrTxManagerNotSet
+	}
+	uowContext, uow, err := acc.GetTxManager().Begin(context)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = uow.Rollback()
+			panic(r)
+		}
+	}()
+	if err = work(uowContext); err != nil {
+		if rollbackErr := uow.Rollback(); rollbackErr != nil {
+			logrus.Errorf("error rolling back unit of work. got %s", rollbackErr.Error())
+		}
+		return err
+	}
+	return uow.Commit()
+}
+
+// atomically runs the function within a unit of work if the TxManager is set, otherwise simply runs it.
+func (acc *Accounting) atomically(context context.Context, work func(context context.Context) error) error {
+	if acc.GetTxManager() == nil {
+		return work(context)
+	}
+	return acc.InUnitOfWork(context, work)
+}
+
 // CreateNewAccount creates a new account
 func (acc *Accounting) CreateNewAccount(context context.Context, accountNumber, name, description, coa string, currency string, alignment Alignment, creator string) (Account, error) {
 	account := acc.GetAccountManager().NewAccount(context).
@@ -73,69 +133,220 @@ type TransactionInfo struct {
 
 // CreateNewJournal creates a new journal
 func (acc *Accounting) CreateNewJournal(context context.Context, description string, transactions []TransactionInfo, creator string) (Journal, error) {
+	journal := acc.newJournal(context, description, transactions, creator)
+
+	err := acc.persistAndCommitJournal(context, journal)
+	if err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// CreateNewJournalWithIdempotencyKey creates a new journal only once for the idempotency key, making retries safe.
+// If a journal was already created with the key, that journal is returned instead of posting again,
+// provided it has the same description and transactions. Otherwise ErrJournalIdempotencyKeyConflict is returned.
+func (acc *Accounting) CreateNewJournalWithIdempotencyKey(context context.Context, idempotencyKey, description string, transactions []TransactionInfo, creator string) (Journal, error) {
+	if len(idempotencyKey) == 0 {
+		return acc.CreateNewJournal(context, description, transactions, creator)
+	}
+	existing, err := acc.GetJournalManager().GetJournalByIdempotencyKey(context, idempotencyKey)
+	if err == nil {
+		return idempotentJournal(existing, description, transactions)
+	}
+	if err != ErrJournalIdempotencyKeyNotFound {
+		return nil, err
+	}
+
+	journal := acc.newJournal(context, description, transactions, creator)
+	journal.SetIdempotencyKey(idempotencyKey)
+	err = acc.persistAndCommitJournal(context, journal)
+	if err != nil {
+		// a concurrent call with the same key may have posted first
+		if existing, lookupErr := acc.GetJournalManager().GetJournalByIdempotencyKey(context, idempotencyKey); lookupErr == nil {
+			return idempotentJournal(existing, description, transactions)
+		}
+		return nil, err
+	}
+	return journal, nil
+}
+
+// idempotentJournal returns the journal already posted with an idempotency key if it has the same description and transactions.
+func idempotentJournal(journal Journal, description string, transactions []TransactionInfo) (Journal, error) {
+	conflict := journal.GetDescription() != description || len(journal.GetTransactions()) != len(transactions)
+	posted := make(map[string]Transaction)
+	for _, trx := range journal.GetTransactions() {
+		posted[trx.GetAccountNumber()] = trx
+	}
+	for _, txinfo := range transactions {
+		trx, exist := posted[txinfo.AccountNumber]
+		if !exist || trx.GetAlignment() != txinfo.TxType || !trx.GetAmount().Equal(txinfo.Amount) || trx.GetDescription() != txinfo.Description {
+			conflict = true
+		}
+	}
+	if conflict {
+		logrus.Errorf("error creating journal. idempotency key %s is already used by journal %s with different content", journal.GetIdempotencyKey(), journal.GetJournalID())
+		return nil, ErrJournalIdempotencyKeyConflict
+	}
+	return journal, nil
+}
+
+// newJournal builds a new un-persisted journal of the transactions
+func (acc *Accounting) newJournal(context context.Context, description string, transactions []TransactionInfo, creator string) Journal {
 	journal := acc.GetJournalManager().NewJournal(context).SetDescription(description)
+	now := acc.GetClock().Now()
 
 	journal.SetJournalID(acc.GetUniqueIDGenerator().NewUniqueID()).SetCreateBy(creator).
-		SetCreateTime(time.Now()).SetJournalingTime(time.Now()).
+		SetCreateTime(now).SetJournalingTime(now).
 		SetReversal(false).SetReversedJournal(nil)
 
 	transacs := make([]Transaction, 0)
 
 	// make sure all Transactions have accounts of the same Currency
 	for _, txinfo := range transactions {
-		newTransaction := acc.GetTransactionManager().NewTransaction(context).SetCreateBy(creator).SetCreateTime(time.Now()).
+		newTransaction := acc.GetTransactionManager().NewTransaction(context).SetCreateBy(creator).SetCreateTime(now).
 			SetDescription(txinfo.Description).SetAccountNumber(txinfo.AccountNumber).SetAmount(txinfo.Amount).
-			SetTransactionTime(time.Now()).SetAlignment(txinfo.TxType).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID())
+			SetTransactionTime(now).SetAlignment(txinfo.TxType).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID())
 
 		transacs = append(transacs, newTransaction)
 	}
 
 	journal.SetTransactions(transacs)
+	return journal
+}
+
+// persistAndCommitJournal persists the journal and commits it, cancelling the journal if either fails.
+// If the journal posts into lot tracked accounts, their lots are updated first, within the same unit of work,
+// so the TxManager must be set.
+func (acc *Accounting) persistAndCommitJournal(ctx context.Context, journal Journal) error {
+	if !acc.tracksLots(journal) {
+		return acc.commitJournal(ctx, journal)
+	}
+	return acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+		// a debit not covered by the open lots fails before the journal is committed.
+		if err := acc.applyLots(ctx, journal); err != nil {
+			return err
+		}
+		return acc.commitJournal(ctx, journal)
+	})
+}
 
+// commitJournal persists the journal and commits it, cancelling the journal if either fails.
+func (acc *Accounting) commitJournal(context context.Context, journal Journal) error {
 	err := acc.GetJournalManager().PersistJournal(context, journal)
-	if err != nil {
+	if err == nil {
 		err = acc.GetJournalManager().CommitJournal(context, journal)
-		if err != nil {
-			err = acc.GetJournalManager().CancelJournal(context, journal)
-			return nil, err
+	}
+	if err != nil {
+		if cancelErr := acc.GetJournalManager().CancelJournal(context, journal); cancelErr != nil {
+			logrus.Errorf("error cancelling journal %s. got %s", journal.GetJournalID(), cancelErr.Error())
 		}
-		fmt.Println("No error but no content")
-		return nil, fmt.Errorf("commit journal raised no error but no content")
+		return err
 	}
-	return journal, nil
+	return nil
 }
 
-// CreateReversal creats a reversal
+// CreateReversal creats a reversal of all the reversed journal transactions.
+// A journal can only be reversed once, either fully or partially.
 func (acc *Accounting) CreateReversal(context context.Context, description string, reversed Journal, creator string) (Journal, error) {
-	journal := acc.GetJournalManager().NewJournal(context).SetDescription(description)
-	journal.SetJournalID(acc.GetUniqueIDGenerator().NewUniqueID()).SetCreateBy(creator).SetCreateTime(time.Now()).SetJournalingTime(time.Now()).
-		SetReversal(true).SetReversedJournal(reversed)
+	transactions := make([]TransactionInfo, 0, len(reversed.GetTransactions()))
+	for _, trx := range reversed.GetTransactions() {
+		transactions = append(transactions, reversalTransaction(trx, trx.GetAmount()))
+	}
+	return acc.createReversal(context, description, reversed, transactions, creator)
+}
 
-	transacs := make([]Transaction, 0)
+// CreatePartialReversal creates a reversal of only the selected transactions of the reversed journal.
+// The selected transactions must balance each other.
+func (acc *Accounting) CreatePartialReversal(context context.Context, description string, reversed Journal, transactionIDs []string, creator string) (Journal, error) {
+	reversedTransactions := make(map[string]Transaction)
+	for _, trx := range reversed.GetTransactions() {
+		reversedTransactions[trx.GetTransactionID()] = trx
+	}
+	transactions := make([]TransactionInfo, 0, len(transactionIDs))
+	for _, transactionID := range transactionIDs {
+		trx, exist := reversedTransactions[transactionID]
+		if !exist {
+			logrus.Errorf("error reversing journal %s. transaction %s is not part of the journal", reversed.GetJournalID(), transactionID)
+			return nil, ErrReversalTransactionNotFound
+		}
+		transactions = append(transactions, reversalTransaction(trx, trx.GetAmount()))
+	}
+	return acc.createReversal(context, description, reversed, transactions, creator)
+}
 
-	// make sure all Transactions have accounts of the same Currency
-	for _, txinfo := range reversed.GetTransactions() {
-		tx := DEBIT
-		if txinfo.GetAlignment() == DEBIT {
-			tx = CREDIT
+// CreateProRatedReversal creates a reversal of the amount out of the reversed journal amount,
+// reversing each transaction in proportion. The prorated amounts are rounded down, the rounding difference of each alignment
+// is shared one smallest unit at a time by the transactions having the largest remainder.
+func (acc *Accounting) CreateProRatedReversal(context context.Context, description string, reversed Journal, amount decimal.Decimal, creator string) (Journal, error) {
+	total := GetTotalDebit(reversed)
+	if !amount.IsPositive() || amount.GreaterThan(total) {
+		logrus.Errorf("error reversing journal %s. reversal amount %s is not within the journal amount %s", reversed.GetJournalID(), amount.String(), total.String())
+		return nil, ErrReversalAmountInvalid
+	}
+
+	// prorated amounts are rounded to the most precise amount involved
+	places := -amount.Exponent()
+	for _, trx := range reversed.GetTransactions() {
+		if -trx.GetAmount().Exponent() > places {
+			places = -trx.GetAmount().Exponent()
 		}
+	}
+	if places < 0 {
+		places = 0
+	}
+	unit := decimal.New(1, -places)
 
-		newTransaction := acc.GetTransactionManager().NewTransaction(context).SetCreateBy(creator).SetCreateTime(time.Now()).
-			SetDescription(fmt.Sprintf("%s - reversed", txinfo.GetDescription())).SetAccountNumber(txinfo.GetAccountNumber()).
-			SetTransactionTime(time.Now()).SetAlignment(tx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID())
+	trxs := reversed.GetTransactions()
+	parts := make([]decimal.Decimal, len(trxs))
+	fractions := make([]decimal.Decimal, len(trxs))
+	legs := make(map[Alignment][]int)
+	remaining := map[Alignment]decimal.Decimal{DEBIT: amount, CREDIT: amount}
+	for idx, trx := range trxs {
+		exact := trx.GetAmount().Mul(amount).Div(total)
+		parts[idx] = exact.Truncate(places)
+		fractions[idx] = exact.Sub(parts[idx])
+		remaining[trx.GetAlignment()] = remaining[trx.GetAlignment()].Sub(parts[idx])
+		legs[trx.GetAlignment()] = append(legs[trx.GetAlignment()], idx)
+	}
+	// the remainder of each part is below one unit, so every part stays within its transaction amount.
+	for alignment, indexes := range legs {
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return fractions[indexes[i]].GreaterThan(fractions[indexes[j]])
+		})
+		for n := 0; remaining[alignment].IsPositive(); n++ {
+			idx := indexes[n%len(indexes)]
+			parts[idx] = parts[idx].Add(unit)
+			remaining[alignment] = remaining[alignment].Sub(unit)
+		}
+	}
 
-		transacs = append(transacs, newTransaction)
+	transactions := make([]TransactionInfo, 0, len(trxs))
+	for idx, trx := range trxs {
+		if parts[idx].IsZero() {
+			continue
+		}
+		transactions = append(transactions, reversalTransaction(trx, parts[idx]))
 	}
+	return acc.createReversal(context, description, reversed, transactions, creator)
+}
 
-	journal.SetTransactions(transacs)
+// reversalTransaction returns the transaction info reversing the amount of the transaction
+func reversalTransaction(trx Transaction, amount decimal.Decimal) TransactionInfo {
+	return TransactionInfo{
+		AccountNumber: trx.GetAccountNumber(),
+		Description:   fmt.Sprintf("%s - reversed", trx.GetDescription()),
+		TxType:        oppositeAlignment(trx.GetAlignment()),
+		Amount:        amount,
+	}
+}
 
-	err := acc.GetJournalManager().PersistJournal(context, journal)
+// createReversal persists a reversal journal of the transactions
+func (acc *Accounting) createReversal(context context.Context, description string, reversed Journal, transactions []TransactionInfo, creator string) (Journal, error) {
+	journal := acc.newJournal(context, description, transactions, creator)
+	journal.SetReversal(true).SetReversedJournal(reversed)
+
+	err := acc.persistAndCommitJournal(context, journal)
 	if err != nil {
-		err = acc.GetJournalManager().CommitJournal(context, journal)
-		if err != nil {
-			err = acc.GetJournalManager().CancelJournal(context, journal)
-			return nil, err
-		}
 		return nil, err
 	}
 	return journal, nil
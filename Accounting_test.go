@@ -2,6 +2,7 @@ package acccore
 
 import (
 	"context"
+	"fmt"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -9,14 +10,13 @@ import (
 )
 
 func TestAccounting_CreateNewAccount(t *testing.T) {
-	ClearInMemoryTables()
-
 	ctx := context.Background()
+	store := NewInMemoryStore()
 
 	acc := &Accounting{
-		accountManager:     &InMemoryAccountManager{},
-		transactionManager: &InMemoryTransactionManager{},
-		journalManager:     &InMemoryJournalManager{},
+		accountManager:     store.AccountManager(),
+		transactionManager: store.TransactionManager(),
+		journalManager:     store.JournalManager(),
 		uniqueIDGenerator: &RandomGenUniqueIDGenerator{
 			Length:        10,
 			LowerAlpha:    false,
@@ -61,14 +61,13 @@ func TestDecimal(t *testing.T) {
 }
 
 func TestAccounting_CreateNewJournal(t *testing.T) {
-	ClearInMemoryTables()
-
 	ctx := context.Background()
+	store := NewInMemoryStore()
 
 	acc := &Accounting{
-		accountManager:     &InMemoryAccountManager{},
-		transactionManager: &InMemoryTransactionManager{},
-		journalManager:     &InMemoryJournalManager{},
+		accountManager:     store.AccountManager(),
+		transactionManager: store.TransactionManager(),
+		journalManager:     store.JournalManager(),
 		uniqueIDGenerator: &RandomGenUniqueIDGenerator{
 			Length:        10,
 			LowerAlpha:    false,
@@ -154,3 +153,278 @@ func TestAccounting_CreateNewJournal(t *testing.T) {
 		t.Log(render)
 	}
 }
+
+func TestAccounting_InUnitOfWork(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager())
+	testUnitOfWorkBehaviour(t, acc)
+}
+
+func TestAccounting_InUnitOfWorkWithoutTxManager(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{})
+	err := acc.InUnitOfWork(context.Background(), func(ctx context.Context) error {
+		return nil
+	})
+	assert.Equal(t, ErrTxManagerNotSet, err)
+}
+
+// testUnitOfWorkBehaviour runs the behaviour every TxManager implementation must share.
+func testUnitOfWorkBehaviour(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Point Reserve", "Point issued by the system", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+
+	openAndFund := func(accountNumber string, amount int64) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			if _, err := acc.CreateNewAccount(ctx, accountNumber, "User Point", "Point owned by user", "2.1", "POINT", CREDIT, "tester"); err != nil {
+				return err
+			}
+			_, err := acc.CreateNewJournal(ctx, "Welcome bonus", []TransactionInfo{
+				{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+				{AccountNumber: accountNumber, Description: "Bonus", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+			}, "tester")
+			return err
+		}
+	}
+
+	// committed
+	assert.NoError(t, acc.InUnitOfWork(ctx, openAndFund("2001", 100)))
+	wallet, err := acc.GetAccountManager().GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(wallet.GetBalance()))
+
+	// rolled back because the function fails after both steps succeed
+	failure := fmt.Errorf("fulfilment failed")
+	err = acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+		if err := openAndFund("2002", 50)(ctx); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	exist, err := acc.GetAccountManager().IsAccountIDExist(ctx, "2002")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	reserve, err := acc.GetAccountManager().GetAccountByID(ctx, "1001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(reserve.GetBalance()), "reserve balance is %s", reserve.GetBalance())
+
+	// rolled back because the journal fails, the account creation is undone as well
+	err = acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+		if _, err := acc.CreateNewAccount(ctx, "2003", "User Point", "Point owned by user", "2.1", "POINT", CREDIT, "tester"); err != nil {
+			return err
+		}
+		_, err := acc.CreateNewJournal(ctx, "Unbalanced bonus", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+			{AccountNumber: "2003", Description: "Bonus", TxType: CREDIT, Amount: decimal.NewFromInt(20)},
+		}, "tester")
+		return err
+	})
+	assert.Equal(t, ErrJournalNotBalance, err)
+	exist, err = acc.GetAccountManager().IsAccountIDExist(ctx, "2003")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	// nested unit of work joins the outer one
+	err = acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+		if err := acc.InUnitOfWork(ctx, openAndFund("2004", 10)); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	exist, err = acc.GetAccountManager().IsAccountIDExist(ctx, "2004")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	// panic rolls back
+	assert.Panics(t, func() {
+		_ = acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+			if err := openAndFund("2005", 10)(ctx); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	})
+	exist, err = acc.GetAccountManager().IsAccountIDExist(ctx, "2005")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	// the ledger still work after all the rollbacks
+	assert.NoError(t, acc.InUnitOfWork(ctx, openAndFund("2006", 25)))
+	reserve, err = acc.GetAccountManager().GetAccountByID(ctx, "1001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(125).Equal(reserve.GetBalance()), "reserve balance is %s", reserve.GetBalance())
+}
+
+func TestAccounting_CreateNewJournalWithIdempotencyKey(t *testing.T) {
+	store := NewInMemoryStore()
+	testIdempotentJournal(t, NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}))
+}
+
+func TestAccounting_CreateNewJournalWithIdempotencyKeySQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testIdempotentJournal(t, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}))
+}
+
+func testIdempotentJournal(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Point Reserve", "Point issued by the system", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2001", "User Point", "Point owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+	grant := func(amount int64) []TransactionInfo {
+		return []TransactionInfo{
+			{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}
+	}
+
+	_, err = acc.GetJournalManager().GetJournalByIdempotencyKey(ctx, "grant-1")
+	assert.Equal(t, ErrJournalIdempotencyKeyNotFound, err)
+	journal, err := acc.CreateNewJournalWithIdempotencyKey(ctx, "grant-1", "Grant points", grant(100), "tester")
+	assert.NoError(t, err)
+	assert.Equal(t, "grant-1", journal.GetIdempotencyKey())
+
+	// a retry returns the original journal without posting again
+	retried, err := acc.CreateNewJournalWithIdempotencyKey(ctx, "grant-1", "Grant points", grant(100), "tester")
+	assert.NoError(t, err)
+	assert.Equal(t, journal.GetJournalID(), retried.GetJournalID())
+	assert.Equal(t, "grant-1", retried.GetIdempotencyKey())
+	wallet, err := acc.GetAccountManager().GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(wallet.GetBalance()), "wallet balance is %s", wallet.GetBalance())
+
+	// reusing the key for another payload fails
+	_, err = acc.CreateNewJournalWithIdempotencyKey(ctx, "grant-1", "Grant points", grant(200), "tester")
+	assert.Equal(t, ErrJournalIdempotencyKeyConflict, err)
+	_, err = acc.CreateNewJournalWithIdempotencyKey(ctx, "grant-1", "Other grant", grant(100), "tester")
+	assert.Equal(t, ErrJournalIdempotencyKeyConflict, err)
+
+	// the journal manager refuses a second journal with the key
+	duplicate := acc.newJournal(ctx, "Grant points", grant(100), "tester").SetIdempotencyKey("grant-1")
+	assert.Equal(t, ErrJournalIdempotencyKeyAlreadyExist, acc.GetJournalManager().PersistJournal(ctx, duplicate))
+
+	// journals without key are never deduplicated
+	_, err = acc.CreateNewJournalWithIdempotencyKey(ctx, "", "Grant points", grant(100), "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewJournal(ctx, "Grant points", grant(100), "tester")
+	assert.NoError(t, err)
+	loaded, err := acc.GetJournalManager().GetJournalByIdempotencyKey(ctx, "grant-1")
+	assert.NoError(t, err)
+	assert.Equal(t, journal.GetJournalID(), loaded.GetJournalID())
+	wallet, err = acc.GetAccountManager().GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(300).Equal(wallet.GetBalance()), "wallet balance is %s", wallet.GetBalance())
+}
+
+func TestAccounting_CreateReversal(t *testing.T) {
+	store := NewInMemoryStore()
+	testReversal(t, NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}))
+}
+
+func TestAccounting_CreateReversalSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testReversal(t, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}))
+}
+
+func testReversal(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	for _, account := range []struct {
+		number    string
+		alignment Alignment
+	}{{"1001", DEBIT}, {"1002", DEBIT}, {"1003", DEBIT}, {"1004", DEBIT}, {"1005", DEBIT}, {"2001", CREDIT}, {"2002", CREDIT}} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, "1.1", "POINT", account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+	assertBalances := func(expected map[string]int64) {
+		for number, balance := range expected {
+			account, err := acc.GetAccountManager().GetAccountByID(ctx, number)
+			assert.NoError(t, err)
+			assert.True(t, decimal.NewFromInt(balance).Equal(account.GetBalance()), "account %s balance is %s", number, account.GetBalance())
+		}
+	}
+	post := func(transactions ...TransactionInfo) Journal {
+		journal, err := acc.CreateNewJournal(ctx, "Grant", transactions, "tester")
+		assert.NoError(t, err)
+		return journal
+	}
+
+	// full reversal
+	journal := post(
+		TransactionInfo{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(100)},
+		TransactionInfo{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(60)},
+		TransactionInfo{AccountNumber: "2002", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(40)})
+	_, err := acc.GetJournalManager().GetReversalOf(ctx, journal.GetJournalID())
+	assert.Equal(t, ErrJournalNotReversed, err)
+	_, err = acc.GetJournalManager().GetReversalOf(ctx, "unknown")
+	assert.Equal(t, ErrJournalIDNotFound, err)
+	reversal, err := acc.CreateReversal(ctx, "Cancel grant", journal, "tester")
+	assert.NoError(t, err)
+	assertBalances(map[string]int64{"1001": 0, "2001": 0, "2002": 0})
+	loaded, err := acc.GetJournalManager().GetReversalOf(ctx, journal.GetJournalID())
+	assert.NoError(t, err)
+	assert.Equal(t, reversal.GetJournalID(), loaded.GetJournalID())
+	assert.True(t, loaded.IsReversal())
+	assert.Equal(t, journal.GetJournalID(), loaded.GetReversedJournal().GetJournalID())
+	assert.True(t, decimal.NewFromInt(100).Equal(loaded.GetAmount()), "reversal amount is %s", loaded.GetAmount())
+	_, err = acc.CreateReversal(ctx, "Cancel grant again", journal, "tester")
+	assert.Equal(t, ErrJournalCanNotDoubleReverse, err)
+	assertBalances(map[string]int64{"1001": 0, "2001": 0, "2002": 0})
+
+	// reversal of selected transactions
+	journal = post(
+		TransactionInfo{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(60)},
+		TransactionInfo{AccountNumber: "1002", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(40)},
+		TransactionInfo{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(60)},
+		TransactionInfo{AccountNumber: "2002", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(40)})
+	selected := make(map[string]string)
+	for _, trx := range journal.GetTransactions() {
+		selected[trx.GetAccountNumber()] = trx.GetTransactionID()
+	}
+	_, err = acc.CreatePartialReversal(ctx, "Cancel grant", journal, []string{"unknown"}, "tester")
+	assert.Equal(t, ErrReversalTransactionNotFound, err)
+	_, err = acc.CreatePartialReversal(ctx, "Cancel grant", journal, []string{selected["1001"], selected["2002"]}, "tester")
+	assert.Equal(t, ErrJournalNotBalance, err)
+	_, err = acc.CreatePartialReversal(ctx, "Cancel grant", journal, []string{selected["1001"], selected["2001"]}, "tester")
+	assert.NoError(t, err)
+	assertBalances(map[string]int64{"1001": 0, "1002": 40, "2001": 0, "2002": 40})
+	_, err = acc.CreatePartialReversal(ctx, "Cancel grant", journal, []string{selected["1002"], selected["2002"]}, "tester")
+	assert.Equal(t, ErrJournalCanNotDoubleReverse, err)
+
+	// pro-rated reversal
+	journal = post(
+		TransactionInfo{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(100)},
+		TransactionInfo{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(67)},
+		TransactionInfo{AccountNumber: "2002", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(33)})
+	_, err = acc.CreateProRatedReversal(ctx, "Cancel grant", journal, decimal.NewFromInt(101), "tester")
+	assert.Equal(t, ErrReversalAmountInvalid, err)
+	_, err = acc.CreateProRatedReversal(ctx, "Cancel grant", journal, decimal.Zero, "tester")
+	assert.Equal(t, ErrReversalAmountInvalid, err)
+	reversal, err = acc.CreateProRatedReversal(ctx, "Cancel grant", journal, decimal.NewFromInt(10), "tester")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(10).Equal(GetTotalCredit(reversal)))
+	assertBalances(map[string]int64{"1001": 90, "1002": 40, "2001": 60, "2002": 70})
+
+	// the rounding difference goes to the largest remainders, no leg goes negative
+	one := decimal.NewFromInt(1)
+	journal = post(
+		TransactionInfo{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: one},
+		TransactionInfo{AccountNumber: "1002", Description: "Issue", TxType: DEBIT, Amount: one},
+		TransactionInfo{AccountNumber: "1003", Description: "Issue", TxType: DEBIT, Amount: one},
+		TransactionInfo{AccountNumber: "1004", Description: "Issue", TxType: DEBIT, Amount: one},
+		TransactionInfo{AccountNumber: "1005", Description: "Issue", TxType: DEBIT, Amount: one},
+		TransactionInfo{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(5)})
+	reversal, err = acc.CreateProRatedReversal(ctx, "Cancel grant", journal, decimal.NewFromInt(3), "tester")
+	assert.NoError(t, err)
+	if assert.NotNil(t, reversal) {
+		assert.Len(t, reversal.GetTransactions(), 4)
+		for _, trx := range reversal.GetTransactions() {
+			assert.True(t, trx.GetAmount().IsPositive(), "reversed amount is %s", trx.GetAmount())
+		}
+		assert.True(t, decimal.NewFromInt(3).Equal(GetTotalCredit(reversal)))
+	}
+	assertBalances(map[string]int64{"1001": 90, "1002": 40, "1003": 0, "1004": 1, "1005": 1, "2001": 62, "2002": 70})
+}
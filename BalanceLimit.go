@@ -0,0 +1,72 @@
+package acccore
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+)
+
+// ErrJournalBalanceLimit is matched by every BalanceLimitError using errors.Is
+var ErrJournalBalanceLimit = fmt.Errorf("journal Transactions bring account balance outside its limit")
+
+// BalanceLimit constrains the balance an account may have after each journal.
+// Balances are in the account alignment, so a negative balance is below zero in the account's own direction.
+type BalanceLimit struct {
+	// AllowNegative allows the balance to go below zero, down to MinBalance if it is set.
+	AllowNegative bool `json:"allow_negative"`
+	// MinBalance is the lowest balance allowed, no minimum other than zero if it is null.
+	MinBalance decimal.NullDecimal `json:"min_balance"`
+	// MaxBalance is the highest balance allowed, no maximum if it is null.
+	MaxBalance decimal.NullDecimal `json:"max_balance"`
+}
+
+// UnlimitedBalance is the BalanceLimit of new accounts, allowing any balance.
+var UnlimitedBalance = BalanceLimit{AllowNegative: true}
+
+// BalanceLimitError is returned by PersistJournal when a transaction would bring an account balance outside its BalanceLimit.
+type BalanceLimitError struct {
+	AccountNumber string
+	// Balance is the balance the transaction would result in
+	Balance decimal.Decimal
+	// Limit is the violated minimum or maximum balance
+	Limit decimal.Decimal
+	// Shortfall is how far the Balance is beyond the Limit
+	Shortfall decimal.Decimal
+	// Maximum is true if the Limit is the maximum balance, false if it is the minimum
+	Maximum bool
+}
+
+// Error returns the error message naming the account and the shortfall
+func (e *BalanceLimitError) Error() string {
+	if e.Maximum {
+		return fmt.Sprintf("account %s balance %s would exceed maximum balance %s by %s", e.AccountNumber, e.Balance.String(), e.Limit.String(), e.Shortfall.String())
+	}
+	return fmt.Sprintf("account %s balance %s would fall below minimum balance %s by %s", e.AccountNumber, e.Balance.String(), e.Limit.String(), e.Shortfall.String())
+}
+
+// Is makes the error match ErrJournalBalanceLimit
+func (e *BalanceLimitError) Is(target error) bool {
+	return target == ErrJournalBalanceLimit
+}
+
+// validate checks that the minimum is not above the maximum
+func (limit BalanceLimit) validate() error {
+	if limit.MinBalance.Valid && limit.MaxBalance.Valid && limit.MinBalance.Decimal.GreaterThan(limit.MaxBalance.Decimal) {
+		return ErrAccountBalanceLimitInvalid
+	}
+	return nil
+}
+
+// check returns the BalanceLimitError if the balance is outside this limit, nil if it is within.
+func (limit BalanceLimit) check(accountNumber string, balance decimal.Decimal) error {
+	minimum := limit.MinBalance
+	if !limit.AllowNegative && (!minimum.Valid || minimum.Decimal.IsNegative()) {
+		minimum = decimal.NewNullDecimal(decimal.Zero)
+	}
+	if minimum.Valid && balance.LessThan(minimum.Decimal) {
+		return &BalanceLimitError{AccountNumber: accountNumber, Balance: balance, Limit: minimum.Decimal, Shortfall: minimum.Decimal.Sub(balance)}
+	}
+	if limit.MaxBalance.Valid && balance.GreaterThan(limit.MaxBalance.Decimal) {
+		return &BalanceLimitError{AccountNumber: accountNumber, Balance: balance, Limit: limit.MaxBalance.Decimal, Shortfall: balance.Sub(limit.MaxBalance.Decimal), Maximum: true}
+	}
+	return nil
+}
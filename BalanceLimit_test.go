@@ -0,0 +1,78 @@
+package acccore
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInMemoryJournalManager_BalanceLimit(t *testing.T) {
+	store := NewInMemoryStore()
+	testBalanceLimit(t, NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}))
+}
+
+func TestSQLJournalManager_BalanceLimit(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testBalanceLimit(t, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}))
+}
+
+func testBalanceLimit(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	am := acc.GetAccountManager()
+	_, err := acc.CreateNewAccount(ctx, "3001", "Point Issuance", "Point issued by the system", "3.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+	wallet, err := acc.CreateNewAccount(ctx, "1101", "User Wallet", "Point wallet of user", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+	assert.Equal(t, UnlimitedBalance, wallet.GetBalanceLimit())
+	wallet.SetBalanceLimit(BalanceLimit{MaxBalance: decimal.NewNullDecimal(decimal.NewFromInt(500))})
+	assert.NoError(t, am.UpdateAccount(ctx, wallet))
+
+	post := func(alignment Alignment, amount int64) error {
+		_, err := acc.CreateNewJournal(ctx, "Points", []TransactionInfo{
+			{AccountNumber: "1101", Description: "Wallet", TxType: alignment, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "3001", Description: "Issuance", TxType: oppositeAlignment(alignment), Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		return err
+	}
+	assertLimitError := func(err error, shortfall int64, maximum bool) {
+		limitErr := &BalanceLimitError{}
+		if assert.True(t, errors.As(err, &limitErr), "error is %v", err) {
+			assert.Equal(t, "1101", limitErr.AccountNumber)
+			assert.True(t, decimal.NewFromInt(shortfall).Equal(limitErr.Shortfall), "shortfall is %s", limitErr.Shortfall)
+			assert.Equal(t, maximum, limitErr.Maximum)
+		}
+		assert.True(t, errors.Is(err, ErrJournalBalanceLimit))
+	}
+	assertWallet := func(balance int64) {
+		wallet, err := am.GetAccountByID(ctx, "1101")
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(balance).Equal(wallet.GetBalance()), "wallet balance is %s", wallet.GetBalance())
+	}
+
+	assert.NoError(t, post(DEBIT, 100))
+	// overdraft is rejected as a whole
+	assertLimitError(post(CREDIT, 150), 50, false)
+	assertWallet(100)
+
+	wallet, err = am.GetAccountByID(ctx, "1101")
+	assert.NoError(t, err)
+	assert.False(t, wallet.GetBalanceLimit().AllowNegative)
+	assert.True(t, decimal.NewFromInt(500).Equal(wallet.GetBalanceLimit().MaxBalance.Decimal))
+	wallet.SetBalanceLimit(BalanceLimit{AllowNegative: true, MinBalance: decimal.NewNullDecimal(decimal.NewFromInt(-100)), MaxBalance: decimal.NewNullDecimal(decimal.NewFromInt(500))})
+	assert.NoError(t, am.UpdateAccount(ctx, wallet))
+	assert.NoError(t, post(CREDIT, 150))
+	assertWallet(-50)
+	assertLimitError(post(CREDIT, 60), 10, false)
+	assertLimitError(post(DEBIT, 600), 50, true)
+	assertWallet(-50)
+
+	wallet.SetBalanceLimit(BalanceLimit{MinBalance: decimal.NewNullDecimal(decimal.NewFromInt(10)), MaxBalance: decimal.NewNullDecimal(decimal.NewFromInt(5))})
+	assert.Equal(t, ErrAccountBalanceLimitInvalid, am.UpdateAccount(ctx, wallet))
+
+	// the wallet was loaded before the last journal, its stale balance is not written back
+	wallet.SetBalanceLimit(UnlimitedBalance)
+	assert.NoError(t, am.UpdateAccount(ctx, wallet))
+	assertWallet(-50)
+}
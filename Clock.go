@@ -0,0 +1,31 @@
+package acccore
+
+import "time"
+
+// Clock tells the current time. Accounting uses it to decide what is due, so tests may drive time instead of waiting on it.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// SystemClock the clock telling the system time
+type SystemClock struct{}
+
+// Now returns the current system time.
+func (clock *SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// GetClock returns the clock, the SystemClock if none is set.
+func (acc *Accounting) GetClock() Clock {
+	if acc.clock == nil {
+		return &SystemClock{}
+	}
+	return acc.clock
+}
+
+// SetClock sets the clock used to decide what is due.
+func (acc *Accounting) SetClock(clock Clock) *Accounting {
+	acc.clock = clock
+	return acc
+}
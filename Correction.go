@@ -0,0 +1,65 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+)
+
+// JournalCorrection is the result of Accounting.CorrectJournal.
+// The Reversal reverses the Original, the Correction is the Original as it should have been posted.
+type JournalCorrection struct {
+	Original   Journal
+	Reversal   Journal
+	Correction Journal
+}
+
+// CorrectJournal corrects a posted journal by reversing it and posting the corrected transactions in its place.
+// The reversal is linked to the original as its reversed journal, the correction is linked to it as its corrected journal.
+// Both journals are posted within one unit of work, so the TxManager must be set.
+func (acc *Accounting) CorrectJournal(ctx context.Context, original Journal, newTransactions []TransactionInfo, reason, author string) (*JournalCorrection, error) {
+	correction := &JournalCorrection{Original: original}
+	err := acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+		var err error
+		correction.Reversal, err = acc.CreateReversal(ctx, fmt.Sprintf("Reversal of %s : %s", original.GetJournalID(), reason), original, author)
+		if err != nil {
+			return err
+		}
+		correction.Correction = acc.newJournal(ctx, fmt.Sprintf("Correction of %s : %s", original.GetJournalID(), reason), newTransactions, author)
+		correction.Correction.SetCorrectedJournalID(original.GetJournalID())
+		return acc.persistAndCommitJournal(ctx, correction.Correction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return correction, nil
+}
+
+// CorrectionChain returns the journal followed by its reversal and correction, followed by the reversal and correction
+// of that correction and so on, eg. original → reversal → correction → reversal → correction.
+// A journal that is reversed without correction ends the chain with its reversal.
+func (acc *Accounting) CorrectionChain(context context.Context, journalID string) ([]Journal, error) {
+	journal, err := acc.GetJournalManager().GetJournalByID(context, journalID)
+	if err != nil {
+		return nil, err
+	}
+	chain := []Journal{journal}
+	for {
+		reversal, err := acc.GetJournalManager().GetReversalOf(context, journal.GetJournalID())
+		if err == ErrJournalNotReversed {
+			return chain, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, reversal)
+		correction, err := acc.GetJournalManager().GetCorrectionOf(context, journal.GetJournalID())
+		if err == ErrJournalNotCorrected {
+			return chain, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, correction)
+		journal = correction
+	}
+}
@@ -0,0 +1,94 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAccounting_CorrectJournal(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager())
+	testCorrectJournal(t, acc)
+}
+
+func TestAccounting_CorrectJournalSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db))
+	testCorrectJournal(t, acc)
+}
+
+func testCorrectJournal(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Point Reserve", "Point issued by the system", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2001", "User Point", "Point owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+	grant := func(amount int64) []TransactionInfo {
+		return []TransactionInfo{
+			{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}
+	}
+	assertWallet := func(balance int64) {
+		wallet, err := acc.GetAccountManager().GetAccountByID(ctx, "2001")
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(balance).Equal(wallet.GetBalance()), "wallet balance is %s", wallet.GetBalance())
+	}
+
+	original, err := acc.CreateNewJournal(ctx, "Grant points", grant(100), "tester")
+	assert.NoError(t, err)
+	correction, err := acc.CorrectJournal(ctx, original, grant(80), "wrong amount", "auditor")
+	assert.NoError(t, err)
+	assertWallet(80)
+	assert.Equal(t, original.GetJournalID(), correction.Reversal.GetReversedJournal().GetJournalID())
+	assert.Equal(t, original.GetJournalID(), correction.Correction.GetCorrectedJournalID())
+
+	// a failing correction rolls back the reversal
+	_, err = acc.CorrectJournal(ctx, correction.Correction, []TransactionInfo{
+		{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(70)},
+		{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(60)},
+	}, "wrong amount again", "auditor")
+	assert.Equal(t, ErrJournalNotBalance, err)
+	assertWallet(80)
+	_, err = acc.GetJournalManager().GetReversalOf(ctx, correction.Correction.GetJournalID())
+	assert.Equal(t, ErrJournalNotReversed, err)
+
+	second, err := acc.CorrectJournal(ctx, correction.Correction, grant(70), "wrong amount again", "auditor")
+	assert.NoError(t, err)
+	assertWallet(70)
+
+	// an already reversed journal can not be corrected
+	_, err = acc.CorrectJournal(ctx, original, grant(90), "late correction", "auditor")
+	assert.Equal(t, ErrJournalCanNotDoubleReverse, err)
+	assertWallet(70)
+
+	chain, err := acc.CorrectionChain(ctx, original.GetJournalID())
+	assert.NoError(t, err)
+	expected := []string{original.GetJournalID(), correction.Reversal.GetJournalID(), correction.Correction.GetJournalID(),
+		second.Reversal.GetJournalID(), second.Correction.GetJournalID()}
+	if assert.Len(t, chain, len(expected)) {
+		for idx, journal := range chain {
+			assert.Equal(t, expected[idx], journal.GetJournalID())
+		}
+	}
+	loaded, err := acc.GetJournalManager().GetCorrectionOf(ctx, original.GetJournalID())
+	assert.NoError(t, err)
+	assert.Equal(t, original.GetJournalID(), loaded.GetCorrectedJournalID())
+	_, err = acc.GetJournalManager().GetCorrectionOf(ctx, second.Correction.GetJournalID())
+	assert.Equal(t, ErrJournalNotCorrected, err)
+
+	// without a TxManager nothing is posted, the reversal can not be kept apart from its failing correction
+	acc.SetTxManager(nil)
+	_, err = acc.CorrectJournal(ctx, second.Correction, []TransactionInfo{
+		{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(70)},
+		{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(60)},
+	}, "no unit of work", "auditor")
+	assert.Equal(t, ErrTxManagerNotSet, err)
+	assertWallet(70)
+	_, err = acc.GetJournalManager().GetReversalOf(ctx, second.Correction.GetJournalID())
+	assert.Equal(t, ErrJournalNotReversed, err)
+}
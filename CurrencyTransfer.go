@@ -0,0 +1,145 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrExchangeManagerNotSet       = fmt.Errorf("accounting has no ExchangeManager")
+	ErrFXClearingAccountNotSet     = fmt.Errorf("no FX clearing account is set for the currency")
+	ErrTransferSameCurrency        = fmt.Errorf("cross currency transfer requires accounts of different currencies")
+	ErrTransferAmountNotPositive   = fmt.Errorf("transfer amount must be positive")
+	ErrTransferConvertedAmountZero = fmt.Errorf("transfer amount is too small to be exchanged")
+)
+
+// CrossCurrencyTransfer is the result of Accounting.CreateCrossCurrencyTransfer.
+// The SourceJournal moves FromAmount out of the source account into the source currency FX clearing account,
+// the DestinationJournal moves ToAmount out of the destination currency FX clearing account into the destination account.
+type CrossCurrencyTransfer struct {
+	FromAccountNumber  string
+	ToAccountNumber    string
+	FromCurrency       string
+	ToCurrency         string
+	FromAmount         decimal.Decimal
+	ToAmount           decimal.Decimal
+	Rate               decimal.Decimal
+	SourceJournal      Journal
+	DestinationJournal Journal
+}
+
+// GetExchangeManager returns exchange manager
+func (acc *Accounting) GetExchangeManager() ExchangeManager {
+	return acc.exchangeManager
+}
+
+// SetExchangeManager sets the exchange manager used for converting between currencies
+func (acc *Accounting) SetExchangeManager(exchangeManager ExchangeManager) *Accounting {
+	acc.exchangeManager = exchangeManager
+	return acc
+}
+
+// GetFXClearingAccount returns the FX clearing account number of the currency, false if there is none.
+func (acc *Accounting) GetFXClearingAccount(currency string) (string, bool) {
+	accountNumber, ok := acc.fxClearingAccounts[currency]
+	return accountNumber, ok
+}
+
+// SetFXClearingAccount sets the account used for clearing cross currency transfers of the currency.
+// The account must be of that currency.
+func (acc *Accounting) SetFXClearingAccount(currency, accountNumber string) *Accounting {
+	if acc.fxClearingAccounts == nil {
+		acc.fxClearingAccounts = make(map[string]string)
+	}
+	acc.fxClearingAccounts[currency] = accountNumber
+	return acc
+}
+
+// CreateCrossCurrencyTransfer moves the amount out of the source account and the exchanged amount into the destination account,
+// eg. converting user GOLD into POINT. As a journal can only have one currency, two journals are posted, each balanced
+// against the FX clearing account of its currency. Both journals are posted within one unit of work, so the TxManager must be set.
+func (acc *Accounting) CreateCrossCurrencyTransfer(ctx context.Context, description, fromAccountNumber, toAccountNumber string, amount decimal.Decimal, creator string) (*CrossCurrencyTransfer, error) {
+	if acc.GetExchangeManager() == nil {
+		return nil, ErrExchangeManagerNotSet
+	}
+	if acc.GetTxManager() == nil {
+		return nil, ErrTxManagerNotSet
+	}
+	if !amount.IsPositive() {
+		return nil, ErrTransferAmountNotPositive
+	}
+
+	var transfer *CrossCurrencyTransfer
+	err := acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+		fromAccount, err := acc.GetAccountManager().GetAccountByID(ctx, fromAccountNumber)
+		if err != nil {
+			return err
+		}
+		toAccount, err := acc.GetAccountManager().GetAccountByID(ctx, toAccountNumber)
+		if err != nil {
+			return err
+		}
+		if fromAccount.GetCurrency() == toAccount.GetCurrency() {
+			return ErrTransferSameCurrency
+		}
+		fromClearing, ok := acc.GetFXClearingAccount(fromAccount.GetCurrency())
+		if !ok {
+			logrus.Errorf("error transferring from %s to %s. no FX clearing account for %s", fromAccountNumber, toAccountNumber, fromAccount.GetCurrency())
+			return ErrFXClearingAccountNotSet
+		}
+		toClearing, ok := acc.GetFXClearingAccount(toAccount.GetCurrency())
+		if !ok {
+			logrus.Errorf("error transferring from %s to %s. no FX clearing account for %s", fromAccountNumber, toAccountNumber, toAccount.GetCurrency())
+			return ErrFXClearingAccountNotSet
+		}
+
+		rate, err := acc.GetExchangeManager().CalculateExchangeRate(ctx, fromAccount.GetCurrency(), toAccount.GetCurrency())
+		if err != nil {
+			return err
+		}
+		exchanged, err := acc.GetExchangeManager().CalculateExchange(ctx, fromAccount.GetCurrency(), toAccount.GetCurrency(), amount)
+		if err != nil {
+			return err
+		}
+		if !exchanged.IsPositive() {
+			return ErrTransferConvertedAmountZero
+		}
+
+		transfer = &CrossCurrencyTransfer{
+			FromAccountNumber: fromAccountNumber,
+			ToAccountNumber:   toAccountNumber,
+			FromCurrency:      fromAccount.GetCurrency(),
+			ToCurrency:        toAccount.GetCurrency(),
+			FromAmount:        amount,
+			ToAmount:          exchanged,
+			Rate:              rate,
+		}
+		// the source account decreases, the destination account increases.
+		transfer.SourceJournal, err = acc.CreateNewJournal(ctx, fmt.Sprintf("%s (%s leg)", description, transfer.FromCurrency), []TransactionInfo{
+			{AccountNumber: fromAccountNumber, Description: description, TxType: oppositeAlignment(fromAccount.GetAlignment()), Amount: amount},
+			{AccountNumber: fromClearing, Description: fmt.Sprintf("FX clearing %s to %s", transfer.FromCurrency, transfer.ToCurrency), TxType: fromAccount.GetAlignment(), Amount: amount},
+		}, creator)
+		if err != nil {
+			return err
+		}
+		transfer.DestinationJournal, err = acc.CreateNewJournal(ctx, fmt.Sprintf("%s (%s leg)", description, transfer.ToCurrency), []TransactionInfo{
+			{AccountNumber: toClearing, Description: fmt.Sprintf("FX clearing %s to %s", transfer.FromCurrency, transfer.ToCurrency), TxType: oppositeAlignment(toAccount.GetAlignment()), Amount: exchanged},
+			{AccountNumber: toAccountNumber, Description: description, TxType: toAccount.GetAlignment(), Amount: exchanged},
+		}, creator)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// oppositeAlignment returns CREDIT for DEBIT and DEBIT for CREDIT
+func oppositeAlignment(alignment Alignment) Alignment {
+	if alignment == DEBIT {
+		return CREDIT
+	}
+	return DEBIT
+}
@@ -0,0 +1,85 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAccounting_CreateCrossCurrencyTransfer(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager()).SetExchangeManager(store.ExchangeManager())
+	testCrossCurrencyTransfer(t, acc)
+}
+
+func TestAccounting_CreateCrossCurrencyTransferSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db)).SetExchangeManager(NewSQLExchangeManager(db, dialect))
+	testCrossCurrencyTransfer(t, acc)
+}
+
+func testCrossCurrencyTransfer(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.GetExchangeManager().CreateCurrency(ctx, "GOLD", "Gold", decimal.NewFromFloat(0.01), "tester")
+	assert.NoError(t, err)
+	_, err = acc.GetExchangeManager().CreateCurrency(ctx, "POINT", "Point", decimal.NewFromFloat(1), "tester")
+	assert.NoError(t, err)
+	for _, account := range []struct {
+		number, currency string
+		alignment        Alignment
+	}{
+		{"1001", "GOLD", DEBIT},
+		{"1901", "GOLD", DEBIT},
+		{"1902", "POINT", DEBIT},
+		{"2001", "GOLD", CREDIT},
+		{"2002", "POINT", CREDIT},
+		{"2003", "GOLD", CREDIT},
+	} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, "1.1", account.currency, account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+	_, err = acc.CreateNewJournal(ctx, "Buy gold", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Cash in", TxType: DEBIT, Amount: decimal.NewFromInt(50)},
+		{AccountNumber: "2001", Description: "Wallet", TxType: CREDIT, Amount: decimal.NewFromInt(50)},
+	}, "tester")
+	assert.NoError(t, err)
+
+	_, err = acc.CreateCrossCurrencyTransfer(ctx, "Convert", "2001", "2002", decimal.NewFromInt(10), "tester")
+	assert.Equal(t, ErrFXClearingAccountNotSet, err)
+	acc.SetFXClearingAccount("GOLD", "1901").SetFXClearingAccount("POINT", "1902")
+	_, err = acc.CreateCrossCurrencyTransfer(ctx, "Convert", "2001", "2003", decimal.NewFromInt(10), "tester")
+	assert.Equal(t, ErrTransferSameCurrency, err)
+	_, err = acc.CreateCrossCurrencyTransfer(ctx, "Convert", "2001", "2002", decimal.Zero, "tester")
+	assert.Equal(t, ErrTransferAmountNotPositive, err)
+
+	transfer, err := acc.CreateCrossCurrencyTransfer(ctx, "Convert", "2001", "2002", decimal.NewFromInt(10), "tester")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1000).Equal(transfer.ToAmount), "exchanged amount is %s", transfer.ToAmount)
+	assert.True(t, decimal.NewFromInt(100).Equal(transfer.Rate), "rate is %s", transfer.Rate)
+	assert.NotNil(t, transfer.SourceJournal)
+	assert.NotNil(t, transfer.DestinationJournal)
+
+	assertBalances := func(expected map[string]int64) {
+		for number, balance := range expected {
+			account, err := acc.GetAccountManager().GetAccountByID(ctx, number)
+			assert.NoError(t, err)
+			assert.True(t, decimal.NewFromInt(balance).Equal(account.GetBalance()), "account %s balance is %s", number, account.GetBalance())
+		}
+	}
+	assertBalances(map[string]int64{"2001": 40, "1901": -10, "1902": 1000, "2002": 1000})
+
+	// a failing destination leg rolls back the source leg
+	acc.SetFXClearingAccount("POINT", "1901")
+	_, err = acc.CreateCrossCurrencyTransfer(ctx, "Convert", "2001", "2002", decimal.NewFromInt(10), "tester")
+	assert.Equal(t, ErrJournalTransactionMixCurrency, err)
+	assertBalances(map[string]int64{"2001": 40, "1901": -10, "1902": 1000, "2002": 1000})
+
+	// without a TxManager nothing is posted, the source leg can not be kept apart from a failing destination leg
+	acc.SetTxManager(nil)
+	_, err = acc.CreateCrossCurrencyTransfer(ctx, "Convert", "2001", "2002", decimal.NewFromInt(10), "tester")
+	assert.Equal(t, ErrTxManagerNotSet, err)
+	assertBalances(map[string]int64{"2001": 40, "1901": -10, "1902": 1000, "2002": 1000})
+}
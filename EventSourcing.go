@@ -0,0 +1,468 @@
+package acccore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// EventAccountOpened is enum ledger event type of a persisted account, its payload is the account.
+	EventAccountOpened LedgerEventType = iota
+	// EventAccountUpdated is enum ledger event type of an updated account, its payload is the account. It never changes the account balance.
+	EventAccountUpdated
+	// EventAccountStatusChanged is enum ledger event type of an account status change, its payload is the AccountStatusChange.
+	EventAccountStatusChanged
+	// EventJournalPosted is enum ledger event type of a persisted journal, its payload is the journal and its transactions.
+	EventJournalPosted
+)
+
+// LedgerEventType is the enum type of ledger event, EventAccountOpened, EventAccountUpdated, EventAccountStatusChanged and EventJournalPosted
+type LedgerEventType int
+
+// String returns the event type name
+func (eventType LedgerEventType) String() string {
+	switch eventType {
+	case EventAccountOpened:
+		return "ACCOUNT_OPENED"
+	case EventAccountUpdated:
+		return "ACCOUNT_UPDATED"
+	case EventAccountStatusChanged:
+		return "ACCOUNT_STATUS_CHANGED"
+	case EventJournalPosted:
+		return "JOURNAL_POSTED"
+	}
+	return fmt.Sprintf("LedgerEventType(%d)", int(eventType))
+}
+
+// LedgerEvent is one change of the ledger appended to an EventLog.
+type LedgerEvent struct {
+	// Sequence is the position of the event in the log, starting from 1. It is set by EventLog.AppendEvents.
+	Sequence  int64           `json:"sequence"`
+	Type      LedgerEventType `json:"type"`
+	EventTime time.Time       `json:"event_time"`
+	// Payload is the JSON of the changed state, see the LedgerEventType.
+	Payload json.RawMessage `json:"payload"`
+}
+
+// LedgerSnapshot is the state of the projections after the event at Sequence, so they can be rebuilt without replaying the whole log.
+type LedgerSnapshot struct {
+	Sequence     int64           `json:"sequence"`
+	SnapshotTime time.Time       `json:"snapshot_time"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// ledgerAccountData is the payload of the account events.
+// Balance is the opening balance of EventAccountOpened and the balance within a snapshot, EventAccountUpdated ignores it.
+type ledgerAccountData struct {
+	AccountNumber string          `json:"account_number"`
+	Currency      string          `json:"currency"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	Alignment     Alignment       `json:"alignment"`
+	Balance       decimal.Decimal `json:"balance"`
+	COA           string          `json:"coa"`
+	Status        AccountStatus   `json:"status"`
+	BalanceLimit  BalanceLimit    `json:"balance_limit"`
+	CreateTime    time.Time       `json:"create_time"`
+	CreateBy      string          `json:"create_by"`
+	UpdateTime    time.Time       `json:"update_time"`
+	UpdateBy      string          `json:"update_by"`
+}
+
+// ledgerJournalData is the payload of EventJournalPosted.
+type ledgerJournalData struct {
+	JournalID          string                  `json:"journal_id"`
+	JournalingTime     time.Time               `json:"journaling_time"`
+	Description        string                  `json:"description"`
+	ReversedJournalID  string                  `json:"reversed_journal_id"`
+	Amount             decimal.Decimal         `json:"amount"`
+	CreateTime         time.Time               `json:"create_time"`
+	CreateBy           string                  `json:"create_by"`
+	IdempotencyKey     string                  `json:"idempotency_key"`
+	CorrectedJournalID string                  `json:"corrected_journal_id"`
+	PreviousHash       string                  `json:"previous_hash"`
+	Hash               string                  `json:"hash"`
+	Transactions       []ledgerTransactionData `json:"transactions"`
+}
+
+// ledgerTransactionData is a transaction of ledgerJournalData.
+// AccountBalance is only kept within a snapshot, replaying EventJournalPosted derives it from the account balance.
+type ledgerTransactionData struct {
+	TransactionID   string          `json:"transaction_id"`
+	TransactionTime time.Time       `json:"transaction_time"`
+	AccountNumber   string          `json:"account_number"`
+	Description     string          `json:"description"`
+	Alignment       Alignment       `json:"alignment"`
+	Amount          decimal.Decimal `json:"amount"`
+	AccountBalance  decimal.Decimal `json:"account_balance"`
+	CreateTime      time.Time       `json:"create_time"`
+	CreateBy        string          `json:"create_by"`
+}
+
+// ledgerSnapshotData is the payload of LedgerSnapshot.
+type ledgerSnapshotData struct {
+	Accounts      []*ledgerAccountData   `json:"accounts"`
+	StatusChanges []*AccountStatusChange `json:"status_changes"`
+	// Journals are in persisting order.
+	Journals []*ledgerJournalData `json:"journals"`
+}
+
+// newLedgerAccountData returns the event payload of the account.
+func newLedgerAccountData(account Account) *ledgerAccountData {
+	return &ledgerAccountData{
+		AccountNumber: account.GetAccountNumber(),
+		Currency:      account.GetCurrency(),
+		Name:          account.GetName(),
+		Description:   account.GetDescription(),
+		Alignment:     account.GetAlignment(),
+		Balance:       account.GetBalance(),
+		COA:           account.GetCOA(),
+		Status:        account.GetStatus(),
+		BalanceLimit:  account.GetBalanceLimit(),
+		CreateTime:    account.GetCreateTime(),
+		CreateBy:      account.GetCreateBy(),
+		UpdateTime:    account.GetUpdateTime(),
+		UpdateBy:      account.GetUpdateBy(),
+	}
+}
+
+// newLedgerJournalData returns the event payload of the persisted journal.
+func newLedgerJournalData(journal Journal) *ledgerJournalData {
+	data := &ledgerJournalData{
+		JournalID:          journal.GetJournalID(),
+		JournalingTime:     journal.GetJournalingTime(),
+		Description:        journal.GetDescription(),
+		Amount:             journal.GetAmount(),
+		CreateTime:         journal.GetCreateTime(),
+		CreateBy:           journal.GetCreateBy(),
+		IdempotencyKey:     journal.GetIdempotencyKey(),
+		CorrectedJournalID: journal.GetCorrectedJournalID(),
+		PreviousHash:       journal.GetPreviousHash(),
+		Hash:               journal.GetHash(),
+		Transactions:       make([]ledgerTransactionData, 0, len(journal.GetTransactions())),
+	}
+	if journal.GetReversedJournal() != nil {
+		data.ReversedJournalID = journal.GetReversedJournal().GetJournalID()
+	}
+	for _, trx := range journal.GetTransactions() {
+		data.Transactions = append(data.Transactions, ledgerTransactionData{
+			TransactionID:   trx.GetTransactionID(),
+			TransactionTime: trx.GetTransactionTime(),
+			AccountNumber:   trx.GetAccountNumber(),
+			Description:     trx.GetDescription(),
+			Alignment:       trx.GetAlignment(),
+			Amount:          trx.GetAmount(),
+			CreateTime:      trx.GetCreateTime(),
+			CreateBy:        trx.GetCreateBy(),
+		})
+	}
+	return data
+}
+
+// newLedgerEvent returns the event of the type carrying the payload.
+func newLedgerEvent(eventType LedgerEventType, payload interface{}) (*LedgerEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &LedgerEvent{Type: eventType, EventTime: time.Now(), Payload: data}, nil
+}
+
+// EventSourcedStore is a ledger whose append-only EventLog is the source of truth. Persisting an account or a journal
+// appends its event to the log, the accounts, their balances and the journals are projections of the log kept in an InMemoryStore.
+// The projections can be rebuilt from the log any time, starting from the latest snapshot.
+// Only accounts, account status changes, journals and transactions are event sourced.
+type EventSourcedStore struct {
+	log        EventLog
+	projection *InMemoryStore
+	// sequence is the last event applied into the projections.
+	sequence int64
+}
+
+// NewEventSourcedStore creates a store on the event log, building its projections from the log.
+func NewEventSourcedStore(context context.Context, log EventLog) (*EventSourcedStore, error) {
+	store := &EventSourcedStore{log: log, projection: NewInMemoryStore()}
+	if err := store.Rebuild(context); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Sequence returns the sequence of the last event applied into the projections.
+// It waits for the running unit of work, so it must not be called within one.
+func (store *EventSourcedStore) Sequence() int64 {
+	store.projection.unitOfWorkMutex.Lock()
+	defer store.projection.unitOfWorkMutex.Unlock()
+	return store.sequence
+}
+
+// Rebuild clears the projections and builds them back from the latest snapshot and the events after it.
+func (store *EventSourcedStore) Rebuild(context context.Context) error {
+	store.projection.unitOfWorkMutex.Lock()
+	defer store.projection.unitOfWorkMutex.Unlock()
+	store.projection.Clear()
+	store.sequence = 0
+
+	snapshot, err := store.log.GetLatestSnapshot(context)
+	if err != nil && err != ErrLedgerSnapshotNotFound {
+		return err
+	}
+	if err == nil {
+		data := &ledgerSnapshotData{}
+		if err := json.Unmarshal(snapshot.Payload, data); err != nil {
+			logrus.Errorf("error restoring ledger snapshot %d. got %s", snapshot.Sequence, err.Error())
+			return err
+		}
+		if err := store.projection.restoreLedgerSnapshot(data); err != nil {
+			return err
+		}
+		store.sequence = snapshot.Sequence
+	}
+
+	events, err := store.log.ReadEvents(context, store.sequence)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := store.projection.applyLedgerEvent(event); err != nil {
+			logrus.Errorf("error replaying ledger event %d %s. got %s", event.Sequence, event.Type.String(), err.Error())
+			return err
+		}
+		store.sequence = event.Sequence
+	}
+	return nil
+}
+
+// Snapshot saves the snapshot of the projections as of the last applied event, and returns it.
+func (store *EventSourcedStore) Snapshot(context context.Context) (*LedgerSnapshot, error) {
+	store.projection.unitOfWorkMutex.Lock()
+	defer store.projection.unitOfWorkMutex.Unlock()
+	payload, err := json.Marshal(store.projection.ledgerSnapshotData())
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &LedgerSnapshot{Sequence: store.sequence, SnapshotTime: time.Now(), Payload: payload}
+	if err := store.log.SaveSnapshot(context, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// AccountManager returns an AccountManager appending the account events to the log.
+func (store *EventSourcedStore) AccountManager() AccountManager {
+	return &EventSourcedAccountManager{AccountManager: store.projection.AccountManager(), store: store}
+}
+
+// JournalManager returns a JournalManager appending the journal events to the log.
+func (store *EventSourcedStore) JournalManager() JournalManager {
+	return &EventSourcedJournalManager{JournalManager: store.projection.JournalManager(), store: store}
+}
+
+// TransactionManager returns a TransactionManager reading the transaction projection.
+func (store *EventSourcedStore) TransactionManager() TransactionManager {
+	return store.projection.TransactionManager()
+}
+
+// TxManager returns a TxManager beginning units of work whose events are appended to the log when they commit.
+func (store *EventSourcedStore) TxManager() TxManager {
+	return &EventSourcedTxManager{store: store}
+}
+
+// eventSourcedUnitOfWorkKey is the context key of the unit of work running on an event sourced store.
+type eventSourcedUnitOfWorkKey struct {
+	store *EventSourcedStore
+}
+
+// EventSourcedTxManager implementation of TxManager for the event sourced store.
+type EventSourcedTxManager struct {
+	store *EventSourcedStore
+}
+
+// Begin starts a new unit of work and returns the context carrying it.
+func (txm *EventSourcedTxManager) Begin(ctx context.Context) (context.Context, UnitOfWork, error) {
+	if txm.store.unitOfWork(ctx) != nil {
+		return ctx, joinedUnitOfWork{}, nil
+	}
+	projectionContext, projectionUnitOfWork, err := txm.store.projection.TxManager().Begin(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+	uow := &EventSourcedUnitOfWork{store: txm.store, projection: projectionUnitOfWork, context: ctx}
+	return context.WithValue(projectionContext, eventSourcedUnitOfWorkKey{store: txm.store}, uow), uow, nil
+}
+
+// EventSourcedUnitOfWork is the unit of work on the event sourced store.
+// It holds the events of the changes made within it, until they are appended to the log on commit.
+type EventSourcedUnitOfWork struct {
+	store      *EventSourcedStore
+	projection UnitOfWork
+	// context is the context the unit of work began with, used for appending the events.
+	context  context.Context
+	events   []*LedgerEvent
+	finished bool
+}
+
+// Commit appends the events to the log and makes the projection changes permanent.
+// If the events can not be appended, the projection changes are rolled back.
+func (uow *EventSourcedUnitOfWork) Commit() error {
+	if uow.finished {
+		return ErrUnitOfWorkFinished
+	}
+	uow.finished = true
+	if len(uow.events) > 0 {
+		if err := uow.store.log.AppendEvents(uow.context, uow.store.sequence, uow.events); err != nil {
+			logrus.Errorf("error appending %d ledger events after %d. got %s", len(uow.events), uow.store.sequence, err.Error())
+			if rollbackErr := uow.projection.Rollback(); rollbackErr != nil {
+				logrus.Errorf("error rolling back the projection. got %s", rollbackErr.Error())
+			}
+			return err
+		}
+		uow.store.sequence = uow.events[len(uow.events)-1].Sequence
+	}
+	return uow.projection.Commit()
+}
+
+// Rollback discards the events and the projection changes.
+func (uow *EventSourcedUnitOfWork) Rollback() error {
+	if uow.finished {
+		return ErrUnitOfWorkFinished
+	}
+	uow.finished = true
+	uow.events = nil
+	return uow.projection.Rollback()
+}
+
+// unitOfWork returns the unit of work on this store carried by the context, nil if there is none.
+func (store *EventSourcedStore) unitOfWork(context context.Context) *EventSourcedUnitOfWork {
+	if uow, ok := context.Value(eventSourcedUnitOfWorkKey{store: store}).(*EventSourcedUnitOfWork); ok && !uow.finished {
+		return uow
+	}
+	return nil
+}
+
+// record runs the change on the projections within the unit of work carried by the context, or within a new one,
+// and keeps the events returned by the change to be appended to the log when the unit of work commits.
+func (store *EventSourcedStore) record(ctx context.Context, change func(ctx context.Context) ([]*LedgerEvent, error)) error {
+	uowContext, uow, err := store.TxManager().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	events, err := change(uowContext)
+	if err != nil {
+		if rollbackErr := uow.Rollback(); rollbackErr != nil {
+			logrus.Errorf("error rolling back ledger change. got %s", rollbackErr.Error())
+		}
+		return err
+	}
+	running := store.unitOfWork(uowContext)
+	running.events = append(running.events, events...)
+	return uow.Commit()
+}
+
+// EventSourcedAccountManager implementation of AccountManager on the event sourced store.
+// The reads are served by the account projection.
+type EventSourcedAccountManager struct {
+	AccountManager
+	store *EventSourcedStore
+}
+
+// PersistAccount will save the account into the projection and append its EventAccountOpened.
+func (am *EventSourcedAccountManager) PersistAccount(ctx context.Context, AccountToPersist Account) error {
+	return am.store.record(ctx, func(ctx context.Context) ([]*LedgerEvent, error) {
+		if err := am.AccountManager.PersistAccount(ctx, AccountToPersist); err != nil {
+			return nil, err
+		}
+		return am.accountEvent(ctx, EventAccountOpened, AccountToPersist.GetAccountNumber())
+	})
+}
+
+// UpdateAccount will update the account projection and append its EventAccountUpdated.
+// The account balance is only changed by journals, the balance of the provided account is ignored.
+func (am *EventSourcedAccountManager) UpdateAccount(ctx context.Context, AccountToUpdate Account) error {
+	return am.store.record(ctx, func(ctx context.Context) ([]*LedgerEvent, error) {
+		current, err := am.AccountManager.GetAccountByID(ctx, AccountToUpdate.GetAccountNumber())
+		if err == ErrAccountIDNotFound {
+			return nil, ErrAccountIsNotPersisted
+		}
+		if err != nil {
+			return nil, err
+		}
+		update := &BaseAccount{
+			Currency:      AccountToUpdate.GetCurrency(),
+			AccountNumber: AccountToUpdate.GetAccountNumber(),
+			Name:          AccountToUpdate.GetName(),
+			Description:   AccountToUpdate.GetDescription(),
+			Alignment:     AccountToUpdate.GetAlignment(),
+			Balance:       current.GetBalance(),
+			COA:           AccountToUpdate.GetCOA(),
+			CreateBy:      AccountToUpdate.GetCreateBy(),
+			UpdateBy:      AccountToUpdate.GetUpdateBy(),
+			BalanceLimit:  AccountToUpdate.GetBalanceLimit(),
+		}
+		if err := am.AccountManager.UpdateAccount(ctx, update); err != nil {
+			return nil, err
+		}
+		return am.accountEvent(ctx, EventAccountUpdated, AccountToUpdate.GetAccountNumber())
+	})
+}
+
+// ChangeAccountStatus changes the account status projection and appends its EventAccountStatusChanged.
+func (am *EventSourcedAccountManager) ChangeAccountStatus(ctx context.Context, accountNumber string, newStatus AccountStatus, reason, author string) error {
+	return am.store.record(ctx, func(ctx context.Context) ([]*LedgerEvent, error) {
+		if err := am.AccountManager.ChangeAccountStatus(ctx, accountNumber, newStatus, reason, author); err != nil {
+			return nil, err
+		}
+		changes, err := am.AccountManager.ListAccountStatusChanges(ctx, accountNumber)
+		if err != nil {
+			return nil, err
+		}
+		event, err := newLedgerEvent(EventAccountStatusChanged, changes[len(changes)-1])
+		if err != nil {
+			return nil, err
+		}
+		return []*LedgerEvent{event}, nil
+	})
+}
+
+// accountEvent returns the event of the account as it is in the projection.
+func (am *EventSourcedAccountManager) accountEvent(context context.Context, eventType LedgerEventType, accountNumber string) ([]*LedgerEvent, error) {
+	account, err := am.AccountManager.GetAccountByID(context, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	event, err := newLedgerEvent(eventType, newLedgerAccountData(account))
+	if err != nil {
+		return nil, err
+	}
+	return []*LedgerEvent{event}, nil
+}
+
+// EventSourcedJournalManager implementation of JournalManager on the event sourced store.
+// The reads are served by the journal projection.
+type EventSourcedJournalManager struct {
+	JournalManager
+	store *EventSourcedStore
+}
+
+// PersistJournal will record the journal into the projection, moving the account balances, and append its EventJournalPosted.
+// The journal is validated the same way as InMemoryJournalManager.PersistJournal does.
+func (jm *EventSourcedJournalManager) PersistJournal(ctx context.Context, journalToPersist Journal) error {
+	return jm.store.record(ctx, func(ctx context.Context) ([]*LedgerEvent, error) {
+		if err := jm.JournalManager.PersistJournal(ctx, journalToPersist); err != nil {
+			return nil, err
+		}
+		persisted, err := jm.JournalManager.GetJournalByID(ctx, journalToPersist.GetJournalID())
+		if err != nil {
+			return nil, err
+		}
+		event, err := newLedgerEvent(EventJournalPosted, newLedgerJournalData(persisted))
+		if err != nil {
+			return nil, err
+		}
+		return []*LedgerEvent{event}, nil
+	})
+}
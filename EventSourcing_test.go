@@ -0,0 +1,155 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEventSourcedStore(t *testing.T) {
+	testEventSourcedStore(t, NewInMemoryEventLog())
+}
+
+func TestEventSourcedStoreSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testEventSourcedStore(t, NewSQLEventLog(db, dialect))
+}
+
+func testEventSourcedStore(t *testing.T, log EventLog) {
+	ctx := context.Background()
+	store, err := NewEventSourcedStore(ctx, log)
+	assert.NoError(t, err)
+	newAccounting := func(store *EventSourcedStore) *Accounting {
+		return NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+			SetTxManager(store.TxManager())
+	}
+	acc := newAccounting(store)
+	for _, account := range []struct {
+		number    string
+		alignment Alignment
+	}{
+		{"1001", DEBIT},
+		{"2001", CREDIT},
+		{"4001", CREDIT},
+	} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, "1.1", "IDR", account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+	post := func(acc *Accounting, credit string, amount int64) (Journal, error) {
+		return acc.CreateNewJournal(ctx, "Deposit", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Cash", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: credit, Description: "Deposit", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+	}
+	assertBalances := func(acc *Accounting, expected map[string]int64) {
+		for accountNumber, balance := range expected {
+			account, err := acc.GetAccountManager().GetAccountByID(ctx, accountNumber)
+			if assert.NoError(t, err) {
+				assert.True(t, decimal.NewFromInt(balance).Equal(account.GetBalance()), "account %s balance is %s", accountNumber, account.GetBalance())
+			}
+		}
+	}
+
+	first, err := post(acc, "2001", 100)
+	assert.NoError(t, err)
+	_, err = post(acc, "4001", 40)
+	assert.NoError(t, err)
+	_, err = acc.CreateReversal(ctx, "Refund", first, "tester")
+	assert.NoError(t, err)
+	_, err = post(acc, "2001", 70)
+	assert.NoError(t, err)
+	assert.NoError(t, acc.GetAccountManager().ChangeAccountStatus(ctx, "4001", AccountFrozen, "audit", "tester"))
+	account, err := acc.GetAccountManager().GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	account.SetName("Renamed").SetBalance(decimal.NewFromInt(1000000))
+	assert.NoError(t, acc.GetAccountManager().UpdateAccount(ctx, account))
+
+	// rejected changes and rolled back units of work append nothing
+	_, err = post(acc, "9999", 10)
+	assert.Equal(t, ErrJournalTransactionAccountNotPersist, err)
+	err = acc.InUnitOfWork(ctx, func(uowCtx context.Context) error {
+		_, err := acc.CreateNewJournal(uowCtx, "Deposit", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Cash", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+			{AccountNumber: "2001", Description: "Deposit", TxType: CREDIT, Amount: decimal.NewFromInt(10)},
+		}, "tester")
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	assert.EqualError(t, err, "abort")
+
+	expected := map[string]int64{"1001": 110, "2001": 70, "4001": 40}
+	assertBalances(acc, expected)
+	events, err := log.ReadEvents(ctx, 0)
+	assert.NoError(t, err)
+	types := make([]LedgerEventType, 0)
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []LedgerEventType{EventAccountOpened, EventAccountOpened, EventAccountOpened,
+		EventJournalPosted, EventJournalPosted, EventJournalPosted, EventJournalPosted, EventAccountStatusChanged, EventAccountUpdated}, types)
+	assert.Equal(t, int64(9), store.Sequence())
+
+	// the projections are rebuilt from the log alone
+	rebuilt, err := NewEventSourcedStore(ctx, log)
+	assert.NoError(t, err)
+	rebuiltAcc := newAccounting(rebuilt)
+	assertBalances(rebuiltAcc, expected)
+	account, err = rebuiltAcc.GetAccountManager().GetAccountByID(ctx, "4001")
+	assert.NoError(t, err)
+	assert.Equal(t, AccountFrozen, account.GetStatus())
+	changes, err := rebuiltAcc.GetAccountManager().ListAccountStatusChanges(ctx, "4001")
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	account, err = rebuiltAcc.GetAccountManager().GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", account.GetName())
+	reversed, err := rebuiltAcc.GetJournalManager().IsJournalIDReversed(ctx, first.GetJournalID())
+	assert.NoError(t, err)
+	assert.True(t, reversed)
+	verification, err := rebuiltAcc.GetJournalManager().VerifyJournalChain(ctx)
+	assert.NoError(t, err)
+	assert.False(t, verification.Broken)
+	assert.Equal(t, 4, verification.Verified)
+	report, err := rebuiltAcc.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.IsConsistent(), report.Render())
+
+	// a stale store can not append after events it has not seen
+	_, err = post(acc, "2001", 30)
+	assert.NoError(t, err)
+	stale := newAccounting(rebuilt)
+	_, err = post(stale, "2001", 5)
+	assert.Equal(t, ErrLedgerEventSequenceConflict, err)
+	assertBalances(stale, expected)
+
+	// the rebuild starts from the latest snapshot, replaying only the events after it
+	snapshot, err := store.Snapshot(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), snapshot.Sequence)
+	_, err = post(acc, "4001", 20)
+	assert.Equal(t, ErrJournalAccountFrozen, err)
+	_, err = post(acc, "2001", 20)
+	assert.NoError(t, err)
+	expected = map[string]int64{"1001": 160, "2001": 120, "4001": 40}
+	assertBalances(acc, expected)
+
+	assert.NoError(t, rebuilt.Rebuild(ctx))
+	assert.Equal(t, int64(11), rebuilt.Sequence())
+	assertBalances(stale, expected)
+	changes, err = stale.GetAccountManager().ListAccountStatusChanges(ctx, "4001")
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	verification, err = stale.GetJournalManager().VerifyJournalChain(ctx)
+	assert.NoError(t, err)
+	assert.False(t, verification.Broken)
+	assert.Equal(t, 6, verification.Verified)
+	report, err = stale.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.IsConsistent(), report.Render())
+	_, err = post(stale, "2001", 5)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,23 @@
+package acccore
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// CurrencyRate is an exchange value of a Currency, effective from ValidFrom until the next rate of that currency.
+// ExchangeManager keeps every rate, so past conversions can be reproduced.
+type CurrencyRate struct {
+	Code       string          `json:"code"`
+	Exchange   decimal.Decimal `json:"exchange"`
+	ValidFrom  time.Time       `json:"valid_from"`
+	CreateTime time.Time       `json:"create_time"`
+	CreateBy   string          `json:"create_by"`
+}
+
+// exchangeRateOf calculates the rate of exchanging between two currency exchange values using the common denominator.
+func exchangeRateOf(denom, fromExchange, toExchange decimal.Decimal) decimal.Decimal {
+	m1 := denom.Div(fromExchange)
+	m2 := m1.Mul(toExchange)
+	return m2.Div(denom)
+}
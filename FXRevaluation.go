@@ -0,0 +1,300 @@
+package acccore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shopspring/decimal"
+	"sort"
+	"time"
+)
+
+var (
+	ErrFXRevaluationNotConfigured = fmt.Errorf("accounting has no FX revaluation configuration")
+)
+
+// FXRevaluationConfig configures the FX revaluation run of Accounting.RevalueFX.
+type FXRevaluationConfig struct {
+	// BaseCurrency is the currency the foreign currency accounts are valued in.
+	BaseCurrency string
+	// GainAccountNumber is the base currency account credited with unrealized FX gain.
+	GainAccountNumber string
+	// LossAccountNumber is the base currency account debited with unrealized FX loss.
+	LossAccountNumber string
+	// AdjustmentAccountNumbers maps a foreign currency to the base currency account accumulating its revaluation adjustments.
+	// Only the currencies in this map are revalued.
+	AdjustmentAccountNumbers map[string]string
+}
+
+// FXRevaluationLine is the revaluation of one foreign currency account.
+type FXRevaluationLine struct {
+	AccountNumber string    `json:"account_number"`
+	Currency      string    `json:"currency"`
+	Alignment     Alignment `json:"alignment"`
+	// Balance is the account balance in its own currency.
+	Balance decimal.Decimal `json:"balance"`
+	// Rate is the exchange rate into the base currency at the revaluation date.
+	Rate decimal.Decimal `json:"rate"`
+	// Value is the balance in the base currency at the Rate.
+	Value decimal.Decimal `json:"value"`
+	// BookedValue is the balance in the base currency at the rates valid when each transaction happened.
+	BookedValue decimal.Decimal `json:"booked_value"`
+	// Unrealized is the gain (positive) or loss (negative) of holding the account, between Value and BookedValue.
+	Unrealized decimal.Decimal `json:"unrealized"`
+}
+
+// FXRevaluationAdjustment is the revaluation of all accounts of one foreign currency.
+type FXRevaluationAdjustment struct {
+	Currency                string `json:"currency"`
+	AdjustmentAccountNumber string `json:"adjustment_account_number"`
+	// Unrealized is the total unrealized gain (positive) or loss (negative) of the currency accounts.
+	Unrealized decimal.Decimal `json:"unrealized"`
+	// Posted is the unrealized gain or loss already posted into the adjustment account by the earlier revaluations.
+	Posted decimal.Decimal `json:"posted"`
+	// Adjustment is the gain (positive) or loss (negative) posted by this revaluation.
+	Adjustment decimal.Decimal `json:"adjustment"`
+}
+
+// FXRevaluation is the result of an FX revaluation run.
+type FXRevaluation struct {
+	Date         time.Time                 `json:"date"`
+	BaseCurrency string                    `json:"base_currency"`
+	DryRun       bool                      `json:"dry_run"`
+	Lines        []FXRevaluationLine       `json:"lines"`
+	Adjustments  []FXRevaluationAdjustment `json:"adjustments"`
+	TotalGain    decimal.Decimal           `json:"total_gain"`
+	TotalLoss    decimal.Decimal           `json:"total_loss"`
+	// AlreadyRun is true if the revaluation of the date was posted before, the Journal is then the earlier journal.
+	AlreadyRun bool `json:"already_run"`
+	// Journal is the posted adjustment journal, nil for dry run or if there was nothing to adjust.
+	Journal Journal `json:"journal"`
+}
+
+// Render will render this revaluation into string for easy inspection
+func (rev *FXRevaluation) Render() string {
+	var buff bytes.Buffer
+	table := tablewriter.NewWriter(&buff)
+	table.SetHeader([]string{"Account", "Currency", "Balance", "Rate", "Value", "Booked Value", "Unrealized"})
+	for _, line := range rev.Lines {
+		table.Append([]string{line.AccountNumber, line.Currency, line.Balance.String(), line.Rate.String(), line.Value.String(), line.BookedValue.String(), line.Unrealized.String()})
+	}
+	for _, adjustment := range rev.Adjustments {
+		table.Append([]string{adjustment.AdjustmentAccountNumber, adjustment.Currency, "", "", "POSTED " + adjustment.Posted.String(), "ADJUSTMENT", adjustment.Adjustment.String()})
+	}
+	table.SetFooter([]string{"", "", "", "", "", "GAIN / LOSS", fmt.Sprintf("%s / %s", rev.TotalGain.String(), rev.TotalLoss.String())})
+	buff.WriteString(fmt.Sprintf("FX Revaluation : %s\n", rev.BaseCurrency))
+	buff.WriteString(fmt.Sprintf("Date           : %s\n", rev.Date.String()))
+	buff.WriteString(fmt.Sprintf("Dry Run        : %v\n", rev.DryRun))
+	buff.WriteString(fmt.Sprintf("Already Run    : %v\n", rev.AlreadyRun))
+	table.Render()
+	return buff.String()
+}
+
+// GetFXRevaluationConfig returns the FX revaluation configuration
+func (acc *Accounting) GetFXRevaluationConfig() *FXRevaluationConfig {
+	return acc.fxRevaluationConfig
+}
+
+// SetFXRevaluationConfig sets the FX revaluation configuration
+func (acc *Accounting) SetFXRevaluationConfig(config *FXRevaluationConfig) *Accounting {
+	acc.fxRevaluationConfig = config
+	return acc
+}
+
+// RevalueFX revalues the ASSET and LIABILITY accounts (according to the chart of accounts) of the configured foreign currencies.
+// Each account balance at the date is valued in the base currency using the rate valid at the date, and compared with its booked value,
+// the balance valued using the rates valid when each transaction happened. The difference not yet posted by the earlier revaluations
+// is posted into the currency adjustment account, against the FX gain or FX loss account.
+// Revaluations should be run in date order. Only one revaluation is posted per base currency and date, running it again returns the
+// earlier journal. A dry run only calculates the revaluation.
+func (acc *Accounting) RevalueFX(context context.Context, date time.Time, dryRun bool, author string) (*FXRevaluation, error) {
+	config := acc.GetFXRevaluationConfig()
+	if config == nil {
+		return nil, ErrFXRevaluationNotConfigured
+	}
+	if acc.GetExchangeManager() == nil {
+		return nil, ErrExchangeManagerNotSet
+	}
+	chart := acc.GetChartOfAccounts()
+	if chart == nil {
+		return nil, ErrChartOfAccountsNotSet
+	}
+
+	rev := &FXRevaluation{
+		Date:         date,
+		BaseCurrency: config.BaseCurrency,
+		DryRun:       dryRun,
+		Lines:        make([]FXRevaluationLine, 0),
+		Adjustments:  make([]FXRevaluationAdjustment, 0),
+		TotalGain:    decimal.Zero,
+		TotalLoss:    decimal.Zero,
+	}
+
+	accounts, err := acc.listAllAccounts(context)
+	if err != nil {
+		return nil, err
+	}
+	unrealized := make(map[string]decimal.Decimal)
+	for _, account := range accounts {
+		if _, ok := config.AdjustmentAccountNumbers[account.GetCurrency()]; !ok || account.GetCurrency() == config.BaseCurrency {
+			continue
+		}
+		if group, ok := chart.GroupOf(account.GetCOA()); !ok || (group != ASSET && group != LIABILITY) {
+			continue
+		}
+		if account.GetCreateTime().After(date) {
+			continue
+		}
+		line, err := acc.revalueFXAccount(context, account, config.BaseCurrency, date)
+		if err != nil {
+			return nil, err
+		}
+		rev.Lines = append(rev.Lines, *line)
+		unrealized[line.Currency] = unrealized[line.Currency].Add(line.Unrealized)
+	}
+
+	currencies := make([]string, 0, len(config.AdjustmentAccountNumbers))
+	for currency := range config.AdjustmentAccountNumbers {
+		if currency != config.BaseCurrency {
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Strings(currencies)
+	legs := make([]TransactionInfo, 0)
+	for _, currency := range currencies {
+		adjustmentAccountNumber := config.AdjustmentAccountNumbers[currency]
+		adjustmentAccount, err := acc.GetAccountManager().GetAccountByID(context, adjustmentAccountNumber)
+		if err != nil {
+			return nil, err
+		}
+		// the adjustment account is debited with gains and credited with losses.
+		posted := adjustmentAccount.GetBalance()
+		if adjustmentAccount.GetAlignment() == CREDIT {
+			posted = posted.Neg()
+		}
+		adjustment := FXRevaluationAdjustment{
+			Currency:                currency,
+			AdjustmentAccountNumber: adjustmentAccountNumber,
+			Unrealized:              unrealized[currency],
+			Posted:                  posted,
+			Adjustment:              unrealized[currency].Sub(posted),
+		}
+		rev.Adjustments = append(rev.Adjustments, adjustment)
+		if adjustment.Adjustment.IsZero() {
+			continue
+		}
+		leg := TransactionInfo{AccountNumber: adjustmentAccountNumber, Description: fmt.Sprintf("FX revaluation of %s", currency), TxType: DEBIT, Amount: adjustment.Adjustment.Abs()}
+		if adjustment.Adjustment.IsPositive() {
+			rev.TotalGain = rev.TotalGain.Add(adjustment.Adjustment)
+		} else {
+			leg.TxType = CREDIT
+			rev.TotalLoss = rev.TotalLoss.Add(adjustment.Adjustment.Abs())
+		}
+		legs = append(legs, leg)
+	}
+	if config.GainAccountNumber == config.LossAccountNumber {
+		net := rev.TotalGain.Sub(rev.TotalLoss)
+		if !net.IsZero() {
+			leg := TransactionInfo{AccountNumber: config.GainAccountNumber, Description: "Unrealized FX gain", TxType: CREDIT, Amount: net.Abs()}
+			if net.IsNegative() {
+				leg.Description, leg.TxType = "Unrealized FX loss", DEBIT
+			}
+			legs = append(legs, leg)
+		}
+	} else {
+		if rev.TotalGain.IsPositive() {
+			legs = append(legs, TransactionInfo{AccountNumber: config.GainAccountNumber, Description: "Unrealized FX gain", TxType: CREDIT, Amount: rev.TotalGain})
+		}
+		if rev.TotalLoss.IsPositive() {
+			legs = append(legs, TransactionInfo{AccountNumber: config.LossAccountNumber, Description: "Unrealized FX loss", TxType: DEBIT, Amount: rev.TotalLoss})
+		}
+	}
+
+	// the journal ID makes the revaluation of a date posted only once.
+	journalID := fmt.Sprintf("FX-REVALUATION-%s-%s", config.BaseCurrency, date.Format("20060102"))
+	exist, err := acc.GetJournalManager().IsJournalIDExist(context, journalID)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		rev.AlreadyRun = true
+		rev.Journal, err = acc.GetJournalManager().GetJournalByID(context, journalID)
+		if err != nil {
+			return nil, err
+		}
+		return rev, nil
+	}
+	if dryRun || len(legs) == 0 {
+		return rev, nil
+	}
+	journal := acc.newJournal(context, fmt.Sprintf("FX revaluation into %s as of %s", config.BaseCurrency, date.Format("2006-01-02")), legs, author)
+	journal.SetJournalID(journalID)
+	if err := acc.persistAndCommitJournal(context, journal); err != nil {
+		return nil, err
+	}
+	rev.Journal = journal
+	return rev, nil
+}
+
+// revalueFXAccount values the account balance at the date, and its booked value from the rates when each transaction happened.
+func (acc *Accounting) revalueFXAccount(context context.Context, account Account, baseCurrency string, date time.Time) (*FXRevaluationLine, error) {
+	em := acc.GetExchangeManager()
+	balance, err := acc.GetTransactionManager().GetAccountBalanceAt(context, account, date)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := em.CalculateExchangeRateAt(context, account.GetCurrency(), baseCurrency, date)
+	if err != nil {
+		return nil, err
+	}
+
+	bookedValue := decimal.Zero
+	opening := balance // what is left after taking out the transactions, valued at the account creation
+	request := PageRequest{PageNo: 1, ItemSize: 100}
+	for {
+		page, transactions, err := acc.GetTransactionManager().ListTransactionsOnAccount(context, time.Time{}, date, account, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, transaction := range transactions {
+			amount := transaction.GetAmount()
+			if transaction.GetAlignment() != account.GetAlignment() {
+				amount = amount.Neg()
+			}
+			value, err := em.CalculateExchangeAt(context, account.GetCurrency(), baseCurrency, amount, transaction.GetTransactionTime())
+			if err != nil {
+				return nil, err
+			}
+			bookedValue = bookedValue.Add(value)
+			opening = opening.Sub(amount)
+		}
+		if !page.HaveNext {
+			break
+		}
+		request.PageNo = page.NextPage
+	}
+	if !opening.IsZero() {
+		value, err := em.CalculateExchangeAt(context, account.GetCurrency(), baseCurrency, opening, account.GetCreateTime())
+		if err != nil {
+			return nil, err
+		}
+		bookedValue = bookedValue.Add(value)
+	}
+
+	line := &FXRevaluationLine{
+		AccountNumber: account.GetAccountNumber(),
+		Currency:      account.GetCurrency(),
+		Alignment:     account.GetAlignment(),
+		Balance:       balance,
+		Rate:          rate,
+		Value:         rate.Mul(balance),
+		BookedValue:   bookedValue,
+	}
+	// a value increase is a gain on DEBIT accounts, and a loss on CREDIT accounts.
+	line.Unrealized = line.Value.Sub(line.BookedValue)
+	if account.GetAlignment() == CREDIT {
+		line.Unrealized = line.Unrealized.Neg()
+	}
+	return line, nil
+}
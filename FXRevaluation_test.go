@@ -0,0 +1,120 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAccounting_RevalueFX(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetExchangeManager(store.ExchangeManager())
+	testRevalueFX(t, acc)
+}
+
+func TestAccounting_RevalueFXSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetExchangeManager(NewSQLExchangeManager(db, dialect))
+	testRevalueFX(t, acc)
+}
+
+func testRevalueFX(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.RevalueFX(ctx, time.Now(), true, "tester")
+	assert.Equal(t, ErrFXRevaluationNotConfigured, err)
+	acc.SetFXRevaluationConfig(&FXRevaluationConfig{
+		BaseCurrency:             "USD",
+		GainAccountNumber:        "4900",
+		LossAccountNumber:        "5900",
+		AdjustmentAccountNumbers: map[string]string{"GOLD": "1900"},
+	})
+	_, err = acc.RevalueFX(ctx, time.Now(), true, "tester")
+	assert.Equal(t, ErrChartOfAccountsNotSet, err)
+	acc.SetChartOfAccounts(newTestChartOfAccounts(t))
+
+	_, err = acc.GetExchangeManager().CreateCurrency(ctx, "GOLD", "Gold", decimal.NewFromFloat(0.01), "tester")
+	assert.NoError(t, err)
+	_, err = acc.GetExchangeManager().CreateCurrency(ctx, "USD", "US Dollar", decimal.NewFromInt(1), "tester")
+	assert.NoError(t, err)
+	for _, account := range []struct {
+		number, coa, currency string
+		alignment             Alignment
+	}{
+		{"1001", "1.1", "GOLD", DEBIT},
+		{"2001", "2.1", "GOLD", CREDIT},
+		{"3001", "3.1", "GOLD", CREDIT},
+		{"1900", "1.9", "USD", DEBIT},
+		{"4900", "4.9", "USD", CREDIT},
+		{"5900", "5.9", "USD", DEBIT},
+	} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, account.coa, account.currency, account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+	_, err = acc.CreateNewJournal(ctx, "Gold capital", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Vault", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+		{AccountNumber: "3001", Description: "Capital", TxType: CREDIT, Amount: decimal.NewFromInt(10)},
+	}, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewJournal(ctx, "Gold deposit", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Vault", TxType: DEBIT, Amount: decimal.NewFromInt(5)},
+		{AccountNumber: "2001", Description: "Wallet", TxType: CREDIT, Amount: decimal.NewFromInt(5)},
+	}, "tester")
+	assert.NoError(t, err)
+
+	// GOLD rises from 100 USD to 125 USD
+	now := time.Now()
+	assert.NoError(t, acc.GetExchangeManager().AddCurrencyRate(ctx, "GOLD", decimal.NewFromFloat(0.008), now.Add(time.Hour), "tester"))
+	assertBalances := func(expected map[string]int64) {
+		for number, balance := range expected {
+			account, err := acc.GetAccountManager().GetAccountByID(ctx, number)
+			assert.NoError(t, err)
+			assert.True(t, decimal.NewFromInt(balance).Equal(account.GetBalance()), "account %s balance is %s", number, account.GetBalance())
+		}
+	}
+
+	dryRun, err := acc.RevalueFX(ctx, now.Add(2*time.Hour), true, "tester")
+	assert.NoError(t, err)
+	assert.Nil(t, dryRun.Journal)
+	assert.Len(t, dryRun.Lines, 2)
+	for _, line := range dryRun.Lines {
+		switch line.AccountNumber {
+		case "1001":
+			assert.True(t, decimal.NewFromInt(1875).Equal(line.Value), "vault value is %s", line.Value)
+			assert.True(t, decimal.NewFromInt(1500).Equal(line.BookedValue), "vault booked value is %s", line.BookedValue)
+			assert.True(t, decimal.NewFromInt(375).Equal(line.Unrealized), "vault unrealized is %s", line.Unrealized)
+		case "2001":
+			assert.True(t, decimal.NewFromInt(-125).Equal(line.Unrealized), "wallet unrealized is %s", line.Unrealized)
+		default:
+			t.Errorf("account %s should not be revalued", line.AccountNumber)
+		}
+	}
+	assert.Len(t, dryRun.Adjustments, 1)
+	assert.True(t, decimal.NewFromInt(250).Equal(dryRun.TotalGain), "gain is %s", dryRun.TotalGain)
+	assert.True(t, dryRun.TotalLoss.IsZero())
+	t.Log(dryRun.Render())
+	assertBalances(map[string]int64{"1900": 0, "4900": 0, "5900": 0})
+
+	rev, err := acc.RevalueFX(ctx, now.Add(2*time.Hour), false, "tester")
+	assert.NoError(t, err)
+	assert.NotNil(t, rev.Journal)
+	assert.False(t, rev.AlreadyRun)
+	assertBalances(map[string]int64{"1900": 250, "4900": 250, "5900": 0})
+
+	// the same date is only posted once
+	again, err := acc.RevalueFX(ctx, now.Add(2*time.Hour), false, "tester")
+	assert.NoError(t, err)
+	assert.True(t, again.AlreadyRun)
+	assert.Equal(t, rev.Journal.GetJournalID(), again.Journal.GetJournalID())
+	assertBalances(map[string]int64{"1900": 250, "4900": 250, "5900": 0})
+
+	// GOLD falls back to 100 USD, the earlier gain is reversed as loss
+	assert.NoError(t, acc.GetExchangeManager().AddCurrencyRate(ctx, "GOLD", decimal.NewFromFloat(0.01), now.Add(25*time.Hour), "tester"))
+	rev, err = acc.RevalueFX(ctx, now.Add(26*time.Hour), false, "tester")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(250).Equal(rev.TotalLoss), "loss is %s", rev.TotalLoss)
+	assertBalances(map[string]int64{"1900": 0, "4900": 250, "5900": 250})
+}
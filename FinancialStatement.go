@@ -0,0 +1,361 @@
+package acccore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shopspring/decimal"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ASSET is enum account group of assets, its normal balance is DEBIT
+	ASSET AccountGroup = iota
+	// LIABILITY is enum account group of liabilities, its normal balance is CREDIT
+	LIABILITY
+	// EQUITY is enum account group of equities, its normal balance is CREDIT
+	EQUITY
+	// REVENUE is enum account group of revenues, its normal balance is CREDIT
+	REVENUE
+	// EXPENSE is enum account group of expenses, its normal balance is DEBIT
+	EXPENSE
+)
+
+var (
+	ErrChartOfAccountsNotSet     = fmt.Errorf("accounting has no chart of accounts")
+	ErrChartOfAccountsMissingCOA = fmt.Errorf("chart of accounts node is missing the COA prefix")
+	ErrChartOfAccountsDuplicate  = fmt.Errorf("chart of accounts already have node with the same COA prefix")
+	ErrChartOfAccountsGroupMixed = fmt.Errorf("chart of accounts node must be in the same group as its parent")
+)
+
+// AccountGroup is the enum type of financial statement groups, ASSET, LIABILITY, EQUITY, REVENUE and EXPENSE
+type AccountGroup int
+
+// String returns the group name
+func (group AccountGroup) String() string {
+	switch group {
+	case ASSET:
+		return "ASSET"
+	case LIABILITY:
+		return "LIABILITY"
+	case EQUITY:
+		return "EQUITY"
+	case REVENUE:
+		return "REVENUE"
+	case EXPENSE:
+		return "EXPENSE"
+	}
+	return fmt.Sprintf("AccountGroup(%d)", int(group))
+}
+
+// NormalAlignment returns the side where the balance of this group increases,
+// DEBIT for ASSET and EXPENSE, CREDIT for the others.
+func (group AccountGroup) NormalAlignment() Alignment {
+	if group == ASSET || group == EXPENSE {
+		return DEBIT
+	}
+	return CREDIT
+}
+
+// COANode is a node in the chart of accounts tree.
+// An account belongs to the deepest node whose Prefix is a prefix of the account COA.
+type COANode struct {
+	Prefix   string
+	Name     string
+	Group    AccountGroup
+	Children []*COANode
+}
+
+// ChartOfAccounts is a tree of COA prefixes, mapping the account COA into account groups.
+// eg. prefix `1` is ASSET, `1.1` is Cash under it, `2` is LIABILITY and so on.
+type ChartOfAccounts struct {
+	Roots []*COANode
+}
+
+// NewChartOfAccounts creates an empty chart of accounts
+func NewChartOfAccounts() *ChartOfAccounts {
+	return &ChartOfAccounts{Roots: make([]*COANode, 0)}
+}
+
+// Add puts a new node into the tree. The node is placed under the node with the longest prefix of its own prefix,
+// and the existing nodes having this node prefix are moved under it.
+// The node must be in the same group as its parent.
+func (coa *ChartOfAccounts) Add(prefix, name string, group AccountGroup) error {
+	if len(prefix) == 0 {
+		return ErrChartOfAccountsMissingCOA
+	}
+	if node := coa.Find(prefix); node != nil {
+		if node.Prefix == prefix {
+			return ErrChartOfAccountsDuplicate
+		}
+		if node.Group != group {
+			return ErrChartOfAccountsGroupMixed
+		}
+	}
+	siblings := &coa.Roots
+	if parent := coa.Find(prefix); parent != nil {
+		siblings = &parent.Children
+	}
+	newNode := &COANode{Prefix: prefix, Name: name, Group: group, Children: make([]*COANode, 0)}
+	remaining := make([]*COANode, 0, len(*siblings))
+	for _, sibling := range *siblings {
+		if strings.HasPrefix(sibling.Prefix, prefix) {
+			if sibling.Group != group {
+				return ErrChartOfAccountsGroupMixed
+			}
+			newNode.Children = append(newNode.Children, sibling)
+		} else {
+			remaining = append(remaining, sibling)
+		}
+	}
+	*siblings = append(remaining, newNode)
+	sortCOANodes(newNode.Children)
+	sortCOANodes(*siblings)
+	return nil
+}
+
+func sortCOANodes(nodes []*COANode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Prefix < nodes[j].Prefix
+	})
+}
+
+// Find returns the deepest node whose prefix is a prefix of the account COA, nil if the COA is not in the chart.
+func (coa *ChartOfAccounts) Find(accountCOA string) *COANode {
+	var found *COANode
+	nodes := coa.Roots
+	for {
+		var next *COANode
+		for _, node := range nodes {
+			if strings.HasPrefix(accountCOA, node.Prefix) {
+				next = node
+				break
+			}
+		}
+		if next == nil {
+			return found
+		}
+		found = next
+		nodes = next.Children
+	}
+}
+
+// GroupOf returns the account group of the account COA, false if the COA is not in the chart.
+func (coa *ChartOfAccounts) GroupOf(accountCOA string) (AccountGroup, bool) {
+	if node := coa.Find(accountCOA); node != nil {
+		return node.Group, true
+	}
+	return ASSET, false
+}
+
+// StatementLine is a line of a financial statement section.
+// A line is either a chart of accounts node with the total of everything under it,
+// or an account (AccountNumber is not empty) under a node.
+type StatementLine struct {
+	COA           string          `json:"coa"`
+	AccountNumber string          `json:"account_number,omitempty"`
+	Name          string          `json:"name"`
+	Depth         int             `json:"depth"`
+	Amount        decimal.Decimal `json:"amount"`
+}
+
+// StatementSection is the lines of one account group in a financial statement.
+// Amounts are signed toward the group's normal balance, eg. a credit balance in an ASSET section is negative.
+type StatementSection struct {
+	Group AccountGroup    `json:"group"`
+	Lines []StatementLine `json:"lines"`
+	Total decimal.Decimal `json:"total"`
+}
+
+// FinancialStatement is a balance sheet or an income statement, built by rolling up the account balances
+// along the chart of accounts tree.
+type FinancialStatement struct {
+	Title    string              `json:"title"`
+	Currency string              `json:"currency"`
+	From     time.Time           `json:"from"`
+	Until    time.Time           `json:"until"`
+	Sections []*StatementSection `json:"sections"`
+	// NetIncome is REVENUE minus EXPENSE in the statement period.
+	NetIncome decimal.Decimal `json:"net_income"`
+	// Unmapped lists accounts whose COA is not in the chart of accounts, they are not part of any section.
+	Unmapped []string `json:"unmapped"`
+}
+
+// Section returns the section of the group, nil if the statement has no such section.
+func (fs *FinancialStatement) Section(group AccountGroup) *StatementSection {
+	for _, section := range fs.Sections {
+		if section.Group == group {
+			return section
+		}
+	}
+	return nil
+}
+
+// Render will render this statement into string for easy inspection
+func (fs *FinancialStatement) Render() string {
+	var buff bytes.Buffer
+	table := tablewriter.NewWriter(&buff)
+	table.SetHeader([]string{"COA", "Account", "Name", "Amount"})
+	table.SetFooter([]string{"", "", "NET INCOME", fs.NetIncome.String()})
+	for _, section := range fs.Sections {
+		table.Append([]string{"", "", section.Group.String(), ""})
+		for _, line := range section.Lines {
+			table.Append([]string{line.COA, line.AccountNumber, strings.Repeat("  ", line.Depth+1) + line.Name, line.Amount.String()})
+		}
+		table.Append([]string{"", "", fmt.Sprintf("TOTAL %s", section.Group.String()), section.Total.String()})
+	}
+	buff.WriteString(fmt.Sprintf("%s : %s\n", fs.Title, fs.Currency))
+	if !fs.From.IsZero() {
+		buff.WriteString(fmt.Sprintf("From      : %s\n", fs.From.String()))
+	}
+	buff.WriteString(fmt.Sprintf("Until     : %s\n", fs.Until.String()))
+	table.Render()
+	return buff.String()
+}
+
+// GetChartOfAccounts returns the chart of accounts used for building financial statements
+func (acc *Accounting) GetChartOfAccounts() *ChartOfAccounts {
+	return acc.chartOfAccounts
+}
+
+// SetChartOfAccounts sets the chart of accounts used for building financial statements
+func (acc *Accounting) SetChartOfAccounts(chartOfAccounts *ChartOfAccounts) *Accounting {
+	acc.chartOfAccounts = chartOfAccounts
+	return acc
+}
+
+// BalanceSheet builds the balance sheet of the currency as of the specified time.
+// It has the ASSET, LIABILITY and EQUITY sections, the REVENUE minus EXPENSE not yet closed into equity
+// is shown as the NetIncome and as the "Current Earnings" line of the EQUITY section.
+func (acc *Accounting) BalanceSheet(context context.Context, asOf time.Time, currency string) (*FinancialStatement, error) {
+	fs, err := acc.buildFinancialStatement(context, "Balance Sheet", time.Time{}, asOf, currency, []AccountGroup{ASSET, LIABILITY, EQUITY, REVENUE, EXPENSE}, nil)
+	if err != nil {
+		return nil, err
+	}
+	equity := fs.Section(EQUITY)
+	equity.Lines = append(equity.Lines, StatementLine{Name: "Current Earnings", Amount: fs.NetIncome})
+	equity.Total = equity.Total.Add(fs.NetIncome)
+	fs.Sections = fs.Sections[:3]
+	return fs, nil
+}
+
+// IncomeStatement builds the income statement of the currency for the period between `from` and `until`,
+// having the REVENUE and EXPENSE sections. The period closing journals are left out, see ClosePeriod.
+func (acc *Accounting) IncomeStatement(context context.Context, from, until time.Time, currency string) (*FinancialStatement, error) {
+	closings, err := acc.closingMovements(context, from, until)
+	if err != nil {
+		return nil, err
+	}
+	return acc.buildFinancialStatement(context, "Income Statement", from, until, currency, []AccountGroup{REVENUE, EXPENSE}, closings)
+}
+
+// buildFinancialStatement rolls up the account balance changes between `from` and `until` along the chart of accounts,
+// less the excluded movements by account number, debit positive.
+func (acc *Accounting) buildFinancialStatement(context context.Context, title string, from, until time.Time, currency string, groups []AccountGroup, excluded map[string]decimal.Decimal) (*FinancialStatement, error) {
+	chart := acc.GetChartOfAccounts()
+	if chart == nil {
+		return nil, ErrChartOfAccountsNotSet
+	}
+	accounts, err := acc.listAllAccounts(context)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := &FinancialStatement{
+		Title:     title,
+		Currency:  currency,
+		From:      from,
+		Until:     until,
+		Sections:  make([]*StatementSection, 0, len(groups)),
+		NetIncome: decimal.Zero,
+		Unmapped:  make([]string, 0),
+	}
+
+	// amount of each account, signed toward the normal balance of its group
+	nodeAccounts := make(map[*COANode][]StatementLine)
+	for _, account := range accounts {
+		if account.GetCurrency() != currency || account.GetCreateTime().After(until) {
+			continue
+		}
+		node := chart.Find(account.GetCOA())
+		if node == nil {
+			fs.Unmapped = append(fs.Unmapped, account.GetAccountNumber())
+			continue
+		}
+		amount, err := acc.accountChange(context, account, from, until)
+		if err != nil {
+			return nil, err
+		}
+		if movement, ok := excluded[account.GetAccountNumber()]; ok {
+			if account.GetAlignment() == CREDIT {
+				movement = movement.Neg()
+			}
+			amount = amount.Sub(movement)
+		}
+		if account.GetAlignment() != node.Group.NormalAlignment() {
+			amount = amount.Neg()
+		}
+		nodeAccounts[node] = append(nodeAccounts[node], StatementLine{
+			COA:           account.GetCOA(),
+			AccountNumber: account.GetAccountNumber(),
+			Name:          account.GetName(),
+			Amount:        amount,
+		})
+	}
+
+	for _, group := range groups {
+		section := &StatementSection{Group: group, Lines: make([]StatementLine, 0), Total: decimal.Zero}
+		for _, root := range chart.Roots {
+			if root.Group == group {
+				lines, total := rollUpCOANode(root, 0, nodeAccounts)
+				section.Lines = append(section.Lines, lines...)
+				section.Total = section.Total.Add(total)
+			}
+		}
+		fs.Sections = append(fs.Sections, section)
+		switch group {
+		case REVENUE:
+			fs.NetIncome = fs.NetIncome.Add(section.Total)
+		case EXPENSE:
+			fs.NetIncome = fs.NetIncome.Sub(section.Total)
+		}
+	}
+	return fs, nil
+}
+
+// accountChange returns the account balance change between `from` and `until`, or the balance at `until` if `from` is zero.
+func (acc *Accounting) accountChange(context context.Context, account Account, from, until time.Time) (decimal.Decimal, error) {
+	if from.IsZero() {
+		return acc.GetTransactionManager().GetAccountBalanceAt(context, account, until)
+	}
+	opening, closing, err := acc.GetTransactionManager().GetAccountBalanceRange(context, account, from, until)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return closing.Sub(opening), nil
+}
+
+// rollUpCOANode returns the statement lines of the node and everything under it, along with its total.
+func rollUpCOANode(node *COANode, depth int, nodeAccounts map[*COANode][]StatementLine) ([]StatementLine, decimal.Decimal) {
+	lines := make([]StatementLine, 0)
+	total := decimal.Zero
+	for _, child := range node.Children {
+		childLines, childTotal := rollUpCOANode(child, depth+1, nodeAccounts)
+		lines = append(lines, childLines...)
+		total = total.Add(childTotal)
+	}
+	accountLines := nodeAccounts[node]
+	sort.SliceStable(accountLines, func(i, j int) bool {
+		return accountLines[i].COA+accountLines[i].AccountNumber < accountLines[j].COA+accountLines[j].AccountNumber
+	})
+	for _, line := range accountLines {
+		line.Depth = depth + 1
+		lines = append(lines, line)
+		total = total.Add(line.Amount)
+	}
+	nodeLine := StatementLine{COA: node.Prefix, Name: node.Name, Depth: depth, Amount: total}
+	return append([]StatementLine{nodeLine}, lines...), total
+}
@@ -0,0 +1,134 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestChartOfAccounts(t *testing.T) *ChartOfAccounts {
+	coa := NewChartOfAccounts()
+	assert.NoError(t, coa.Add("1.1", "Cash", ASSET))
+	assert.NoError(t, coa.Add("1", "Assets", ASSET))
+	assert.NoError(t, coa.Add("1.2", "Receivables", ASSET))
+	assert.NoError(t, coa.Add("2", "Liabilities", LIABILITY))
+	assert.NoError(t, coa.Add("3", "Equity", EQUITY))
+	assert.NoError(t, coa.Add("4", "Revenue", REVENUE))
+	assert.NoError(t, coa.Add("5", "Expenses", EXPENSE))
+	return coa
+}
+
+func TestChartOfAccounts_Add(t *testing.T) {
+	coa := newTestChartOfAccounts(t)
+	assert.Len(t, coa.Roots, 5)
+	assert.Equal(t, "1", coa.Roots[0].Prefix)
+	assert.Len(t, coa.Roots[0].Children, 2)
+	assert.Equal(t, "1.1", coa.Find("1.1.5").Prefix)
+	assert.Equal(t, "1", coa.Find("1.3").Prefix)
+	assert.Nil(t, coa.Find("9.1"))
+
+	group, ok := coa.GroupOf("5.2")
+	assert.True(t, ok)
+	assert.Equal(t, EXPENSE, group)
+
+	assert.Equal(t, ErrChartOfAccountsDuplicate, coa.Add("1.1", "Cash again", ASSET))
+	assert.Equal(t, ErrChartOfAccountsGroupMixed, coa.Add("1.9", "Payables", LIABILITY))
+	assert.Equal(t, ErrChartOfAccountsMissingCOA, coa.Add("", "Nothing", ASSET))
+}
+
+func TestAccounting_FinancialStatements(t *testing.T) {
+	store := NewInMemoryStore()
+	testFinancialStatements(t, NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}))
+}
+
+func TestAccounting_FinancialStatementsSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testFinancialStatements(t, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}))
+}
+
+func testFinancialStatements(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.BalanceSheet(ctx, time.Now(), "GOLD")
+	assert.Equal(t, ErrChartOfAccountsNotSet, err)
+	acc.SetChartOfAccounts(newTestChartOfAccounts(t))
+
+	accounts := []struct {
+		number, name, coa string
+		alignment         Alignment
+	}{
+		{"1001", "Gold Cash", "1.1.1", DEBIT},
+		{"1201", "Receivable", "1.2.1", DEBIT},
+		{"2001", "User Gold Wallet", "2.1", CREDIT},
+		{"3001", "Gold Equity", "3.1", CREDIT},
+		{"4001", "Sales", "4.1", CREDIT},
+		{"5001", "Operational Expense", "5.1", DEBIT},
+		{"9001", "Suspense", "9.1", DEBIT},
+	}
+	for _, account := range accounts {
+		_, err := acc.CreateNewAccount(ctx, account.number, account.name, account.name, account.coa, "GOLD", account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+
+	_, err = acc.CreateNewJournal(ctx, "Initial capital", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Cash in", TxType: DEBIT, Amount: decimal.NewFromInt(1000)},
+		{AccountNumber: "3001", Description: "Capital", TxType: CREDIT, Amount: decimal.NewFromInt(1000)},
+	}, "tester")
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	from := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	_, err = acc.CreateNewJournal(ctx, "Sell gold", []TransactionInfo{
+		{AccountNumber: "1201", Description: "Billed", TxType: DEBIT, Amount: decimal.NewFromInt(400)},
+		{AccountNumber: "4001", Description: "Sales", TxType: CREDIT, Amount: decimal.NewFromInt(400)},
+	}, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewJournal(ctx, "Pay operation", []TransactionInfo{
+		{AccountNumber: "5001", Description: "Operation", TxType: DEBIT, Amount: decimal.NewFromInt(150)},
+		{AccountNumber: "1001", Description: "Cash out", TxType: CREDIT, Amount: decimal.NewFromInt(150)},
+	}, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewJournal(ctx, "User deposit", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Cash in", TxType: DEBIT, Amount: decimal.NewFromInt(200)},
+		{AccountNumber: "2001", Description: "Wallet", TxType: CREDIT, Amount: decimal.NewFromInt(200)},
+	}, "tester")
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	until := time.Now()
+
+	bs, err := acc.BalanceSheet(ctx, until, "GOLD")
+	assert.NoError(t, err)
+	assert.Len(t, bs.Sections, 3)
+	assert.Equal(t, []string{"9001"}, bs.Unmapped)
+	assert.True(t, decimal.NewFromInt(1450).Equal(bs.Section(ASSET).Total), "asset is %s", bs.Section(ASSET).Total)
+	assert.True(t, decimal.NewFromInt(200).Equal(bs.Section(LIABILITY).Total), "liability is %s", bs.Section(LIABILITY).Total)
+	assert.True(t, decimal.NewFromInt(250).Equal(bs.NetIncome), "net income is %s", bs.NetIncome)
+	assert.True(t, decimal.NewFromInt(1250).Equal(bs.Section(EQUITY).Total), "equity is %s", bs.Section(EQUITY).Total)
+	assert.True(t, bs.Section(ASSET).Total.Equal(bs.Section(LIABILITY).Total.Add(bs.Section(EQUITY).Total)))
+	// the asset lines roll up the tree, "Assets" first then "Cash" with its account
+	assert.Equal(t, "1", bs.Section(ASSET).Lines[0].COA)
+	assert.Equal(t, 0, bs.Section(ASSET).Lines[0].Depth)
+	assert.Equal(t, "1.1", bs.Section(ASSET).Lines[1].COA)
+	assert.True(t, decimal.NewFromInt(1050).Equal(bs.Section(ASSET).Lines[1].Amount))
+	assert.Equal(t, "1001", bs.Section(ASSET).Lines[2].AccountNumber)
+	assert.Equal(t, 2, bs.Section(ASSET).Lines[2].Depth)
+	t.Log(bs.Render())
+
+	bs, err = acc.BalanceSheet(ctx, from, "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1000).Equal(bs.Section(ASSET).Total), "asset is %s", bs.Section(ASSET).Total)
+	assert.True(t, bs.NetIncome.IsZero())
+
+	is, err := acc.IncomeStatement(ctx, from, until, "GOLD")
+	assert.NoError(t, err)
+	assert.Len(t, is.Sections, 2)
+	assert.True(t, decimal.NewFromInt(400).Equal(is.Section(REVENUE).Total), "revenue is %s", is.Section(REVENUE).Total)
+	assert.True(t, decimal.NewFromInt(150).Equal(is.Section(EXPENSE).Total), "expense is %s", is.Section(EXPENSE).Total)
+	assert.True(t, decimal.NewFromInt(250).Equal(is.NetIncome), "net income is %s", is.NetIncome)
+	t.Log(is.Render())
+
+	is, err = acc.IncomeStatement(ctx, until, time.Now(), "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, is.NetIncome.IsZero())
+}
@@ -0,0 +1,165 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// HoldActive is enum hold status of a hold reserving its amount until it expires
+	HoldActive HoldStatus = iota
+	// HoldCaptured is enum hold status of a hold captured into a journal
+	HoldCaptured
+	// HoldReleased is enum hold status of a hold released without posting anything
+	HoldReleased
+	// HoldExpired is enum hold status of a hold swept after its expiry time
+	HoldExpired
+)
+
+var (
+	ErrHoldManagerNotSet = fmt.Errorf("accounting has no HoldManager")
+)
+
+// HoldStatus is the enum type of hold status, HoldActive, HoldCaptured, HoldReleased and HoldExpired
+type HoldStatus int
+
+// String returns the status name
+func (status HoldStatus) String() string {
+	switch status {
+	case HoldActive:
+		return "ACTIVE"
+	case HoldCaptured:
+		return "CAPTURED"
+	case HoldReleased:
+		return "RELEASED"
+	case HoldExpired:
+		return "EXPIRED"
+	}
+	return fmt.Sprintf("HoldStatus(%d)", int(status))
+}
+
+// canTransit checks if a hold in this status may change into the new status.
+// Only an active hold may change, and it can not change back into active.
+func (status HoldStatus) canTransit(newStatus HoldStatus) bool {
+	return status == HoldActive && newStatus != HoldActive
+}
+
+// Hold reserves an amount of an account balance, eg. points reserved for a reward store purchase before it is fulfilled.
+// While the hold is active and not expired, the amount is not available for other journals reducing the account balance.
+type Hold struct {
+	HoldID        string          `json:"hold_id"`
+	AccountNumber string          `json:"account_number"`
+	Amount        decimal.Decimal `json:"amount"`
+	// CapturedAmount is the amount posted when the hold is captured, it may be less than the Amount.
+	CapturedAmount decimal.Decimal `json:"captured_amount"`
+	Status         HoldStatus      `json:"status"`
+	ExpiryTime     time.Time       `json:"expiry_time"`
+	Description    string          `json:"description"`
+	// JournalID is the journal posted when the hold is captured.
+	JournalID  string    `json:"journal_id"`
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   string    `json:"create_by"`
+	UpdateTime time.Time `json:"update_time"`
+	UpdateBy   string    `json:"update_by"`
+}
+
+// IsActiveAt returns true if the hold still reserves its amount at the time.
+func (hold *Hold) IsActiveAt(at time.Time) bool {
+	return hold.Status == HoldActive && at.Before(hold.ExpiryTime)
+}
+
+// GetHoldManager returns hold manager
+func (acc *Accounting) GetHoldManager() HoldManager {
+	return acc.holdManager
+}
+
+// SetHoldManager sets the hold manager, it must work on the same storage as the journal manager.
+func (acc *Accounting) SetHoldManager(holdManager HoldManager) *Accounting {
+	acc.holdManager = holdManager
+	return acc
+}
+
+// PlaceHold reserves the amount of the account balance until the expiry time.
+// The account available balance must cover the amount.
+func (acc *Accounting) PlaceHold(context context.Context, accountNumber string, amount decimal.Decimal, expiry time.Time, description, author string) (*Hold, error) {
+	if acc.GetHoldManager() == nil {
+		return nil, ErrHoldManagerNotSet
+	}
+	return acc.GetHoldManager().PlaceHold(context, acc.GetUniqueIDGenerator().NewUniqueID(), accountNumber, amount, expiry, description, author)
+}
+
+// CaptureHold posts a journal moving the amount out of the hold account into the counter account, and marks the hold captured.
+// The amount may be less than the held amount, the rest is no longer reserved. Capturing and posting happen within one
+// unit of work, so the TxManager must be set.
+func (acc *Accounting) CaptureHold(ctx context.Context, holdID string, amount decimal.Decimal, counterAccountNumber, description, author string) (Journal, error) {
+	if acc.GetHoldManager() == nil {
+		return nil, ErrHoldManagerNotSet
+	}
+	if acc.GetTxManager() == nil {
+		return nil, ErrTxManagerNotSet
+	}
+
+	var journal Journal
+	err := acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+		hold, err := acc.GetHoldManager().GetHold(ctx, holdID)
+		if err != nil {
+			return err
+		}
+		if !amount.IsPositive() || amount.GreaterThan(hold.Amount) {
+			logrus.Errorf("error capturing hold %s. amount %s is not within the held %s", holdID, amount.String(), hold.Amount.String())
+			return ErrHoldCaptureAmountInvalid
+		}
+		account, err := acc.GetAccountManager().GetAccountByID(ctx, hold.AccountNumber)
+		if err != nil {
+			return err
+		}
+		// the hold account decreases, the counter account receives the amount.
+		journal = acc.newJournal(ctx, description, []TransactionInfo{
+			{AccountNumber: hold.AccountNumber, Description: description, TxType: oppositeAlignment(account.GetAlignment()), Amount: amount},
+			{AccountNumber: counterAccountNumber, Description: description, TxType: account.GetAlignment(), Amount: amount},
+		}, author)
+		// the hold is captured first, so it no longer reserves the amount the journal is about to take.
+		if err := acc.GetHoldManager().CaptureHold(ctx, holdID, amount, journal.GetJournalID(), author); err != nil {
+			return err
+		}
+		return acc.persistAndCommitJournal(ctx, journal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// ReleaseHold releases an active hold without posting anything, making its amount available again.
+func (acc *Accounting) ReleaseHold(context context.Context, holdID, author string) error {
+	if acc.GetHoldManager() == nil {
+		return ErrHoldManagerNotSet
+	}
+	return acc.GetHoldManager().ReleaseHold(context, holdID, author)
+}
+
+// heldAmount sums the amount of the holds active at the time.
+func heldAmount(holds []*Hold, at time.Time) decimal.Decimal {
+	held := decimal.Zero
+	for _, hold := range holds {
+		if hold.IsActiveAt(at) {
+			held = held.Add(hold.Amount)
+		}
+	}
+	return held
+}
+
+// checkAvailableBalance makes sure a transaction reducing the account balance leaves enough balance for the held amount.
+func checkAvailableBalance(journal Journal, trx Transaction, balance, newBalance, held decimal.Decimal) error {
+	if held.IsZero() || !newBalance.LessThan(balance) {
+		return nil
+	}
+	if newBalance.LessThan(held) {
+		logrus.Errorf("error persisting journal %s. account %s balance %s would not cover its held %s", journal.GetJournalID(), trx.GetAccountNumber(), newBalance.String(), held.String())
+		return ErrJournalAvailableBalanceInsufficient
+	}
+	return nil
+}
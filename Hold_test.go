@@ -0,0 +1,160 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAccounting_Holds(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager()).SetHoldManager(store.HoldManager())
+	testHolds(t, acc)
+}
+
+func TestAccounting_HoldsSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db)).SetHoldManager(NewSQLHoldManager(db, dialect))
+	testHolds(t, acc)
+}
+
+func testHolds(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	for _, account := range []struct {
+		number    string
+		alignment Alignment
+	}{
+		{"1001", DEBIT},
+		{"2001", CREDIT},
+		{"4001", CREDIT},
+	} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, "1.1", "POINT", account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+	_, err := acc.CreateNewJournal(ctx, "Earn points", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Points issued", TxType: DEBIT, Amount: decimal.NewFromInt(100)},
+		{AccountNumber: "2001", Description: "Wallet", TxType: CREDIT, Amount: decimal.NewFromInt(100)},
+	}, "tester")
+	assert.NoError(t, err)
+
+	holdManager := acc.GetHoldManager()
+	assertAvailable := func(expected int64) {
+		available, err := holdManager.GetAvailableBalance(ctx, "2001", time.Now())
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(expected).Equal(available), "available balance is %s", available)
+	}
+	spend := func(amount int64) error {
+		_, err := acc.CreateNewJournal(ctx, "Spend", []TransactionInfo{
+			{AccountNumber: "2001", Description: "Wallet", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "4001", Description: "Redeemed", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		return err
+	}
+	expiry := time.Now().Add(time.Hour)
+
+	_, err = acc.PlaceHold(ctx, "2001", decimal.Zero, expiry, "Reward", "tester")
+	assert.Equal(t, ErrHoldAmountNotPositive, err)
+	_, err = acc.PlaceHold(ctx, "2001", decimal.NewFromInt(10), time.Now().Add(-time.Minute), "Reward", "tester")
+	assert.Equal(t, ErrHoldExpiryPassed, err)
+	_, err = acc.PlaceHold(ctx, "9999", decimal.NewFromInt(10), expiry, "Reward", "tester")
+	assert.Equal(t, ErrAccountIDNotFound, err)
+	_, err = acc.PlaceHold(ctx, "2001", decimal.NewFromInt(101), expiry, "Reward", "tester")
+	assert.Equal(t, ErrHoldInsufficientBalance, err)
+
+	first, err := acc.PlaceHold(ctx, "2001", decimal.NewFromInt(60), expiry, "Reward mug", "tester")
+	assert.NoError(t, err)
+	assert.Equal(t, HoldActive, first.Status)
+	assertAvailable(40)
+	_, err = acc.PlaceHold(ctx, "2001", decimal.NewFromInt(50), expiry, "Reward hat", "tester")
+	assert.Equal(t, ErrHoldInsufficientBalance, err)
+
+	// the held amount can not be spent, the rest can
+	assert.Equal(t, ErrJournalAvailableBalanceInsufficient, spend(50))
+	assert.NoError(t, spend(10))
+	assertAvailable(30)
+	account, err := acc.GetAccountManager().GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(90).Equal(account.GetBalance()), "balance is %s", account.GetBalance())
+
+	// capturing part of the hold posts the journal and frees the rest
+	_, err = acc.CaptureHold(ctx, first.HoldID, decimal.NewFromInt(61), "4001", "Mug shipped", "tester")
+	assert.Equal(t, ErrHoldCaptureAmountInvalid, err)
+	journal, err := acc.CaptureHold(ctx, first.HoldID, decimal.NewFromInt(55), "4001", "Mug shipped", "tester")
+	assert.NoError(t, err)
+	assert.Len(t, journal.GetTransactions(), 2)
+	redeemed, err := acc.GetAccountManager().GetAccountByID(ctx, "4001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(65).Equal(redeemed.GetBalance()), "redeemed balance is %s", redeemed.GetBalance())
+	captured, err := holdManager.GetHold(ctx, first.HoldID)
+	assert.NoError(t, err)
+	assert.Equal(t, HoldCaptured, captured.Status)
+	assert.Equal(t, journal.GetJournalID(), captured.JournalID)
+	assert.True(t, decimal.NewFromInt(55).Equal(captured.CapturedAmount))
+	assertAvailable(35)
+	_, err = acc.CaptureHold(ctx, first.HoldID, decimal.NewFromInt(5), "4001", "Mug shipped", "tester")
+	assert.Equal(t, ErrHoldNotActive, err)
+	assert.Equal(t, ErrHoldNotActive, acc.ReleaseHold(ctx, first.HoldID, "tester"))
+
+	// a failing capture journal leaves the hold active
+	second, err := acc.PlaceHold(ctx, "2001", decimal.NewFromInt(20), expiry, "Reward hat", "tester")
+	assert.NoError(t, err)
+	_, err = acc.CaptureHold(ctx, second.HoldID, decimal.NewFromInt(20), "9999", "Hat shipped", "tester")
+	assert.Equal(t, ErrJournalTransactionAccountNotPersist, err)
+	second, err = holdManager.GetHold(ctx, second.HoldID)
+	assert.NoError(t, err)
+	assert.Equal(t, HoldActive, second.Status)
+	assertAvailable(15)
+
+	// releasing makes the amount available again
+	assert.NoError(t, acc.ReleaseHold(ctx, second.HoldID, "tester"))
+	assertAvailable(35)
+	assert.Equal(t, ErrHoldNotFound, acc.ReleaseHold(ctx, "unknown", "tester"))
+
+	// an expired hold no longer reserves its amount and is swept by ExpireHolds
+	third, err := acc.PlaceHold(ctx, "2001", decimal.NewFromInt(30), expiry, "Reward pen", "tester")
+	assert.NoError(t, err)
+	holds, err := holdManager.ListActiveHolds(ctx, "2001", time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, holds, 1)
+	holds, err = holdManager.ListActiveHolds(ctx, "2001", expiry)
+	assert.NoError(t, err)
+	assert.Len(t, holds, 0)
+	available, err := holdManager.GetAvailableBalance(ctx, "2001", expiry)
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(35).Equal(available), "available balance after expiry is %s", available)
+
+	expired, err := holdManager.ExpireHolds(ctx, time.Now(), "sweeper")
+	assert.NoError(t, err)
+	assert.Len(t, expired, 0)
+	expired, err = holdManager.ExpireHolds(ctx, expiry, "sweeper")
+	assert.NoError(t, err)
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, third.HoldID, expired[0].HoldID)
+		assert.Equal(t, HoldExpired, expired[0].Status)
+	}
+	assertAvailable(35)
+	assert.NoError(t, spend(35))
+
+	// without a TxManager the hold is not captured, it can not be kept apart from a failing capture journal
+	_, err = acc.CreateNewJournal(ctx, "Earn points", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Points issued", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+		{AccountNumber: "2001", Description: "Wallet", TxType: CREDIT, Amount: decimal.NewFromInt(10)},
+	}, "tester")
+	assert.NoError(t, err)
+	fourth, err := acc.PlaceHold(ctx, "2001", decimal.NewFromInt(10), expiry, "Reward pen", "tester")
+	assert.NoError(t, err)
+	acc.SetTxManager(nil)
+	_, err = acc.CaptureHold(ctx, fourth.HoldID, decimal.NewFromInt(10), "9999", "Pen shipped", "tester")
+	assert.Equal(t, ErrTxManagerNotSet, err)
+	fourth, err = holdManager.GetHold(ctx, fourth.HoldID)
+	assert.NoError(t, err)
+	assert.Equal(t, HoldActive, fourth.Status)
+	assertAvailable(0)
+	acc.SetHoldManager(nil)
+	_, err = acc.PlaceHold(ctx, "2001", decimal.NewFromInt(1), expiry, "Reward", "tester")
+	assert.Equal(t, ErrHoldManagerNotSet, err)
+}
@@ -0,0 +1,191 @@
+package acccore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shopspring/decimal"
+	"sort"
+	"time"
+)
+
+const (
+	// IntegrityTransactionBalanceMismatch is enum discrepancy kind of a transaction whose account balance snapshot
+	// is not the snapshot before it moved by the transaction amount
+	IntegrityTransactionBalanceMismatch IntegrityDiscrepancyKind = iota
+	// IntegrityAccountBalanceMismatch is enum discrepancy kind of an account whose balance is not the replay of its transactions
+	IntegrityAccountBalanceMismatch
+	// IntegrityJournalNotBalance is enum discrepancy kind of a journal whose sum of debit and sum of credit are not equal
+	IntegrityJournalNotBalance
+	// IntegrityJournalAmountMismatch is enum discrepancy kind of a journal whose amount is not the sum of its credit
+	IntegrityJournalAmountMismatch
+	// IntegrityJournalNotFound is enum discrepancy kind of a journal referred by transactions but not exist
+	IntegrityJournalNotFound
+	// IntegrityReversedJournalNotFound is enum discrepancy kind of a reversal journal whose reversed journal is not exist
+	IntegrityReversedJournalNotFound
+)
+
+// IntegrityDiscrepancyKind is the enum type of integrity discrepancy found by Accounting.CheckIntegrity
+type IntegrityDiscrepancyKind int
+
+// String returns the discrepancy kind name
+func (kind IntegrityDiscrepancyKind) String() string {
+	switch kind {
+	case IntegrityTransactionBalanceMismatch:
+		return "TRANSACTION_BALANCE_MISMATCH"
+	case IntegrityAccountBalanceMismatch:
+		return "ACCOUNT_BALANCE_MISMATCH"
+	case IntegrityJournalNotBalance:
+		return "JOURNAL_NOT_BALANCE"
+	case IntegrityJournalAmountMismatch:
+		return "JOURNAL_AMOUNT_MISMATCH"
+	case IntegrityJournalNotFound:
+		return "JOURNAL_NOT_FOUND"
+	case IntegrityReversedJournalNotFound:
+		return "REVERSED_JOURNAL_NOT_FOUND"
+	}
+	return fmt.Sprintf("IntegrityDiscrepancyKind(%d)", int(kind))
+}
+
+// IntegrityDiscrepancy is one disagreement found in the ledger. Expected is the recomputed value, Actual the stored one.
+// For IntegrityJournalNotBalance, Expected is the sum of debit and Actual the sum of credit. Only the fields relevant to the Kind are filled.
+type IntegrityDiscrepancy struct {
+	Kind          IntegrityDiscrepancyKind `json:"kind"`
+	AccountNumber string                   `json:"account_number"`
+	JournalID     string                   `json:"journal_id"`
+	TransactionID string                   `json:"transaction_id"`
+	Expected      decimal.Decimal          `json:"expected"`
+	Actual        decimal.Decimal          `json:"actual"`
+}
+
+// IntegrityReport is the outcome of Accounting.CheckIntegrity.
+type IntegrityReport struct {
+	CheckTime           time.Time              `json:"check_time"`
+	AccountsChecked     int                    `json:"accounts_checked"`
+	TransactionsChecked int                    `json:"transactions_checked"`
+	JournalsChecked     int                    `json:"journals_checked"`
+	Discrepancies       []IntegrityDiscrepancy `json:"discrepancies"`
+}
+
+// IsConsistent returns true if no discrepancy was found.
+func (report *IntegrityReport) IsConsistent() bool {
+	return len(report.Discrepancies) == 0
+}
+
+// Render will render this report into string for easy inspection
+func (report *IntegrityReport) Render() string {
+	var buff bytes.Buffer
+	table := tablewriter.NewWriter(&buff)
+	table.SetHeader([]string{"Discrepancy", "Account", "Journal", "Transaction", "Expected", "Actual"})
+	for _, discrepancy := range report.Discrepancies {
+		table.Append([]string{discrepancy.Kind.String(), discrepancy.AccountNumber, discrepancy.JournalID, discrepancy.TransactionID,
+			discrepancy.Expected.String(), discrepancy.Actual.String()})
+	}
+	buff.WriteString(fmt.Sprintf("Integrity Check : %s\n", report.CheckTime.String()))
+	buff.WriteString(fmt.Sprintf("Checked         : %d accounts, %d transactions, %d journals\n", report.AccountsChecked, report.TransactionsChecked, report.JournalsChecked))
+	table.Render()
+	return buff.String()
+}
+
+// CheckIntegrity audits the whole ledger. The transactions of every account are replayed in posting order,
+// each transaction account balance snapshot is compared with the snapshot before it moved by the transaction amount,
+// and the account balance is compared with the replay of all its transactions.
+// Every journal referred by the transactions is checked to exist, to balance, and if it is a reversal, to reverse an existing journal.
+// The discrepancies found are reported, an error is only returned if the ledger could not be read.
+func (acc *Accounting) CheckIntegrity(context context.Context) (*IntegrityReport, error) {
+	report := &IntegrityReport{
+		CheckTime:     acc.GetClock().Now(),
+		Discrepancies: make([]IntegrityDiscrepancy, 0),
+	}
+	accounts, err := acc.listAllAccounts(context)
+	if err != nil {
+		return nil, err
+	}
+	journalIDs := make(map[string]bool)
+	for _, account := range accounts {
+		replayed, snapshot := decimal.Zero, decimal.Zero
+		request := PageRequest{PageNo: 1, ItemSize: 100}
+		for {
+			page, transactions, err := acc.GetTransactionManager().ListTransactionsOnAccount(context, time.Time{}, report.CheckTime, account, request)
+			if err != nil {
+				return nil, err
+			}
+			for _, trx := range transactions {
+				replayed = balanceAfter(account.GetAlignment(), trx.GetAlignment(), trx.GetAmount(), replayed)
+				// each snapshot is checked against the one before it, so a single bad snapshot is reported only once
+				if expected := balanceAfter(account.GetAlignment(), trx.GetAlignment(), trx.GetAmount(), snapshot); !expected.Equal(trx.GetAccountBalance()) {
+					report.Discrepancies = append(report.Discrepancies, IntegrityDiscrepancy{
+						Kind:          IntegrityTransactionBalanceMismatch,
+						AccountNumber: account.GetAccountNumber(),
+						JournalID:     trx.GetJournalID(),
+						TransactionID: trx.GetTransactionID(),
+						Expected:      expected,
+						Actual:        trx.GetAccountBalance(),
+					})
+				}
+				snapshot = trx.GetAccountBalance()
+				journalIDs[trx.GetJournalID()] = true
+				report.TransactionsChecked++
+			}
+			if !page.HaveNext {
+				break
+			}
+			request.PageNo = page.NextPage
+		}
+		if !replayed.Equal(account.GetBalance()) {
+			report.Discrepancies = append(report.Discrepancies, IntegrityDiscrepancy{
+				Kind:          IntegrityAccountBalanceMismatch,
+				AccountNumber: account.GetAccountNumber(),
+				Expected:      replayed,
+				Actual:        account.GetBalance(),
+			})
+		}
+		report.AccountsChecked++
+	}
+
+	sortedJournalIDs := make([]string, 0, len(journalIDs))
+	for journalID := range journalIDs {
+		sortedJournalIDs = append(sortedJournalIDs, journalID)
+	}
+	sort.Strings(sortedJournalIDs)
+	for _, journalID := range sortedJournalIDs {
+		report.JournalsChecked++
+		journal, err := acc.GetJournalManager().GetJournalByID(context, journalID)
+		switch err {
+		case nil:
+		case ErrJournalIDNotFound:
+			report.Discrepancies = append(report.Discrepancies, IntegrityDiscrepancy{Kind: IntegrityJournalNotFound, JournalID: journalID})
+			continue
+		case ErrJournalLoadReversalInconsistent:
+			report.Discrepancies = append(report.Discrepancies, IntegrityDiscrepancy{Kind: IntegrityReversedJournalNotFound, JournalID: journalID})
+			continue
+		default:
+			return nil, err
+		}
+		debitSum, creditSum := decimal.Zero, decimal.Zero
+		for _, trx := range journal.GetTransactions() {
+			if trx.GetAlignment() == DEBIT {
+				debitSum = debitSum.Add(trx.GetAmount())
+			} else {
+				creditSum = creditSum.Add(trx.GetAmount())
+			}
+		}
+		if !debitSum.Equal(creditSum) {
+			report.Discrepancies = append(report.Discrepancies, IntegrityDiscrepancy{
+				Kind:      IntegrityJournalNotBalance,
+				JournalID: journalID,
+				Expected:  debitSum,
+				Actual:    creditSum,
+			})
+		} else if !creditSum.Equal(journal.GetAmount()) {
+			report.Discrepancies = append(report.Discrepancies, IntegrityDiscrepancy{
+				Kind:      IntegrityJournalAmountMismatch,
+				JournalID: journalID,
+				Expected:  creditSum,
+				Actual:    journal.GetAmount(),
+			})
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,178 @@
+package acccore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// integrityTamper changes the ledger records directly in the storage, bypassing the managers.
+type integrityTamper struct {
+	transactionAmount  func(transactionID string, amount decimal.Decimal)
+	transactionBalance func(transactionID string, balance decimal.Decimal)
+	accountBalance     func(accountNumber string, balance decimal.Decimal)
+	reversedJournal    func(journalID, reversedJournalID string)
+	createTime         func(createTime time.Time)
+}
+
+func TestAccounting_CheckIntegrity(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{})
+	testCheckIntegrity(t, acc, integrityTamper{
+		transactionAmount: func(transactionID string, amount decimal.Decimal) {
+			store.mutex.Lock()
+			defer store.mutex.Unlock()
+			store.transactionTable[transactionID].amount = amount
+		},
+		transactionBalance: func(transactionID string, balance decimal.Decimal) {
+			store.mutex.Lock()
+			defer store.mutex.Unlock()
+			store.transactionTable[transactionID].accountBalance = balance
+		},
+		accountBalance: func(accountNumber string, balance decimal.Decimal) {
+			store.mutex.Lock()
+			defer store.mutex.Unlock()
+			store.accountTable[accountNumber].balance = balance
+		},
+		reversedJournal: func(journalID, reversedJournalID string) {
+			store.mutex.Lock()
+			defer store.mutex.Unlock()
+			store.journalTable[journalID].reversedJournalID = reversedJournalID
+		},
+		createTime: func(createTime time.Time) {
+			store.mutex.Lock()
+			defer store.mutex.Unlock()
+			for _, trx := range store.transactionTable {
+				trx.createTime = createTime
+			}
+		},
+	})
+}
+
+func TestAccounting_CheckIntegritySQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{})
+	exec := func(query string, args ...interface{}) {
+		_, err := db.Exec(dialect.Rebind(query), args...)
+		assert.NoError(t, err)
+	}
+	testCheckIntegrity(t, acc, integrityTamper{
+		transactionAmount: func(transactionID string, amount decimal.Decimal) {
+			exec(`UPDATE acc_transaction SET amount = ? WHERE transaction_id = ?`, amount, transactionID)
+		},
+		transactionBalance: func(transactionID string, balance decimal.Decimal) {
+			exec(`UPDATE acc_transaction SET account_balance = ? WHERE transaction_id = ?`, balance, transactionID)
+		},
+		accountBalance: func(accountNumber string, balance decimal.Decimal) {
+			exec(`UPDATE acc_account SET balance = ? WHERE account_number = ?`, balance, accountNumber)
+		},
+		reversedJournal: func(journalID, reversedJournalID string) {
+			exec(`UPDATE acc_journal SET reversed_journal_id = ? WHERE journal_id = ?`, sql.NullString{String: reversedJournalID, Valid: true}, journalID)
+		},
+		createTime: func(createTime time.Time) {
+			exec(`UPDATE acc_transaction SET create_time = ?`, createTime.UTC())
+		},
+	})
+}
+
+func testCheckIntegrity(t *testing.T, acc *Accounting, tamper integrityTamper) {
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Cash", "Cash", "1.1", "IDR", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "4001", "Sales", "Sales", "4.1", "IDR", CREDIT, "tester")
+	assert.NoError(t, err)
+	journals := make([]Journal, 0)
+	for _, amount := range []int64{100, 50, 25} {
+		journal, err := acc.CreateNewJournal(ctx, "Sales", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Cash", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "4001", Description: "Sales", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		assert.NoError(t, err)
+		journals = append(journals, journal)
+	}
+	reversal, err := acc.CreateReversal(ctx, "Refund", journals[2], "tester")
+	assert.NoError(t, err)
+	cashTransaction := func(journal Journal) string {
+		for _, trx := range journal.GetTransactions() {
+			if trx.GetAccountNumber() == "1001" {
+				return trx.GetTransactionID()
+			}
+		}
+		return ""
+	}
+
+	report, err := acc.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.IsConsistent(), report.Render())
+	assert.Equal(t, 2, report.AccountsChecked)
+	assert.Equal(t, 8, report.TransactionsChecked)
+	assert.Equal(t, 4, report.JournalsChecked)
+
+	// a bad snapshot is reported once, the account balance still agrees with the replay
+	tamper.transactionBalance(cashTransaction(journals[1]), decimal.NewFromInt(999))
+	report, err = acc.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, report.Discrepancies, 2) {
+		assert.Equal(t, IntegrityTransactionBalanceMismatch, report.Discrepancies[0].Kind)
+		assert.Equal(t, cashTransaction(journals[1]), report.Discrepancies[0].TransactionID)
+		assert.True(t, decimal.NewFromInt(150).Equal(report.Discrepancies[0].Expected))
+		assert.True(t, decimal.NewFromInt(999).Equal(report.Discrepancies[0].Actual))
+		// the snapshot after it does not follow from the bad one either
+		assert.Equal(t, cashTransaction(journals[2]), report.Discrepancies[1].TransactionID)
+	}
+	tamper.transactionBalance(cashTransaction(journals[1]), decimal.NewFromInt(150))
+
+	tamper.accountBalance("4001", decimal.NewFromInt(500))
+	report, err = acc.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, report.Discrepancies, 1) {
+		assert.Equal(t, IntegrityAccountBalanceMismatch, report.Discrepancies[0].Kind)
+		assert.Equal(t, "4001", report.Discrepancies[0].AccountNumber)
+		assert.True(t, decimal.NewFromInt(150).Equal(report.Discrepancies[0].Expected))
+	}
+	tamper.accountBalance("4001", decimal.NewFromInt(150))
+
+	// a changed amount unbalances its journal, its snapshot and the account balance
+	tamper.transactionAmount(cashTransaction(journals[0]), decimal.NewFromInt(90))
+	report, err = acc.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	kinds := make(map[IntegrityDiscrepancyKind]string)
+	for _, discrepancy := range report.Discrepancies {
+		kinds[discrepancy.Kind] = discrepancy.JournalID + discrepancy.AccountNumber
+	}
+	assert.Equal(t, map[IntegrityDiscrepancyKind]string{
+		IntegrityTransactionBalanceMismatch: journals[0].GetJournalID() + "1001",
+		IntegrityAccountBalanceMismatch:     "1001",
+		IntegrityJournalNotBalance:          journals[0].GetJournalID(),
+	}, kinds)
+	tamper.transactionAmount(cashTransaction(journals[0]), decimal.NewFromInt(100))
+
+	tamper.reversedJournal(reversal.GetJournalID(), "missing")
+	report, err = acc.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, report.Discrepancies, 1) {
+		assert.Equal(t, IntegrityReversedJournalNotFound, report.Discrepancies[0].Kind)
+		assert.Equal(t, reversal.GetJournalID(), report.Discrepancies[0].JournalID)
+	}
+	assert.Contains(t, report.Render(), "REVERSED_JOURNAL_NOT_FOUND")
+	tamper.reversedJournal(reversal.GetJournalID(), journals[2].GetJournalID())
+
+	// the transactions are replayed in posting order, neither by their transaction time nor their create time
+	backdated := acc.GetJournalManager().NewJournal(ctx).SetJournalID(acc.GetUniqueIDGenerator().NewUniqueID()).
+		SetDescription("Backdated sales").SetCreateBy("tester").SetJournalingTime(journals[0].GetJournalingTime().Add(-time.Hour)).
+		SetTransactions([]Transaction{
+			acc.GetTransactionManager().NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber("1001").SetAlignment(DEBIT).SetAmount(decimal.NewFromInt(10)).SetCreateBy("tester"),
+			acc.GetTransactionManager().NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber("4001").SetAlignment(CREDIT).SetAmount(decimal.NewFromInt(10)).SetCreateBy("tester"),
+		})
+	assert.NoError(t, acc.GetJournalManager().PersistJournal(ctx, backdated))
+	tamper.createTime(time.Now())
+	report, err = acc.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.IsConsistent(), report.Render())
+	assert.Equal(t, 10, report.TransactionsChecked)
+}
@@ -0,0 +1,129 @@
+package acccore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+const (
+	// ChainReasonHashMismatch tells the journal content does not match its hash, the journal or its transactions were changed.
+	ChainReasonHashMismatch = "journal content does not match its hash"
+	// ChainReasonLinkBroken tells the journal previous hash is not the hash of the journal before it, a journal was removed or inserted.
+	ChainReasonLinkBroken = "journal previous hash does not match the hash of the journal before it"
+	// ChainReasonTailMissing tells the chain ends before the last persisted journal, the latest journals were removed.
+	ChainReasonTailMissing = "journal chain ends before the last persisted journal"
+)
+
+// JournalChainVerification is the result of JournalManager.VerifyJournalChain walking the journal hash chain.
+type JournalChainVerification struct {
+	// Verified is the number of journals, in persisting order, whose hash and link are intact.
+	Verified int `json:"verified"`
+	// Broken is true if the walk found a broken link, the chain is intact otherwise.
+	Broken bool `json:"broken"`
+	// BrokenJournalID is the first journal whose hash or link is broken, empty if the chain tail is missing.
+	BrokenJournalID string `json:"broken_journal_id"`
+	// Reason is one of ChainReasonHashMismatch, ChainReasonLinkBroken and ChainReasonTailMissing.
+	Reason string `json:"reason"`
+}
+
+// journalHashContent is the canonical content of a journal hashed into the chain.
+type journalHashContent struct {
+	PreviousHash       string                   `json:"previous_hash"`
+	JournalID          string                   `json:"journal_id"`
+	JournalingTime     string                   `json:"journaling_time"`
+	Description        string                   `json:"description"`
+	ReversedJournalID  string                   `json:"reversed_journal_id"`
+	CreateBy           string                   `json:"create_by"`
+	IdempotencyKey     string                   `json:"idempotency_key"`
+	CorrectedJournalID string                   `json:"corrected_journal_id"`
+	Transactions       []transactionHashContent `json:"transactions"`
+}
+
+// transactionHashContent is the canonical content of a journal transaction hashed into the chain.
+type transactionHashContent struct {
+	TransactionID string    `json:"transaction_id"`
+	AccountNumber string    `json:"account_number"`
+	Description   string    `json:"description"`
+	Alignment     Alignment `json:"alignment"`
+	Amount        string    `json:"amount"`
+	CreateBy      string    `json:"create_by"`
+}
+
+// chainTime returns the time as hashed into the chain. Times are hashed in UTC with microsecond precision,
+// the finest precision every supported database keeps.
+func chainTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// journalHash returns the SHA-256 hex hash of the journal content, as persisted with the journaling time, chained to the previous hash.
+// The transactions are hashed ordered by their ID, so the order they are loaded in does not matter.
+func journalHash(previousHash string, journal Journal, journalingTime time.Time) string {
+	content := journalHashContent{
+		PreviousHash:       previousHash,
+		JournalID:          journal.GetJournalID(),
+		JournalingTime:     chainTime(journalingTime).Format(time.RFC3339Nano),
+		Description:        journal.GetDescription(),
+		CreateBy:           journal.GetCreateBy(),
+		IdempotencyKey:     journal.GetIdempotencyKey(),
+		CorrectedJournalID: journal.GetCorrectedJournalID(),
+		Transactions:       make([]transactionHashContent, 0, len(journal.GetTransactions())),
+	}
+	if journal.GetReversedJournal() != nil {
+		content.ReversedJournalID = journal.GetReversedJournal().GetJournalID()
+	}
+	for _, trx := range journal.GetTransactions() {
+		content.Transactions = append(content.Transactions, transactionHashContent{
+			TransactionID: trx.GetTransactionID(),
+			AccountNumber: trx.GetAccountNumber(),
+			Description:   trx.GetDescription(),
+			Alignment:     trx.GetAlignment(),
+			Amount:        trx.GetAmount().String(),
+			CreateBy:      trx.GetCreateBy(),
+		})
+	}
+	sort.Slice(content.Transactions, func(i, j int) bool {
+		return content.Transactions[i].TransactionID < content.Transactions[j].TransactionID
+	})
+	// marshalling a struct of strings can not fail
+	canonical, _ := json.Marshal(content)
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// journalChainVerifier walks the journals in persisting order, stopping at the first broken link.
+type journalChainVerifier struct {
+	verification JournalChainVerification
+	previousHash string
+}
+
+// check verifies the journal hash and its link to the journal before it. Returns false once the chain is broken.
+func (verifier *journalChainVerifier) check(journal Journal) bool {
+	if verifier.verification.Broken {
+		return false
+	}
+	switch {
+	case journal.GetPreviousHash() != verifier.previousHash:
+		verifier.verification.Reason = ChainReasonLinkBroken
+	case journalHash(journal.GetPreviousHash(), journal, journal.GetJournalingTime()) != journal.GetHash():
+		verifier.verification.Reason = ChainReasonHashMismatch
+	default:
+		verifier.previousHash = journal.GetHash()
+		verifier.verification.Verified++
+		return true
+	}
+	verifier.verification.Broken = true
+	verifier.verification.BrokenJournalID = journal.GetJournalID()
+	return false
+}
+
+// finish compares the last walked hash with the hash of the last persisted journal, and returns the verification.
+func (verifier *journalChainVerifier) finish(lastHash string) *JournalChainVerification {
+	if !verifier.verification.Broken && verifier.previousHash != lastHash {
+		verifier.verification.Broken = true
+		verifier.verification.Reason = ChainReasonTailMissing
+	}
+	return &verifier.verification
+}
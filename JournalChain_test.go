@@ -0,0 +1,134 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJournalManager_VerifyJournalChain(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{})
+	testVerifyJournalChain(t, acc, func(journalID, transactionID string) {
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+		if len(transactionID) > 0 {
+			store.transactionTable[transactionID].amount = decimal.NewFromInt(1000)
+			return
+		}
+		store.journalTable[journalID].description = "Tampered"
+	})
+}
+
+func TestJournalManager_VerifyJournalChainTailMissing(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{})
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Cash", "Cash", "1.1", "IDR", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "4001", "Sales", "Sales", "4.1", "IDR", CREDIT, "tester")
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = acc.CreateNewJournal(ctx, "Sales", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Cash", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+			{AccountNumber: "4001", Description: "Sales", TxType: CREDIT, Amount: decimal.NewFromInt(10)},
+		}, "tester")
+		assert.NoError(t, err)
+	}
+
+	// removing the last journal leaves no broken link behind it, only the chain head tells
+	store.mutex.Lock()
+	last := store.journalChain[len(store.journalChain)-1]
+	delete(store.journalTable, last)
+	store.journalChain = store.journalChain[:len(store.journalChain)-1]
+	store.mutex.Unlock()
+
+	verification, err := acc.GetJournalManager().VerifyJournalChain(ctx)
+	assert.NoError(t, err)
+	assert.True(t, verification.Broken)
+	assert.Equal(t, 1, verification.Verified)
+	assert.Empty(t, verification.BrokenJournalID)
+	assert.Equal(t, ChainReasonTailMissing, verification.Reason)
+}
+
+func TestJournalManager_VerifyJournalChainSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db))
+	testVerifyJournalChain(t, acc, func(journalID, transactionID string) {
+		var err error
+		if len(transactionID) > 0 {
+			_, err = db.Exec(dialect.Rebind(`UPDATE acc_transaction SET amount = ? WHERE transaction_id = ?`), decimal.NewFromInt(1000), transactionID)
+		} else {
+			_, err = db.Exec(dialect.Rebind(`UPDATE acc_journal SET description = ? WHERE journal_id = ?`), "Tampered", journalID)
+		}
+		assert.NoError(t, err)
+	})
+}
+
+// testVerifyJournalChain posts journals, then tampers them with the tamper function, which changes the journal description,
+// or the transaction amount if a transaction ID is given, directly in the storage.
+func testVerifyJournalChain(t *testing.T, acc *Accounting, tamper func(journalID, transactionID string)) {
+	ctx := context.Background()
+	for _, account := range []struct {
+		number    string
+		alignment Alignment
+	}{
+		{"1001", DEBIT},
+		{"4001", CREDIT},
+	} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, "1.1", "IDR", account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+
+	verification, err := acc.GetJournalManager().VerifyJournalChain(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, JournalChainVerification{}, *verification)
+
+	journals := make([]Journal, 0)
+	for i := int64(1); i <= 4; i++ {
+		journal, err := acc.CreateNewJournal(ctx, "Sales", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Cash", TxType: DEBIT, Amount: decimal.NewFromInt(i * 10)},
+			{AccountNumber: "4001", Description: "Sales", TxType: CREDIT, Amount: decimal.NewFromInt(i * 10)},
+		}, "tester")
+		assert.NoError(t, err)
+		journals = append(journals, journal)
+	}
+	reversal, err := acc.CreateReversal(ctx, "Refund", journals[0], "tester")
+	assert.NoError(t, err)
+	journals = append(journals, reversal)
+
+	// every journal is chained to the one persisted before it
+	assert.Empty(t, journals[0].GetPreviousHash())
+	for i := 1; i < len(journals); i++ {
+		assert.Len(t, journals[i].GetHash(), 64)
+		assert.Equal(t, journals[i-1].GetHash(), journals[i].GetPreviousHash())
+	}
+	loaded, err := acc.GetJournalManager().GetJournalByID(ctx, journals[2].GetJournalID())
+	assert.NoError(t, err)
+	assert.Equal(t, journals[2].GetHash(), loaded.GetHash())
+	assert.Equal(t, journals[1].GetHash(), loaded.GetPreviousHash())
+
+	verification, err = acc.GetJournalManager().VerifyJournalChain(ctx)
+	assert.NoError(t, err)
+	assert.False(t, verification.Broken)
+	assert.Equal(t, 5, verification.Verified)
+
+	// a changed transaction amount is reported on its journal
+	tamper(journals[3].GetJournalID(), journals[3].GetTransactions()[0].GetTransactionID())
+	verification, err = acc.GetJournalManager().VerifyJournalChain(ctx)
+	assert.NoError(t, err)
+	assert.True(t, verification.Broken)
+	assert.Equal(t, 3, verification.Verified)
+	assert.Equal(t, journals[3].GetJournalID(), verification.BrokenJournalID)
+	assert.Equal(t, ChainReasonHashMismatch, verification.Reason)
+
+	// the first broken link is reported
+	tamper(journals[1].GetJournalID(), "")
+	verification, err = acc.GetJournalManager().VerifyJournalChain(ctx)
+	assert.NoError(t, err)
+	assert.True(t, verification.Broken)
+	assert.Equal(t, 1, verification.Verified)
+	assert.Equal(t, journals[1].GetJournalID(), verification.BrokenJournalID)
+}
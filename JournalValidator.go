@@ -0,0 +1,459 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// JournalRuleDescriptionRequired is the rule name of RequireJournalDescription
+	JournalRuleDescriptionRequired = "DESCRIPTION_REQUIRED"
+	// JournalRuleMaxAmount is the rule name of MaxJournalAmount
+	JournalRuleMaxAmount = "MAX_AMOUNT"
+	// JournalRuleRestrictedCredit is the rule name of RestrictCredit
+	JournalRuleRestrictedCredit = "RESTRICTED_CREDIT"
+)
+
+// ErrJournalRuleViolated is matched by every JournalRuleError using errors.Is
+var ErrJournalRuleViolated = fmt.Errorf("journal violates a journal rule")
+
+// JournalRuleError is returned by PersistJournal when a journal violates a registered JournalRule.
+type JournalRuleError struct {
+	// Rule is the name of the violated rule
+	Rule      string
+	JournalID string
+	// AccountNumber is the account of the violating transaction, empty if the rule is about the whole journal
+	AccountNumber string
+	Reason        string
+}
+
+// Error returns the error message naming the rule and the journal
+func (e *JournalRuleError) Error() string {
+	if len(e.AccountNumber) > 0 {
+		return fmt.Sprintf("journal %s violates %s on account %s: %s", e.JournalID, e.Rule, e.AccountNumber, e.Reason)
+	}
+	return fmt.Sprintf("journal %s violates %s: %s", e.JournalID, e.Rule, e.Reason)
+}
+
+// Is makes the error match ErrJournalRuleViolated
+func (e *JournalRuleError) Is(target error) bool {
+	return target == ErrJournalRuleViolated
+}
+
+// JournalLedger is the view of the ledger a JournalRule checks a journal against. JournalManager implementations
+// provide it from within the unit persisting the journal, so the rules see the ledger the journal is persisted onto.
+type JournalLedger interface {
+	// IsJournalIDExist checks if a journal with the ID is persisted.
+	IsJournalIDExist(context context.Context, journalID string) (bool, error)
+
+	// IsIdempotencyKeyExist checks if a journal is persisted with the idempotency key.
+	IsIdempotencyKeyExist(context context.Context, idempotencyKey string) (bool, error)
+
+	// IsTransactionIDExist checks if a transaction with the ID is persisted.
+	IsTransactionIDExist(context context.Context, transactionID string) (bool, error)
+
+	// IsJournalIDReversed checks if the journal has been reversed, ErrJournalIDNotFound should be returned if it is not exist.
+	IsJournalIDReversed(context context.Context, journalID string) (bool, error)
+
+	// GetAccount returns the account, nil if it is not exist. Implementations holding row locks
+	// keep the account locked until the unit persisting the journal ends.
+	GetAccount(context context.Context, accountNumber string) (Account, error)
+
+	// IsPeriodOpenAt checks if the time is not in a closed or locked period.
+	IsPeriodOpenAt(context context.Context, at time.Time) (bool, error)
+
+	// HeldAmount returns the amount held on the account by the holds active at the time.
+	HeldAmount(context context.Context, accountNumber string, at time.Time) (decimal.Decimal, error)
+}
+
+// JournalValidation is the journal a JournalRule validates, with the ledger it is about to be persisted onto.
+type JournalValidation struct {
+	Journal Journal
+	// JournalingTime is the journal journaling time, or Now if the journal has none
+	JournalingTime time.Time
+	// Now is the time the journal is persisted
+	Now    time.Time
+	Ledger JournalLedger
+
+	// accounts caches the accounts read from the Ledger, nil for accounts not exist
+	accounts map[string]Account
+}
+
+// NewJournalValidation creates the validation of the journal about to be persisted onto the ledger at now.
+func NewJournalValidation(journal Journal, ledger JournalLedger, now time.Time) *JournalValidation {
+	validation := &JournalValidation{Journal: journal, JournalingTime: now, Now: now, Ledger: ledger, accounts: make(map[string]Account)}
+	if journal != nil && !journal.GetJournalingTime().IsZero() {
+		validation.JournalingTime = journal.GetJournalingTime()
+	}
+	return validation
+}
+
+// Account returns the account from the Ledger, nil if it is not exist. Each account is read from the Ledger only once.
+func (validation *JournalValidation) Account(context context.Context, accountNumber string) (Account, error) {
+	if account, ok := validation.accounts[accountNumber]; ok {
+		return account, nil
+	}
+	account, err := validation.Ledger.GetAccount(context, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	validation.accounts[accountNumber] = account
+	return account, nil
+}
+
+// JournalRule checks a journal about to be persisted, returning an error if the journal must not be persisted.
+// Rules are only run on journals passing the built-in rules, so the journal is not nil, balanced,
+// and all its transactions are IDed and belong to distinct existing accounts.
+type JournalRule func(context context.Context, validation *JournalValidation) error
+
+// JournalValidator is the chain of rules every JournalManager runs before persisting a journal.
+// It always starts with the built-in rules, listed on JournalManager.PersistJournal, followed by the registered rules
+// in registering order, thus a zero JournalValidator runs the built-in rules alone.
+// The first rule failing stops the chain and its error is returned by PersistJournal.
+type JournalValidator struct {
+	// rules are the registered rules, the built-in rules are not part of them and can not be left out
+	rules []JournalRule
+}
+
+// builtInJournalRules are the rules run before the registered rules of every JournalValidator.
+var builtInJournalRules = []JournalRule{
+	checkJournalMandatory,
+	checkJournalTransactionIDs,
+	checkJournalBalance,
+	checkJournalAccountsDistinct,
+	checkJournalNotPersisted,
+	checkJournalTransactionsNotPersisted,
+	checkJournalAccountsExist,
+	checkJournalAccountStatus,
+	checkJournalCurrency,
+	checkJournalReversal,
+	checkJournalCorrection,
+	checkJournalPeriod,
+	checkJournalBalanceLimit,
+	checkJournalAvailableBalance,
+}
+
+// NewJournalValidator creates a validator of the built-in rules.
+func NewJournalValidator() *JournalValidator {
+	return &JournalValidator{rules: make([]JournalRule, 0)}
+}
+
+// defaultJournalValidator is used by the JournalManagers without a validator set.
+var defaultJournalValidator = NewJournalValidator()
+
+// journalValidatorOrDefault returns the validator if not nil, otherwise the default validator.
+func journalValidatorOrDefault(validator *JournalValidator) *JournalValidator {
+	if validator == nil {
+		return defaultJournalValidator
+	}
+	return validator
+}
+
+// Register appends the rules after the rules already in the chain.
+// Rules should be registered before the validator is used, registering is not safe for concurrent use.
+func (validator *JournalValidator) Register(rules ...JournalRule) *JournalValidator {
+	validator.rules = append(validator.rules, rules...)
+	return validator
+}
+
+// Validate runs the built-in rules and then the registered rules in order, returning the error of the first failing rule.
+func (validator *JournalValidator) Validate(context context.Context, validation *JournalValidation) error {
+	for _, rule := range builtInJournalRules {
+		if err := rule(context, validation); err != nil {
+			return err
+		}
+	}
+	for _, rule := range validator.rules {
+		if err := rule(context, validation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequireJournalDescription rejects journals without a description.
+func RequireJournalDescription() JournalRule {
+	return func(context context.Context, validation *JournalValidation) error {
+		if len(strings.TrimSpace(validation.Journal.GetDescription())) == 0 {
+			return &JournalRuleError{Rule: JournalRuleDescriptionRequired, JournalID: validation.Journal.GetJournalID(), Reason: "description is empty"}
+		}
+		return nil
+	}
+}
+
+// MaxJournalAmount rejects journals whose amount, the sum of their credit, is above the maximum.
+func MaxJournalAmount(maximum decimal.Decimal) JournalRule {
+	return func(context context.Context, validation *JournalValidation) error {
+		if amount := GetTotalCredit(validation.Journal); amount.GreaterThan(maximum) {
+			return &JournalRuleError{Rule: JournalRuleMaxAmount, JournalID: validation.Journal.GetJournalID(),
+				Reason: fmt.Sprintf("amount %s is above maximum %s", amount.String(), maximum.String())}
+		}
+		return nil
+	}
+}
+
+// RestrictCredit rejects journals crediting an account whose COA starts with the prefix, unless created by one of the authors.
+func RestrictCredit(coaPrefix string, authors ...string) JournalRule {
+	allowed := make(map[string]bool, len(authors))
+	for _, author := range authors {
+		allowed[author] = true
+	}
+	return func(context context.Context, validation *JournalValidation) error {
+		if allowed[validation.Journal.GetCreateBy()] {
+			return nil
+		}
+		for _, trx := range validation.Journal.GetTransactions() {
+			if trx.GetAlignment() != CREDIT {
+				continue
+			}
+			account, err := validation.Account(context, trx.GetAccountNumber())
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(account.GetCOA(), coaPrefix) {
+				return &JournalRuleError{Rule: JournalRuleRestrictedCredit, JournalID: validation.Journal.GetJournalID(), AccountNumber: trx.GetAccountNumber(),
+					Reason: fmt.Sprintf("COA %s can not be credited by %s", account.GetCOA(), validation.Journal.GetCreateBy())}
+			}
+		}
+		return nil
+	}
+}
+
+// checkJournalMandatory makes sure the mandatories is not missing.
+func checkJournalMandatory(context context.Context, validation *JournalValidation) error {
+	journal := validation.Journal
+	if journal == nil {
+		return ErrJournalNil
+	}
+	if len(journal.GetJournalID()) == 0 {
+		logrus.Errorf("error persisting journal. journal is missing the JournalID")
+		return ErrJournalMissingID
+	}
+	if len(journal.GetTransactions()) == 0 {
+		logrus.Errorf("error persisting journal %s. journal contains no Transactions.", journal.GetJournalID())
+		return ErrJournalNoTransaction
+	}
+	if len(journal.GetCreateBy()) == 0 {
+		logrus.Errorf("error persisting journal %s. journal author not known.", journal.GetJournalID())
+		return ErrJournalMissingAuthor
+	}
+	return nil
+}
+
+// checkJournalTransactionIDs makes sure all journal Transactions are IDed.
+func checkJournalTransactionIDs(context context.Context, validation *JournalValidation) error {
+	for idx, trx := range validation.Journal.GetTransactions() {
+		if len(trx.GetTransactionID()) == 0 {
+			logrus.Errorf("error persisting journal %s. transaction %d is missing TransactionID.", validation.Journal.GetJournalID(), idx)
+			return ErrJournalTransactionMissingID
+		}
+	}
+	return nil
+}
+
+// checkJournalBalance makes sure Transactions are balanced.
+func checkJournalBalance(context context.Context, validation *JournalValidation) error {
+	if debitSum, creditSum := GetTotalDebit(validation.Journal), GetTotalCredit(validation.Journal); !creditSum.Equal(debitSum) {
+		logrus.Errorf("error persisting journal %s. debit (%s) != credit (%s). journal not Balance", validation.Journal.GetJournalID(), debitSum.String(), creditSum.String())
+		return ErrJournalNotBalance
+	}
+	return nil
+}
+
+// checkJournalAccountsDistinct makes sure Transactions account are not appear twice in the journal.
+func checkJournalAccountsDistinct(context context.Context, validation *JournalValidation) error {
+	accountDupCheck := make(map[string]bool)
+	for _, trx := range validation.Journal.GetTransactions() {
+		if accountDupCheck[trx.GetAccountNumber()] {
+			logrus.Errorf("error persisting journal %s. multiple transaction belong to the same account (%s)", validation.Journal.GetJournalID(), trx.GetAccountNumber())
+			return ErrJournalTransactionAccountDuplicate
+		}
+		accountDupCheck[trx.GetAccountNumber()] = true
+	}
+	return nil
+}
+
+// checkJournalNotPersisted makes sure the journal ID is not persisted,
+// and no other journal is persisted with the same idempotency key.
+func checkJournalNotPersisted(context context.Context, validation *JournalValidation) error {
+	journal := validation.Journal
+	exist, err := validation.Ledger.IsJournalIDExist(context, journal.GetJournalID())
+	if err != nil {
+		return err
+	}
+	if exist {
+		logrus.Errorf("error persisting journal %s. journal already exist.", journal.GetJournalID())
+		return ErrJournalAlreadyPersisted
+	}
+	if key := journal.GetIdempotencyKey(); len(key) > 0 {
+		exist, err := validation.Ledger.IsIdempotencyKeyExist(context, key)
+		if err != nil {
+			return err
+		}
+		if exist {
+			logrus.Errorf("error persisting journal %s. idempotency key %s is already used.", journal.GetJournalID(), key)
+			return ErrJournalIdempotencyKeyAlreadyExist
+		}
+	}
+	return nil
+}
+
+// checkJournalTransactionsNotPersisted makes sure all journal Transactions are not persisted.
+func checkJournalTransactionsNotPersisted(context context.Context, validation *JournalValidation) error {
+	for idx, trx := range validation.Journal.GetTransactions() {
+		exist, err := validation.Ledger.IsTransactionIDExist(context, trx.GetTransactionID())
+		if err != nil {
+			return err
+		}
+		if exist {
+			logrus.Errorf("error persisting journal %s. transaction %d is already exist.", validation.Journal.GetJournalID(), idx)
+			return ErrJournalTransactionAlreadyPersisted
+		}
+	}
+	return nil
+}
+
+// checkJournalAccountsExist makes sure Transactions are all belong to existing accounts.
+// The accounts are read in account number order, so ledgers locking them always lock in the same order
+// and concurrent journals can not dead lock each other.
+func checkJournalAccountsExist(context context.Context, validation *JournalValidation) error {
+	accountNumbers := make([]string, 0, len(validation.Journal.GetTransactions()))
+	for _, trx := range validation.Journal.GetTransactions() {
+		accountNumbers = append(accountNumbers, trx.GetAccountNumber())
+	}
+	sort.Strings(accountNumbers)
+	for _, accountNumber := range accountNumbers {
+		account, err := validation.Account(context, accountNumber)
+		if err != nil {
+			return err
+		}
+		if account == nil {
+			logrus.Errorf("error persisting journal %s. theres a transaction belong to non existent account (%s)", validation.Journal.GetJournalID(), accountNumber)
+			return ErrJournalTransactionAccountNotPersist
+		}
+	}
+	return nil
+}
+
+// checkJournalAccountStatus makes sure the account status accepts the Transactions.
+func checkJournalAccountStatus(context context.Context, validation *JournalValidation) error {
+	for _, trx := range validation.Journal.GetTransactions() {
+		account, err := validation.Account(context, trx.GetAccountNumber())
+		if err != nil {
+			return err
+		}
+		if err := checkAccountStatusAccepts(validation.Journal, trx, account.GetStatus()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkJournalCurrency makes sure Transactions are all have the same Currency.
+func checkJournalCurrency(context context.Context, validation *JournalValidation) error {
+	var currency string
+	for idx, trx := range validation.Journal.GetTransactions() {
+		account, err := validation.Account(context, trx.GetAccountNumber())
+		if err != nil {
+			return err
+		}
+		if idx == 0 {
+			currency = account.GetCurrency()
+		} else if account.GetCurrency() != currency {
+			logrus.Errorf("error persisting journal %s. Transactions here uses account with different currencies", validation.Journal.GetJournalID())
+			return ErrJournalTransactionMixCurrency
+		}
+	}
+	return nil
+}
+
+// checkJournalReversal makes sure the journal being reversed, if this is a Reversal journal, exist and have not been reversed before.
+func checkJournalReversal(context context.Context, validation *JournalValidation) error {
+	if validation.Journal.GetReversedJournal() == nil {
+		return nil
+	}
+	reversedJournalID := validation.Journal.GetReversedJournal().GetJournalID()
+	reversed, err := validation.Ledger.IsJournalIDReversed(context, reversedJournalID)
+	if err != nil {
+		logrus.Errorf("error persisting journal %s. can not check the reversed journal %s. got %s", validation.Journal.GetJournalID(), reversedJournalID, err.Error())
+		return err
+	}
+	if reversed {
+		logrus.Errorf("error persisting journal %s. this journal try to make reverse transaction on journals thats already reversed %s", validation.Journal.GetJournalID(), reversedJournalID)
+		return ErrJournalCanNotDoubleReverse
+	}
+	return nil
+}
+
+// checkJournalCorrection makes sure the corrected journal exist, if this is a correction journal.
+func checkJournalCorrection(context context.Context, validation *JournalValidation) error {
+	correctedJournalID := validation.Journal.GetCorrectedJournalID()
+	if len(correctedJournalID) == 0 {
+		return nil
+	}
+	exist, err := validation.Ledger.IsJournalIDExist(context, correctedJournalID)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		logrus.Errorf("error persisting journal %s. the corrected journal %s is not exist", validation.Journal.GetJournalID(), correctedJournalID)
+		return ErrJournalIDNotFound
+	}
+	return nil
+}
+
+// checkJournalPeriod makes sure the journal is not journaled into a closed period.
+func checkJournalPeriod(context context.Context, validation *JournalValidation) error {
+	open, err := validation.Ledger.IsPeriodOpenAt(context, validation.JournalingTime)
+	if err != nil {
+		return err
+	}
+	if !open {
+		logrus.Errorf("error persisting journal %s. journaling time %s falls in a closed period", validation.Journal.GetJournalID(), validation.JournalingTime.String())
+		return ErrJournalPeriodClosed
+	}
+	return nil
+}
+
+// checkJournalBalanceLimit makes sure the account balances stay within their limits.
+func checkJournalBalanceLimit(context context.Context, validation *JournalValidation) error {
+	for _, trx := range validation.Journal.GetTransactions() {
+		account, err := validation.Account(context, trx.GetAccountNumber())
+		if err != nil {
+			return err
+		}
+		newBalance := balanceAfter(account.GetAlignment(), trx.GetAlignment(), trx.GetAmount(), account.GetBalance())
+		if err := account.GetBalanceLimit().check(account.GetAccountNumber(), newBalance); err != nil {
+			logrus.Errorf("error persisting journal %s. %s", validation.Journal.GetJournalID(), err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// checkJournalAvailableBalance makes sure the reduced account balances still cover their active holds.
+func checkJournalAvailableBalance(context context.Context, validation *JournalValidation) error {
+	for _, trx := range validation.Journal.GetTransactions() {
+		account, err := validation.Account(context, trx.GetAccountNumber())
+		if err != nil {
+			return err
+		}
+		newBalance := balanceAfter(account.GetAlignment(), trx.GetAlignment(), trx.GetAmount(), account.GetBalance())
+		if !newBalance.LessThan(account.GetBalance()) {
+			continue
+		}
+		held, err := validation.Ledger.HeldAmount(context, account.GetAccountNumber(), validation.Now)
+		if err != nil {
+			return err
+		}
+		if err := checkAvailableBalance(validation.Journal, trx, account.GetBalance(), newBalance, held); err != nil {
+			return err
+		}
+	}
+	return nil
+}
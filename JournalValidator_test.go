@@ -0,0 +1,85 @@
+package acccore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJournalValidator(t *testing.T) {
+	store := NewInMemoryStore()
+	testJournalValidator(t, NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}))
+}
+
+func TestJournalValidatorSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testJournalValidator(t, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}))
+}
+
+func testJournalValidator(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Cash", "Cash", "1.1", "IDR", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "9001", "Suspense", "Suspense", "9.1", "IDR", CREDIT, "tester")
+	assert.NoError(t, err)
+	errMaintenance := fmt.Errorf("ledger in maintenance")
+	maintenance := false
+	acc.GetJournalManager().SetJournalValidator(NewJournalValidator().Register(
+		RequireJournalDescription(),
+		MaxJournalAmount(decimal.NewFromInt(1000)),
+		RestrictCredit("9", "system"),
+		func(ctx context.Context, validation *JournalValidation) error {
+			if maintenance {
+				return errMaintenance
+			}
+			return nil
+		},
+	))
+	post := func(description, credit string, amount int64, author string) error {
+		_, err := acc.CreateNewJournal(ctx, description, []TransactionInfo{
+			{AccountNumber: "1001", Description: "Cash", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: credit, Description: "Suspense", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, author)
+		return err
+	}
+	assertRuleError := func(err error, rule, accountNumber string) {
+		assert.True(t, errors.Is(err, ErrJournalRuleViolated))
+		ruleErr := &JournalRuleError{}
+		if assert.True(t, errors.As(err, &ruleErr)) {
+			assert.Equal(t, rule, ruleErr.Rule)
+			assert.Equal(t, accountNumber, ruleErr.AccountNumber)
+		}
+	}
+
+	assertRuleError(post(" ", "9001", 100, "system"), JournalRuleDescriptionRequired, "")
+	assertRuleError(post("Deposit", "9001", 1500, "system"), JournalRuleMaxAmount, "")
+	assertRuleError(post("Deposit", "9001", 100, "tester"), JournalRuleRestrictedCredit, "9001")
+	maintenance = true
+	assert.Equal(t, errMaintenance, post("Deposit", "9001", 100, "system"))
+	// the built-in rules run before the registered rules
+	assert.Equal(t, ErrJournalTransactionAccountNotPersist, post("", "9999", 100, "tester"))
+	maintenance = false
+
+	account, err := acc.GetAccountManager().GetAccountByID(ctx, "9001")
+	assert.NoError(t, err)
+	assert.True(t, account.GetBalance().IsZero())
+	assert.NoError(t, post("Deposit", "9001", 1000, "system"))
+	account, err = acc.GetAccountManager().GetAccountByID(ctx, "9001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1000).Equal(account.GetBalance()))
+
+	// nil restores the built-in rules alone
+	acc.GetJournalManager().SetJournalValidator(nil)
+	assert.NoError(t, post("", "9001", 5000, "tester"))
+
+	// a zero validator still runs the built-in rules, the unknown account is rejected
+	acc.GetJournalManager().SetJournalValidator(&JournalValidator{})
+	assert.Equal(t, ErrJournalTransactionAccountNotPersist, post("Deposit", "9999", 100, "tester"))
+	assert.NoError(t, post("", "9001", 100, "tester"))
+	account, err = acc.GetAccountManager().GetAccountByID(ctx, "9001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(6100).Equal(account.GetBalance()))
+}
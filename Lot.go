@@ -0,0 +1,245 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sort"
+	"time"
+)
+
+const (
+	// LotOpen is enum lot status of a lot whose remaining amount may still be consumed
+	LotOpen LotStatus = iota
+	// LotConsumed is enum lot status of a lot fully consumed by debits
+	LotConsumed
+	// LotExpired is enum lot status of a lot whose remaining amount was moved into the breakage account
+	LotExpired
+)
+
+var (
+	ErrLotManagerNotSet = fmt.Errorf("accounting has no LotManager")
+)
+
+// LotStatus is the enum type of lot status, LotOpen, LotConsumed and LotExpired
+type LotStatus int
+
+// String returns the status name
+func (status LotStatus) String() string {
+	switch status {
+	case LotOpen:
+		return "OPEN"
+	case LotConsumed:
+		return "CONSUMED"
+	case LotExpired:
+		return "EXPIRED"
+	}
+	return fmt.Sprintf("LotStatus(%d)", int(status))
+}
+
+// Lot is an amount credited into a lot tracked account, eg. points earned by a wallet, that expires at its ExpiryTime.
+// Debits consume the open lots first in first out, the remaining amount of an expired lot is moved into the breakage account.
+type Lot struct {
+	// LotID is the ID of the transaction crediting the lot.
+	LotID         string          `json:"lot_id"`
+	AccountNumber string          `json:"account_number"`
+	JournalID     string          `json:"journal_id"`
+	Amount        decimal.Decimal `json:"amount"`
+	// Remaining is the amount not consumed yet, for an expired lot it is the amount moved into the breakage account.
+	Remaining  decimal.Decimal `json:"remaining"`
+	Status     LotStatus       `json:"status"`
+	ExpiryTime time.Time       `json:"expiry_time"`
+	// ExpiryJournalID is the journal moving the remaining amount into the breakage account, once the lot expired.
+	ExpiryJournalID string    `json:"expiry_journal_id"`
+	CreateTime      time.Time `json:"create_time"`
+	CreateBy        string    `json:"create_by"`
+	UpdateTime      time.Time `json:"update_time"`
+	UpdateBy        string    `json:"update_by"`
+}
+
+// LotPolicy configures a lot tracked account.
+type LotPolicy struct {
+	// BreakageAccountNumber receives the remaining amount of expired lots, it must be of the tracked account currency.
+	BreakageAccountNumber string
+	// Validity is how long a lot lives, its first occurrence after the credit is the lot expiry time.
+	Validity Recurrence
+}
+
+// GetLotManager returns lot manager
+func (acc *Accounting) GetLotManager() LotManager {
+	return acc.lotManager
+}
+
+// SetLotManager sets the lot manager, it must work on the same storage as the journal manager.
+func (acc *Accounting) SetLotManager(lotManager LotManager) *Accounting {
+	acc.lotManager = lotManager
+	return acc
+}
+
+// GetLotTracking returns the lot policy of the account, false if the account is not lot tracked.
+func (acc *Accounting) GetLotTracking(accountNumber string) (LotPolicy, bool) {
+	policy, ok := acc.lotPolicies[accountNumber]
+	return policy, ok
+}
+
+// SetLotTracking tracks the lots of the account, eg. a points wallet, according to the policy.
+// Every journal posted by this Accounting then credits new lots into the account and consumes its lots on debits,
+// within one unit of work, so the TxManager must be set. Tracking should start before the account receives any posting,
+// as its balance from before is not in any lot.
+func (acc *Accounting) SetLotTracking(accountNumber string, policy LotPolicy) *Accounting {
+	if acc.lotPolicies == nil {
+		acc.lotPolicies = make(map[string]LotPolicy)
+	}
+	acc.lotPolicies[accountNumber] = policy
+	return acc
+}
+
+// tracksLots returns true if the journal posts into any lot tracked account.
+func (acc *Accounting) tracksLots(journal Journal) bool {
+	if acc.GetLotManager() == nil {
+		return false
+	}
+	for _, trx := range journal.GetTransactions() {
+		if _, ok := acc.GetLotTracking(trx.GetAccountNumber()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyLots credits a lot for every transaction increasing a lot tracked account, and consumes the account lots
+// for every transaction decreasing it. Lots expiring by the clock time can not be consumed.
+func (acc *Accounting) applyLots(context context.Context, journal Journal) error {
+	now := acc.GetClock().Now()
+	for _, trx := range journal.GetTransactions() {
+		policy, ok := acc.GetLotTracking(trx.GetAccountNumber())
+		if !ok {
+			continue
+		}
+		account, err := acc.GetAccountManager().GetAccountByID(context, trx.GetAccountNumber())
+		if err != nil {
+			return err
+		}
+		if trx.GetAlignment() != account.GetAlignment() {
+			if _, err := acc.GetLotManager().ConsumeLots(context, trx.GetAccountNumber(), trx.GetAmount(), now, journal.GetCreateBy()); err != nil {
+				return err
+			}
+			continue
+		}
+		err = acc.GetLotManager().CreateLot(context, &Lot{
+			LotID:         trx.GetTransactionID(),
+			AccountNumber: trx.GetAccountNumber(),
+			JournalID:     journal.GetJournalID(),
+			Amount:        trx.GetAmount(),
+			Remaining:     trx.GetAmount(),
+			Status:        LotOpen,
+			ExpiryTime:    policy.Validity.Occurrence(now, 1),
+			CreateBy:      journal.GetCreateBy(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpirePoints sweeps every lot tracked account, moving the remaining amount of the lots expired by the clock time
+// into the account breakage account. One expiry journal is posted for each account having expired lots, the journals are returned.
+// Marking the lots expired and posting the journal happen within one unit of work, so the TxManager must be set.
+func (acc *Accounting) ExpirePoints(ctx context.Context, author string) ([]Journal, error) {
+	if acc.GetLotManager() == nil {
+		return nil, ErrLotManagerNotSet
+	}
+	if acc.GetTxManager() == nil {
+		return nil, ErrTxManagerNotSet
+	}
+	now := acc.GetClock().Now()
+	accountNumbers := make([]string, 0, len(acc.lotPolicies))
+	for accountNumber := range acc.lotPolicies {
+		accountNumbers = append(accountNumbers, accountNumber)
+	}
+	sort.Strings(accountNumbers)
+
+	journals := make([]Journal, 0)
+	for _, accountNumber := range accountNumbers {
+		policy := acc.lotPolicies[accountNumber]
+		var journal Journal
+		err := acc.InUnitOfWork(ctx, func(ctx context.Context) error {
+			account, err := acc.GetAccountManager().GetAccountByID(ctx, accountNumber)
+			if err != nil {
+				return err
+			}
+			journalID := acc.GetUniqueIDGenerator().NewUniqueID()
+			expired, err := acc.GetLotManager().ExpireLots(ctx, accountNumber, now, journalID, author)
+			if err != nil || len(expired) == 0 {
+				return err
+			}
+			breakage := decimal.Zero
+			for _, lot := range expired {
+				breakage = breakage.Add(lot.Remaining)
+			}
+			description := fmt.Sprintf("Expiry of %s lots until %s", accountNumber, now.Format("2006-01-02"))
+			journal = acc.newJournal(ctx, description, []TransactionInfo{
+				{AccountNumber: accountNumber, Description: description, TxType: oppositeAlignment(account.GetAlignment()), Amount: breakage},
+				{AccountNumber: policy.BreakageAccountNumber, Description: description, TxType: account.GetAlignment(), Amount: breakage},
+			}, author)
+			journal.SetJournalID(journalID)
+			// the expired lots are already taken out, so the journal must not consume the open ones.
+			return acc.commitJournal(ctx, journal)
+		})
+		if err != nil {
+			return journals, err
+		}
+		if journal != nil {
+			journals = append(journals, journal)
+		}
+	}
+	return journals, nil
+}
+
+// PointsExpiringBefore returns the remaining amount of the account lots expiring before the time, and those lots.
+func (acc *Accounting) PointsExpiringBefore(context context.Context, accountNumber string, before time.Time) (decimal.Decimal, []*Lot, error) {
+	if acc.GetLotManager() == nil {
+		return decimal.Zero, nil, ErrLotManagerNotSet
+	}
+	lots, err := acc.GetLotManager().ListLotsExpiringBefore(context, accountNumber, before)
+	if err != nil {
+		return decimal.Zero, nil, err
+	}
+	expiring := decimal.Zero
+	for _, lot := range lots {
+		expiring = expiring.Add(lot.Remaining)
+	}
+	return expiring, lots, nil
+}
+
+// consumeLots takes the amount out of the lots first in first out, returning the lots changed.
+// The lots must be open, not expired at the time, and ordered by their expiry.
+func consumeLots(lots []*Lot, amount decimal.Decimal, at time.Time) ([]*Lot, error) {
+	available := decimal.Zero
+	for _, lot := range lots {
+		if lot.Status == LotOpen && at.Before(lot.ExpiryTime) {
+			available = available.Add(lot.Remaining)
+		}
+	}
+	if available.LessThan(amount) {
+		return nil, ErrLotInsufficient
+	}
+	consumed := make([]*Lot, 0)
+	for _, lot := range lots {
+		if !amount.IsPositive() {
+			break
+		}
+		if lot.Status != LotOpen || !at.Before(lot.ExpiryTime) {
+			continue
+		}
+		take := decimal.Min(amount, lot.Remaining)
+		lot.Remaining = lot.Remaining.Sub(take)
+		if lot.Remaining.IsZero() {
+			lot.Status = LotConsumed
+		}
+		amount = amount.Sub(take)
+		consumed = append(consumed, lot)
+	}
+	return consumed, nil
+}
@@ -0,0 +1,132 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAccounting_PointExpiry(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager()).SetLotManager(store.LotManager())
+	testPointExpiry(t, acc)
+}
+
+func TestAccounting_PointExpirySQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db)).SetLotManager(NewSQLLotManager(db, dialect))
+	testPointExpiry(t, acc)
+}
+
+func testPointExpiry(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	for _, account := range []struct {
+		number    string
+		alignment Alignment
+	}{
+		{"5001", DEBIT},
+		{"2001", CREDIT},
+		{"4001", CREDIT},
+		{"4900", CREDIT},
+	} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, "1.1", "POINT", account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+	acc.SetLotTracking("2001", LotPolicy{BreakageAccountNumber: "4900", Validity: Recurrence{Frequency: RecurMonthly, Interval: 12}})
+	clock := &testClock{}
+	acc.SetClock(clock)
+
+	earn := func(amount int64) error {
+		_, err := acc.CreateNewJournal(ctx, "Earn", []TransactionInfo{
+			{AccountNumber: "5001", Description: "Loyalty expense", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "2001", Description: "Points earned", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		return err
+	}
+	spend := func(amount int64) error {
+		_, err := acc.CreateNewJournal(ctx, "Spend", []TransactionInfo{
+			{AccountNumber: "2001", Description: "Points spent", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "4001", Description: "Redeemed", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		return err
+	}
+	assertBalance := func(accountNumber string, expected int64) {
+		account, err := acc.GetAccountManager().GetAccountByID(ctx, accountNumber)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(expected).Equal(account.GetBalance()), "account %s balance is %s", accountNumber, account.GetBalance())
+	}
+	assertExpiring := func(before time.Time, expected int64) {
+		expiring, _, err := acc.PointsExpiringBefore(ctx, "2001", before)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(expected).Equal(expiring), "expiring before %s is %s", before.String(), expiring)
+	}
+
+	clock.now = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, earn(100))
+	clock.now = time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, earn(50))
+
+	// debits consume the oldest lot first
+	clock.now = time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, spend(120))
+	lots, err := acc.GetLotManager().ListLots(ctx, "2001")
+	assert.NoError(t, err)
+	if assert.Len(t, lots, 2) {
+		assert.Equal(t, LotConsumed, lots[0].Status)
+		assert.True(t, lots[0].Remaining.IsZero())
+		assert.Equal(t, LotOpen, lots[1].Status)
+		assert.True(t, decimal.NewFromInt(30).Equal(lots[1].Remaining), "remaining is %s", lots[1].Remaining)
+		assert.Equal(t, time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC), lots[1].ExpiryTime.UTC())
+	}
+	assert.Equal(t, ErrLotInsufficient, spend(40))
+	assertBalance("2001", 30)
+	assertBalance("4001", 120)
+
+	assertExpiring(time.Date(2027, time.February, 1, 0, 0, 0, 0, time.UTC), 0)
+	assertExpiring(time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC), 30)
+
+	clock.now = time.Date(2027, time.February, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, earn(20))
+	journals, err := acc.ExpirePoints(ctx, "sweeper")
+	assert.NoError(t, err)
+	assert.Len(t, journals, 0)
+
+	// the sweep moves the expired remainder into the breakage account
+	clock.now = time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, ErrLotInsufficient, spend(25))
+	journals, err = acc.ExpirePoints(ctx, "sweeper")
+	assert.NoError(t, err)
+	if assert.Len(t, journals, 1) {
+		assert.Len(t, journals[0].GetTransactions(), 2)
+		lots, err = acc.GetLotManager().ListLots(ctx, "2001")
+		assert.NoError(t, err)
+		if assert.Len(t, lots, 3) {
+			assert.Equal(t, LotExpired, lots[1].Status)
+			assert.Equal(t, journals[0].GetJournalID(), lots[1].ExpiryJournalID)
+		}
+	}
+	assertBalance("2001", 20)
+	assertBalance("4900", 30)
+	assertExpiring(time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC), 0)
+	assertExpiring(time.Date(2028, time.April, 1, 0, 0, 0, 0, time.UTC), 20)
+
+	journals, err = acc.ExpirePoints(ctx, "sweeper")
+	assert.NoError(t, err)
+	assert.Len(t, journals, 0)
+	assert.NoError(t, spend(20))
+	assertBalance("2001", 0)
+
+	// lot tracked postings and the sweep need a unit of work
+	acc.SetTxManager(nil)
+	assert.Equal(t, ErrTxManagerNotSet, earn(10))
+	assertBalance("2001", 0)
+	_, err = acc.ExpirePoints(ctx, "sweeper")
+	assert.Equal(t, ErrTxManagerNotSet, err)
+	lots, err = acc.GetLotManager().ListLots(ctx, "2001")
+	assert.NoError(t, err)
+	assert.Len(t, lots, 3)
+}
@@ -3,12 +3,14 @@ package acccore
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/olekukonko/tablewriter"
 	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,14 +18,18 @@ import (
 
 // InMemoryJournalRecords simulates records in Journal table
 type InMemoryJournalRecords struct {
-	journalID         string
-	journalingTime    time.Time
-	description       string
-	reversal          bool
-	reversedJournalID string
-	amount            decimal.Decimal
-	createTime        time.Time
-	createBy          string
+	journalID          string
+	journalingTime     time.Time
+	description        string
+	reversal           bool
+	reversedJournalID  string
+	amount             decimal.Decimal
+	createTime         time.Time
+	createBy           string
+	idempotencyKey     string
+	correctedJournalID string
+	previousHash       string
+	hash               string
 }
 
 // InMemoryAccountRecord is simulating records in Account table
@@ -39,6 +45,18 @@ type InMemoryAccountRecord struct {
 	createBy            string
 	updateTime          time.Time
 	updateBy            string
+	status              AccountStatus
+	balanceLimit        BalanceLimit
+}
+
+// InMemoryAccountStatusRecords is simulating records in Account Status Change table
+type InMemoryAccountStatusRecords struct {
+	accountNumber string
+	fromStatus    AccountStatus
+	toStatus      AccountStatus
+	reason        string
+	changeTime    time.Time
+	changeBy      string
 }
 
 // InMemoryTransactionRecords is simulating records in Transaction table
@@ -66,155 +84,405 @@ type InMemoryCurrencyRecords struct {
 	updateBy   string
 }
 
-var (
-	// InMemoryJournalTable the simulated Journal table
-	InMemoryJournalTable map[string]*InMemoryJournalRecords
+// InMemoryCurrencyRateRecords is simulating records in Currency Rate table
+type InMemoryCurrencyRateRecords struct {
+	code       string
+	exchange   decimal.Decimal
+	validFrom  time.Time
+	createTime time.Time
+	createBy   string
+}
 
-	// InMemoryAccountTable the simulated Account table
-	InMemoryAccountTable map[string]*InMemoryAccountRecord
+// InMemoryPeriodRecords is simulating records in Period table
+type InMemoryPeriodRecords struct {
+	periodID          string
+	description       string
+	from              time.Time
+	until             time.Time
+	status            PeriodStatus
+	closingJournalIDs []string
+	createTime        time.Time
+	createBy          string
+	updateTime        time.Time
+	updateBy          string
+}
 
-	// InMemoryTransactionTable the simulated Transaction table
-	InMemoryTransactionTable map[string]*InMemoryTransactionRecords
+// InMemoryHoldRecords is simulating records in Hold table
+type InMemoryHoldRecords struct {
+	holdID         string
+	accountNumber  string
+	amount         decimal.Decimal
+	capturedAmount decimal.Decimal
+	status         HoldStatus
+	expiryTime     time.Time
+	description    string
+	journalID      string
+	createTime     time.Time
+	createBy       string
+	updateTime     time.Time
+	updateBy       string
+}
 
-	// InMemoryCurrencyTable the simulated Currency table
-	InMemoryCurrencyTable map[string]*InMemoryCurrencyRecords
-)
+// InMemoryScheduleRecords is simulating records in Schedule table
+type InMemoryScheduleRecords struct {
+	scheduleID   string
+	description  string
+	transactions []TransactionInfo
+	recurrence   Recurrence
+	startTime    time.Time
+	endTime      time.Time
+	nextSequence int
+	createTime   time.Time
+	createBy     string
+	updateTime   time.Time
+	updateBy     string
+}
+
+// InMemoryLotRecords is simulating records in Lot table
+type InMemoryLotRecords struct {
+	lotID           string
+	accountNumber   string
+	journalID       string
+	amount          decimal.Decimal
+	remaining       decimal.Decimal
+	status          LotStatus
+	expiryTime      time.Time
+	expiryJournalID string
+	createTime      time.Time
+	createBy        string
+	updateTime      time.Time
+	updateBy        string
+}
+
+// InMemoryStore simulates a database holding the Journal, Account, Transaction and Currency tables.
+// Each store owns its own tables, so separate ledgers in one process are isolated from each other.
+// All access to the tables is guarded by the store mutex, making the store safe for concurrent use.
+type InMemoryStore struct {
+	mutex sync.RWMutex
+
+	// unitOfWorkMutex is held by the running unit of work
+	unitOfWorkMutex sync.Mutex
+
+	// journalTable the simulated Journal table
+	journalTable map[string]*InMemoryJournalRecords
+
+	// journalIdempotencyKeys simulates the unique index of the Journal table on idempotency key, pointing to the journal ID
+	journalIdempotencyKeys map[string]string
+
+	// journalChain simulates the Journal table index on chain sequence, the journal IDs in persisting order
+	journalChain []string
+
+	// journalChainHead simulates the Journal Chain table, holding the hash of the last persisted journal
+	journalChainHead string
+
+	// accountTable the simulated Account table
+	accountTable map[string]*InMemoryAccountRecord
+
+	// accountStatusTable the simulated Account Status Change table, the changes of each account in change order
+	accountStatusTable map[string][]*InMemoryAccountStatusRecords
+
+	// transactionTable the simulated Transaction table
+	transactionTable map[string]*InMemoryTransactionRecords
+
+	// accountTransactions simulates the Transaction table index on account number, in posting order
+	accountTransactions map[string][]*InMemoryTransactionRecords
+
+	// currencyTable the simulated Currency table
+	currencyTable map[string]*InMemoryCurrencyRecords
+
+	// currencyRateTable the simulated Currency Rate table, the rates of each currency ordered by valid from time
+	currencyRateTable map[string][]*InMemoryCurrencyRateRecords
+
+	// periodTable the simulated Period table
+	periodTable map[string]*InMemoryPeriodRecords
+
+	// holdTable the simulated Hold table
+	holdTable map[string]*InMemoryHoldRecords
+
+	// scheduleTable the simulated Schedule table
+	scheduleTable map[string]*InMemoryScheduleRecords
+
+	// scheduleRunTable the simulated Schedule Run table, the runs of each schedule in record order
+	scheduleRunTable map[string][]*ScheduleRun
+
+	// lotTable the simulated Lot table
+	lotTable map[string]*InMemoryLotRecords
+
+	// accountLots simulates the Lot table index on account number, in creation order
+	accountLots map[string][]*InMemoryLotRecords
+
+	// outboxTable the simulated Outbox table, the events in offset order
+	outboxTable []*OutboxEvent
+
+	// outboxOffsetTable the simulated Outbox Subscriber table, the last offset delivered to each subscriber
+	outboxOffsetTable map[string]int64
+}
+
+// NewInMemoryStore creates a new empty in-memory store.
+func NewInMemoryStore() *InMemoryStore {
+	store := &InMemoryStore{}
+	store.Clear()
+	return store
+}
+
+// Clear removes all records from the store tables.
+func (store *InMemoryStore) Clear() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.journalTable = make(map[string]*InMemoryJournalRecords, 0)
+	store.journalIdempotencyKeys = make(map[string]string, 0)
+	store.journalChain = make([]string, 0)
+	store.journalChainHead = ""
+	store.accountTable = make(map[string]*InMemoryAccountRecord, 0)
+	store.accountStatusTable = make(map[string][]*InMemoryAccountStatusRecords, 0)
+	store.transactionTable = make(map[string]*InMemoryTransactionRecords, 0)
+	store.accountTransactions = make(map[string][]*InMemoryTransactionRecords, 0)
+	store.currencyTable = make(map[string]*InMemoryCurrencyRecords, 0)
+	store.currencyRateTable = make(map[string][]*InMemoryCurrencyRateRecords, 0)
+	store.periodTable = make(map[string]*InMemoryPeriodRecords, 0)
+	store.holdTable = make(map[string]*InMemoryHoldRecords, 0)
+	store.scheduleTable = make(map[string]*InMemoryScheduleRecords, 0)
+	store.scheduleRunTable = make(map[string][]*ScheduleRun, 0)
+	store.lotTable = make(map[string]*InMemoryLotRecords, 0)
+	store.accountLots = make(map[string][]*InMemoryLotRecords, 0)
+	store.outboxTable = make([]*OutboxEvent, 0)
+	store.outboxOffsetTable = make(map[string]int64, 0)
+}
+
+// JournalManager returns a JournalManager working on this store tables.
+func (store *InMemoryStore) JournalManager() JournalManager {
+	return &InMemoryJournalManager{store: store}
+}
+
+// AccountManager returns an AccountManager working on this store tables.
+func (store *InMemoryStore) AccountManager() AccountManager {
+	return &InMemoryAccountManager{store: store}
+}
+
+// TransactionManager returns a TransactionManager working on this store tables.
+func (store *InMemoryStore) TransactionManager() TransactionManager {
+	return &InMemoryTransactionManager{store: store}
+}
+
+// ExchangeManager returns an ExchangeManager working on this store tables.
+func (store *InMemoryStore) ExchangeManager() ExchangeManager {
+	return &InMemoryExchangeManager{
+		store:             store,
+		commonDenominator: decimal.NewFromInt(1),
+	}
+}
+
+// PeriodManager returns a PeriodManager working on this store tables.
+func (store *InMemoryStore) PeriodManager() PeriodManager {
+	return &InMemoryPeriodManager{store: store}
+}
+
+// HoldManager returns a HoldManager working on this store tables.
+func (store *InMemoryStore) HoldManager() HoldManager {
+	return &InMemoryHoldManager{store: store}
+}
 
-func init() {
-	ClearInMemoryTables()
+// ScheduleManager returns a ScheduleManager working on this store tables.
+func (store *InMemoryStore) ScheduleManager() ScheduleManager {
+	return &InMemoryScheduleManager{store: store}
 }
 
-// ClearInMemoryTables initializes the memory tables
+// LotManager returns a LotManager working on this store tables.
+func (store *InMemoryStore) LotManager() LotManager {
+	return &InMemoryLotManager{store: store}
+}
+
+// OutboxManager returns an OutboxManager working on this store tables.
+func (store *InMemoryStore) OutboxManager() OutboxManager {
+	return &InMemoryOutboxManager{store: store}
+}
+
+// TxManager returns a TxManager beginning units of work on this store tables.
+func (store *InMemoryStore) TxManager() TxManager {
+	return &InMemoryTxManager{store: store}
+}
+
+// defaultInMemoryStore is used by in-memory managers that are not built from an InMemoryStore.
+var defaultInMemoryStore = NewInMemoryStore()
+
+// inMemoryStoreOrDefault returns the store if not nil, otherwise the default store.
+func inMemoryStoreOrDefault(store *InMemoryStore) *InMemoryStore {
+	if store == nil {
+		return defaultInMemoryStore
+	}
+	return store
+}
+
+// ClearInMemoryTables clears the tables of the default store, used by in-memory managers
+// that are not built from an InMemoryStore.
 func ClearInMemoryTables() {
-	InMemoryJournalTable = make(map[string]*InMemoryJournalRecords, 0)
-	InMemoryAccountTable = make(map[string]*InMemoryAccountRecord, 0)
-	InMemoryTransactionTable = make(map[string]*InMemoryTransactionRecords, 0)
-	InMemoryCurrencyTable = make(map[string]*InMemoryCurrencyRecords, 0)
+	defaultInMemoryStore.Clear()
 }
 
-// InMemoryJournalManager implementation of JournalManager using inmemory Journal table map
-type InMemoryJournalManager struct {
+// inMemoryUnitOfWorkKey is the context key of the unit of work running on a store.
+type inMemoryUnitOfWorkKey struct {
+	store *InMemoryStore
 }
 
-// NewJournal will create new blank un-persisted journal
-func (jm *InMemoryJournalManager) NewJournal(context context.Context) Journal {
-	return &BaseJournal{}
+// InMemoryTxManager implementation of TxManager for the in-memory store.
+// Units of work on the same store are run one after another, and changes made outside a unit of work wait
+// for the running one to end. The changes made within a unit of work are visible to other readers before it commits,
+// rolling back undo those changes.
+type InMemoryTxManager struct {
+	store *InMemoryStore
 }
 
-// PersistJournal will record a journal entry into database.
-// It requires list of Transactions for which each of the transaction MUST BE :
-//
-//	1.NOT BE PERSISTED. (the journal AccountNumber is not exist in DB yet)
-//	2.Pointing or owned by a PERSISTED Account
-//	3.Each of this account must belong to the same Currency
-//	4.Balanced. The total sum of DEBIT and total sum of CREDIT is equal.
-//	5.No duplicate transaction that belongs to the same Account.
-//
-// If your database support 2 phased commit, you can make all Balance changes in
-// accounts and Transactions. If your db do not support this, you can implement your own 2 phase commits mechanism
-// on the CommitJournal and CancelJournal
-func (jm *InMemoryJournalManager) PersistJournal(context context.Context, journalToPersist Journal) error {
-	// First we have to make sure that the journalToPersist is not yet in our database.
-	// 1. Checking if the mandatories is not missing
-	if journalToPersist == nil {
-		return ErrJournalNil
+// Begin starts a new unit of work and returns the context carrying it.
+func (txm *InMemoryTxManager) Begin(ctx context.Context) (context.Context, UnitOfWork, error) {
+	store := inMemoryStoreOrDefault(txm.store)
+	if store.unitOfWork(ctx) != nil {
+		return ctx, joinedUnitOfWork{}, nil
+	}
+	store.unitOfWorkMutex.Lock()
+	uow := &InMemoryUnitOfWork{store: store}
+	return context.WithValue(ctx, inMemoryUnitOfWorkKey{store: store}, uow), uow, nil
+}
+
+// InMemoryUnitOfWork is the unit of work on the in-memory store.
+// It records how to undo every change made within it.
+type InMemoryUnitOfWork struct {
+	store *InMemoryStore
+	undo  []func()
+	// outbox holds the outbox events written within this unit of work, until they are inserted on commit
+	outbox   []*OutboxEvent
+	finished bool
+}
+
+// Commit makes all changes done within this unit of work permanent.
+func (uow *InMemoryUnitOfWork) Commit() error {
+	if uow.finished {
+		return ErrUnitOfWorkFinished
 	}
-	if len(journalToPersist.GetJournalID()) == 0 {
-		logrus.Errorf("error persisting journal. journal is missing the JournalID")
-		return ErrJournalMissingID
+	uow.finished = true
+	uow.undo = nil
+	if len(uow.outbox) > 0 {
+		uow.store.mutex.Lock()
+		uow.store.insertOutbox(uow.outbox)
+		uow.store.mutex.Unlock()
+		uow.outbox = nil
 	}
-	if len(journalToPersist.GetTransactions()) == 0 {
-		logrus.Errorf("error persisting journal %s. journal contains no Transactions.", journalToPersist.GetJournalID())
-		return ErrJournalNoTransaction
+	uow.store.unitOfWorkMutex.Unlock()
+	return nil
+}
+
+// Rollback discards all changes done within this unit of work.
+func (uow *InMemoryUnitOfWork) Rollback() error {
+	if uow.finished {
+		return ErrUnitOfWorkFinished
 	}
-	if len(journalToPersist.GetCreateBy()) == 0 {
-		logrus.Errorf("error persisting journal %s. journal author not known.", journalToPersist.GetJournalID())
-		return ErrJournalMissingAuthor
+	uow.store.mutex.Lock()
+	for i := len(uow.undo) - 1; i >= 0; i-- {
+		uow.undo[i]()
 	}
+	uow.store.mutex.Unlock()
+	uow.finished = true
+	uow.undo = nil
+	uow.outbox = nil
+	uow.store.unitOfWorkMutex.Unlock()
+	return nil
+}
 
-	// 2. Checking if the journal ID must not in the Database (already persisted)
-	//    SQL HINT : SELECT COUNT(*) FROM JOURNAL WHERE JOURNAL.ID = {journalToPersist.GetJournalID()}
-	//    If COUNT(*) is > 0 return error
-	if _, exist := InMemoryJournalTable[journalToPersist.GetJournalID()]; exist == true {
-		logrus.Errorf("error persisting journal %s. journal already exist.", journalToPersist.GetJournalID())
-		return ErrJournalAlreadyPersisted
+// unitOfWork returns the unit of work on this store carried by the context, nil if there is none.
+func (store *InMemoryStore) unitOfWork(context context.Context) *InMemoryUnitOfWork {
+	if uow, ok := context.Value(inMemoryUnitOfWorkKey{store: store}).(*InMemoryUnitOfWork); ok && !uow.finished {
+		return uow
 	}
+	return nil
+}
 
-	// 3. Make sure all journal Transactions are IDed.
-	for idx, trx := range journalToPersist.GetTransactions() {
-		if len(trx.GetTransactionID()) == 0 {
-			logrus.Errorf("error persisting journal %s. transaction %d is missing TransactionID.", journalToPersist.GetJournalID(), idx)
-			return ErrJournalTransactionMissingID
-		}
+// appendOutbox writes the events into the outbox. Within a unit of work the events are held until it commits,
+// so the outbox readers never see the events of changes that may still be rolled back.
+// The caller must hold the store lock.
+func (store *InMemoryStore) appendOutbox(context context.Context, events []*OutboxEvent) {
+	if uow := store.unitOfWork(context); uow != nil {
+		uow.outbox = append(uow.outbox, events...)
+		return
 	}
+	store.insertOutbox(events)
+}
 
-	// 4. Make sure all journal Transactions are not persisted.
-	for idx, trx := range journalToPersist.GetTransactions() {
-		if _, exist := InMemoryTransactionTable[trx.GetTransactionID()]; exist {
-			logrus.Errorf("error persisting journal %s. transaction %d is already exist.", journalToPersist.GetJournalID(), idx)
-			return ErrJournalTransactionAlreadyPersisted
-		}
+// insertOutbox inserts the events into the Outbox table, numbering them from the next offset.
+// The caller must hold the store lock.
+func (store *InMemoryStore) insertOutbox(events []*OutboxEvent) {
+	for _, event := range events {
+		event.Offset = int64(len(store.outboxTable) + 1)
+		store.outboxTable = append(store.outboxTable, event)
 	}
+}
 
-	// 5. Make sure Transactions are balanced.
-	var creditSum, debitSum decimal.Decimal
-	for _, trx := range journalToPersist.GetTransactions() {
-		if trx.GetAlignment() == DEBIT {
-			debitSum = debitSum.Add(trx.GetAmount())
-		}
-		if trx.GetAlignment() == CREDIT {
-			creditSum = creditSum.Add(trx.GetAmount())
-		}
+// lock takes the store lock for a change and returns the function releasing it.
+// A change made outside a unit of work first waits for the running unit of work to end,
+// so rolling back a unit of work never undoes the changes of other writers.
+func (store *InMemoryStore) lock(context context.Context) func() {
+	if store.unitOfWork(context) != nil {
+		store.mutex.Lock()
+		return store.mutex.Unlock
 	}
-	if !creditSum.Equal(debitSum) {
-		logrus.Errorf("error persisting journal %s. debit (%d) != credit (%d). journal not Balance", journalToPersist.GetJournalID(), debitSum, creditSum)
-		return ErrJournalNotBalance
+	store.unitOfWorkMutex.Lock()
+	store.mutex.Lock()
+	return func() {
+		store.mutex.Unlock()
+		store.unitOfWorkMutex.Unlock()
 	}
+}
 
-	// 6. Make sure Transactions account are not appear twice in the journal
-	accountDupCheck := make(map[string]bool)
-	for _, trx := range journalToPersist.GetTransactions() {
-		if _, exist := accountDupCheck[trx.GetAccountNumber()]; exist {
-			logrus.Errorf("error persisting journal %s. multiple transaction belong to the same account (%s)", journalToPersist.GetJournalID(), trx.GetAccountNumber())
-			return ErrJournalTransactionAccountDuplicate
-		}
-		accountDupCheck[trx.GetAccountNumber()] = true
+// onRollback registers the function undoing a change, if the change is made within a unit of work.
+// The caller must hold the store lock.
+func (store *InMemoryStore) onRollback(context context.Context, undo func()) {
+	if uow := store.unitOfWork(context); uow != nil {
+		uow.undo = append(uow.undo, undo)
 	}
+}
 
-	// 7. Make sure Transactions are all belong to existing accounts
-	for _, trx := range journalToPersist.GetTransactions() {
-		if _, exist := InMemoryAccountTable[trx.GetAccountNumber()]; !exist {
-			logrus.Errorf("error persisting journal %s. theres a transaction belong to non existent account (%s)", journalToPersist.GetJournalID(), trx.GetAccountNumber())
-			return ErrJournalTransactionAccountNotPersist
-		}
-	}
+// InMemoryJournalManager implementation of JournalManager using inmemory Journal table map
+type InMemoryJournalManager struct {
+	store     *InMemoryStore
+	validator *JournalValidator
+}
 
-	// 8. Make sure Transactions are all have the same Currency
-	var currency string
-	for idx, trx := range journalToPersist.GetTransactions() {
-		// SELECT CURRENCY FROM ACCOUNT WHERE ACCOUNT_NUMBER = {trx.GetAccountNumber()}
-		cur := InMemoryAccountTable[trx.GetAccountNumber()].currency
-		if idx == 0 {
-			currency = cur
-		} else {
-			if cur != currency {
-				logrus.Errorf("error persisting journal %s. Transactions here uses account with different currencies", journalToPersist.GetJournalID())
-				return ErrJournalTransactionMixCurrency
-			}
-		}
-	}
+// SetJournalValidator sets the validator PersistJournal runs, nil restores the validator of the built-in rules.
+func (jm *InMemoryJournalManager) SetJournalValidator(validator *JournalValidator) {
+	jm.validator = validator
+}
 
-	// 9. If this is a Reversal journal, make sure the journal being reversed have not been reversed before.
-	if journalToPersist.GetReversedJournal() != nil {
-		reversed, err := jm.IsJournalIDReversed(context, journalToPersist.GetJournalID())
-		if err != nil {
-			return err
-		}
-		if reversed {
-			logrus.Errorf("error persisting journal %s. this journal try to make reverse transaction on journals thats already reversed %s", journalToPersist.GetJournalID(), journalToPersist.GetJournalID())
-			return ErrJournalCanNotDoubleReverse
-		}
+// NewJournal will create new blank un-persisted journal
+func (jm *InMemoryJournalManager) NewJournal(context context.Context) Journal {
+	return &BaseJournal{}
+}
+
+// PersistJournal will record a journal entry into database.
+// It requires list of Transactions for which each of the transaction MUST BE :
+//
+//	1.NOT BE PERSISTED. (the journal AccountNumber is not exist in DB yet)
+//	2.Pointing or owned by a PERSISTED Account
+//	3.Each of this account must belong to the same Currency
+//	4.Balanced. The total sum of DEBIT and total sum of CREDIT is equal.
+//	5.No duplicate transaction that belongs to the same Account.
+//	6.No other journal with the same idempotency key, if the journal has one.
+//	7.Leaving enough balance for the active holds of every account it reduces.
+//
+// These are checked by the JournalValidator of this manager, followed by its registered rules.
+// If your database support 2 phased commit, you can make all Balance changes in
+// accounts and Transactions. If your db do not support this, you can implement your own 2 phase commits mechanism
+// on the CommitJournal and CancelJournal
+func (jm *InMemoryJournalManager) PersistJournal(context context.Context, journalToPersist Journal) error {
+	// The whole validation and persisting is done while holding the store lock,
+	// so concurrent journals can not corrupt the account balances.
+	store := inMemoryStoreOrDefault(jm.store)
+	defer store.lock(context)()
+
+	// Run the journal validator against the store tables.
+	validation := NewJournalValidation(journalToPersist, &inMemoryJournalLedger{store: store}, time.Now())
+	if err := journalValidatorOrDefault(jm.validator).Validate(context, validation); err != nil {
+		return err
 	}
+	creditSum := GetTotalCredit(journalToPersist)
+	journalingTime := validation.JournalingTime
 
 	// ALL is OK. So lets start persisting.
 
@@ -222,27 +490,54 @@ func (jm *InMemoryJournalManager) PersistJournal(context context.Context, journa
 
 	// 1. Save the Journal
 	journalToInsert := &InMemoryJournalRecords{
-		journalID:         journalToPersist.GetJournalID(),
-		journalingTime:    time.Now(), // now is set
-		description:       journalToPersist.GetDescription(),
-		reversal:          false,      // will be set
-		reversedJournalID: "",         // will be set
-		amount:            creditSum,  // since we know credit sum and debit sum is equal, lets use one of the sum.
-		createTime:        time.Now(), // now is set
-		createBy:          journalToPersist.GetCreateBy(),
+		journalID:          journalToPersist.GetJournalID(),
+		journalingTime:     journalingTime, // now if not provided
+		description:        journalToPersist.GetDescription(),
+		reversal:           false,      // will be set
+		reversedJournalID:  "",         // will be set
+		amount:             creditSum,  // since we know credit sum and debit sum is equal, lets use one of the sum.
+		createTime:         time.Now(), // now is set
+		createBy:           journalToPersist.GetCreateBy(),
+		idempotencyKey:     journalToPersist.GetIdempotencyKey(),
+		correctedJournalID: journalToPersist.GetCorrectedJournalID(),
+		previousHash:       store.journalChainHead,
+		hash:               journalHash(store.journalChainHead, journalToPersist, journalingTime),
 	}
 	if journalToPersist.GetReversedJournal() != nil {
 		journalToInsert.reversedJournalID = journalToPersist.GetReversedJournal().GetJournalID()
 		journalToInsert.reversal = true
 	}
 	// This is when we insert the record into table.
-	InMemoryJournalTable[journalToInsert.journalID] = journalToInsert
+	store.journalTable[journalToInsert.journalID] = journalToInsert
+	if len(journalToInsert.idempotencyKey) > 0 {
+		store.journalIdempotencyKeys[journalToInsert.idempotencyKey] = journalToInsert.journalID
+	}
+	// and chain it after the last persisted journal.
+	previousHead := store.journalChainHead
+	store.journalChain = append(store.journalChain, journalToInsert.journalID)
+	store.journalChainHead = journalToInsert.hash
+	store.onRollback(context, func() {
+		delete(store.journalTable, journalToInsert.journalID)
+		if len(journalToInsert.idempotencyKey) > 0 {
+			delete(store.journalIdempotencyKeys, journalToInsert.idempotencyKey)
+		}
+		for i := len(store.journalChain) - 1; i >= 0; i-- {
+			if store.journalChain[i] == journalToInsert.journalID {
+				store.journalChain = append(store.journalChain[:i], store.journalChain[i+1:]...)
+				break
+			}
+		}
+		store.journalChainHead = previousHead
+	})
+	journalToPersist.SetPreviousHash(journalToInsert.previousHash)
+	journalToPersist.SetHash(journalToInsert.hash)
 
 	// 2 Save the Transactions
+	balances := make(map[string]decimal.Decimal, len(journalToPersist.GetTransactions()))
 	for _, trx := range journalToPersist.GetTransactions() {
 		transactionToInsert := &InMemoryTransactionRecords{
 			transactionID:   trx.GetTransactionID(),
-			transactionTime: time.Now(), // now is set
+			transactionTime: journalingTime, // the transactions happen at the journal time
 			accountNumber:   trx.GetAccountNumber(),
 			journalID:       journalToInsert.journalID,
 			description:     trx.GetDescription(),
@@ -254,26 +549,35 @@ func (jm *InMemoryJournalManager) PersistJournal(context context.Context, journa
 		}
 		// get the account current Balance
 		// SELECT BALANCE, BASE_TRANSACTION_TYPE FROM ACCOUNT WHERE ACCOUNT_ID = {trx.GetAccountNumber()}
-		balance, accountTrxType := InMemoryAccountTable[trx.GetAccountNumber()].balance, InMemoryAccountTable[trx.GetAccountNumber()].baseTransactionType
+		balance, accountTrxType := store.accountTable[trx.GetAccountNumber()].balance, store.accountTable[trx.GetAccountNumber()].baseTransactionType
 
-		newBalance := decimal.Zero
-		if transactionToInsert.transactionType == accountTrxType {
-			newBalance = balance.Add(transactionToInsert.amount)
-		} else {
-			newBalance = balance.Sub(transactionToInsert.amount)
-		}
+		newBalance := balanceAfter(accountTrxType, transactionToInsert.transactionType, transactionToInsert.amount, balance)
 		transactionToInsert.accountBalance = newBalance
+		balances[transactionToInsert.transactionID] = newBalance
 
 		// This is when we insert the record into table.
-		InMemoryTransactionTable[transactionToInsert.transactionID] = transactionToInsert
+		store.transactionTable[transactionToInsert.transactionID] = transactionToInsert
+		store.accountTransactions[transactionToInsert.accountNumber] = append(store.accountTransactions[transactionToInsert.accountNumber], transactionToInsert)
 
 		// Update Account Balance.
 		// UPDATE ACCOUNT SET BALANCE = {newBalance},  UPDATEBY = {trx.GetCreateBy()}, UPDATE_TIME = {time.Now()} WHERE ACCOUNT_ID = {trx.GetAccountNumber()}
-		InMemoryAccountTable[trx.GetAccountNumber()].balance = newBalance
-		InMemoryAccountTable[trx.GetAccountNumber()].updateTime = time.Now()
-		InMemoryAccountTable[trx.GetAccountNumber()].updateBy = trx.GetCreateBy()
+		accountRecord := store.accountTable[trx.GetAccountNumber()]
+		delta, updateTime, updateBy := newBalance.Sub(balance), accountRecord.updateTime, accountRecord.updateBy
+		accountRecord.balance = newBalance
+		accountRecord.updateTime = time.Now()
+		accountRecord.updateBy = trx.GetCreateBy()
+		store.onRollback(context, func() {
+			delete(store.transactionTable, transactionToInsert.transactionID)
+			accountTransactions := store.accountTransactions[transactionToInsert.accountNumber]
+			store.accountTransactions[transactionToInsert.accountNumber] = accountTransactions[:len(accountTransactions)-1]
+			accountRecord.balance = accountRecord.balance.Sub(delta)
+			accountRecord.updateTime, accountRecord.updateBy = updateTime, updateBy
+		})
 	}
 
+	// 3 Write the journal events into the outbox
+	store.appendOutbox(context, journalOutboxEvents(journalToPersist, creditSum, balances, journalToInsert.createTime))
+
 	// COMMIT transaction
 
 	return nil
@@ -302,23 +606,77 @@ func (jm *InMemoryJournalManager) IsJournalIDExist(context context.Context, id s
 	// SELECT COUNT(*) FROM JOURNAL WHERE JOURNAL_ID = <AccountNumber>
 	// return true if COUNT > 0
 	// return false if COUNT == 0
-	_, exist := InMemoryJournalTable[id]
+	store := inMemoryStoreOrDefault(jm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	_, exist := store.journalTable[id]
 	return exist, nil
 }
 
 // GetJournalByID retrieved a Journal information identified by its ID.
 // the provided ID must be exactly the same, not uses the LIKE select expression.
 func (jm *InMemoryJournalManager) GetJournalByID(context context.Context, journalID string) (Journal, error) {
-	journalRecord, exist := InMemoryJournalTable[journalID]
+	store := inMemoryStoreOrDefault(jm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.getJournalByID(journalID)
+}
+
+// GetJournalByIdempotencyKey retrieved the Journal persisted with the idempotency key.
+func (jm *InMemoryJournalManager) GetJournalByIdempotencyKey(context context.Context, idempotencyKey string) (Journal, error) {
+	// SELECT JOURNAL_ID FROM JOURNAL WHERE IDEMPOTENCY_KEY = {idempotencyKey}
+	store := inMemoryStoreOrDefault(jm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	journalID, exist := store.journalIdempotencyKeys[idempotencyKey]
+	if !exist || len(idempotencyKey) == 0 {
+		return nil, ErrJournalIdempotencyKeyNotFound
+	}
+	return store.getJournalByID(journalID)
+}
+
+// VerifyJournalChain walks the journals in persisting order, recomputing each journal hash from its content,
+// and reports the first journal whose hash or link to the journal before it is broken.
+func (jm *InMemoryJournalManager) VerifyJournalChain(context context.Context) (*JournalChainVerification, error) {
+	// SELECT JOURNAL_ID FROM JOURNAL ORDER BY CHAIN_SEQUENCE
+	store := inMemoryStoreOrDefault(jm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	verifier := &journalChainVerifier{}
+	for _, journalID := range store.journalChain {
+		journal, err := store.getJournalByID(journalID)
+		if err != nil {
+			return nil, err
+		}
+		if !verifier.check(journal) {
+			break
+		}
+	}
+	return verifier.finish(store.journalChainHead), nil
+}
+
+// getJournalByID loads a journal and its transactions. The caller must hold the store lock.
+func (store *InMemoryStore) getJournalByID(journalID string) (Journal, error) {
+	journalRecord, exist := store.journalTable[journalID]
 	if !exist {
 		return nil, ErrJournalIDNotFound
 	}
-	journal := jm.NewJournal(context).SetDescription(journalRecord.description).SetCreateTime(journalRecord.createTime).
-		SetCreateBy(journalRecord.createBy).SetReversal(journalRecord.reversal).
-		SetJournalingTime(journalRecord.journalingTime).SetJournalID(journalRecord.journalID).SetAmount(journalRecord.amount)
+	journal := &BaseJournal{
+		JournalID:          journalRecord.journalID,
+		JournalingTime:     journalRecord.journalingTime,
+		Description:        journalRecord.description,
+		Reversal:           journalRecord.reversal,
+		Amount:             journalRecord.amount,
+		CreateTime:         journalRecord.createTime,
+		CreatedBy:          journalRecord.createBy,
+		IdempotencyKey:     journalRecord.idempotencyKey,
+		CorrectedJournalID: journalRecord.correctedJournalID,
+		PreviousHash:       journalRecord.previousHash,
+		Hash:               journalRecord.hash,
+	}
 
 	if journalRecord.reversal {
-		reversed, err := jm.GetJournalByID(context, journalRecord.reversedJournalID)
+		reversed, err := store.getJournalByID(journalRecord.reversedJournalID)
 		if err != nil {
 			return nil, ErrJournalLoadReversalInconsistent
 		}
@@ -328,7 +686,7 @@ func (jm *InMemoryJournalManager) GetJournalByID(context context.Context, journa
 	// Populate all Transactions from DB.
 	transactions := make([]Transaction, 0)
 	// SELECT * FROM TRANSACTION WHERE JOURNAL_ID = {journalRecord.JournalID}
-	for _, trx := range InMemoryTransactionTable {
+	for _, trx := range store.transactionTable {
 		if trx.journalID == journalRecord.journalID {
 			transaction := &BaseTransaction{
 				TransactionID:   trx.transactionID,
@@ -354,9 +712,13 @@ func (jm *InMemoryJournalManager) GetJournalByID(context context.Context, journa
 // ListJournals retrieve list of journals with transaction date between the `from` and `until` time range inclusive.
 // This function uses pagination.
 func (jm *InMemoryJournalManager) ListJournals(context context.Context, from time.Time, until time.Time, request PageRequest) (PageResult, []Journal, error) {
+	store := inMemoryStoreOrDefault(jm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
 	// SELECT COUNT(*) FROM JOURNAL WHERE JOURNALING_TIME < {until} AND JOURNALING_TIME > {from}
 	allResult := make([]*InMemoryJournalRecords, 0)
-	for _, j := range InMemoryJournalTable {
+	for _, j := range store.journalTable {
 		if j.journalingTime.After(from) && j.journalingTime.Before(until) {
 			allResult = append(allResult, j)
 		}
@@ -371,7 +733,7 @@ func (jm *InMemoryJournalManager) ListJournals(context context.Context, from tim
 
 	journals := make([]Journal, pageResult.PageSize)
 	for i, r := range allResult[pageResult.Offset : pageResult.Offset+pageResult.PageSize] {
-		journal, err := jm.GetJournalByID(context, r.journalID)
+		journal, err := store.getJournalByID(r.journalID)
 		if err != nil {
 			return PageResult{}, nil, err
 		}
@@ -407,9 +769,51 @@ func (jm *InMemoryJournalManager) IsJournalIDReversed(context context.Context, j
 	// SELECT COUNT(*) FROM JOURNAL WHERE REVERSED_JOURNAL_ID = {JournalID}
 	// return false if COUNT = 0
 	// return true if COUNT > 0
-	_, exist := InMemoryJournalTable[journalID]
+	store := inMemoryStoreOrDefault(jm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.isJournalIDReversed(journalID)
+}
+
+// GetReversalOf retrieved the Journal reversing the journal with specified ID.
+func (jm *InMemoryJournalManager) GetReversalOf(context context.Context, journalID string) (Journal, error) {
+	// SELECT JOURNAL_ID FROM JOURNAL WHERE REVERSED_JOURNAL_ID = {JournalID}
+	store := inMemoryStoreOrDefault(jm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if _, exist := store.journalTable[journalID]; !exist {
+		return nil, ErrJournalIDNotFound
+	}
+	for _, j := range store.journalTable {
+		if j.reversal && j.reversedJournalID == journalID {
+			return store.getJournalByID(j.journalID)
+		}
+	}
+	return nil, ErrJournalNotReversed
+}
+
+// GetCorrectionOf retrieved the Journal correcting the journal with specified ID.
+func (jm *InMemoryJournalManager) GetCorrectionOf(context context.Context, journalID string) (Journal, error) {
+	// SELECT JOURNAL_ID FROM JOURNAL WHERE CORRECTED_JOURNAL_ID = {JournalID}
+	store := inMemoryStoreOrDefault(jm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if _, exist := store.journalTable[journalID]; !exist {
+		return nil, ErrJournalIDNotFound
+	}
+	for _, j := range store.journalTable {
+		if j.correctedJournalID == journalID {
+			return store.getJournalByID(j.journalID)
+		}
+	}
+	return nil, ErrJournalNotCorrected
+}
+
+// isJournalIDReversed check if the journal with specified ID has been reversed. The caller must hold the store lock.
+func (store *InMemoryStore) isJournalIDReversed(journalID string) (bool, error) {
+	_, exist := store.journalTable[journalID]
 	if exist {
-		for _, j := range InMemoryJournalTable {
+		for _, j := range store.journalTable {
 			if j.reversedJournalID == journalID {
 				return true, nil
 			}
@@ -421,9 +825,60 @@ func (jm *InMemoryJournalManager) IsJournalIDReversed(context context.Context, j
 
 }
 
+// inMemoryJournalLedger is the JournalLedger of the store tables, used while the store lock is held.
+type inMemoryJournalLedger struct {
+	store *InMemoryStore
+}
+
+// IsJournalIDExist checks if a journal with the ID is persisted.
+func (ledger *inMemoryJournalLedger) IsJournalIDExist(context context.Context, journalID string) (bool, error) {
+	_, exist := ledger.store.journalTable[journalID]
+	return exist, nil
+}
+
+// IsIdempotencyKeyExist checks if a journal is persisted with the idempotency key.
+func (ledger *inMemoryJournalLedger) IsIdempotencyKeyExist(context context.Context, idempotencyKey string) (bool, error) {
+	_, exist := ledger.store.journalIdempotencyKeys[idempotencyKey]
+	return exist, nil
+}
+
+// IsTransactionIDExist checks if a transaction with the ID is persisted.
+func (ledger *inMemoryJournalLedger) IsTransactionIDExist(context context.Context, transactionID string) (bool, error) {
+	_, exist := ledger.store.transactionTable[transactionID]
+	return exist, nil
+}
+
+// IsJournalIDReversed checks if the journal has been reversed, ErrJournalIDNotFound if it is not exist.
+func (ledger *inMemoryJournalLedger) IsJournalIDReversed(context context.Context, journalID string) (bool, error) {
+	return ledger.store.isJournalIDReversed(journalID)
+}
+
+// GetAccount returns the account, nil if it is not exist.
+func (ledger *inMemoryJournalLedger) GetAccount(context context.Context, accountNumber string) (Account, error) {
+	if accountRecord, exist := ledger.store.accountTable[accountNumber]; exist {
+		return accountRecord.toAccount(), nil
+	}
+	return nil, nil
+}
+
+// IsPeriodOpenAt checks if the time is not in a closed or locked period.
+func (ledger *inMemoryJournalLedger) IsPeriodOpenAt(context context.Context, at time.Time) (bool, error) {
+	period := ledger.store.getPeriodAt(at)
+	return period == nil || period.status == PeriodOpen, nil
+}
+
+// HeldAmount returns the amount held on the account by the holds active at the time.
+func (ledger *inMemoryJournalLedger) HeldAmount(context context.Context, accountNumber string, at time.Time) (decimal.Decimal, error) {
+	return ledger.store.heldAmount(accountNumber, at), nil
+}
+
 // RenderJournal will render this journal into string for easy inspection
 func (jm *InMemoryJournalManager) RenderJournal(context context.Context, journal Journal) string {
+	return renderJournal(journal)
+}
 
+// renderJournal renders a journal into a table, used by all JournalManager implementations.
+func renderJournal(journal Journal) string {
 	var buff bytes.Buffer
 	table := tablewriter.NewWriter(&buff)
 	table.SetHeader([]string{"TRX ID", "Account", "Description", "DEBIT", "CREDIT"})
@@ -448,11 +903,12 @@ func (jm *InMemoryJournalManager) RenderJournal(context context.Context, journal
 
 // InMemoryAccountManager implementation of AccountManager using inmemory Account table map
 type InMemoryAccountManager struct {
+	store *InMemoryStore
 }
 
 // NewAccount will create a new blank un-persisted account.
 func (am *InMemoryAccountManager) NewAccount(context context.Context) Account {
-	return &BaseAccount{}
+	return &BaseAccount{BalanceLimit: UnlimitedBalance}
 }
 
 // PersistAccount will save the account into database.
@@ -470,13 +926,15 @@ func (am *InMemoryAccountManager) PersistAccount(context context.Context, Accoun
 	if len(AccountToPersist.GetCreateBy()) == 0 {
 		return ErrAccountMissingCreator
 	}
-
-	// First make sure that The account have never been created in DB.
-	exist, err := am.IsAccountIDExist(context, AccountToPersist.GetAccountNumber())
-	if err != nil {
+	if err := AccountToPersist.GetBalanceLimit().validate(); err != nil {
 		return err
 	}
-	if exist {
+
+	store := inMemoryStoreOrDefault(am.store)
+	defer store.lock(context)()
+
+	// First make sure that The account have never been created in DB.
+	if _, exist := store.accountTable[AccountToPersist.GetAccountNumber()]; exist {
 		return ErrAccountAlreadyPersisted
 	}
 
@@ -492,15 +950,20 @@ func (am *InMemoryAccountManager) PersistAccount(context context.Context, Accoun
 		createBy:            AccountToPersist.GetCreateBy(),
 		updateTime:          time.Now(),
 		updateBy:            AccountToPersist.GetUpdateBy(),
+		balanceLimit:        AccountToPersist.GetBalanceLimit(),
 	}
 
-	InMemoryAccountTable[accountRecord.id] = accountRecord
+	store.accountTable[accountRecord.id] = accountRecord
+	store.onRollback(context, func() {
+		delete(store.accountTable, accountRecord.id)
+	})
 
 	return nil
 }
 
 // UpdateAccount will update the account database to reflect to the provided account information.
 // This update account function will fail if the account ID/number is not existing in the database.
+// The account balance is only changed by journals, the balance of the provided account is ignored.
 func (am *InMemoryAccountManager) UpdateAccount(context context.Context, AccountToUpdate Account) error {
 	if len(AccountToUpdate.GetAccountNumber()) == 0 {
 		return ErrAccountMissingID
@@ -514,13 +977,15 @@ func (am *InMemoryAccountManager) UpdateAccount(context context.Context, Account
 	if len(AccountToUpdate.GetCreateBy()) == 0 {
 		return ErrAccountMissingCreator
 	}
-
-	// First make sure that The account have never been created in DB.
-	exist, err := am.IsAccountIDExist(context, AccountToUpdate.GetAccountNumber())
-	if err != nil {
+	if err := AccountToUpdate.GetBalanceLimit().validate(); err != nil {
 		return err
 	}
-	if !exist {
+
+	store := inMemoryStoreOrDefault(am.store)
+	defer store.lock(context)()
+
+	// First make sure that The account have been created in DB.
+	if _, exist := store.accountTable[AccountToUpdate.GetAccountNumber()]; !exist {
 		return ErrAccountIsNotPersisted
 	}
 
@@ -530,15 +995,22 @@ func (am *InMemoryAccountManager) UpdateAccount(context context.Context, Account
 		name:                AccountToUpdate.GetName(),
 		description:         AccountToUpdate.GetDescription(),
 		baseTransactionType: AccountToUpdate.GetAlignment(),
-		balance:             AccountToUpdate.GetBalance(),
 		coa:                 AccountToUpdate.GetCOA(),
 		createTime:          time.Now(),
 		createBy:            AccountToUpdate.GetCreateBy(),
 		updateTime:          time.Now(),
 		updateBy:            AccountToUpdate.GetUpdateBy(),
+		balanceLimit:        AccountToUpdate.GetBalanceLimit(),
 	}
 
-	InMemoryAccountTable[accountRecord.id] = accountRecord
+	// the balance is only moved by the journals, it is left as is.
+	previousRecord := store.accountTable[accountRecord.id]
+	accountRecord.balance = previousRecord.balance
+	accountRecord.status = previousRecord.status
+	store.accountTable[accountRecord.id] = accountRecord
+	store.onRollback(context, func() {
+		store.accountTable[accountRecord.id] = previousRecord
+	})
 
 	return nil
 }
@@ -546,36 +1018,52 @@ func (am *InMemoryAccountManager) UpdateAccount(context context.Context, Account
 // IsAccountIDExist will check if an account ID/number is exist in the database.
 func (am *InMemoryAccountManager) IsAccountIDExist(context context.Context, id string) (bool, error) {
 	// SELECT COUNT(*) FROM ACCOUNT WHERE ACCOUNT_NUMBER = {AccountNumber}
-	_, exist := InMemoryAccountTable[id]
+	store := inMemoryStoreOrDefault(am.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	_, exist := store.accountTable[id]
 	return exist, nil
 }
 
 // GetAccountByID retrieve an account information by specifying the ID/number
 func (am *InMemoryAccountManager) GetAccountByID(context context.Context, id string) (Account, error) {
-	accountRecord, exist := InMemoryAccountTable[id]
+	store := inMemoryStoreOrDefault(am.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	accountRecord, exist := store.accountTable[id]
 	if !exist {
 		return nil, ErrAccountIDNotFound
 	}
+	return accountRecord.toAccount(), nil
+}
+
+func (rec *InMemoryAccountRecord) toAccount() Account {
 	return &BaseAccount{
-		Currency:      accountRecord.currency,
-		AccountNumber: accountRecord.id,
-		Name:          accountRecord.name,
-		Description:   accountRecord.description,
-		Alignment:     accountRecord.baseTransactionType,
-		Balance:       accountRecord.balance,
-		COA:           accountRecord.coa,
-		CreateTime:    accountRecord.createTime,
-		CreateBy:      accountRecord.createBy,
-		UpdateTime:    accountRecord.updateTime,
-		UpdateBy:      accountRecord.updateBy,
-	}, nil
+		Currency:      rec.currency,
+		AccountNumber: rec.id,
+		Name:          rec.name,
+		Description:   rec.description,
+		Alignment:     rec.baseTransactionType,
+		Balance:       rec.balance,
+		COA:           rec.coa,
+		CreateTime:    rec.createTime,
+		CreateBy:      rec.createBy,
+		UpdateTime:    rec.updateTime,
+		UpdateBy:      rec.updateBy,
+		Status:        rec.status,
+		BalanceLimit:  rec.balanceLimit,
+	}
 }
 
 // ListAccounts list all account in the database.
 // This function uses pagination
 func (am *InMemoryAccountManager) ListAccounts(context context.Context, request PageRequest) (PageResult, []Account, error) {
+	store := inMemoryStoreOrDefault(am.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
 	resultSlice := make([]*InMemoryAccountRecord, 0)
-	for _, r := range InMemoryAccountTable {
+	for _, r := range store.accountTable {
 		resultSlice = append(resultSlice, r)
 	}
 	sort.SliceStable(resultSlice, func(i, j int) bool {
@@ -598,6 +1086,8 @@ func (am *InMemoryAccountManager) ListAccounts(context context.Context, request
 			CreateBy:      s.createBy,
 			UpdateTime:    s.updateTime,
 			UpdateBy:      s.updateBy,
+			Status:        s.status,
+			BalanceLimit:  s.balanceLimit,
 		}
 		accounts[i] = bacc
 	}
@@ -608,8 +1098,12 @@ func (am *InMemoryAccountManager) ListAccounts(context context.Context, request
 // ListAccountByCOA returns list of accounts that have the same COA number.
 // This function uses pagination
 func (am *InMemoryAccountManager) ListAccountByCOA(context context.Context, coa string, request PageRequest) (PageResult, []Account, error) {
+	store := inMemoryStoreOrDefault(am.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
 	resultSlice := make([]*InMemoryAccountRecord, 0)
-	for _, r := range InMemoryAccountTable {
+	for _, r := range store.accountTable {
 		if r.coa == coa {
 			resultSlice = append(resultSlice, r)
 		}
@@ -634,6 +1128,8 @@ func (am *InMemoryAccountManager) ListAccountByCOA(context context.Context, coa
 			CreateBy:      s.createBy,
 			UpdateTime:    s.updateTime,
 			UpdateBy:      s.updateBy,
+			Status:        s.status,
+			BalanceLimit:  s.balanceLimit,
 		}
 		accounts[i] = bacc
 	}
@@ -644,9 +1140,13 @@ func (am *InMemoryAccountManager) ListAccountByCOA(context context.Context, coa
 // FindAccounts returns list of accounts that have their Name contains a substring of specified parameter.
 // this search should  be case insensitive.
 func (am *InMemoryAccountManager) FindAccounts(context context.Context, nameLike string, request PageRequest) (PageResult, []Account, error) {
+	store := inMemoryStoreOrDefault(am.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
 	resultSlice := make([]*InMemoryAccountRecord, 0)
 	lookup := strings.ToUpper(strings.ReplaceAll(nameLike, "%", ""))
-	for _, r := range InMemoryAccountTable {
+	for _, r := range store.accountTable {
 		if strings.Contains(strings.ToUpper(r.name), lookup) {
 			resultSlice = append(resultSlice, r)
 		}
@@ -671,6 +1171,8 @@ func (am *InMemoryAccountManager) FindAccounts(context context.Context, nameLike
 			CreateBy:      s.createBy,
 			UpdateTime:    s.updateTime,
 			UpdateBy:      s.updateBy,
+			Status:        s.status,
+			BalanceLimit:  s.balanceLimit,
 		}
 		accounts[i] = bacc
 	}
@@ -678,8 +1180,70 @@ func (am *InMemoryAccountManager) FindAccounts(context context.Context, nameLike
 	return pageResult, accounts, nil
 }
 
+// ChangeAccountStatus changes the account into the new status, recording the reason and author of the change.
+func (am *InMemoryAccountManager) ChangeAccountStatus(context context.Context, accountNumber string, newStatus AccountStatus, reason, author string) error {
+	if len(author) == 0 {
+		return ErrAccountStatusNoAuthor
+	}
+	store := inMemoryStoreOrDefault(am.store)
+	defer store.lock(context)()
+	accountRecord, exist := store.accountTable[accountNumber]
+	if !exist {
+		return ErrAccountIDNotFound
+	}
+	if !accountRecord.status.canTransit(newStatus) {
+		logrus.Errorf("error changing account %s status. can not change from %s into %s", accountNumber, accountRecord.status.String(), newStatus.String())
+		return ErrAccountStatusInvalid
+	}
+	if newStatus == AccountClosed && !accountRecord.balance.IsZero() {
+		logrus.Errorf("error closing account %s. account balance is %s", accountNumber, accountRecord.balance.String())
+		return ErrAccountCloseNonZero
+	}
+
+	change := &InMemoryAccountStatusRecords{
+		accountNumber: accountNumber,
+		fromStatus:    accountRecord.status,
+		toStatus:      newStatus,
+		reason:        reason,
+		changeTime:    time.Now(),
+		changeBy:      author,
+	}
+	previousStatus, updateTime, updateBy := accountRecord.status, accountRecord.updateTime, accountRecord.updateBy
+	accountRecord.status, accountRecord.updateTime, accountRecord.updateBy = newStatus, change.changeTime, author
+	store.accountStatusTable[accountNumber] = append(store.accountStatusTable[accountNumber], change)
+	store.onRollback(context, func() {
+		accountRecord.status, accountRecord.updateTime, accountRecord.updateBy = previousStatus, updateTime, updateBy
+		changes := store.accountStatusTable[accountNumber]
+		store.accountStatusTable[accountNumber] = changes[:len(changes)-1]
+	})
+	return nil
+}
+
+// ListAccountStatusChanges returns the status changes of the account, oldest first.
+func (am *InMemoryAccountManager) ListAccountStatusChanges(context context.Context, accountNumber string) ([]*AccountStatusChange, error) {
+	store := inMemoryStoreOrDefault(am.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if _, exist := store.accountTable[accountNumber]; !exist {
+		return nil, ErrAccountIDNotFound
+	}
+	changes := make([]*AccountStatusChange, 0, len(store.accountStatusTable[accountNumber]))
+	for _, record := range store.accountStatusTable[accountNumber] {
+		changes = append(changes, &AccountStatusChange{
+			AccountNumber: record.accountNumber,
+			From:          record.fromStatus,
+			To:            record.toStatus,
+			Reason:        record.reason,
+			ChangeTime:    record.changeTime,
+			ChangeBy:      record.changeBy,
+		})
+	}
+	return changes, nil
+}
+
 // InMemoryTransactionManager implementation of TransactionManager using inmemory Account table map
 type InMemoryTransactionManager struct {
+	store *InMemoryStore
 }
 
 // NewTransaction will create new blank un-persisted Transaction
@@ -689,13 +1253,19 @@ func (tm *InMemoryTransactionManager) NewTransaction(context context.Context) Tr
 
 // IsTransactionIDExist will check if an Transaction ID/number is exist in the database.
 func (tm *InMemoryTransactionManager) IsTransactionIDExist(context context.Context, id string) (bool, error) {
-	_, exist := InMemoryTransactionTable[id]
+	store := inMemoryStoreOrDefault(tm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	_, exist := store.transactionTable[id]
 	return exist, nil
 }
 
 // GetTransactionByID will retrieve one single transaction that identified by some ID
 func (tm *InMemoryTransactionManager) GetTransactionByID(context context.Context, id string) (Transaction, error) {
-	trx, exist := InMemoryTransactionTable[id]
+	store := inMemoryStoreOrDefault(tm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	trx, exist := store.transactionTable[id]
 	if !exist {
 		return nil, ErrTransactionNotFound
 	}
@@ -716,23 +1286,25 @@ func (tm *InMemoryTransactionManager) GetTransactionByID(context context.Context
 }
 
 // ListTransactionsOnAccount retrieves list of Transactions that belongs to this account
-// that transaction happens between the `from` and `until` time range.
+// that transaction happens between the `from` and `until` time range, in posting order.
 // This function uses pagination
 func (tm *InMemoryTransactionManager) ListTransactionsOnAccount(context context.Context, from time.Time, until time.Time, account Account, request PageRequest) (PageResult, []Transaction, error) {
+	store := inMemoryStoreOrDefault(tm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	// SELECT * FROM TRANSACTION WHERE ACCOUNT_NUMBER = {account.GetAccountNumber()} AND TRANSACTION_TIME >= {from} AND TRANSACTION_TIME <= {until}
 	resultRecord := make([]*InMemoryTransactionRecords, 0)
-	for _, trx := range InMemoryTransactionTable {
-		if trx.accountNumber == account.GetAccountNumber() {
+	for _, trx := range store.accountTransactions[account.GetAccountNumber()] {
+		if !trx.transactionTime.Before(from) && !trx.transactionTime.After(until) {
 			resultRecord = append(resultRecord, trx)
 		}
 	}
-	sort.SliceStable(resultRecord, func(i, j int) bool {
-		return resultRecord[i].createTime.Before(resultRecord[j].createTime)
-	})
 
 	pageResult := PageResultFor(request, len(resultRecord))
 
-	transactions := make([]Transaction, len(resultRecord))
-	for idx, trx := range resultRecord {
+	transactions := make([]Transaction, pageResult.PageSize)
+	for idx, trx := range resultRecord[pageResult.Offset : pageResult.Offset+pageResult.PageSize] {
 		transaction := &BaseTransaction{
 			TransactionID:   trx.transactionID,
 			TransactionTime: trx.transactionTime,
@@ -750,14 +1322,70 @@ func (tm *InMemoryTransactionManager) ListTransactionsOnAccount(context context.
 	return pageResult, transactions, nil
 }
 
-// RenderTransactionsOnAccount Render list of transaction been down on an account in a time span
-func (tm *InMemoryTransactionManager) RenderTransactionsOnAccount(context context.Context, from time.Time, until time.Time, account Account, request PageRequest) (string, error) {
+// GetAccountBalanceAt returns the account balance as of the specified time, after all transactions up to and including that time.
+// The balance is the current account balance with the transactions after that time taken back,
+// every transaction of the account is visited as they are kept in posting order.
+func (tm *InMemoryTransactionManager) GetAccountBalanceAt(context context.Context, account Account, at time.Time) (decimal.Decimal, error) {
+	store := inMemoryStoreOrDefault(tm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
 
-	result, transactions, err := tm.ListTransactionsOnAccount(context, from, until, account, request)
-	if err != nil {
-		return "Error rendering", err
+	accountRecord, exist := store.accountTable[account.GetAccountNumber()]
+	if !exist {
+		return decimal.Zero, ErrAccountIDNotFound
+	}
+	// a journal may be posted back dated, thus the transactions after the time are taken back from the current balance.
+	balance := accountRecord.balance
+	for _, trx := range store.accountTransactions[account.GetAccountNumber()] {
+		if trx.transactionTime.After(at) {
+			balance = balanceBefore(accountRecord.baseTransactionType, trx.transactionType, trx.amount, balance)
+		}
 	}
+	return balance, nil
+}
 
+// GetAccountBalanceRange returns the account opening balance, before any transaction in the `from` and `until` time range,
+// and the closing balance, after all transactions in that time range.
+func (tm *InMemoryTransactionManager) GetAccountBalanceRange(context context.Context, account Account, from time.Time, until time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	opening, err := tm.GetAccountBalanceAt(context, account, from.Add(-time.Nanosecond))
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	closing, err := tm.GetAccountBalanceAt(context, account, until)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	return opening, closing, nil
+}
+
+// balanceBefore returns the account balance before a transaction, from the account balance recorded on that transaction.
+func balanceBefore(accountAlignment, transactionAlignment Alignment, amount, accountBalance decimal.Decimal) decimal.Decimal {
+	if accountAlignment == transactionAlignment {
+		return accountBalance.Sub(amount)
+	}
+	return accountBalance.Add(amount)
+}
+
+// balanceAfter returns the account balance after the transaction, given the balance before it.
+func balanceAfter(accountAlignment, transactionAlignment Alignment, amount, accountBalance decimal.Decimal) decimal.Decimal {
+	if accountAlignment == transactionAlignment {
+		return accountBalance.Add(amount)
+	}
+	return accountBalance.Sub(amount)
+}
+
+// RenderTransactionsOnAccount Render list of transaction been down on an account in a time span
+func (tm *InMemoryTransactionManager) RenderTransactionsOnAccount(context context.Context, from time.Time, until time.Time, account Account, request PageRequest) (string, error) {
+	result, transactions, err := tm.ListTransactionsOnAccount(context, from, until, account, request)
+	if err != nil {
+		return "Error rendering", err
+	}
+	return renderTransactionsOnAccount(from, until, account, result, transactions), nil
+}
+
+// renderTransactionsOnAccount renders a page of transactions on an account into a table,
+// used by all TransactionManager implementations.
+func renderTransactionsOnAccount(from time.Time, until time.Time, account Account, result PageResult, transactions []Transaction) string {
 	var buff bytes.Buffer
 	table := tablewriter.NewWriter(&buff)
 	table.SetHeader([]string{"TRX ID", "TIME", "JOURNAL ID", "Description", "DEBIT", "CREDIT", "BALANCE"})
@@ -781,10 +1409,11 @@ func (tm *InMemoryTransactionManager) RenderTransactionsOnAccount(context contex
 	buff.WriteString(fmt.Sprintf("#Transactions     : %d\n", result.TotalEntries))
 	buff.WriteString(fmt.Sprintf("Showing page      : %d/%d\n", result.Page, result.TotalPages))
 	table.Render()
-	return buff.String(), err
+	return buff.String()
 }
 
-// NewInMemoryExchangeManager initializes a new excahnge manager in memory
+// NewInMemoryExchangeManager initializes a new excahnge manager in memory, working on the default store.
+// Use InMemoryStore.ExchangeManager to work on an isolated store.
 func NewInMemoryExchangeManager() ExchangeManager {
 	return &InMemoryExchangeManager{
 		commonDenominator: decimal.NewFromInt(1),
@@ -793,6 +1422,7 @@ func NewInMemoryExchangeManager() ExchangeManager {
 
 // InMemoryExchangeManager is a base implementation of ExchangeManager.
 type InMemoryExchangeManager struct {
+	store             *InMemoryStore
 	commonDenominator decimal.Decimal
 }
 
@@ -800,7 +1430,10 @@ type InMemoryExchangeManager struct {
 // non-existent Currency means that the Currency is not supported.
 // error should be thrown if only there's an underlying error such as db error.
 func (em *InMemoryExchangeManager) IsCurrencyExist(context context.Context, currency string) (bool, error) {
-	_, exist := InMemoryCurrencyTable[currency]
+	store := inMemoryStoreOrDefault(em.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	_, exist := store.currencyTable[currency]
 	return exist, nil
 }
 
@@ -816,7 +1449,10 @@ func (em *InMemoryExchangeManager) SetDenom(context context.Context, denom decim
 
 // GetCurrency retrieve currency data indicated by the code argument
 func (em *InMemoryExchangeManager) GetCurrency(context context.Context, code string) (Currency, error) {
-	if curRec, exist := InMemoryCurrencyTable[code]; exist {
+	store := inMemoryStoreOrDefault(em.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if curRec, exist := store.currencyTable[code]; exist {
 		cur := &BaseCurrency{
 			Code:       curRec.code,
 			Name:       curRec.name,
@@ -835,7 +1471,9 @@ func (em *InMemoryExchangeManager) GetCurrency(context context.Context, code str
 // CreateCurrency set the specified value as denominator value for that speciffic Currency.
 // This function should return error if the Currency specified is not exist.
 func (em *InMemoryExchangeManager) CreateCurrency(context context.Context, code, name string, exchange decimal.Decimal, author string) (Currency, error) {
-	if _, exist := InMemoryCurrencyTable[code]; exist {
+	store := inMemoryStoreOrDefault(em.store)
+	defer store.lock(context)()
+	if _, exist := store.currencyTable[code]; exist {
 		return nil, ErrCurrencyAlreadyPersisted
 	}
 	bc := &InMemoryCurrencyRecords{
@@ -847,7 +1485,13 @@ func (em *InMemoryExchangeManager) CreateCurrency(context context.Context, code,
 		updateTime: time.Now(),
 		updateBy:   author,
 	}
-	InMemoryCurrencyTable[code] = bc
+	store.currencyTable[code] = bc
+	store.onRollback(context, func() {
+		delete(store.currencyTable, code)
+	})
+	if err := store.addCurrencyRate(context, code, exchange, bc.createTime, author); err != nil {
+		return nil, err
+	}
 	return &BaseCurrency{
 		Code:       code,
 		Name:       name,
@@ -862,15 +1506,24 @@ func (em *InMemoryExchangeManager) CreateCurrency(context context.Context, code,
 // UpdateCurrency updates the currency data
 // Error should be returned if the specified Currency is not exist.
 func (em *InMemoryExchangeManager) UpdateCurrency(context context.Context, code string, currency Currency, author string) error {
-	curr, exist := InMemoryCurrencyTable[code]
+	store := inMemoryStoreOrDefault(em.store)
+	defer store.lock(context)()
+	curr, exist := store.currencyTable[code]
 	if !exist {
 		return ErrCurrencyNotFound
 	}
+	previousRecord := *curr
+	store.onRollback(context, func() {
+		*curr = previousRecord
+	})
 	curr.exchange = currency.GetExchange()
 	curr.name = currency.GetName()
 	curr.exchange = currency.GetExchange()
 	curr.updateBy = author
 	curr.updateTime = time.Now()
+	if err := store.addCurrencyRate(context, code, curr.exchange, curr.updateTime, author); err != nil {
+		return err
+	}
 
 	currency.SetCode(code)
 	return nil
@@ -910,10 +1563,127 @@ func (em *InMemoryExchangeManager) CalculateExchange(context context.Context, fr
 	return m1, nil
 }
 
+// AddCurrencyRate records the currency exchange value effective from the validFrom time, keeping the other rates as history.
+// If the new rate is the latest one already in effect, it also becomes the currency exchange value.
+func (em *InMemoryExchangeManager) AddCurrencyRate(context context.Context, code string, exchange decimal.Decimal, validFrom time.Time, author string) error {
+	store := inMemoryStoreOrDefault(em.store)
+	defer store.lock(context)()
+	curr, exist := store.currencyTable[code]
+	if !exist {
+		return ErrCurrencyNotFound
+	}
+	if err := store.addCurrencyRate(context, code, exchange, validFrom, author); err != nil {
+		return err
+	}
+	if rate := store.getCurrencyRateAt(code, time.Now()); rate != nil && rate.validFrom.Equal(validFrom) {
+		previousRecord := *curr
+		store.onRollback(context, func() {
+			*curr = previousRecord
+		})
+		curr.exchange = exchange
+		curr.updateBy = author
+		curr.updateTime = time.Now()
+	}
+	return nil
+}
+
+// addCurrencyRate inserts the rate into the currency rate history.
+// The caller must hold the store lock.
+func (store *InMemoryStore) addCurrencyRate(context context.Context, code string, exchange decimal.Decimal, validFrom time.Time, author string) error {
+	rates := store.currencyRateTable[code]
+	idx := sort.Search(len(rates), func(i int) bool {
+		return !rates[i].validFrom.Before(validFrom)
+	})
+	if idx < len(rates) && rates[idx].validFrom.Equal(validFrom) {
+		return ErrCurrencyRateAlreadyExist
+	}
+	rate := &InMemoryCurrencyRateRecords{
+		code:       code,
+		exchange:   exchange,
+		validFrom:  validFrom,
+		createTime: time.Now(),
+		createBy:   author,
+	}
+	newRates := make([]*InMemoryCurrencyRateRecords, 0, len(rates)+1)
+	newRates = append(append(append(newRates, rates[:idx]...), rate), rates[idx:]...)
+	store.currencyRateTable[code] = newRates
+	store.onRollback(context, func() {
+		store.currencyRateTable[code] = rates
+	})
+	return nil
+}
+
+// getCurrencyRateAt returns the rate of the currency valid at the time, nil if there is none.
+// The caller must hold the store lock.
+func (store *InMemoryStore) getCurrencyRateAt(code string, at time.Time) *InMemoryCurrencyRateRecords {
+	rates := store.currencyRateTable[code]
+	idx := sort.Search(len(rates), func(i int) bool {
+		return rates[i].validFrom.After(at)
+	})
+	if idx == 0 {
+		return nil
+	}
+	return rates[idx-1]
+}
+
+// ListCurrencyRates list the exchange rate history of the currency, ordered by their valid from time.
+func (em *InMemoryExchangeManager) ListCurrencyRates(context context.Context, code string) ([]*CurrencyRate, error) {
+	store := inMemoryStoreOrDefault(em.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if _, exist := store.currencyTable[code]; !exist {
+		return nil, ErrCurrencyNotFound
+	}
+	rates := make([]*CurrencyRate, 0, len(store.currencyRateTable[code]))
+	for _, rate := range store.currencyRateTable[code] {
+		rates = append(rates, &CurrencyRate{
+			Code:       rate.code,
+			Exchange:   rate.exchange,
+			ValidFrom:  rate.validFrom,
+			CreateTime: rate.createTime,
+			CreateBy:   rate.createBy,
+		})
+	}
+	return rates, nil
+}
+
+// CalculateExchangeRateAt is CalculateExchangeRate using the currency rates valid at the specified time.
+func (em *InMemoryExchangeManager) CalculateExchangeRateAt(context context.Context, fromCurrency, toCurrency string, at time.Time) (decimal.Decimal, error) {
+	store := inMemoryStoreOrDefault(em.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	exchanges := make([]decimal.Decimal, 2)
+	for idx, code := range []string{fromCurrency, toCurrency} {
+		if _, exist := store.currencyTable[code]; !exist {
+			return decimal.Zero, ErrCurrencyNotFound
+		}
+		rate := store.getCurrencyRateAt(code, at)
+		if rate == nil {
+			logrus.Errorf("error calculating exchange rate. currency %s has no rate valid at %s", code, at.String())
+			return decimal.Zero, ErrCurrencyRateNotFound
+		}
+		exchanges[idx] = rate.exchange
+	}
+	return exchangeRateOf(em.GetDenom(context), exchanges[0], exchanges[1]), nil
+}
+
+// CalculateExchangeAt is CalculateExchange using the currency rates valid at the specified time.
+func (em *InMemoryExchangeManager) CalculateExchangeAt(context context.Context, fromCurrency, toCurrency string, amount decimal.Decimal, at time.Time) (decimal.Decimal, error) {
+	exchange, err := em.CalculateExchangeRateAt(context, fromCurrency, toCurrency, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return exchange.Mul(amount), nil
+}
+
 // ListCurrencies will list all currencies.
 func (em *InMemoryExchangeManager) ListCurrencies(context context.Context) ([]Currency, error) {
+	store := inMemoryStoreOrDefault(em.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
 	ret := make([]Currency, 0)
-	for _, cur := range InMemoryCurrencyTable {
+	for _, cur := range store.currencyTable {
 		rec := &BaseCurrency{
 			Code:       cur.code,
 			Name:       cur.name,
@@ -927,3 +1697,964 @@ func (em *InMemoryExchangeManager) ListCurrencies(context context.Context) ([]Cu
 	}
 	return ret, nil
 }
+
+// InMemoryPeriodManager implementation of PeriodManager using inmemory Period table map
+type InMemoryPeriodManager struct {
+	store *InMemoryStore
+}
+
+// OpenPeriod creates a new open period between `from` and `until` time range inclusive.
+// Error should be returned if the period overlaps with another period.
+func (pm *InMemoryPeriodManager) OpenPeriod(context context.Context, periodID, description string, from, until time.Time, author string) (*Period, error) {
+	if len(periodID) == 0 {
+		return nil, ErrPeriodMissingID
+	}
+	if !from.Before(until) {
+		return nil, ErrPeriodInvalidRange
+	}
+	store := inMemoryStoreOrDefault(pm.store)
+	defer store.lock(context)()
+	if _, exist := store.periodTable[periodID]; exist {
+		return nil, ErrPeriodAlreadyPersisted
+	}
+	for _, rec := range store.periodTable {
+		if !rec.from.After(until) && !from.After(rec.until) {
+			logrus.Errorf("error opening period %s. it overlaps with period %s", periodID, rec.periodID)
+			return nil, ErrPeriodOverlap
+		}
+	}
+	rec := &InMemoryPeriodRecords{
+		periodID:          periodID,
+		description:       description,
+		from:              from,
+		until:             until,
+		status:            PeriodOpen,
+		closingJournalIDs: make([]string, 0),
+		createTime:        time.Now(),
+		createBy:          author,
+		updateTime:        time.Now(),
+		updateBy:          author,
+	}
+	store.periodTable[periodID] = rec
+	store.onRollback(context, func() {
+		delete(store.periodTable, periodID)
+	})
+	return rec.toPeriod(), nil
+}
+
+// ClosePeriod closes an open period, recording the closing journals posted for it.
+func (pm *InMemoryPeriodManager) ClosePeriod(context context.Context, periodID string, closingJournalIDs []string, author string) error {
+	return pm.changeStatus(context, periodID, PeriodClosed, closingJournalIDs, author)
+}
+
+// ReopenPeriod opens back a closed period. Locked period can not be reopened.
+func (pm *InMemoryPeriodManager) ReopenPeriod(context context.Context, periodID, author string) error {
+	return pm.changeStatus(context, periodID, PeriodOpen, nil, author)
+}
+
+// LockPeriod locks a closed period for good.
+func (pm *InMemoryPeriodManager) LockPeriod(context context.Context, periodID, author string) error {
+	return pm.changeStatus(context, periodID, PeriodLocked, nil, author)
+}
+
+func (pm *InMemoryPeriodManager) changeStatus(context context.Context, periodID string, status PeriodStatus, closingJournalIDs []string, author string) error {
+	store := inMemoryStoreOrDefault(pm.store)
+	defer store.lock(context)()
+	rec, exist := store.periodTable[periodID]
+	if !exist {
+		return ErrPeriodNotFound
+	}
+	if !rec.status.canTransit(status) {
+		logrus.Errorf("error changing period %s status. can not change from %s to %s", periodID, rec.status.String(), status.String())
+		return ErrPeriodInvalidTransition
+	}
+	previousRecord := *rec
+	store.onRollback(context, func() {
+		*rec = previousRecord
+	})
+	rec.status = status
+	rec.closingJournalIDs = append(append(make([]string, 0, len(rec.closingJournalIDs)+len(closingJournalIDs)), rec.closingJournalIDs...), closingJournalIDs...)
+	rec.updateTime = time.Now()
+	rec.updateBy = author
+	return nil
+}
+
+// GetPeriodByID retrieve a period identified by its ID.
+func (pm *InMemoryPeriodManager) GetPeriodByID(context context.Context, periodID string) (*Period, error) {
+	store := inMemoryStoreOrDefault(pm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if rec, exist := store.periodTable[periodID]; exist {
+		return rec.toPeriod(), nil
+	}
+	return nil, ErrPeriodNotFound
+}
+
+// GetPeriodAt retrieve the period containing the specified time, ErrPeriodNotFound if there is none.
+func (pm *InMemoryPeriodManager) GetPeriodAt(context context.Context, at time.Time) (*Period, error) {
+	store := inMemoryStoreOrDefault(pm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if rec := store.getPeriodAt(at); rec != nil {
+		return rec.toPeriod(), nil
+	}
+	return nil, ErrPeriodNotFound
+}
+
+// getPeriodAt returns the period record containing the time, nil if there is none.
+// The caller must hold the store lock.
+func (store *InMemoryStore) getPeriodAt(at time.Time) *InMemoryPeriodRecords {
+	for _, rec := range store.periodTable {
+		if !at.Before(rec.from) && !at.After(rec.until) {
+			return rec
+		}
+	}
+	return nil
+}
+
+// ListPeriods list all periods ordered by their start time.
+func (pm *InMemoryPeriodManager) ListPeriods(context context.Context) ([]*Period, error) {
+	store := inMemoryStoreOrDefault(pm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	periods := make([]*Period, 0, len(store.periodTable))
+	for _, rec := range store.periodTable {
+		periods = append(periods, rec.toPeriod())
+	}
+	sort.SliceStable(periods, func(i, j int) bool {
+		return periods[i].From.Before(periods[j].From)
+	})
+	return periods, nil
+}
+
+func (rec *InMemoryPeriodRecords) toPeriod() *Period {
+	closingJournalIDs := make([]string, len(rec.closingJournalIDs))
+	copy(closingJournalIDs, rec.closingJournalIDs)
+	return &Period{
+		PeriodID:          rec.periodID,
+		Description:       rec.description,
+		From:              rec.from,
+		Until:             rec.until,
+		Status:            rec.status,
+		ClosingJournalIDs: closingJournalIDs,
+		CreateTime:        rec.createTime,
+		CreateBy:          rec.createBy,
+		UpdateTime:        rec.updateTime,
+		UpdateBy:          rec.updateBy,
+	}
+}
+
+// InMemoryHoldManager implementation of HoldManager using inmemory Hold table map
+type InMemoryHoldManager struct {
+	store *InMemoryStore
+}
+
+// PlaceHold reserves the amount of the account balance until the expiry time.
+// The amount must be positive, the expiry in the future, and the account available balance must cover the amount.
+func (hm *InMemoryHoldManager) PlaceHold(context context.Context, holdID, accountNumber string, amount decimal.Decimal, expiry time.Time, description, author string) (*Hold, error) {
+	if len(holdID) == 0 {
+		return nil, ErrHoldMissingID
+	}
+	if !amount.IsPositive() {
+		return nil, ErrHoldAmountNotPositive
+	}
+	now := time.Now()
+	if !now.Before(expiry) {
+		return nil, ErrHoldExpiryPassed
+	}
+	store := inMemoryStoreOrDefault(hm.store)
+	defer store.lock(context)()
+	if _, exist := store.holdTable[holdID]; exist {
+		return nil, ErrHoldAlreadyPersisted
+	}
+	accountRecord, exist := store.accountTable[accountNumber]
+	if !exist {
+		return nil, ErrAccountIDNotFound
+	}
+	if available := accountRecord.balance.Sub(store.heldAmount(accountNumber, now)); available.LessThan(amount) {
+		logrus.Errorf("error placing hold %s. account %s available balance %s does not cover %s", holdID, accountNumber, available.String(), amount.String())
+		return nil, ErrHoldInsufficientBalance
+	}
+	rec := &InMemoryHoldRecords{
+		holdID:         holdID,
+		accountNumber:  accountNumber,
+		amount:         amount,
+		capturedAmount: decimal.Zero,
+		status:         HoldActive,
+		expiryTime:     expiry,
+		description:    description,
+		createTime:     now,
+		createBy:       author,
+		updateTime:     now,
+		updateBy:       author,
+	}
+	store.holdTable[holdID] = rec
+	store.onRollback(context, func() {
+		delete(store.holdTable, holdID)
+	})
+	return rec.toHold(), nil
+}
+
+// CaptureHold marks an active, not expired hold captured by the journal, posting the amount out of the held amount.
+// The journal itself is persisted by the caller, within the same unit of work.
+func (hm *InMemoryHoldManager) CaptureHold(context context.Context, holdID string, amount decimal.Decimal, journalID, author string) error {
+	store := inMemoryStoreOrDefault(hm.store)
+	defer store.lock(context)()
+	rec, exist := store.holdTable[holdID]
+	if !exist {
+		return ErrHoldNotFound
+	}
+	if !rec.toHold().IsActiveAt(time.Now()) {
+		logrus.Errorf("error capturing hold %s. hold is %s and expires at %s", holdID, rec.status.String(), rec.expiryTime.String())
+		return ErrHoldNotActive
+	}
+	if !amount.IsPositive() || amount.GreaterThan(rec.amount) {
+		return ErrHoldCaptureAmountInvalid
+	}
+	store.changeHoldStatus(context, rec, HoldCaptured, author)
+	rec.capturedAmount = amount
+	rec.journalID = journalID
+	return nil
+}
+
+// ReleaseHold marks an active hold released.
+func (hm *InMemoryHoldManager) ReleaseHold(context context.Context, holdID, author string) error {
+	store := inMemoryStoreOrDefault(hm.store)
+	defer store.lock(context)()
+	rec, exist := store.holdTable[holdID]
+	if !exist {
+		return ErrHoldNotFound
+	}
+	if !rec.status.canTransit(HoldReleased) {
+		logrus.Errorf("error releasing hold %s. hold is %s", holdID, rec.status.String())
+		return ErrHoldNotActive
+	}
+	store.changeHoldStatus(context, rec, HoldReleased, author)
+	return nil
+}
+
+// ExpireHolds marks every active hold whose expiry time is not after `at` expired, returning them.
+func (hm *InMemoryHoldManager) ExpireHolds(context context.Context, at time.Time, author string) ([]*Hold, error) {
+	store := inMemoryStoreOrDefault(hm.store)
+	defer store.lock(context)()
+	expired := make([]*Hold, 0)
+	for _, rec := range store.holdTable {
+		if rec.status == HoldActive && !at.Before(rec.expiryTime) {
+			store.changeHoldStatus(context, rec, HoldExpired, author)
+			expired = append(expired, rec.toHold())
+		}
+	}
+	sortHolds(expired)
+	return expired, nil
+}
+
+// changeHoldStatus changes the hold record status, undoing the whole record change on rollback.
+// The caller must hold the store lock.
+func (store *InMemoryStore) changeHoldStatus(context context.Context, rec *InMemoryHoldRecords, status HoldStatus, author string) {
+	previousRecord := *rec
+	store.onRollback(context, func() {
+		*rec = previousRecord
+	})
+	rec.status = status
+	rec.updateTime = time.Now()
+	rec.updateBy = author
+}
+
+// GetHold retrieve a hold identified by its ID.
+func (hm *InMemoryHoldManager) GetHold(context context.Context, holdID string) (*Hold, error) {
+	store := inMemoryStoreOrDefault(hm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if rec, exist := store.holdTable[holdID]; exist {
+		return rec.toHold(), nil
+	}
+	return nil, ErrHoldNotFound
+}
+
+// ListActiveHolds list the holds of the account active at the time, ordered by their expiry time.
+func (hm *InMemoryHoldManager) ListActiveHolds(context context.Context, accountNumber string, at time.Time) ([]*Hold, error) {
+	store := inMemoryStoreOrDefault(hm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if _, exist := store.accountTable[accountNumber]; !exist {
+		return nil, ErrAccountIDNotFound
+	}
+	return store.activeHolds(accountNumber, at), nil
+}
+
+// GetAvailableBalance returns the account balance minus the amount of its holds active at the time.
+func (hm *InMemoryHoldManager) GetAvailableBalance(context context.Context, accountNumber string, at time.Time) (decimal.Decimal, error) {
+	store := inMemoryStoreOrDefault(hm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	accountRecord, exist := store.accountTable[accountNumber]
+	if !exist {
+		return decimal.Zero, ErrAccountIDNotFound
+	}
+	return accountRecord.balance.Sub(store.heldAmount(accountNumber, at)), nil
+}
+
+// activeHolds returns the holds of the account active at the time, ordered by their expiry time.
+// The caller must hold the store lock.
+func (store *InMemoryStore) activeHolds(accountNumber string, at time.Time) []*Hold {
+	holds := make([]*Hold, 0)
+	for _, rec := range store.holdTable {
+		if rec.accountNumber != accountNumber {
+			continue
+		}
+		if hold := rec.toHold(); hold.IsActiveAt(at) {
+			holds = append(holds, hold)
+		}
+	}
+	sortHolds(holds)
+	return holds
+}
+
+// heldAmount returns the amount of the account holds active at the time. The caller must hold the store lock.
+func (store *InMemoryStore) heldAmount(accountNumber string, at time.Time) decimal.Decimal {
+	return heldAmount(store.activeHolds(accountNumber, at), at)
+}
+
+// sortHolds orders the holds by their expiry time, then by their ID.
+func sortHolds(holds []*Hold) {
+	sort.SliceStable(holds, func(i, j int) bool {
+		if holds[i].ExpiryTime.Equal(holds[j].ExpiryTime) {
+			return holds[i].HoldID < holds[j].HoldID
+		}
+		return holds[i].ExpiryTime.Before(holds[j].ExpiryTime)
+	})
+}
+
+func (rec *InMemoryHoldRecords) toHold() *Hold {
+	return &Hold{
+		HoldID:         rec.holdID,
+		AccountNumber:  rec.accountNumber,
+		Amount:         rec.amount,
+		CapturedAmount: rec.capturedAmount,
+		Status:         rec.status,
+		ExpiryTime:     rec.expiryTime,
+		Description:    rec.description,
+		JournalID:      rec.journalID,
+		CreateTime:     rec.createTime,
+		CreateBy:       rec.createBy,
+		UpdateTime:     rec.updateTime,
+		UpdateBy:       rec.updateBy,
+	}
+}
+
+// InMemoryOutboxManager implementation of OutboxManager using inmemory Outbox table
+type InMemoryOutboxManager struct {
+	store *InMemoryStore
+}
+
+// ReadOutbox returns at most `limit` events after the `afterOffset`, in offset order.
+func (om *InMemoryOutboxManager) ReadOutbox(context context.Context, afterOffset int64, limit int) ([]*OutboxEvent, error) {
+	store := inMemoryStoreOrDefault(om.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	events := make([]*OutboxEvent, 0)
+	// offsets start from 1 without gaps, so the event after the offset is at its index
+	for i := int(afterOffset); i >= 0 && i < len(store.outboxTable) && len(events) < limit; i++ {
+		event := *store.outboxTable[i]
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+// GetSubscriberOffset returns the offset of the last event delivered to the subscriber, zero if none was delivered.
+func (om *InMemoryOutboxManager) GetSubscriberOffset(context context.Context, subscriber string) (int64, error) {
+	store := inMemoryStoreOrDefault(om.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.outboxOffsetTable[subscriber], nil
+}
+
+// SetSubscriberOffset records the offset of the last event delivered to the subscriber.
+func (om *InMemoryOutboxManager) SetSubscriberOffset(context context.Context, subscriber string, offset int64) error {
+	store := inMemoryStoreOrDefault(om.store)
+	defer store.lock(context)()
+	previous, exist := store.outboxOffsetTable[subscriber]
+	store.outboxOffsetTable[subscriber] = offset
+	store.onRollback(context, func() {
+		if exist {
+			store.outboxOffsetTable[subscriber] = previous
+		} else {
+			delete(store.outboxOffsetTable, subscriber)
+		}
+	})
+	return nil
+}
+
+// InMemoryScheduleManager implementation of ScheduleManager using inmemory Schedule table map
+type InMemoryScheduleManager struct {
+	store *InMemoryStore
+}
+
+// CreateSchedule creates a schedule posting the transactions on every occurrence of the recurrence between start and end inclusive.
+// A zero end never ends the schedule.
+func (sm *InMemoryScheduleManager) CreateSchedule(context context.Context, scheduleID, description string, transactions []TransactionInfo, recurrence Recurrence, start, end time.Time, author string) (*JournalSchedule, error) {
+	if err := validateSchedule(scheduleID, transactions, recurrence, start, end); err != nil {
+		return nil, err
+	}
+	store := inMemoryStoreOrDefault(sm.store)
+	defer store.lock(context)()
+	if _, exist := store.scheduleTable[scheduleID]; exist {
+		return nil, ErrScheduleAlreadyPersisted
+	}
+	rec := &InMemoryScheduleRecords{
+		scheduleID:   scheduleID,
+		description:  description,
+		transactions: append(make([]TransactionInfo, 0, len(transactions)), transactions...),
+		recurrence:   recurrence,
+		startTime:    start,
+		endTime:      end,
+		nextSequence: 0,
+		createTime:   time.Now(),
+		createBy:     author,
+		updateTime:   time.Now(),
+		updateBy:     author,
+	}
+	store.scheduleTable[scheduleID] = rec
+	store.onRollback(context, func() {
+		delete(store.scheduleTable, scheduleID)
+	})
+	return rec.toSchedule(), nil
+}
+
+// GetSchedule retrieve a schedule identified by its ID.
+func (sm *InMemoryScheduleManager) GetSchedule(context context.Context, scheduleID string) (*JournalSchedule, error) {
+	store := inMemoryStoreOrDefault(sm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if rec, exist := store.scheduleTable[scheduleID]; exist {
+		return rec.toSchedule(), nil
+	}
+	return nil, ErrScheduleNotFound
+}
+
+// ListSchedules list all schedules ordered by their start time.
+func (sm *InMemoryScheduleManager) ListSchedules(context context.Context) ([]*JournalSchedule, error) {
+	store := inMemoryStoreOrDefault(sm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	schedules := make([]*JournalSchedule, 0, len(store.scheduleTable))
+	for _, rec := range store.scheduleTable {
+		schedules = append(schedules, rec.toSchedule())
+	}
+	sort.SliceStable(schedules, func(i, j int) bool {
+		if schedules[i].StartTime.Equal(schedules[j].StartTime) {
+			return schedules[i].ScheduleID < schedules[j].ScheduleID
+		}
+		return schedules[i].StartTime.Before(schedules[j].StartTime)
+	})
+	return schedules, nil
+}
+
+// RecordScheduleRun records the outcome of a schedule run. A posted run moves the schedule NextSequence past its occurrence.
+func (sm *InMemoryScheduleManager) RecordScheduleRun(context context.Context, run *ScheduleRun) error {
+	store := inMemoryStoreOrDefault(sm.store)
+	defer store.lock(context)()
+	rec, exist := store.scheduleTable[run.ScheduleID]
+	if !exist {
+		return ErrScheduleNotFound
+	}
+	recorded := *run
+	previousRecord := *rec
+	store.scheduleRunTable[run.ScheduleID] = append(store.scheduleRunTable[run.ScheduleID], &recorded)
+	if run.Status == ScheduleRunPosted && run.Sequence >= rec.nextSequence {
+		rec.nextSequence = run.Sequence + 1
+		rec.updateTime = time.Now()
+		rec.updateBy = run.RunBy
+	}
+	store.onRollback(context, func() {
+		*rec = previousRecord
+		runs := store.scheduleRunTable[run.ScheduleID]
+		store.scheduleRunTable[run.ScheduleID] = runs[:len(runs)-1]
+	})
+	return nil
+}
+
+// ListScheduleRuns returns the runs of the schedule in the order they were recorded.
+func (sm *InMemoryScheduleManager) ListScheduleRuns(context context.Context, scheduleID string) ([]*ScheduleRun, error) {
+	store := inMemoryStoreOrDefault(sm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if _, exist := store.scheduleTable[scheduleID]; !exist {
+		return nil, ErrScheduleNotFound
+	}
+	runs := make([]*ScheduleRun, 0, len(store.scheduleRunTable[scheduleID]))
+	for _, run := range store.scheduleRunTable[scheduleID] {
+		recorded := *run
+		runs = append(runs, &recorded)
+	}
+	return runs, nil
+}
+
+func (rec *InMemoryScheduleRecords) toSchedule() *JournalSchedule {
+	return &JournalSchedule{
+		ScheduleID:   rec.scheduleID,
+		Description:  rec.description,
+		Transactions: append(make([]TransactionInfo, 0, len(rec.transactions)), rec.transactions...),
+		Recurrence:   rec.recurrence,
+		StartTime:    rec.startTime,
+		EndTime:      rec.endTime,
+		NextSequence: rec.nextSequence,
+		CreateTime:   rec.createTime,
+		CreateBy:     rec.createBy,
+		UpdateTime:   rec.updateTime,
+		UpdateBy:     rec.updateBy,
+	}
+}
+
+// InMemoryLotManager implementation of LotManager using inmemory Lot table map
+type InMemoryLotManager struct {
+	store *InMemoryStore
+}
+
+// CreateLot records a new open lot, its remaining amount must be the lot amount.
+func (lm *InMemoryLotManager) CreateLot(context context.Context, lot *Lot) error {
+	if !lot.Amount.IsPositive() {
+		return ErrLotAmountNotPositive
+	}
+	store := inMemoryStoreOrDefault(lm.store)
+	defer store.lock(context)()
+	if _, exist := store.lotTable[lot.LotID]; exist {
+		return ErrLotAlreadyPersisted
+	}
+	if _, exist := store.accountTable[lot.AccountNumber]; !exist {
+		return ErrAccountIDNotFound
+	}
+	rec := &InMemoryLotRecords{
+		lotID:         lot.LotID,
+		accountNumber: lot.AccountNumber,
+		journalID:     lot.JournalID,
+		amount:        lot.Amount,
+		remaining:     lot.Amount,
+		status:        LotOpen,
+		expiryTime:    lot.ExpiryTime,
+		createTime:    time.Now(),
+		createBy:      lot.CreateBy,
+		updateTime:    time.Now(),
+		updateBy:      lot.CreateBy,
+	}
+	store.lotTable[rec.lotID] = rec
+	store.accountLots[rec.accountNumber] = append(store.accountLots[rec.accountNumber], rec)
+	store.onRollback(context, func() {
+		delete(store.lotTable, rec.lotID)
+		lots := store.accountLots[rec.accountNumber]
+		store.accountLots[rec.accountNumber] = lots[:len(lots)-1]
+	})
+	return nil
+}
+
+// ConsumeLots takes the amount out of the account open lots not expired at the time, first in first out, returning the lots changed.
+func (lm *InMemoryLotManager) ConsumeLots(context context.Context, accountNumber string, amount decimal.Decimal, at time.Time, author string) ([]*Lot, error) {
+	store := inMemoryStoreOrDefault(lm.store)
+	defer store.lock(context)()
+	consumed, err := consumeLots(store.listLots(accountNumber, true), amount, at)
+	if err != nil {
+		logrus.Errorf("error consuming %s from account %s lots. got %s", amount.String(), accountNumber, err.Error())
+		return nil, err
+	}
+	for _, lot := range consumed {
+		store.updateLot(context, lot, author)
+	}
+	return consumed, nil
+}
+
+// ExpireLots marks the account open lots whose expiry time is not after `at` expired by the expiry journal, returning them.
+func (lm *InMemoryLotManager) ExpireLots(context context.Context, accountNumber string, at time.Time, expiryJournalID, author string) ([]*Lot, error) {
+	store := inMemoryStoreOrDefault(lm.store)
+	defer store.lock(context)()
+	expired := make([]*Lot, 0)
+	for _, lot := range store.listLots(accountNumber, true) {
+		if at.Before(lot.ExpiryTime) {
+			continue
+		}
+		lot.Status = LotExpired
+		lot.ExpiryJournalID = expiryJournalID
+		store.updateLot(context, lot, author)
+		expired = append(expired, lot)
+	}
+	return expired, nil
+}
+
+// updateLot writes the lot remaining amount, status and expiry journal into its record. The caller must hold the store lock.
+func (store *InMemoryStore) updateLot(context context.Context, lot *Lot, author string) {
+	rec := store.lotTable[lot.LotID]
+	previousRecord := *rec
+	store.onRollback(context, func() {
+		*rec = previousRecord
+	})
+	rec.remaining = lot.Remaining
+	rec.status = lot.Status
+	rec.expiryJournalID = lot.ExpiryJournalID
+	rec.updateTime = time.Now()
+	rec.updateBy = author
+	lot.UpdateTime, lot.UpdateBy = rec.updateTime, rec.updateBy
+}
+
+// ListLots list all lots of the account, first in first out.
+func (lm *InMemoryLotManager) ListLots(context context.Context, accountNumber string) ([]*Lot, error) {
+	store := inMemoryStoreOrDefault(lm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if _, exist := store.accountTable[accountNumber]; !exist {
+		return nil, ErrAccountIDNotFound
+	}
+	return store.listLots(accountNumber, false), nil
+}
+
+// ListLotsExpiringBefore list the account open lots expiring before the time, first in first out.
+func (lm *InMemoryLotManager) ListLotsExpiringBefore(context context.Context, accountNumber string, before time.Time) ([]*Lot, error) {
+	store := inMemoryStoreOrDefault(lm.store)
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if _, exist := store.accountTable[accountNumber]; !exist {
+		return nil, ErrAccountIDNotFound
+	}
+	lots := make([]*Lot, 0)
+	for _, lot := range store.listLots(accountNumber, true) {
+		if lot.ExpiryTime.Before(before) {
+			lots = append(lots, lot)
+		}
+	}
+	return lots, nil
+}
+
+// listLots returns the account lots first in first out, only the open ones if openOnly. The caller must hold the store lock.
+func (store *InMemoryStore) listLots(accountNumber string, openOnly bool) []*Lot {
+	lots := make([]*Lot, 0, len(store.accountLots[accountNumber]))
+	for _, rec := range store.accountLots[accountNumber] {
+		if !openOnly || rec.status == LotOpen {
+			lots = append(lots, rec.toLot())
+		}
+	}
+	sort.SliceStable(lots, func(i, j int) bool {
+		return lots[i].ExpiryTime.Before(lots[j].ExpiryTime)
+	})
+	return lots
+}
+
+func (rec *InMemoryLotRecords) toLot() *Lot {
+	return &Lot{
+		LotID:           rec.lotID,
+		AccountNumber:   rec.accountNumber,
+		JournalID:       rec.journalID,
+		Amount:          rec.amount,
+		Remaining:       rec.remaining,
+		Status:          rec.status,
+		ExpiryTime:      rec.expiryTime,
+		ExpiryJournalID: rec.expiryJournalID,
+		CreateTime:      rec.createTime,
+		CreateBy:        rec.createBy,
+		UpdateTime:      rec.updateTime,
+		UpdateBy:        rec.updateBy,
+	}
+}
+
+// applyLedgerEvent applies the event of an EventSourcedStore into the tables, without validating it again.
+// Journals move the account balances, each transaction account balance is derived from the balance before it.
+func (store *InMemoryStore) applyLedgerEvent(event *LedgerEvent) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	switch event.Type {
+	case EventAccountOpened, EventAccountUpdated:
+		data := &ledgerAccountData{}
+		if err := json.Unmarshal(event.Payload, data); err != nil {
+			return err
+		}
+		record := &InMemoryAccountRecord{
+			currency:            data.Currency,
+			id:                  data.AccountNumber,
+			name:                data.Name,
+			description:         data.Description,
+			baseTransactionType: data.Alignment,
+			balance:             data.Balance,
+			coa:                 data.COA,
+			createTime:          data.CreateTime,
+			createBy:            data.CreateBy,
+			updateTime:          data.UpdateTime,
+			updateBy:            data.UpdateBy,
+			status:              data.Status,
+			balanceLimit:        data.BalanceLimit,
+		}
+		if event.Type == EventAccountUpdated {
+			previous, exist := store.accountTable[data.AccountNumber]
+			if !exist {
+				return ErrAccountIsNotPersisted
+			}
+			record.balance, record.status = previous.balance, previous.status
+		}
+		store.accountTable[record.id] = record
+	case EventAccountStatusChanged:
+		change := &AccountStatusChange{}
+		if err := json.Unmarshal(event.Payload, change); err != nil {
+			return err
+		}
+		accountRecord, exist := store.accountTable[change.AccountNumber]
+		if !exist {
+			return ErrAccountIDNotFound
+		}
+		accountRecord.status, accountRecord.updateTime, accountRecord.updateBy = change.To, change.ChangeTime, change.ChangeBy
+		store.insertAccountStatusChange(change)
+	case EventJournalPosted:
+		data := &ledgerJournalData{}
+		if err := json.Unmarshal(event.Payload, data); err != nil {
+			return err
+		}
+		return store.insertLedgerJournal(data, true)
+	default:
+		return ErrLedgerEventTypeUnknown
+	}
+	return nil
+}
+
+// restoreLedgerSnapshot fills the cleared tables from the snapshot of an EventSourcedStore.
+func (store *InMemoryStore) restoreLedgerSnapshot(data *ledgerSnapshotData) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, account := range data.Accounts {
+		store.accountTable[account.AccountNumber] = &InMemoryAccountRecord{
+			currency:            account.Currency,
+			id:                  account.AccountNumber,
+			name:                account.Name,
+			description:         account.Description,
+			baseTransactionType: account.Alignment,
+			balance:             account.Balance,
+			coa:                 account.COA,
+			createTime:          account.CreateTime,
+			createBy:            account.CreateBy,
+			updateTime:          account.UpdateTime,
+			updateBy:            account.UpdateBy,
+			status:              account.Status,
+			balanceLimit:        account.BalanceLimit,
+		}
+	}
+	for _, change := range data.StatusChanges {
+		store.insertAccountStatusChange(change)
+	}
+	for _, journal := range data.Journals {
+		if err := store.insertLedgerJournal(journal, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ledgerSnapshotData returns the snapshot of the account, journal and transaction tables.
+func (store *InMemoryStore) ledgerSnapshotData() *ledgerSnapshotData {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data := &ledgerSnapshotData{
+		Accounts:      make([]*ledgerAccountData, 0, len(store.accountTable)),
+		StatusChanges: make([]*AccountStatusChange, 0),
+		Journals:      make([]*ledgerJournalData, 0, len(store.journalChain)),
+	}
+	accountNumbers := make([]string, 0, len(store.accountTable))
+	for accountNumber := range store.accountTable {
+		accountNumbers = append(accountNumbers, accountNumber)
+	}
+	sort.Strings(accountNumbers)
+	for _, accountNumber := range accountNumbers {
+		record := store.accountTable[accountNumber]
+		data.Accounts = append(data.Accounts, &ledgerAccountData{
+			AccountNumber: record.id,
+			Currency:      record.currency,
+			Name:          record.name,
+			Description:   record.description,
+			Alignment:     record.baseTransactionType,
+			Balance:       record.balance,
+			COA:           record.coa,
+			Status:        record.status,
+			BalanceLimit:  record.balanceLimit,
+			CreateTime:    record.createTime,
+			CreateBy:      record.createBy,
+			UpdateTime:    record.updateTime,
+			UpdateBy:      record.updateBy,
+		})
+		for _, change := range store.accountStatusTable[accountNumber] {
+			data.StatusChanges = append(data.StatusChanges, &AccountStatusChange{
+				AccountNumber: change.accountNumber,
+				From:          change.fromStatus,
+				To:            change.toStatus,
+				Reason:        change.reason,
+				ChangeTime:    change.changeTime,
+				ChangeBy:      change.changeBy,
+			})
+		}
+	}
+	for _, journalID := range store.journalChain {
+		record := store.journalTable[journalID]
+		journal := &ledgerJournalData{
+			JournalID:          record.journalID,
+			JournalingTime:     record.journalingTime,
+			Description:        record.description,
+			ReversedJournalID:  record.reversedJournalID,
+			Amount:             record.amount,
+			CreateTime:         record.createTime,
+			CreateBy:           record.createBy,
+			IdempotencyKey:     record.idempotencyKey,
+			CorrectedJournalID: record.correctedJournalID,
+			PreviousHash:       record.previousHash,
+			Hash:               record.hash,
+			Transactions:       make([]ledgerTransactionData, 0),
+		}
+		data.Journals = append(data.Journals, journal)
+	}
+	// the transactions are added in posting order, so each account keeps its transactions order when restored
+	journals := make(map[string]*ledgerJournalData, len(data.Journals))
+	for _, journal := range data.Journals {
+		journals[journal.JournalID] = journal
+	}
+	for _, accountNumber := range accountNumbers {
+		for _, trx := range store.accountTransactions[accountNumber] {
+			journal := journals[trx.journalID]
+			journal.Transactions = append(journal.Transactions, ledgerTransactionData{
+				TransactionID:   trx.transactionID,
+				TransactionTime: trx.transactionTime,
+				AccountNumber:   trx.accountNumber,
+				Description:     trx.description,
+				Alignment:       trx.transactionType,
+				Amount:          trx.amount,
+				AccountBalance:  trx.accountBalance,
+				CreateTime:      trx.createTime,
+				CreateBy:        trx.createBy,
+			})
+		}
+	}
+	return data
+}
+
+// insertAccountStatusChange inserts the status change record. The caller must hold the store lock.
+func (store *InMemoryStore) insertAccountStatusChange(change *AccountStatusChange) {
+	store.accountStatusTable[change.AccountNumber] = append(store.accountStatusTable[change.AccountNumber], &InMemoryAccountStatusRecords{
+		accountNumber: change.AccountNumber,
+		fromStatus:    change.From,
+		toStatus:      change.To,
+		reason:        change.Reason,
+		changeTime:    change.ChangeTime,
+		changeBy:      change.ChangeBy,
+	})
+}
+
+// insertLedgerJournal inserts the journal and its transaction records, chaining the journal. If moveBalances, the account balances
+// are moved by the transactions, otherwise the transactions keep their recorded account balance. The caller must hold the store lock.
+func (store *InMemoryStore) insertLedgerJournal(data *ledgerJournalData, moveBalances bool) error {
+	for _, trx := range data.Transactions {
+		if _, exist := store.accountTable[trx.AccountNumber]; !exist {
+			return ErrJournalTransactionAccountNotPersist
+		}
+	}
+	record := &InMemoryJournalRecords{
+		journalID:          data.JournalID,
+		journalingTime:     data.JournalingTime,
+		description:        data.Description,
+		reversal:           len(data.ReversedJournalID) > 0,
+		reversedJournalID:  data.ReversedJournalID,
+		amount:             data.Amount,
+		createTime:         data.CreateTime,
+		createBy:           data.CreateBy,
+		idempotencyKey:     data.IdempotencyKey,
+		correctedJournalID: data.CorrectedJournalID,
+		previousHash:       data.PreviousHash,
+		hash:               data.Hash,
+	}
+	store.journalTable[record.journalID] = record
+	if len(record.idempotencyKey) > 0 {
+		store.journalIdempotencyKeys[record.idempotencyKey] = record.journalID
+	}
+	store.journalChain = append(store.journalChain, record.journalID)
+	store.journalChainHead = record.hash
+
+	for _, trx := range data.Transactions {
+		accountRecord := store.accountTable[trx.AccountNumber]
+		transactionRecord := &InMemoryTransactionRecords{
+			transactionID:   trx.TransactionID,
+			transactionTime: trx.TransactionTime,
+			accountNumber:   trx.AccountNumber,
+			journalID:       record.journalID,
+			description:     trx.Description,
+			transactionType: trx.Alignment,
+			amount:          trx.Amount,
+			accountBalance:  trx.AccountBalance,
+			createTime:      trx.CreateTime,
+			createBy:        trx.CreateBy,
+		}
+		if moveBalances {
+			transactionRecord.accountBalance = balanceAfter(accountRecord.baseTransactionType, trx.Alignment, trx.Amount, accountRecord.balance)
+			accountRecord.balance = transactionRecord.accountBalance
+			accountRecord.updateTime, accountRecord.updateBy = trx.CreateTime, trx.CreateBy
+		}
+		store.transactionTable[transactionRecord.transactionID] = transactionRecord
+		store.accountTransactions[transactionRecord.accountNumber] = append(store.accountTransactions[transactionRecord.accountNumber], transactionRecord)
+	}
+	return nil
+}
+
+// NewInMemoryEventLog creates a new empty in-memory event el.
+func NewInMemoryEventLog() *InMemoryEventLog {
+	return &InMemoryEventLog{events: make([]*LedgerEvent, 0), snapshots: make([]*LedgerSnapshot, 0)}
+}
+
+// InMemoryEventLog implementation of EventLog keeping the events and snapshots in memory.
+type InMemoryEventLog struct {
+	mutex     sync.RWMutex
+	events    []*LedgerEvent
+	snapshots []*LedgerSnapshot
+}
+
+// AppendEvents appends the events at the end of the log in the given order, numbering them from the next sequence.
+func (el *InMemoryEventLog) AppendEvents(context context.Context, afterSequence int64, events []*LedgerEvent) error {
+	el.mutex.Lock()
+	defer el.mutex.Unlock()
+	if int64(len(el.events)) != afterSequence {
+		return ErrLedgerEventSequenceConflict
+	}
+	for _, event := range events {
+		event.Sequence = int64(len(el.events)) + 1
+		stored := *event
+		el.events = append(el.events, &stored)
+	}
+	return nil
+}
+
+// ReadEvents returns the events after the `afterSequence`, in sequence order.
+func (el *InMemoryEventLog) ReadEvents(context context.Context, afterSequence int64) ([]*LedgerEvent, error) {
+	el.mutex.RLock()
+	defer el.mutex.RUnlock()
+	events := make([]*LedgerEvent, 0)
+	for _, event := range el.events {
+		if event.Sequence > afterSequence {
+			read := *event
+			events = append(events, &read)
+		}
+	}
+	return events, nil
+}
+
+// SaveSnapshot records the snapshot of the projections as of its Sequence.
+func (el *InMemoryEventLog) SaveSnapshot(context context.Context, snapshot *LedgerSnapshot) error {
+	el.mutex.Lock()
+	defer el.mutex.Unlock()
+	stored := *snapshot
+	el.snapshots = append(el.snapshots, &stored)
+	return nil
+}
+
+// GetLatestSnapshot returns the snapshot with the highest Sequence.
+func (el *InMemoryEventLog) GetLatestSnapshot(context context.Context) (*LedgerSnapshot, error) {
+	el.mutex.RLock()
+	defer el.mutex.RUnlock()
+	var latest *LedgerSnapshot
+	for _, snapshot := range el.snapshots {
+		if latest == nil || snapshot.Sequence >= latest.Sequence {
+			latest = snapshot
+		}
+	}
+	if latest == nil {
+		return nil, ErrLedgerSnapshotNotFound
+	}
+	read := *latest
+	return &read, nil
+}
@@ -2,8 +2,12 @@ package acccore
 
 import (
 	"context"
+	"fmt"
 	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
+	"time"
 )
 
 type ExchangeTest struct {
@@ -14,8 +18,12 @@ type ExchangeTest struct {
 }
 
 func TestInMemoryExchangeManager_CalculateExchange(t *testing.T) {
+	testExchangeManagerBehaviour(t, NewInMemoryStore().ExchangeManager())
+}
+
+// testExchangeManagerBehaviour runs the behaviour every ExchangeManager implementation must share.
+func testExchangeManagerBehaviour(t *testing.T, exchangeManager ExchangeManager) {
 	ctx := context.Background()
-	exchangeManager := NewInMemoryExchangeManager()
 	_, _ = exchangeManager.CreateCurrency(ctx, "PLATINUM", "Platinum", decimal.NewFromFloat(0.001), "superman")
 	_, _ = exchangeManager.CreateCurrency(ctx, "GOLD", "Gold", decimal.NewFromFloat(0.01), "superman")
 	_, _ = exchangeManager.CreateCurrency(ctx, "SILVER", "Silver", decimal.NewFromFloat(0.1), "superman")
@@ -64,4 +72,374 @@ func TestInMemoryExchangeManager_CalculateExchange(t *testing.T) {
 			t.Fail()
 		}
 	}
+
+	_, err := exchangeManager.CreateCurrency(ctx, "GOLD", "Gold", decimal.NewFromFloat(0.01), "superman")
+	assert.Equal(t, ErrCurrencyAlreadyPersisted, err)
+	_, err = exchangeManager.CalculateExchange(ctx, "GOLD", "UNOBTAINIUM", decimal.NewFromInt(1))
+	assert.Equal(t, ErrCurrencyNotFound, err)
+
+	silver, err := exchangeManager.GetCurrency(ctx, "SILVER")
+	assert.NoError(t, err)
+	silver.SetExchange(decimal.NewFromFloat(0.2))
+	assert.NoError(t, exchangeManager.UpdateCurrency(ctx, "SILVER", silver, "superman"))
+	result, err := exchangeManager.CalculateExchange(ctx, "GOLD", "SILVER", decimal.NewFromInt(1000))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(20000), result.InexactFloat64())
+	assert.Equal(t, ErrCurrencyNotFound, exchangeManager.UpdateCurrency(ctx, "UNOBTAINIUM", silver, "superman"))
+
+	currencies, err := exchangeManager.ListCurrencies(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, currencies, 4)
+}
+
+func TestInMemoryExchangeManager_RateHistory(t *testing.T) {
+	testExchangeRateHistory(t, NewInMemoryStore().ExchangeManager())
+}
+
+func testExchangeRateHistory(t *testing.T, exchangeManager ExchangeManager) {
+	ctx := context.Background()
+	gold, err := exchangeManager.CreateCurrency(ctx, "GOLD", "Gold", decimal.NewFromFloat(0.01), "tester")
+	assert.NoError(t, err)
+	_, err = exchangeManager.CreateCurrency(ctx, "POINT", "Point", decimal.NewFromFloat(1), "tester")
+	assert.NoError(t, err)
+	created := time.Now()
+
+	assertRate := func(at time.Time, expected int64) {
+		rate, err := exchangeManager.CalculateExchangeRateAt(ctx, "GOLD", "POINT", at)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(expected).Equal(rate), "rate at %s is %s, expecting %d", at, rate, expected)
+	}
+
+	// back filling the history
+	assert.NoError(t, exchangeManager.AddCurrencyRate(ctx, "GOLD", decimal.NewFromFloat(0.005), created.Add(-time.Hour), "tester"))
+	_, err = exchangeManager.CalculateExchangeRateAt(ctx, "GOLD", "POINT", created.Add(-30*time.Minute))
+	assert.Equal(t, ErrCurrencyRateNotFound, err)
+	assert.NoError(t, exchangeManager.AddCurrencyRate(ctx, "POINT", decimal.NewFromInt(1), created.Add(-2*time.Hour), "tester"))
+	assertRate(created.Add(-30*time.Minute), 200)
+	assertRate(created, 100)
+
+	time.Sleep(5 * time.Millisecond)
+	gold.SetExchange(decimal.NewFromFloat(0.02))
+	assert.NoError(t, exchangeManager.UpdateCurrency(ctx, "GOLD", gold, "tester"))
+	assertRate(created, 100)
+	assertRate(time.Now(), 50)
+
+	// a scheduled rate does not change the current exchange
+	assert.NoError(t, exchangeManager.AddCurrencyRate(ctx, "GOLD", decimal.NewFromFloat(0.04), time.Now().Add(time.Hour), "tester"))
+	rate, err := exchangeManager.CalculateExchangeRate(ctx, "GOLD", "POINT")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(50).Equal(rate), "current rate is %s", rate)
+	assertRate(time.Now().Add(2*time.Hour), 25)
+	exchanged, err := exchangeManager.CalculateExchangeAt(ctx, "GOLD", "POINT", decimal.NewFromInt(3), created)
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(300).Equal(exchanged), "exchanged is %s", exchanged)
+
+	// the latest rate in effect becomes the current exchange
+	time.Sleep(5 * time.Millisecond)
+	now := time.Now()
+	assert.NoError(t, exchangeManager.AddCurrencyRate(ctx, "GOLD", decimal.NewFromFloat(0.025), now, "tester"))
+	loaded, err := exchangeManager.GetCurrency(ctx, "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(0.025).Equal(loaded.GetExchange()), "current exchange is %s", loaded.GetExchange())
+	assert.Equal(t, ErrCurrencyRateAlreadyExist, exchangeManager.AddCurrencyRate(ctx, "GOLD", decimal.NewFromFloat(0.03), now, "tester"))
+	assert.Equal(t, ErrCurrencyNotFound, exchangeManager.AddCurrencyRate(ctx, "SILVER", decimal.NewFromFloat(0.03), now, "tester"))
+
+	rates, err := exchangeManager.ListCurrencyRates(ctx, "GOLD")
+	assert.NoError(t, err)
+	assert.Len(t, rates, 5)
+	for idx, exchange := range []float64{0.005, 0.01, 0.02, 0.025, 0.04} {
+		assert.True(t, decimal.NewFromFloat(exchange).Equal(rates[idx].Exchange), "rate %d is %s", idx, rates[idx].Exchange)
+	}
+	_, err = exchangeManager.ListCurrencyRates(ctx, "SILVER")
+	assert.Equal(t, ErrCurrencyNotFound, err)
+}
+
+func TestInMemoryManagers_Behaviour(t *testing.T) {
+	store := NewInMemoryStore()
+	testManagersBehaviour(t, store.AccountManager(), store.TransactionManager(), store.JournalManager())
+}
+
+func TestInMemoryTransactionManager_BalanceHistory(t *testing.T) {
+	store := NewInMemoryStore()
+	testBalanceHistory(t, store.AccountManager(), store.TransactionManager(), store.JournalManager())
+}
+
+func TestInMemoryManagers_DefaultStore(t *testing.T) {
+	ClearInMemoryTables()
+	defer ClearInMemoryTables()
+	testManagersBehaviour(t, &InMemoryAccountManager{}, &InMemoryTransactionManager{}, &InMemoryJournalManager{})
+}
+
+func TestInMemoryStore_Isolation(t *testing.T) {
+	ctx := context.Background()
+	first, second := NewInMemoryStore(), NewInMemoryStore()
+	firstAcc := NewAccounting(first.AccountManager(), first.TransactionManager(), first.JournalManager(), &UUIDUniqueIDGenerator{})
+	secondAcc := NewAccounting(second.AccountManager(), second.TransactionManager(), second.JournalManager(), &UUIDUniqueIDGenerator{})
+
+	_, err := firstAcc.CreateNewAccount(ctx, "1001", "Cash", "Cash account", "1.1", "GOLD", DEBIT, "tester")
+	assert.NoError(t, err)
+	exist, err := secondAcc.GetAccountManager().IsAccountIDExist(ctx, "1001")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	_, err = secondAcc.CreateNewAccount(ctx, "1001", "Cash", "Cash account", "1.1", "GOLD", DEBIT, "tester")
+	assert.NoError(t, err)
+
+	second.Clear()
+	exist, err = firstAcc.GetAccountManager().IsAccountIDExist(ctx, "1001")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
+
+func TestInMemoryJournalManager_ConcurrentPersist(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{})
+	_, err := acc.CreateNewAccount(ctx, "1001", "Point Reserve", "Point issued by the system", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2001", "User Point", "Point owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := acc.CreateNewJournal(ctx, "Point grant", []TransactionInfo{
+				{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+				{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(10)},
+			}, "tester")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	wallet, err := acc.GetAccountManager().GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(500).Equal(wallet.GetBalance()), "wallet balance is %s", wallet.GetBalance())
+}
+
+func TestInMemoryTxManager_ConcurrentRollback(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager())
+	_, err := acc.CreateNewAccount(ctx, "1001", "Point Reserve", "Point issued by the system", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2001", "User Point", "Point owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+	post := func(ctx context.Context) error {
+		_, err := acc.CreateNewJournal(ctx, "Point grant", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+			{AccountNumber: "2001", Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(10)},
+		}, "tester")
+		return err
+	}
+
+	posted := make(chan struct{})
+	rolledBack := make(chan error)
+	go func() {
+		rolledBack <- acc.InUnitOfWork(ctx, func(uowCtx context.Context) error {
+			if err := post(uowCtx); err != nil {
+				return err
+			}
+			close(posted)
+			// give the journals posted outside the unit of work the chance to interleave
+			time.Sleep(50 * time.Millisecond)
+			return fmt.Errorf("abort")
+		})
+	}()
+	<-posted
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, post(ctx))
+		}()
+	}
+	assert.EqualError(t, <-rolledBack, "abort")
+	wg.Wait()
+
+	// the rollback only undid the journal of the unit of work
+	wallet, err := acc.GetAccountManager().GetAccountByID(ctx, "2001")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(wallet.GetBalance()), "wallet balance is %s", wallet.GetBalance())
+	verification, err := acc.GetJournalManager().VerifyJournalChain(ctx)
+	assert.NoError(t, err)
+	assert.False(t, verification.Broken)
+	assert.Equal(t, 10, verification.Verified)
+	report, err := acc.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.IsConsistent(), report.Render())
+	assert.Equal(t, 20, report.TransactionsChecked)
+}
+
+// testManagersBehaviour runs the behaviour every AccountManager, TransactionManager and JournalManager implementation must share.
+func testManagersBehaviour(t *testing.T, accountManager AccountManager, transactionManager TransactionManager, journalManager JournalManager) {
+	ctx := context.Background()
+	acc := NewAccounting(accountManager, transactionManager, journalManager, &UUIDUniqueIDGenerator{})
+
+	cash, err := acc.CreateNewAccount(ctx, "1001", "Gold Cash", "Gold cash reserve", "1.1", "GOLD", DEBIT, "tester")
+	assert.NoError(t, err)
+	equity, err := acc.CreateNewAccount(ctx, "3001", "Gold Equity", "Gold owner equity", "3.1", "GOLD", CREDIT, "tester")
+	assert.NoError(t, err)
+	wallet, err := acc.CreateNewAccount(ctx, "2001", "User Gold Wallet", "Gold owned by user", "2.1", "GOLD", CREDIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2002", "User Point Wallet", "Point owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+
+	_, err = acc.CreateNewAccount(ctx, "1001", "Gold Cash", "Gold cash reserve", "1.1", "GOLD", DEBIT, "tester")
+	assert.Equal(t, ErrAccountAlreadyPersisted, err)
+	_, err = acc.CreateNewAccount(ctx, "1002", "", "No name", "1.1", "GOLD", DEBIT, "tester")
+	assert.Equal(t, ErrAccountMissingName, err)
+	err = accountManager.UpdateAccount(ctx, &BaseAccount{AccountNumber: "9999", Name: "Ghost", Description: "Ghost", CreateBy: "tester"})
+	assert.Equal(t, ErrAccountIsNotPersisted, err)
+
+	loaded, err := accountManager.GetAccountByID(ctx, "3001")
+	assert.NoError(t, err)
+	assert.Equal(t, "Gold Equity", loaded.GetName())
+	assert.Equal(t, CREDIT, loaded.GetAlignment())
+	_, err = accountManager.GetAccountByID(ctx, "9999")
+	assert.Equal(t, ErrAccountIDNotFound, err)
+
+	page, accounts, err := accountManager.ListAccountByCOA(ctx, "2.1", PageRequest{PageNo: 1, ItemSize: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.TotalEntries)
+	assert.Len(t, accounts, 2)
+	page, accounts, err = accountManager.FindAccounts(ctx, "wallet", PageRequest{PageNo: 1, ItemSize: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.TotalEntries)
+	assert.Len(t, accounts, 1)
+	page, _, err = accountManager.ListAccounts(ctx, PageRequest{PageNo: 1, ItemSize: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, page.TotalEntries)
+
+	journal, err := acc.CreateNewJournal(ctx, "Initial capital", []TransactionInfo{
+		{AccountNumber: cash.GetAccountNumber(), Description: "Cash in", TxType: DEBIT, Amount: decimal.NewFromInt(1000)},
+		{AccountNumber: equity.GetAccountNumber(), Description: "Capital", TxType: CREDIT, Amount: decimal.NewFromInt(1000)},
+	}, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewJournal(ctx, "Sell gold to user", []TransactionInfo{
+		{AccountNumber: cash.GetAccountNumber(), Description: "Cash out", TxType: CREDIT, Amount: decimal.NewFromInt(300)},
+		{AccountNumber: wallet.GetAccountNumber(), Description: "Gold to wallet", TxType: DEBIT, Amount: decimal.NewFromInt(300)},
+	}, "tester")
+	assert.NoError(t, err)
+
+	loaded, err = accountManager.GetAccountByID(ctx, cash.GetAccountNumber())
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(700).Equal(loaded.GetBalance()), "cash balance is %s", loaded.GetBalance())
+	loaded, err = accountManager.GetAccountByID(ctx, wallet.GetAccountNumber())
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(-300).Equal(loaded.GetBalance()), "wallet balance is %s", loaded.GetBalance())
+
+	persisted, err := journalManager.GetJournalByID(ctx, journal.GetJournalID())
+	assert.NoError(t, err)
+	assert.Equal(t, "Initial capital", persisted.GetDescription())
+	assert.True(t, decimal.NewFromInt(1000).Equal(persisted.GetAmount()))
+	assert.Len(t, persisted.GetTransactions(), 2)
+	for _, trx := range persisted.GetTransactions() {
+		assert.True(t, decimal.NewFromInt(1000).Equal(trx.GetAccountBalance()))
+		exist, err := transactionManager.IsTransactionIDExist(ctx, trx.GetTransactionID())
+		assert.NoError(t, err)
+		assert.True(t, exist)
+	}
+	_, err = journalManager.GetJournalByID(ctx, "unknown")
+	assert.Equal(t, ErrJournalIDNotFound, err)
+	exist, err := journalManager.IsJournalIDExist(ctx, journal.GetJournalID())
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	reversed, err := journalManager.IsJournalIDReversed(ctx, journal.GetJournalID())
+	assert.NoError(t, err)
+	assert.False(t, reversed)
+	_, err = journalManager.IsJournalIDReversed(ctx, "unknown")
+	assert.Equal(t, ErrJournalIDNotFound, err)
+
+	page, journals, err := journalManager.ListJournals(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), PageRequest{PageNo: 1, ItemSize: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.TotalEntries)
+	assert.Len(t, journals, 2)
+
+	page, transactions, err := transactionManager.ListTransactionsOnAccount(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), cash, PageRequest{PageNo: 1, ItemSize: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.TotalEntries)
+	assert.Len(t, transactions, 2)
+	_, err = transactionManager.GetTransactionByID(ctx, "unknown")
+	assert.Equal(t, ErrTransactionNotFound, err)
+
+	newJournal := func(transactions ...TransactionInfo) Journal {
+		trxs := make([]Transaction, 0)
+		for _, info := range transactions {
+			trxs = append(trxs, transactionManager.NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber(info.AccountNumber).SetAlignment(info.TxType).SetAmount(info.Amount).SetCreateBy("tester"))
+		}
+		return journalManager.NewJournal(ctx).SetJournalID(acc.GetUniqueIDGenerator().NewUniqueID()).SetCreateBy("tester").SetTransactions(trxs)
+	}
+	err = journalManager.PersistJournal(ctx, newJournal(
+		TransactionInfo{AccountNumber: "1001", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+		TransactionInfo{AccountNumber: "3001", TxType: CREDIT, Amount: decimal.NewFromInt(11)}))
+	assert.Equal(t, ErrJournalNotBalance, err)
+	err = journalManager.PersistJournal(ctx, newJournal(
+		TransactionInfo{AccountNumber: "1001", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+		TransactionInfo{AccountNumber: "2002", TxType: CREDIT, Amount: decimal.NewFromInt(10)}))
+	assert.Equal(t, ErrJournalTransactionMixCurrency, err)
+	err = journalManager.PersistJournal(ctx, newJournal(
+		TransactionInfo{AccountNumber: "1001", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+		TransactionInfo{AccountNumber: "1001", TxType: CREDIT, Amount: decimal.NewFromInt(10)}))
+	assert.Equal(t, ErrJournalTransactionAccountDuplicate, err)
+	err = journalManager.PersistJournal(ctx, newJournal(
+		TransactionInfo{AccountNumber: "1001", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+		TransactionInfo{AccountNumber: "9999", TxType: CREDIT, Amount: decimal.NewFromInt(10)}))
+	assert.Equal(t, ErrJournalTransactionAccountNotPersist, err)
+	err = journalManager.PersistJournal(ctx, persisted)
+	assert.Equal(t, ErrJournalAlreadyPersisted, err)
+
+	loaded, err = accountManager.GetAccountByID(ctx, cash.GetAccountNumber())
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(700).Equal(loaded.GetBalance()), "rejected journals must not change balance, got %s", loaded.GetBalance())
+}
+
+func testBalanceHistory(t *testing.T, accountManager AccountManager, transactionManager TransactionManager, journalManager JournalManager) {
+	ctx := context.Background()
+	acc := NewAccounting(accountManager, transactionManager, journalManager, &UUIDUniqueIDGenerator{})
+	cash, err := acc.CreateNewAccount(ctx, "1001", "Gold Cash", "Gold cash reserve", "1.1", "GOLD", DEBIT, "tester")
+	assert.NoError(t, err)
+	wallet, err := acc.CreateNewAccount(ctx, "2001", "User Gold Wallet", "Gold owned by user", "2.1", "GOLD", CREDIT, "tester")
+	assert.NoError(t, err)
+
+	beforeAll := time.Now()
+	marks := make([]time.Time, 0)
+	for _, amount := range []int64{100, 250, 50} {
+		time.Sleep(5 * time.Millisecond)
+		_, err = acc.CreateNewJournal(ctx, "Deposit", []TransactionInfo{
+			{AccountNumber: cash.GetAccountNumber(), Description: "Cash in", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: wallet.GetAccountNumber(), Description: "Wallet", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		assert.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		marks = append(marks, time.Now())
+	}
+	_, err = acc.CreateNewJournal(ctx, "Withdraw", []TransactionInfo{
+		{AccountNumber: cash.GetAccountNumber(), Description: "Cash out", TxType: CREDIT, Amount: decimal.NewFromInt(30)},
+		{AccountNumber: wallet.GetAccountNumber(), Description: "Wallet", TxType: DEBIT, Amount: decimal.NewFromInt(30)},
+	}, "tester")
+	assert.NoError(t, err)
+
+	for at, expected := range map[time.Time]int64{beforeAll: 0, marks[0]: 100, marks[1]: 350, marks[2]: 400, time.Now(): 370} {
+		balance, err := transactionManager.GetAccountBalanceAt(ctx, wallet, at)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(expected).Equal(balance), "balance at %s is %s, expecting %d", at, balance, expected)
+	}
+
+	opening, closing, err := transactionManager.GetAccountBalanceRange(ctx, cash, marks[0], marks[2])
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(opening), "opening is %s", opening)
+	assert.True(t, decimal.NewFromInt(400).Equal(closing), "closing is %s", closing)
+
+	untouched, err := acc.CreateNewAccount(ctx, "3001", "Gold Equity", "Gold owner equity", "3.1", "GOLD", CREDIT, "tester")
+	assert.NoError(t, err)
+	balance, err := transactionManager.GetAccountBalanceAt(ctx, untouched, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, balance.IsZero())
+	_, err = transactionManager.GetAccountBalanceAt(ctx, &BaseAccount{AccountNumber: "9999"}, time.Now())
+	assert.Equal(t, ErrAccountIDNotFound, err)
 }
@@ -0,0 +1,2543 @@
+package acccore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLDialect defines the differences between SQL databases that the SQL managers need to know about.
+// All queries in the SQL managers are written using `?` as bind parameter placeholder.
+type SQLDialect interface {
+	// Rebind converts a query written with `?` placeholders into the placeholder style of this dialect.
+	Rebind(query string) string
+
+	// LockClause returns the clause appended to a SELECT statement to lock the selected rows
+	// until the end of the transaction. Returns empty string if the database locks in a different way.
+	LockClause() string
+
+	// DecimalType returns the column type used for storing decimal values
+	DecimalType() string
+
+	// TimestampType returns the column type used for storing timestamp values
+	TimestampType() string
+
+	// BooleanType returns the column type used for storing boolean values
+	BooleanType() string
+}
+
+// PostgreSQLDialect is the SQLDialect for PostgreSQL database.
+type PostgreSQLDialect struct{}
+
+// Rebind converts `?` placeholders into PostgreSQL's `$1`, `$2`, ... placeholders.
+func (d *PostgreSQLDialect) Rebind(query string) string {
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString("$")
+			builder.WriteString(strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// LockClause returns PostgreSQL row locking clause
+func (d *PostgreSQLDialect) LockClause() string {
+	return " FOR UPDATE"
+}
+
+// DecimalType returns the column type used for storing decimal values
+func (d *PostgreSQLDialect) DecimalType() string {
+	return "NUMERIC"
+}
+
+// TimestampType returns the column type used for storing timestamp values
+func (d *PostgreSQLDialect) TimestampType() string {
+	return "TIMESTAMPTZ"
+}
+
+// BooleanType returns the column type used for storing boolean values
+func (d *PostgreSQLDialect) BooleanType() string {
+	return "BOOLEAN"
+}
+
+// SCHEMA migrations ***********************
+
+// SQLMigration is a single versioned step of the SQL schema.
+// Statements may use {decimal}, {timestamp} and {boolean} tokens for the dialect specific column types.
+type SQLMigration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// SQLMigrations is the ordered list of schema migrations applied by MigrateSQLSchema.
+// Never change an already released migration, append a new one instead.
+var SQLMigrations = []SQLMigration{
+	{
+		Version:     1,
+		Description: "create account, journal and transaction tables",
+		Statements: []string{
+			`CREATE TABLE acc_account (
+				account_number VARCHAR(64) NOT NULL PRIMARY KEY,
+				currency VARCHAR(16) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				description TEXT NOT NULL,
+				alignment INTEGER NOT NULL,
+				balance {decimal} NOT NULL,
+				coa VARCHAR(64) NOT NULL,
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL,
+				update_time {timestamp} NOT NULL,
+				update_by VARCHAR(255) NOT NULL
+			)`,
+			`CREATE INDEX acc_account_coa_idx ON acc_account (coa)`,
+			`CREATE TABLE acc_journal (
+				journal_id VARCHAR(64) NOT NULL PRIMARY KEY,
+				journaling_time {timestamp} NOT NULL,
+				description TEXT NOT NULL,
+				reversal {boolean} NOT NULL,
+				reversed_journal_id VARCHAR(64),
+				amount {decimal} NOT NULL,
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL
+			)`,
+			`CREATE INDEX acc_journal_time_idx ON acc_journal (journaling_time)`,
+			`CREATE INDEX acc_journal_reversed_idx ON acc_journal (reversed_journal_id)`,
+			`CREATE TABLE acc_transaction (
+				transaction_id VARCHAR(64) NOT NULL PRIMARY KEY,
+				transaction_time {timestamp} NOT NULL,
+				account_number VARCHAR(64) NOT NULL REFERENCES acc_account (account_number),
+				journal_id VARCHAR(64) NOT NULL REFERENCES acc_journal (journal_id),
+				description TEXT NOT NULL,
+				alignment INTEGER NOT NULL,
+				amount {decimal} NOT NULL,
+				account_balance {decimal} NOT NULL,
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL
+			)`,
+			`CREATE INDEX acc_transaction_account_idx ON acc_transaction (account_number, transaction_time)`,
+			`CREATE INDEX acc_transaction_journal_idx ON acc_transaction (journal_id)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "create currency table",
+		Statements: []string{
+			`CREATE TABLE acc_currency (
+				code VARCHAR(16) NOT NULL PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				exchange {decimal} NOT NULL,
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL,
+				update_time {timestamp} NOT NULL,
+				update_by VARCHAR(255) NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "create accounting period tables",
+		Statements: []string{
+			`CREATE TABLE acc_period (
+				period_id VARCHAR(64) NOT NULL PRIMARY KEY,
+				description TEXT NOT NULL,
+				period_from {timestamp} NOT NULL,
+				period_until {timestamp} NOT NULL,
+				status INTEGER NOT NULL,
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL,
+				update_time {timestamp} NOT NULL,
+				update_by VARCHAR(255) NOT NULL
+			)`,
+			`CREATE INDEX acc_period_range_idx ON acc_period (period_from, period_until)`,
+			`CREATE TABLE acc_period_closing_journal (
+				period_id VARCHAR(64) NOT NULL REFERENCES acc_period (period_id),
+				journal_id VARCHAR(64) NOT NULL REFERENCES acc_journal (journal_id),
+				PRIMARY KEY (period_id, journal_id)
+			)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "create currency rate history table",
+		Statements: []string{
+			`CREATE TABLE acc_currency_rate (
+				code VARCHAR(16) NOT NULL REFERENCES acc_currency (code),
+				valid_from {timestamp} NOT NULL,
+				exchange {decimal} NOT NULL,
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL,
+				PRIMARY KEY (code, valid_from)
+			)`,
+			// the existing currencies only know their current exchange value
+			`INSERT INTO acc_currency_rate (code, valid_from, exchange, create_time, create_by)
+				SELECT code, create_time, exchange, update_time, update_by FROM acc_currency`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "add journal idempotency key",
+		Statements: []string{
+			// journals without idempotency key keep it NULL, so they do not collide in the unique index
+			`ALTER TABLE acc_journal ADD COLUMN idempotency_key VARCHAR(255)`,
+			`CREATE UNIQUE INDEX acc_journal_idempotency_key_idx ON acc_journal (idempotency_key)`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "add journal correction link",
+		Statements: []string{
+			`ALTER TABLE acc_journal ADD COLUMN corrected_journal_id VARCHAR(64)`,
+			`CREATE INDEX acc_journal_corrected_idx ON acc_journal (corrected_journal_id)`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "add account status and its change history",
+		Statements: []string{
+			`ALTER TABLE acc_account ADD COLUMN status INTEGER NOT NULL DEFAULT 0`,
+			`CREATE TABLE acc_account_status_change (
+				account_number VARCHAR(64) NOT NULL REFERENCES acc_account (account_number),
+				sequence INTEGER NOT NULL,
+				from_status INTEGER NOT NULL,
+				to_status INTEGER NOT NULL,
+				reason TEXT NOT NULL,
+				change_time {timestamp} NOT NULL,
+				change_by VARCHAR(255) NOT NULL,
+				PRIMARY KEY (account_number, sequence)
+			)`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "add account balance limit",
+		Statements: []string{
+			`ALTER TABLE acc_account ADD COLUMN allow_negative {boolean} NOT NULL DEFAULT TRUE`,
+			`ALTER TABLE acc_account ADD COLUMN min_balance {decimal}`,
+			`ALTER TABLE acc_account ADD COLUMN max_balance {decimal}`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "create hold table",
+		Statements: []string{
+			// the hold is captured before its journal is persisted, so journal_id does not reference acc_journal
+			`CREATE TABLE acc_hold (
+				hold_id VARCHAR(64) NOT NULL PRIMARY KEY,
+				account_number VARCHAR(64) NOT NULL REFERENCES acc_account (account_number),
+				amount {decimal} NOT NULL,
+				captured_amount {decimal} NOT NULL,
+				status INTEGER NOT NULL,
+				expiry_time {timestamp} NOT NULL,
+				description TEXT NOT NULL,
+				journal_id VARCHAR(64),
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL,
+				update_time {timestamp} NOT NULL,
+				update_by VARCHAR(255) NOT NULL
+			)`,
+			`CREATE INDEX acc_hold_account_idx ON acc_hold (account_number, status)`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "create journal schedule tables",
+		Statements: []string{
+			`CREATE TABLE acc_schedule (
+				schedule_id VARCHAR(64) NOT NULL PRIMARY KEY,
+				description TEXT NOT NULL,
+				frequency INTEGER NOT NULL,
+				interval_count INTEGER NOT NULL,
+				start_time {timestamp} NOT NULL,
+				end_time {timestamp},
+				next_sequence INTEGER NOT NULL,
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL,
+				update_time {timestamp} NOT NULL,
+				update_by VARCHAR(255) NOT NULL
+			)`,
+			// the accounts are checked when the journal is posted, an account may be closed after the schedule is created
+			`CREATE TABLE acc_schedule_transaction (
+				schedule_id VARCHAR(64) NOT NULL REFERENCES acc_schedule (schedule_id),
+				sequence INTEGER NOT NULL,
+				account_number VARCHAR(64) NOT NULL,
+				description TEXT NOT NULL,
+				transaction_type INTEGER NOT NULL,
+				amount {decimal} NOT NULL,
+				PRIMARY KEY (schedule_id, sequence)
+			)`,
+			`CREATE TABLE acc_schedule_run (
+				schedule_id VARCHAR(64) NOT NULL REFERENCES acc_schedule (schedule_id),
+				run_number INTEGER NOT NULL,
+				sequence INTEGER NOT NULL,
+				due_time {timestamp} NOT NULL,
+				run_time {timestamp} NOT NULL,
+				status INTEGER NOT NULL,
+				journal_id VARCHAR(64),
+				message TEXT NOT NULL,
+				run_by VARCHAR(255) NOT NULL,
+				PRIMARY KEY (schedule_id, run_number)
+			)`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "create lot table",
+		Statements: []string{
+			// lots are recorded within the unit of work posting their journal, so the journals are not referenced
+			`CREATE TABLE acc_lot (
+				lot_id VARCHAR(64) NOT NULL PRIMARY KEY,
+				account_number VARCHAR(64) NOT NULL REFERENCES acc_account (account_number),
+				sequence INTEGER NOT NULL,
+				journal_id VARCHAR(64) NOT NULL,
+				amount {decimal} NOT NULL,
+				remaining {decimal} NOT NULL,
+				status INTEGER NOT NULL,
+				expiry_time {timestamp} NOT NULL,
+				expiry_journal_id VARCHAR(64),
+				create_time {timestamp} NOT NULL,
+				create_by VARCHAR(255) NOT NULL,
+				update_time {timestamp} NOT NULL,
+				update_by VARCHAR(255) NOT NULL
+			)`,
+			`CREATE INDEX acc_lot_account_idx ON acc_lot (account_number, status, expiry_time)`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "add journal hash chain",
+		Statements: []string{
+			// journals persisted before the chain keep these NULL and are not verified
+			`ALTER TABLE acc_journal ADD COLUMN chain_sequence INTEGER`,
+			`ALTER TABLE acc_journal ADD COLUMN previous_hash VARCHAR(64)`,
+			`ALTER TABLE acc_journal ADD COLUMN hash VARCHAR(64)`,
+			`CREATE UNIQUE INDEX acc_journal_chain_sequence_idx ON acc_journal (chain_sequence)`,
+			// the single row holds the last chained journal, locking it serializes the chaining
+			`CREATE TABLE acc_journal_chain (
+				chain_id INTEGER NOT NULL PRIMARY KEY,
+				last_sequence INTEGER NOT NULL,
+				last_hash VARCHAR(64) NOT NULL
+			)`,
+			`INSERT INTO acc_journal_chain (chain_id, last_sequence, last_hash) VALUES (1, 0, '')`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "create ledger event log tables",
+		Statements: []string{
+			`CREATE TABLE acc_ledger_event (
+				sequence INTEGER NOT NULL PRIMARY KEY,
+				event_type INTEGER NOT NULL,
+				event_time {timestamp} NOT NULL,
+				payload TEXT NOT NULL
+			)`,
+			`CREATE TABLE acc_ledger_snapshot (
+				sequence INTEGER NOT NULL PRIMARY KEY,
+				snapshot_time {timestamp} NOT NULL,
+				payload TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "create outbox tables",
+		Statements: []string{
+			`CREATE TABLE acc_outbox (
+				event_offset INTEGER NOT NULL PRIMARY KEY,
+				event_type INTEGER NOT NULL,
+				event_time {timestamp} NOT NULL,
+				journal_id VARCHAR(255) NOT NULL,
+				account_number VARCHAR(255) NOT NULL,
+				transaction_id VARCHAR(255) NOT NULL,
+				alignment INTEGER NOT NULL,
+				amount {decimal} NOT NULL,
+				balance {decimal} NOT NULL
+			)`,
+			`CREATE TABLE acc_outbox_subscriber (
+				subscriber VARCHAR(255) NOT NULL PRIMARY KEY,
+				last_offset INTEGER NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     15,
+		Description: "add transaction posting sequence",
+		Statements: []string{
+			// transactions posted before the sequence keep zero and fall back to their create time order
+			`ALTER TABLE acc_transaction ADD COLUMN posting_sequence INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX acc_transaction_posting_idx ON acc_transaction (account_number, posting_sequence)`,
+		},
+	},
+	{
+		Version:     16,
+		Description: "add transaction balance index",
+		Statements: []string{
+			// covers GetAccountBalanceAt, the transactions after a time are read from the index alone
+			`CREATE INDEX acc_transaction_balance_idx ON acc_transaction (account_number, transaction_time, alignment, amount)`,
+		},
+	},
+}
+
+// MigrateSQLSchema brings the database schema up to the latest SQLMigrations version.
+// Already applied migrations are recorded in the acc_schema_migration table and will not be applied twice.
+func MigrateSQLSchema(context context.Context, db *sql.DB, dialect SQLDialect) error {
+	_, err := db.ExecContext(context, expandSQLTypes(dialect, `CREATE TABLE IF NOT EXISTS acc_schema_migration (
+		version INTEGER NOT NULL PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_time {timestamp} NOT NULL
+	)`))
+	if err != nil {
+		logrus.Errorf("error creating schema migration table. got %s", err.Error())
+		return err
+	}
+
+	for _, migration := range SQLMigrations {
+		var count int
+		err := db.QueryRowContext(context, dialect.Rebind(`SELECT COUNT(*) FROM acc_schema_migration WHERE version = ?`), migration.Version).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		tx, err := db.BeginTx(context, nil)
+		if err != nil {
+			return err
+		}
+		for _, statement := range migration.Statements {
+			if _, err := tx.ExecContext(context, expandSQLTypes(dialect, statement)); err != nil {
+				logrus.Errorf("error applying schema migration %d (%s). got %s", migration.Version, migration.Description, err.Error())
+				_ = tx.Rollback()
+				return err
+			}
+		}
+		_, err = tx.ExecContext(context, dialect.Rebind(`INSERT INTO acc_schema_migration (version, description, applied_time) VALUES (?, ?, ?)`),
+			migration.Version, migration.Description, time.Now().UTC())
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandSQLTypes replaces the column type tokens in a schema statement with the dialect's column types.
+func expandSQLTypes(dialect SQLDialect, statement string) string {
+	return strings.NewReplacer(
+		"{decimal}", dialect.DecimalType(),
+		"{timestamp}", dialect.TimestampType(),
+		"{boolean}", dialect.BooleanType(),
+	).Replace(statement)
+}
+
+// sqlExecutor is the common functions of sql.DB and sql.Tx
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// sqlUnitOfWorkKey is the context key of the database transaction running as unit of work on a database.
+type sqlUnitOfWorkKey struct {
+	db *sql.DB
+}
+
+// NewSQLTxManager creates a TxManager that runs units of work as database transactions.
+func NewSQLTxManager(db *sql.DB) TxManager {
+	return &SQLTxManager{db: db}
+}
+
+// SQLTxManager implementation of TxManager using database/sql transactions.
+type SQLTxManager struct {
+	db *sql.DB
+}
+
+// Begin starts a new database transaction and returns the context carrying it.
+func (txm *SQLTxManager) Begin(ctx context.Context) (context.Context, UnitOfWork, error) {
+	if _, ok := ctx.Value(sqlUnitOfWorkKey{db: txm.db}).(*sql.Tx); ok {
+		return ctx, joinedUnitOfWork{}, nil
+	}
+	tx, err := txm.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return context.WithValue(ctx, sqlUnitOfWorkKey{db: txm.db}, tx), &SQLUnitOfWork{tx: tx}, nil
+}
+
+// SQLUnitOfWork is the unit of work running as a database transaction.
+type SQLUnitOfWork struct {
+	tx *sql.Tx
+}
+
+// Commit commits the database transaction.
+func (uow *SQLUnitOfWork) Commit() error {
+	if err := uow.tx.Commit(); err != nil {
+		if err == sql.ErrTxDone {
+			return ErrUnitOfWorkFinished
+		}
+		return err
+	}
+	return nil
+}
+
+// Rollback rolls back the database transaction.
+func (uow *SQLUnitOfWork) Rollback() error {
+	if err := uow.tx.Rollback(); err != nil {
+		if err == sql.ErrTxDone {
+			return ErrUnitOfWorkFinished
+		}
+		return err
+	}
+	return nil
+}
+
+// sqlBase holds the database handle and dialect shared by all SQL managers.
+type sqlBase struct {
+	db      *sql.DB
+	dialect SQLDialect
+}
+
+// executor returns the database transaction carried by the context, or the database itself if there is none.
+func (base *sqlBase) executor(context context.Context) sqlExecutor {
+	if tx, ok := context.Value(sqlUnitOfWorkKey{db: base.db}).(*sql.Tx); ok {
+		return tx
+	}
+	return base.db
+}
+
+// inTx runs the work within the database transaction carried by the context.
+// If there is none, the work runs in its own database transaction.
+func (base *sqlBase) inTx(context context.Context, work func(tx sqlExecutor) error) error {
+	if tx, ok := base.executor(context).(*sql.Tx); ok {
+		return work(tx)
+	}
+	tx, err := base.db.BeginTx(context, nil)
+	if err != nil {
+		return err
+	}
+	if err := work(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (base *sqlBase) exec(context context.Context, executor sqlExecutor, query string, args ...interface{}) (sql.Result, error) {
+	return executor.ExecContext(context, base.dialect.Rebind(query), args...)
+}
+
+func (base *sqlBase) query(context context.Context, executor sqlExecutor, query string, args ...interface{}) (*sql.Rows, error) {
+	return executor.QueryContext(context, base.dialect.Rebind(query), args...)
+}
+
+func (base *sqlBase) queryRow(context context.Context, executor sqlExecutor, query string, args ...interface{}) *sql.Row {
+	return executor.QueryRowContext(context, base.dialect.Rebind(query), args...)
+}
+
+func (base *sqlBase) count(context context.Context, executor sqlExecutor, query string, args ...interface{}) (int, error) {
+	var count int
+	err := base.queryRow(context, executor, query, args...).Scan(&count)
+	return count, err
+}
+
+// sqlRowScanner is the common function of sql.Row and sql.Rows
+type sqlRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const sqlAccountColumns = `account_number, currency, name, description, alignment, balance, coa, create_time, create_by, update_time, update_by, status, allow_negative, min_balance, max_balance`
+
+func scanSQLAccount(scanner sqlRowScanner) (*BaseAccount, error) {
+	account := &BaseAccount{}
+	err := scanner.Scan(&account.AccountNumber, &account.Currency, &account.Name, &account.Description, &account.Alignment,
+		&account.Balance, &account.COA, &account.CreateTime, &account.CreateBy, &account.UpdateTime, &account.UpdateBy, &account.Status,
+		&account.BalanceLimit.AllowNegative, &account.BalanceLimit.MinBalance, &account.BalanceLimit.MaxBalance)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+const sqlTransactionColumns = `transaction_id, transaction_time, account_number, journal_id, description, alignment, amount, account_balance, create_time, create_by`
+
+func scanSQLTransaction(scanner sqlRowScanner) (*BaseTransaction, error) {
+	transaction := &BaseTransaction{}
+	err := scanner.Scan(&transaction.TransactionID, &transaction.TransactionTime, &transaction.AccountNumber, &transaction.JournalID,
+		&transaction.Description, &transaction.TransactionType, &transaction.Amount, &transaction.AccountBalance,
+		&transaction.CreateTime, &transaction.CreateBy)
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+func scanSQLTransactions(rows *sql.Rows) ([]Transaction, error) {
+	defer rows.Close()
+	transactions := make([]Transaction, 0)
+	for rows.Next() {
+		transaction, err := scanSQLTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// NewSQLJournalManager creates a JournalManager backed by SQL database.
+// The database schema must be prepared using MigrateSQLSchema.
+func NewSQLJournalManager(db *sql.DB, dialect SQLDialect) JournalManager {
+	return &SQLJournalManager{sqlBase: sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLJournalManager implementation of JournalManager using database/sql
+type SQLJournalManager struct {
+	sqlBase
+	validator *JournalValidator
+}
+
+// SetJournalValidator sets the validator PersistJournal runs, nil restores the validator of the built-in rules.
+func (jm *SQLJournalManager) SetJournalValidator(validator *JournalValidator) {
+	jm.validator = validator
+}
+
+// NewJournal will create new blank un-persisted journal
+func (jm *SQLJournalManager) NewJournal(context context.Context) Journal {
+	return &BaseJournal{}
+}
+
+// PersistJournal will record a journal entry into database.
+// It requires list of Transactions for which each of the transaction MUST BE :
+//
+//	1.NOT BE PERSISTED. (the journal AccountNumber is not exist in DB yet)
+//	2.Pointing or owned by a PERSISTED Account
+//	3.Each of this account must belong to the same Currency
+//	4.Balanced. The total sum of DEBIT and total sum of CREDIT is equal.
+//	5.No duplicate transaction that belongs to the same Account.
+//	6.No other journal with the same idempotency key, if the journal has one.
+//	7.Leaving enough balance for the active holds of every account it reduces.
+//
+// These are checked by the JournalValidator of this manager, followed by its registered rules.
+// The whole journal is written in one database transaction, and every account it touches is
+// locked until the transaction ends, so concurrent journals can not corrupt the account balances.
+func (jm *SQLJournalManager) PersistJournal(context context.Context, journalToPersist Journal) error {
+	// Within a unit of work, the unit of work decides when to commit.
+	if tx, ok := jm.executor(context).(*sql.Tx); ok {
+		return jm.persistJournal(context, tx, journalToPersist)
+	}
+
+	// BEGIN transaction
+	tx, err := jm.db.BeginTx(context, nil)
+	if err != nil {
+		return err
+	}
+	err = jm.persistJournal(context, tx, journalToPersist)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	// COMMIT transaction
+	return tx.Commit()
+}
+
+func (jm *SQLJournalManager) persistJournal(context context.Context, tx sqlExecutor, journalToPersist Journal) error {
+	// Run the journal validator within the transaction, it locks every account the journal touches.
+	now := time.Now().UTC()
+	validation := NewJournalValidation(journalToPersist, &sqlJournalLedger{jm: jm, tx: tx}, now)
+	if err := journalValidatorOrDefault(jm.validator).Validate(context, validation); err != nil {
+		return err
+	}
+	amount := GetTotalCredit(journalToPersist)
+	journalingTime := validation.JournalingTime.UTC()
+	var idempotencyKey, reversedJournalID, correctedJournalID sql.NullString
+	if key := journalToPersist.GetIdempotencyKey(); len(key) > 0 {
+		idempotencyKey = sql.NullString{String: key, Valid: true}
+	}
+	if journalToPersist.GetReversedJournal() != nil {
+		reversedJournalID = sql.NullString{String: journalToPersist.GetReversedJournal().GetJournalID(), Valid: true}
+	}
+	if journalID := journalToPersist.GetCorrectedJournalID(); len(journalID) > 0 {
+		correctedJournalID = sql.NullString{String: journalID, Valid: true}
+	}
+
+	// ALL is OK. So lets start persisting.
+
+	// 1. Save the Journal, chained after the last persisted journal
+	var lastSequence int64
+	var previousHash string
+	err := jm.queryRow(context, tx, `SELECT last_sequence, last_hash FROM acc_journal_chain WHERE chain_id = 1`+jm.dialect.LockClause()).Scan(&lastSequence, &previousHash)
+	if err != nil {
+		logrus.Errorf("error persisting journal %s. can not read the journal chain. got %s", journalToPersist.GetJournalID(), err.Error())
+		return err
+	}
+	hash := journalHash(previousHash, journalToPersist, journalingTime)
+	_, err = jm.exec(context, tx, `INSERT INTO acc_journal (journal_id, journaling_time, description, reversal, reversed_journal_id, amount, create_time, create_by, idempotency_key, corrected_journal_id, chain_sequence, previous_hash, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		journalToPersist.GetJournalID(), journalingTime, journalToPersist.GetDescription(), reversedJournalID.Valid, reversedJournalID, amount, now, journalToPersist.GetCreateBy(), idempotencyKey, correctedJournalID, lastSequence+1, previousHash, hash)
+	if err != nil {
+		logrus.Errorf("error persisting journal %s. got %s", journalToPersist.GetJournalID(), err.Error())
+		return err
+	}
+	_, err = jm.exec(context, tx, `UPDATE acc_journal_chain SET last_sequence = ?, last_hash = ? WHERE chain_id = 1`, lastSequence+1, hash)
+	if err != nil {
+		logrus.Errorf("error persisting journal %s. can not update the journal chain. got %s", journalToPersist.GetJournalID(), err.Error())
+		return err
+	}
+	journalToPersist.SetPreviousHash(previousHash)
+	journalToPersist.SetHash(hash)
+
+	// 2 Save the Transactions and update the account balances, the journal chain lock serializes the posting sequence
+	var lastPosting int64
+	err = jm.queryRow(context, tx, `SELECT COALESCE(MAX(posting_sequence), 0) FROM acc_transaction`).Scan(&lastPosting)
+	if err != nil {
+		return err
+	}
+	balances := make(map[string]decimal.Decimal, len(journalToPersist.GetTransactions()))
+	for _, trx := range journalToPersist.GetTransactions() {
+		account, err := validation.Account(context, trx.GetAccountNumber())
+		if err != nil {
+			return err
+		}
+		account.SetBalance(balanceAfter(account.GetAlignment(), trx.GetAlignment(), trx.GetAmount(), account.GetBalance()))
+		balances[trx.GetTransactionID()] = account.GetBalance()
+
+		lastPosting++
+		_, err = jm.exec(context, tx, `INSERT INTO acc_transaction (`+sqlTransactionColumns+`, posting_sequence) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			trx.GetTransactionID(), journalingTime, trx.GetAccountNumber(), journalToPersist.GetJournalID(), trx.GetDescription(),
+			trx.GetAlignment(), trx.GetAmount(), account.GetBalance(), now, trx.GetCreateBy(), lastPosting)
+		if err != nil {
+			logrus.Errorf("error persisting journal %s transaction %s. got %s", journalToPersist.GetJournalID(), trx.GetTransactionID(), err.Error())
+			return err
+		}
+
+		_, err = jm.exec(context, tx, `UPDATE acc_account SET balance = ?, update_time = ?, update_by = ? WHERE account_number = ?`,
+			account.GetBalance(), now, trx.GetCreateBy(), trx.GetAccountNumber())
+		if err != nil {
+			logrus.Errorf("error updating account %s balance. got %s", trx.GetAccountNumber(), err.Error())
+			return err
+		}
+	}
+
+	// 3 Write the journal events into the outbox, the journal chain lock serializes the offsets
+	var lastOffset int64
+	err = jm.queryRow(context, tx, `SELECT COALESCE(MAX(event_offset), 0) FROM acc_outbox`).Scan(&lastOffset)
+	if err != nil {
+		return err
+	}
+	for _, event := range journalOutboxEvents(journalToPersist, amount, balances, now) {
+		lastOffset++
+		_, err = jm.exec(context, tx, `INSERT INTO acc_outbox (`+sqlOutboxColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			lastOffset, event.Type, event.EventTime, event.JournalID, event.AccountNumber, event.TransactionID, event.Alignment, event.Amount, event.Balance)
+		if err != nil {
+			logrus.Errorf("error writing journal %s outbox event %d. got %s", journalToPersist.GetJournalID(), lastOffset, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlJournalLedger is the JournalLedger of the database, read within the transaction persisting the journal.
+type sqlJournalLedger struct {
+	jm *SQLJournalManager
+	tx sqlExecutor
+}
+
+// IsJournalIDExist checks if a journal with the ID is persisted.
+func (ledger *sqlJournalLedger) IsJournalIDExist(context context.Context, journalID string) (bool, error) {
+	count, err := ledger.jm.count(context, ledger.tx, `SELECT COUNT(*) FROM acc_journal WHERE journal_id = ?`, journalID)
+	return count > 0, err
+}
+
+// IsIdempotencyKeyExist checks if a journal is persisted with the idempotency key.
+func (ledger *sqlJournalLedger) IsIdempotencyKeyExist(context context.Context, idempotencyKey string) (bool, error) {
+	count, err := ledger.jm.count(context, ledger.tx, `SELECT COUNT(*) FROM acc_journal WHERE idempotency_key = ?`, idempotencyKey)
+	return count > 0, err
+}
+
+// IsTransactionIDExist checks if a transaction with the ID is persisted.
+func (ledger *sqlJournalLedger) IsTransactionIDExist(context context.Context, transactionID string) (bool, error) {
+	count, err := ledger.jm.count(context, ledger.tx, `SELECT COUNT(*) FROM acc_transaction WHERE transaction_id = ?`, transactionID)
+	return count > 0, err
+}
+
+// IsJournalIDReversed checks if the journal has been reversed, ErrJournalIDNotFound if it is not exist.
+func (ledger *sqlJournalLedger) IsJournalIDReversed(context context.Context, journalID string) (bool, error) {
+	exist, err := ledger.IsJournalIDExist(context, journalID)
+	if err != nil {
+		return false, err
+	}
+	if !exist {
+		return false, ErrJournalIDNotFound
+	}
+	count, err := ledger.jm.count(context, ledger.tx, `SELECT COUNT(*) FROM acc_journal WHERE reversed_journal_id = ?`, journalID)
+	return count > 0, err
+}
+
+// GetAccount returns the account, nil if it is not exist. The account row stays locked until the transaction ends.
+func (ledger *sqlJournalLedger) GetAccount(context context.Context, accountNumber string) (Account, error) {
+	account, err := scanSQLAccount(ledger.jm.queryRow(context, ledger.tx, `SELECT `+sqlAccountColumns+` FROM acc_account WHERE account_number = ?`+ledger.jm.dialect.LockClause(), accountNumber))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// IsPeriodOpenAt checks if the time is not in a closed or locked period.
+func (ledger *sqlJournalLedger) IsPeriodOpenAt(context context.Context, at time.Time) (bool, error) {
+	count, err := ledger.jm.count(context, ledger.tx, `SELECT COUNT(*) FROM acc_period WHERE period_from <= ? AND period_until >= ? AND status <> ?`, at.UTC(), at.UTC(), PeriodOpen)
+	return count == 0, err
+}
+
+// HeldAmount returns the amount held on the account by the holds active at the time.
+func (ledger *sqlJournalLedger) HeldAmount(context context.Context, accountNumber string, at time.Time) (decimal.Decimal, error) {
+	return sqlHeldAmount(context, &ledger.jm.sqlBase, ledger.tx, accountNumber, at)
+}
+
+// CommitJournal will commit the journal into the system
+// The SQL database support transaction, so all commit is done in PersistJournal and this function simply return nil.
+func (jm *SQLJournalManager) CommitJournal(context context.Context, journalToCommit Journal) error {
+	return nil
+}
+
+// CancelJournal Cancel a journal
+// The SQL database support transaction, so all roll back is done in PersistJournal and this function simply return nil.
+func (jm *SQLJournalManager) CancelJournal(context context.Context, journalToCancel Journal) error {
+	return nil
+}
+
+// IsJournalIDReversed check if the journal with specified ID has been reversed
+func (jm *SQLJournalManager) IsJournalIDReversed(context context.Context, journalID string) (bool, error) {
+	exist, err := jm.IsJournalIDExist(context, journalID)
+	if err != nil {
+		return false, err
+	}
+	if !exist {
+		return false, ErrJournalIDNotFound
+	}
+	count, err := jm.count(context, jm.executor(context), `SELECT COUNT(*) FROM acc_journal WHERE reversed_journal_id = ?`, journalID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetReversalOf retrieved the Journal reversing the journal with specified ID.
+func (jm *SQLJournalManager) GetReversalOf(context context.Context, journalID string) (Journal, error) {
+	exist, err := jm.IsJournalIDExist(context, journalID)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, ErrJournalIDNotFound
+	}
+	var reversalID string
+	err = jm.queryRow(context, jm.executor(context), `SELECT journal_id FROM acc_journal WHERE reversed_journal_id = ?`, journalID).Scan(&reversalID)
+	if err == sql.ErrNoRows {
+		return nil, ErrJournalNotReversed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jm.GetJournalByID(context, reversalID)
+}
+
+// GetCorrectionOf retrieved the Journal correcting the journal with specified ID.
+func (jm *SQLJournalManager) GetCorrectionOf(context context.Context, journalID string) (Journal, error) {
+	exist, err := jm.IsJournalIDExist(context, journalID)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, ErrJournalIDNotFound
+	}
+	var correctionID string
+	err = jm.queryRow(context, jm.executor(context), `SELECT journal_id FROM acc_journal WHERE corrected_journal_id = ?`, journalID).Scan(&correctionID)
+	if err == sql.ErrNoRows {
+		return nil, ErrJournalNotCorrected
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jm.GetJournalByID(context, correctionID)
+}
+
+// IsJournalIDExist will check if a Journal ID/number is exist in the database.
+func (jm *SQLJournalManager) IsJournalIDExist(context context.Context, journalID string) (bool, error) {
+	count, err := jm.count(context, jm.executor(context), `SELECT COUNT(*) FROM acc_journal WHERE journal_id = ?`, journalID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetJournalByID retrieved a Journal information identified by its ID.
+// the provided ID must be exactly the same, not uses the LIKE select expression.
+func (jm *SQLJournalManager) GetJournalByID(context context.Context, journalID string) (Journal, error) {
+	var reversedJournalID, idempotencyKey, correctedJournalID, previousHash, hash sql.NullString
+	journal := &BaseJournal{}
+	err := jm.queryRow(context, jm.executor(context), `SELECT journal_id, journaling_time, description, reversal, reversed_journal_id, amount, create_time, create_by, idempotency_key, corrected_journal_id, previous_hash, hash FROM acc_journal WHERE journal_id = ?`, journalID).
+		Scan(&journal.JournalID, &journal.JournalingTime, &journal.Description, &journal.Reversal, &reversedJournalID, &journal.Amount, &journal.CreateTime, &journal.CreatedBy, &idempotencyKey, &correctedJournalID, &previousHash, &hash)
+	if err == sql.ErrNoRows {
+		return nil, ErrJournalIDNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	journal.IdempotencyKey = idempotencyKey.String
+	journal.CorrectedJournalID = correctedJournalID.String
+	journal.PreviousHash = previousHash.String
+	journal.Hash = hash.String
+
+	if journal.Reversal {
+		reversed, err := jm.GetJournalByID(context, reversedJournalID.String)
+		if err != nil {
+			return nil, ErrJournalLoadReversalInconsistent
+		}
+		journal.SetReversedJournal(reversed)
+	}
+
+	rows, err := jm.query(context, jm.executor(context), `SELECT `+sqlTransactionColumns+` FROM acc_transaction WHERE journal_id = ? ORDER BY posting_sequence, create_time, transaction_id`, journalID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := scanSQLTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	journal.SetTransactions(transactions)
+
+	return journal, nil
+}
+
+// VerifyJournalChain walks the journals in persisting order, recomputing each journal hash from its content,
+// and reports the first journal whose hash or link to the journal before it is broken.
+// Journals persisted before the chain was introduced are not verified.
+func (jm *SQLJournalManager) VerifyJournalChain(context context.Context) (*JournalChainVerification, error) {
+	rows, err := jm.query(context, jm.executor(context), `SELECT journal_id FROM acc_journal WHERE chain_sequence IS NOT NULL ORDER BY chain_sequence`)
+	if err != nil {
+		return nil, err
+	}
+	journalIDs := make([]string, 0)
+	for rows.Next() {
+		var journalID string
+		if err := rows.Scan(&journalID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		journalIDs = append(journalIDs, journalID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	verifier := &journalChainVerifier{}
+	for _, journalID := range journalIDs {
+		journal, err := jm.GetJournalByID(context, journalID)
+		if err != nil {
+			return nil, err
+		}
+		if !verifier.check(journal) {
+			break
+		}
+	}
+	var lastHash string
+	err = jm.queryRow(context, jm.executor(context), `SELECT last_hash FROM acc_journal_chain WHERE chain_id = 1`).Scan(&lastHash)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.finish(lastHash), nil
+}
+
+// GetJournalByIdempotencyKey retrieved the Journal persisted with the idempotency key.
+func (jm *SQLJournalManager) GetJournalByIdempotencyKey(context context.Context, idempotencyKey string) (Journal, error) {
+	var journalID string
+	err := jm.queryRow(context, jm.executor(context), `SELECT journal_id FROM acc_journal WHERE idempotency_key = ?`, idempotencyKey).Scan(&journalID)
+	if err == sql.ErrNoRows {
+		return nil, ErrJournalIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jm.GetJournalByID(context, journalID)
+}
+
+// ListJournals retrieve list of journals with transaction date between the `from` and `until` time range inclusive.
+// This function uses pagination.
+func (jm *SQLJournalManager) ListJournals(context context.Context, from time.Time, until time.Time, request PageRequest) (PageResult, []Journal, error) {
+	count, err := jm.count(context, jm.executor(context), `SELECT COUNT(*) FROM acc_journal WHERE journaling_time >= ? AND journaling_time <= ?`, from.UTC(), until.UTC())
+	if err != nil {
+		return PageResult{}, nil, err
+	}
+	pageResult := PageResultFor(request, count)
+
+	rows, err := jm.query(context, jm.executor(context), `SELECT journal_id FROM acc_journal WHERE journaling_time >= ? AND journaling_time <= ? ORDER BY journaling_time, journal_id LIMIT ? OFFSET ?`,
+		from.UTC(), until.UTC(), pageResult.PageSize, pageResult.Offset)
+	if err != nil {
+		return PageResult{}, nil, err
+	}
+	journalIDs := make([]string, 0, pageResult.PageSize)
+	for rows.Next() {
+		var journalID string
+		if err := rows.Scan(&journalID); err != nil {
+			rows.Close()
+			return PageResult{}, nil, err
+		}
+		journalIDs = append(journalIDs, journalID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return PageResult{}, nil, err
+	}
+
+	journals := make([]Journal, len(journalIDs))
+	for i, journalID := range journalIDs {
+		journal, err := jm.GetJournalByID(context, journalID)
+		if err != nil {
+			return PageResult{}, nil, err
+		}
+		journals[i] = journal
+	}
+	return pageResult, journals, nil
+}
+
+// RenderJournal will render this journal into string for easy inspection
+func (jm *SQLJournalManager) RenderJournal(context context.Context, journal Journal) string {
+	return renderJournal(journal)
+}
+
+// NewSQLAccountManager creates an AccountManager backed by SQL database.
+// The database schema must be prepared using MigrateSQLSchema.
+func NewSQLAccountManager(db *sql.DB, dialect SQLDialect) AccountManager {
+	return &SQLAccountManager{sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLAccountManager implementation of AccountManager using database/sql
+type SQLAccountManager struct {
+	sqlBase
+}
+
+// NewAccount will create a new blank un-persisted account.
+func (am *SQLAccountManager) NewAccount(context context.Context) Account {
+	return &BaseAccount{BalanceLimit: UnlimitedBalance}
+}
+
+// PersistAccount will save the account into database.
+// will throw error if the account already persisted
+func (am *SQLAccountManager) PersistAccount(context context.Context, AccountToPersist Account) error {
+	if err := validateAccount(AccountToPersist); err != nil {
+		return err
+	}
+
+	exist, err := am.IsAccountIDExist(context, AccountToPersist.GetAccountNumber())
+	if err != nil {
+		return err
+	}
+	if exist {
+		return ErrAccountAlreadyPersisted
+	}
+
+	now := time.Now().UTC()
+	_, err = am.exec(context, am.executor(context), `INSERT INTO acc_account (`+sqlAccountColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		AccountToPersist.GetAccountNumber(), AccountToPersist.GetCurrency(), AccountToPersist.GetName(), AccountToPersist.GetDescription(),
+		AccountToPersist.GetAlignment(), AccountToPersist.GetBalance(), AccountToPersist.GetCOA(),
+		now, AccountToPersist.GetCreateBy(), now, AccountToPersist.GetUpdateBy(), AccountActive,
+		AccountToPersist.GetBalanceLimit().AllowNegative, AccountToPersist.GetBalanceLimit().MinBalance, AccountToPersist.GetBalanceLimit().MaxBalance)
+	if err != nil {
+		logrus.Errorf("error persisting account %s. got %s", AccountToPersist.GetAccountNumber(), err.Error())
+		return err
+	}
+	return nil
+}
+
+// UpdateAccount will update the account database to reflect to the provided account information.
+// This update account function will fail if the account ID/number is not existing in the database.
+// The account balance is only changed by journals, the balance of the provided account is ignored.
+func (am *SQLAccountManager) UpdateAccount(context context.Context, AccountToUpdate Account) error {
+	if err := validateAccount(AccountToUpdate); err != nil {
+		return err
+	}
+
+	limit := AccountToUpdate.GetBalanceLimit()
+	// the balance is only moved by the journals, it is left as is.
+	result, err := am.exec(context, am.executor(context), `UPDATE acc_account SET currency = ?, name = ?, description = ?, alignment = ?, coa = ?, update_time = ?, update_by = ?, allow_negative = ?, min_balance = ?, max_balance = ? WHERE account_number = ?`,
+		AccountToUpdate.GetCurrency(), AccountToUpdate.GetName(), AccountToUpdate.GetDescription(), AccountToUpdate.GetAlignment(),
+		AccountToUpdate.GetCOA(), time.Now().UTC(), AccountToUpdate.GetUpdateBy(),
+		limit.AllowNegative, limit.MinBalance, limit.MaxBalance, AccountToUpdate.GetAccountNumber())
+	if err != nil {
+		logrus.Errorf("error updating account %s. got %s", AccountToUpdate.GetAccountNumber(), err.Error())
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAccountIsNotPersisted
+	}
+	return nil
+}
+
+// validateAccount checks the mandatory account fields before persisting or updating it.
+func validateAccount(account Account) error {
+	if len(account.GetAccountNumber()) == 0 {
+		return ErrAccountMissingID
+	}
+	if len(account.GetName()) == 0 {
+		return ErrAccountMissingName
+	}
+	if len(account.GetDescription()) == 0 {
+		return ErrAccountMissingDescription
+	}
+	if len(account.GetCreateBy()) == 0 {
+		return ErrAccountMissingCreator
+	}
+	return account.GetBalanceLimit().validate()
+}
+
+// IsAccountIDExist will check if an account ID/number is exist in the database.
+func (am *SQLAccountManager) IsAccountIDExist(context context.Context, id string) (bool, error) {
+	count, err := am.count(context, am.executor(context), `SELECT COUNT(*) FROM acc_account WHERE account_number = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetAccountByID retrieve an account information by specifying the ID/number
+func (am *SQLAccountManager) GetAccountByID(context context.Context, id string) (Account, error) {
+	account, err := scanSQLAccount(am.queryRow(context, am.executor(context), `SELECT `+sqlAccountColumns+` FROM acc_account WHERE account_number = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrAccountIDNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// ListAccounts list all account in the database.
+// This function uses pagination
+func (am *SQLAccountManager) ListAccounts(context context.Context, request PageRequest) (PageResult, []Account, error) {
+	return am.listAccounts(context, "1 = 1", request)
+}
+
+// ListAccountByCOA returns list of accounts that have the same COA number.
+// This function uses pagination
+func (am *SQLAccountManager) ListAccountByCOA(context context.Context, coa string, request PageRequest) (PageResult, []Account, error) {
+	return am.listAccounts(context, "coa = ?", request, coa)
+}
+
+// FindAccounts returns list of accounts that have their Name contains a substring of specified parameter.
+// this search should  be case insensitive.
+func (am *SQLAccountManager) FindAccounts(context context.Context, nameLike string, request PageRequest) (PageResult, []Account, error) {
+	lookup := "%" + strings.ToUpper(strings.ReplaceAll(nameLike, "%", "")) + "%"
+	return am.listAccounts(context, "UPPER(name) LIKE ?", request, lookup)
+}
+
+func (am *SQLAccountManager) listAccounts(context context.Context, where string, request PageRequest, args ...interface{}) (PageResult, []Account, error) {
+	count, err := am.count(context, am.executor(context), `SELECT COUNT(*) FROM acc_account WHERE `+where, args...)
+	if err != nil {
+		return PageResult{}, nil, err
+	}
+	pageResult := PageResultFor(request, count)
+
+	rows, err := am.query(context, am.executor(context), `SELECT `+sqlAccountColumns+` FROM acc_account WHERE `+where+` ORDER BY create_time, account_number LIMIT ? OFFSET ?`,
+		append(args, pageResult.PageSize, pageResult.Offset)...)
+	if err != nil {
+		return PageResult{}, nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]Account, 0, pageResult.PageSize)
+	for rows.Next() {
+		account, err := scanSQLAccount(rows)
+		if err != nil {
+			return PageResult{}, nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return pageResult, accounts, rows.Err()
+}
+
+// ChangeAccountStatus changes the account into the new status, recording the reason and author of the change.
+func (am *SQLAccountManager) ChangeAccountStatus(context context.Context, accountNumber string, newStatus AccountStatus, reason, author string) error {
+	if len(author) == 0 {
+		return ErrAccountStatusNoAuthor
+	}
+	return am.inTx(context, func(tx sqlExecutor) error {
+		account, err := scanSQLAccount(am.queryRow(context, tx, `SELECT `+sqlAccountColumns+` FROM acc_account WHERE account_number = ?`+am.dialect.LockClause(), accountNumber))
+		if err == sql.ErrNoRows {
+			return ErrAccountIDNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !account.Status.canTransit(newStatus) {
+			logrus.Errorf("error changing account %s status. can not change from %s into %s", accountNumber, account.Status.String(), newStatus.String())
+			return ErrAccountStatusInvalid
+		}
+		if newStatus == AccountClosed && !account.Balance.IsZero() {
+			logrus.Errorf("error closing account %s. account balance is %s", accountNumber, account.Balance.String())
+			return ErrAccountCloseNonZero
+		}
+
+		now := time.Now().UTC()
+		_, err = am.exec(context, tx, `UPDATE acc_account SET status = ?, update_time = ?, update_by = ? WHERE account_number = ?`, newStatus, now, author, accountNumber)
+		if err != nil {
+			return err
+		}
+		sequence, err := am.count(context, tx, `SELECT COUNT(*) FROM acc_account_status_change WHERE account_number = ?`, accountNumber)
+		if err != nil {
+			return err
+		}
+		_, err = am.exec(context, tx, `INSERT INTO acc_account_status_change (account_number, sequence, from_status, to_status, reason, change_time, change_by) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			accountNumber, sequence+1, account.Status, newStatus, reason, now, author)
+		if err != nil {
+			logrus.Errorf("error recording account %s status change. got %s", accountNumber, err.Error())
+		}
+		return err
+	})
+}
+
+// ListAccountStatusChanges returns the status changes of the account, oldest first.
+func (am *SQLAccountManager) ListAccountStatusChanges(context context.Context, accountNumber string) ([]*AccountStatusChange, error) {
+	exist, err := am.IsAccountIDExist(context, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, ErrAccountIDNotFound
+	}
+	rows, err := am.query(context, am.executor(context), `SELECT account_number, from_status, to_status, reason, change_time, change_by FROM acc_account_status_change WHERE account_number = ? ORDER BY sequence`, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	changes := make([]*AccountStatusChange, 0)
+	for rows.Next() {
+		change := &AccountStatusChange{}
+		if err := rows.Scan(&change.AccountNumber, &change.From, &change.To, &change.Reason, &change.ChangeTime, &change.ChangeBy); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// NewSQLTransactionManager creates a TransactionManager backed by SQL database.
+// The database schema must be prepared using MigrateSQLSchema.
+func NewSQLTransactionManager(db *sql.DB, dialect SQLDialect) TransactionManager {
+	return &SQLTransactionManager{sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLTransactionManager implementation of TransactionManager using database/sql
+type SQLTransactionManager struct {
+	sqlBase
+}
+
+// NewTransaction will create new blank un-persisted Transaction
+func (tm *SQLTransactionManager) NewTransaction(context context.Context) Transaction {
+	return &BaseTransaction{}
+}
+
+// IsTransactionIDExist will check if an Transaction ID/number is exist in the database.
+func (tm *SQLTransactionManager) IsTransactionIDExist(context context.Context, id string) (bool, error) {
+	count, err := tm.count(context, tm.executor(context), `SELECT COUNT(*) FROM acc_transaction WHERE transaction_id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetTransactionByID will retrieve one single transaction that identified by some ID
+func (tm *SQLTransactionManager) GetTransactionByID(context context.Context, id string) (Transaction, error) {
+	transaction, err := scanSQLTransaction(tm.queryRow(context, tm.executor(context), `SELECT `+sqlTransactionColumns+` FROM acc_transaction WHERE transaction_id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// ListTransactionsOnAccount retrieves list of Transactions that belongs to this account
+// that transaction happens between the `from` and `until` time range, in posting order.
+// This function uses pagination
+func (tm *SQLTransactionManager) ListTransactionsOnAccount(context context.Context, from time.Time, until time.Time, account Account, request PageRequest) (PageResult, []Transaction, error) {
+	count, err := tm.count(context, tm.executor(context), `SELECT COUNT(*) FROM acc_transaction WHERE account_number = ? AND transaction_time >= ? AND transaction_time <= ?`,
+		account.GetAccountNumber(), from.UTC(), until.UTC())
+	if err != nil {
+		return PageResult{}, nil, err
+	}
+	pageResult := PageResultFor(request, count)
+
+	rows, err := tm.query(context, tm.executor(context), `SELECT `+sqlTransactionColumns+` FROM acc_transaction WHERE account_number = ? AND transaction_time >= ? AND transaction_time <= ? ORDER BY posting_sequence, create_time, transaction_id LIMIT ? OFFSET ?`,
+		account.GetAccountNumber(), from.UTC(), until.UTC(), pageResult.PageSize, pageResult.Offset)
+	if err != nil {
+		return PageResult{}, nil, err
+	}
+	transactions, err := scanSQLTransactions(rows)
+	if err != nil {
+		return PageResult{}, nil, err
+	}
+	return pageResult, transactions, nil
+}
+
+// GetAccountBalanceAt returns the account balance as of the specified time, after all transactions up to and including that time.
+// The balance is the current account balance with the transactions after that time taken back,
+// only those transactions are read through the (account_number, transaction_time) prefix of acc_transaction_balance_idx.
+func (tm *SQLTransactionManager) GetAccountBalanceAt(context context.Context, account Account, at time.Time) (decimal.Decimal, error) {
+	executor := tm.executor(context)
+	var accountAlignment Alignment
+	var balance decimal.Decimal
+	err := tm.queryRow(context, executor, `SELECT alignment, balance FROM acc_account WHERE account_number = ?`, account.GetAccountNumber()).Scan(&accountAlignment, &balance)
+	if err == sql.ErrNoRows {
+		return decimal.Zero, ErrAccountIDNotFound
+	}
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	// a journal may be posted back dated, thus the transactions after the time are taken back from the current balance.
+	// the amounts are summed here as the decimal column type differs by dialect, acc_transaction_balance_idx covers the query.
+	rows, err := tm.query(context, executor, `SELECT alignment, amount FROM acc_transaction WHERE account_number = ? AND transaction_time > ?`,
+		account.GetAccountNumber(), at.UTC())
+	if err != nil {
+		return decimal.Zero, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var transactionAlignment Alignment
+		var amount decimal.Decimal
+		if err := rows.Scan(&transactionAlignment, &amount); err != nil {
+			return decimal.Zero, err
+		}
+		balance = balanceBefore(accountAlignment, transactionAlignment, amount, balance)
+	}
+	return balance, rows.Err()
+}
+
+// GetAccountBalanceRange returns the account opening balance, before any transaction in the `from` and `until` time range,
+// and the closing balance, after all transactions in that time range.
+func (tm *SQLTransactionManager) GetAccountBalanceRange(context context.Context, account Account, from time.Time, until time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	opening, err := tm.GetAccountBalanceAt(context, account, from.Add(-time.Nanosecond))
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	closing, err := tm.GetAccountBalanceAt(context, account, until)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	return opening, closing, nil
+}
+
+// RenderTransactionsOnAccount Render list of transaction been down on an account in a time span
+func (tm *SQLTransactionManager) RenderTransactionsOnAccount(context context.Context, from time.Time, until time.Time, account Account, request PageRequest) (string, error) {
+	result, transactions, err := tm.ListTransactionsOnAccount(context, from, until, account, request)
+	if err != nil {
+		return "Error rendering", err
+	}
+	return renderTransactionsOnAccount(from, until, account, result, transactions), nil
+}
+
+// NewSQLExchangeManager creates an ExchangeManager backed by SQL database.
+// The database schema must be prepared using MigrateSQLSchema.
+func NewSQLExchangeManager(db *sql.DB, dialect SQLDialect) ExchangeManager {
+	return &SQLExchangeManager{
+		sqlBase:           sqlBase{db: db, dialect: dialect},
+		commonDenominator: decimal.NewFromInt(1),
+	}
+}
+
+// SQLExchangeManager implementation of ExchangeManager using database/sql
+type SQLExchangeManager struct {
+	sqlBase
+	commonDenominator decimal.Decimal
+}
+
+const sqlCurrencyColumns = `code, name, exchange, create_time, create_by, update_time, update_by`
+
+func scanSQLCurrency(scanner sqlRowScanner) (*BaseCurrency, error) {
+	currency := &BaseCurrency{}
+	err := scanner.Scan(&currency.Code, &currency.Name, &currency.Exchange, &currency.CreateTime, &currency.CreateBy, &currency.UpdateTime, &currency.UpdateBy)
+	if err != nil {
+		return nil, err
+	}
+	return currency, nil
+}
+
+// IsCurrencyExist will check in the exchange system for a Currency existance
+// non-existent Currency means that the Currency is not supported.
+// error should be thrown if only there's an underlying error such as db error.
+func (em *SQLExchangeManager) IsCurrencyExist(context context.Context, currency string) (bool, error) {
+	count, err := em.count(context, em.executor(context), `SELECT COUNT(*) FROM acc_currency WHERE code = ?`, currency)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetDenom get the current common denominator used in the exchange
+func (em *SQLExchangeManager) GetDenom(context context.Context) decimal.Decimal {
+	return em.commonDenominator
+}
+
+// SetDenom set the current common denominator value into the specified value
+func (em *SQLExchangeManager) SetDenom(context context.Context, denom decimal.Decimal) {
+	em.commonDenominator = denom
+}
+
+// ListCurrencies will list all currencies.
+func (em *SQLExchangeManager) ListCurrencies(context context.Context) ([]Currency, error) {
+	rows, err := em.query(context, em.executor(context), `SELECT `+sqlCurrencyColumns+` FROM acc_currency ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	currencies := make([]Currency, 0)
+	for rows.Next() {
+		currency, err := scanSQLCurrency(rows)
+		if err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, currency)
+	}
+	return currencies, rows.Err()
+}
+
+// GetCurrency retrieve currency data indicated by the code argument
+func (em *SQLExchangeManager) GetCurrency(context context.Context, code string) (Currency, error) {
+	currency, err := scanSQLCurrency(em.queryRow(context, em.executor(context), `SELECT `+sqlCurrencyColumns+` FROM acc_currency WHERE code = ?`, code))
+	if err == sql.ErrNoRows {
+		return nil, ErrCurrencyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return currency, nil
+}
+
+// CreateCurrency set the specified value as denominator value for that speciffic Currency.
+// This function should return error if the Currency specified is not exist.
+func (em *SQLExchangeManager) CreateCurrency(context context.Context, code, name string, exchange decimal.Decimal, author string) (Currency, error) {
+	exist, err := em.IsCurrencyExist(context, code)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return nil, ErrCurrencyAlreadyPersisted
+	}
+	now := sqlRateTime(time.Now())
+	err = em.inTx(context, func(tx sqlExecutor) error {
+		_, err := em.exec(context, tx, `INSERT INTO acc_currency (`+sqlCurrencyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			code, name, exchange, now, author, now, author)
+		if err != nil {
+			logrus.Errorf("error persisting currency %s. got %s", code, err.Error())
+			return err
+		}
+		return em.insertCurrencyRate(context, tx, code, exchange, now, author)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BaseCurrency{
+		Code:       code,
+		Name:       name,
+		Exchange:   exchange,
+		CreateTime: now,
+		CreateBy:   author,
+		UpdateTime: now,
+		UpdateBy:   author,
+	}, nil
+}
+
+// UpdateCurrency updates the currency data
+// Error should be returned if the specified Currency is not exist.
+func (em *SQLExchangeManager) UpdateCurrency(context context.Context, code string, currency Currency, author string) error {
+	now := sqlRateTime(time.Now())
+	err := em.inTx(context, func(tx sqlExecutor) error {
+		result, err := em.exec(context, tx, `UPDATE acc_currency SET name = ?, exchange = ?, update_time = ?, update_by = ? WHERE code = ?`,
+			currency.GetName(), currency.GetExchange(), now, author, code)
+		if err != nil {
+			logrus.Errorf("error updating currency %s. got %s", code, err.Error())
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrCurrencyNotFound
+		}
+		return em.insertCurrencyRate(context, tx, code, currency.GetExchange(), now, author)
+	})
+	if err != nil {
+		return err
+	}
+	currency.SetCode(code)
+	return nil
+}
+
+// sqlRateTime drops the time precision the databases may not keep, so a rate is found exactly at its valid from time.
+func sqlRateTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+func (em *SQLExchangeManager) insertCurrencyRate(context context.Context, tx sqlExecutor, code string, exchange decimal.Decimal, validFrom time.Time, author string) error {
+	count, err := em.count(context, tx, `SELECT COUNT(*) FROM acc_currency_rate WHERE code = ? AND valid_from = ?`, code, validFrom)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCurrencyRateAlreadyExist
+	}
+	_, err = em.exec(context, tx, `INSERT INTO acc_currency_rate (code, valid_from, exchange, create_time, create_by) VALUES (?, ?, ?, ?, ?)`,
+		code, validFrom, exchange, time.Now().UTC(), author)
+	if err != nil {
+		logrus.Errorf("error persisting currency %s rate. got %s", code, err.Error())
+	}
+	return err
+}
+
+// AddCurrencyRate records the currency exchange value effective from the validFrom time, keeping the other rates as history.
+// If the new rate is the latest one already in effect, it also becomes the currency exchange value.
+func (em *SQLExchangeManager) AddCurrencyRate(context context.Context, code string, exchange decimal.Decimal, validFrom time.Time, author string) error {
+	validFrom = sqlRateTime(validFrom)
+	return em.inTx(context, func(tx sqlExecutor) error {
+		count, err := em.count(context, tx, `SELECT COUNT(*) FROM acc_currency WHERE code = ?`, code)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrCurrencyNotFound
+		}
+		if err := em.insertCurrencyRate(context, tx, code, exchange, validFrom, author); err != nil {
+			return err
+		}
+		current, err := em.currencyRateAt(context, tx, code, time.Now())
+		if err != nil {
+			return err
+		}
+		if current.ValidFrom.Equal(validFrom) {
+			_, err = em.exec(context, tx, `UPDATE acc_currency SET exchange = ?, update_time = ?, update_by = ? WHERE code = ?`,
+				exchange, time.Now().UTC(), author, code)
+		}
+		return err
+	})
+}
+
+const sqlCurrencyRateColumns = `code, exchange, valid_from, create_time, create_by`
+
+func scanSQLCurrencyRate(scanner sqlRowScanner) (*CurrencyRate, error) {
+	rate := &CurrencyRate{}
+	err := scanner.Scan(&rate.Code, &rate.Exchange, &rate.ValidFrom, &rate.CreateTime, &rate.CreateBy)
+	if err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// currencyRateAt returns the rate of the currency valid at the time, ErrCurrencyRateNotFound if there is none.
+func (em *SQLExchangeManager) currencyRateAt(context context.Context, executor sqlExecutor, code string, at time.Time) (*CurrencyRate, error) {
+	rate, err := scanSQLCurrencyRate(em.queryRow(context, executor, `SELECT `+sqlCurrencyRateColumns+` FROM acc_currency_rate WHERE code = ? AND valid_from <= ? ORDER BY valid_from DESC LIMIT 1`, code, at.UTC()))
+	if err == sql.ErrNoRows {
+		return nil, ErrCurrencyRateNotFound
+	}
+	return rate, err
+}
+
+// ListCurrencyRates list the exchange rate history of the currency, ordered by their valid from time.
+func (em *SQLExchangeManager) ListCurrencyRates(context context.Context, code string) ([]*CurrencyRate, error) {
+	exist, err := em.IsCurrencyExist(context, code)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, ErrCurrencyNotFound
+	}
+	rows, err := em.query(context, em.executor(context), `SELECT `+sqlCurrencyRateColumns+` FROM acc_currency_rate WHERE code = ? ORDER BY valid_from`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rates := make([]*CurrencyRate, 0)
+	for rows.Next() {
+		rate, err := scanSQLCurrencyRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// CalculateExchangeRateAt is CalculateExchangeRate using the currency rates valid at the specified time.
+func (em *SQLExchangeManager) CalculateExchangeRateAt(context context.Context, fromCurrency, toCurrency string, at time.Time) (decimal.Decimal, error) {
+	exchanges := make([]decimal.Decimal, 2)
+	for idx, code := range []string{fromCurrency, toCurrency} {
+		exist, err := em.IsCurrencyExist(context, code)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		if !exist {
+			return decimal.Zero, ErrCurrencyNotFound
+		}
+		rate, err := em.currencyRateAt(context, em.executor(context), code, at)
+		if err != nil {
+			if err == ErrCurrencyRateNotFound {
+				logrus.Errorf("error calculating exchange rate. currency %s has no rate valid at %s", code, at.String())
+			}
+			return decimal.Zero, err
+		}
+		exchanges[idx] = rate.Exchange
+	}
+	return exchangeRateOf(em.GetDenom(context), exchanges[0], exchanges[1]), nil
+}
+
+// CalculateExchangeAt is CalculateExchange using the currency rates valid at the specified time.
+func (em *SQLExchangeManager) CalculateExchangeAt(context context.Context, fromCurrency, toCurrency string, amount decimal.Decimal, at time.Time) (decimal.Decimal, error) {
+	exchange, err := em.CalculateExchangeRateAt(context, fromCurrency, toCurrency, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return exchange.Mul(amount), nil
+}
+
+// CalculateExchangeRate gets the Currency exchange rate for exchanging between the two Currency.
+// if any of the Currency is not exist, an error should be returned.
+// if from and to Currency is equal, this must return 1.0
+func (em *SQLExchangeManager) CalculateExchangeRate(context context.Context, fromCurrency, toCurrency string) (decimal.Decimal, error) {
+	from, err := em.GetCurrency(context, fromCurrency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	to, err := em.GetCurrency(context, toCurrency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	m1 := em.GetDenom(context).Div(from.GetExchange())
+	m2 := m1.Mul(to.GetExchange())
+	return m2.Div(em.GetDenom(context)), nil
+}
+
+// CalculateExchange gets the Currency exchange value for the Amount of fromCurrency into toCurrency.
+// If any of the Currency is not exist, an error should be returned.
+// if from and to Currency is equal, the returned Amount must be equal to the Amount in the argument.
+func (em *SQLExchangeManager) CalculateExchange(context context.Context, fromCurrency, toCurrency string, amount decimal.Decimal) (decimal.Decimal, error) {
+	exchange, err := em.CalculateExchangeRate(context, fromCurrency, toCurrency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return exchange.Mul(amount), nil
+}
+
+// NewSQLPeriodManager creates a PeriodManager backed by SQL database.
+// The database schema must be prepared using MigrateSQLSchema.
+func NewSQLPeriodManager(db *sql.DB, dialect SQLDialect) PeriodManager {
+	return &SQLPeriodManager{sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLPeriodManager implementation of PeriodManager using database/sql
+type SQLPeriodManager struct {
+	sqlBase
+}
+
+const sqlPeriodColumns = `period_id, description, period_from, period_until, status, create_time, create_by, update_time, update_by`
+
+func scanSQLPeriod(scanner sqlRowScanner) (*Period, error) {
+	period := &Period{ClosingJournalIDs: make([]string, 0)}
+	err := scanner.Scan(&period.PeriodID, &period.Description, &period.From, &period.Until, &period.Status,
+		&period.CreateTime, &period.CreateBy, &period.UpdateTime, &period.UpdateBy)
+	if err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// OpenPeriod creates a new open period between `from` and `until` time range inclusive.
+// Error should be returned if the period overlaps with another period.
+func (pm *SQLPeriodManager) OpenPeriod(context context.Context, periodID, description string, from, until time.Time, author string) (*Period, error) {
+	if len(periodID) == 0 {
+		return nil, ErrPeriodMissingID
+	}
+	if !from.Before(until) {
+		return nil, ErrPeriodInvalidRange
+	}
+	executor := pm.executor(context)
+	count, err := pm.count(context, executor, `SELECT COUNT(*) FROM acc_period WHERE period_id = ?`, periodID)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrPeriodAlreadyPersisted
+	}
+	count, err = pm.count(context, executor, `SELECT COUNT(*) FROM acc_period WHERE period_from <= ? AND period_until >= ?`, until.UTC(), from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		logrus.Errorf("error opening period %s. it overlaps with another period", periodID)
+		return nil, ErrPeriodOverlap
+	}
+
+	now := time.Now().UTC()
+	_, err = pm.exec(context, executor, `INSERT INTO acc_period (`+sqlPeriodColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		periodID, description, from.UTC(), until.UTC(), PeriodOpen, now, author, now, author)
+	if err != nil {
+		logrus.Errorf("error persisting period %s. got %s", periodID, err.Error())
+		return nil, err
+	}
+	return pm.GetPeriodByID(context, periodID)
+}
+
+// ClosePeriod closes an open period, recording the closing journals posted for it.
+func (pm *SQLPeriodManager) ClosePeriod(context context.Context, periodID string, closingJournalIDs []string, author string) error {
+	return pm.changeStatus(context, periodID, PeriodClosed, closingJournalIDs, author)
+}
+
+// ReopenPeriod opens back a closed period. Locked period can not be reopened.
+func (pm *SQLPeriodManager) ReopenPeriod(context context.Context, periodID, author string) error {
+	return pm.changeStatus(context, periodID, PeriodOpen, nil, author)
+}
+
+// LockPeriod locks a closed period for good.
+func (pm *SQLPeriodManager) LockPeriod(context context.Context, periodID, author string) error {
+	return pm.changeStatus(context, periodID, PeriodLocked, nil, author)
+}
+
+func (pm *SQLPeriodManager) changeStatus(context context.Context, periodID string, status PeriodStatus, closingJournalIDs []string, author string) error {
+	return pm.inTx(context, func(tx sqlExecutor) error {
+		return pm.changeStatusInTx(context, tx, periodID, status, closingJournalIDs, author)
+	})
+}
+
+func (pm *SQLPeriodManager) changeStatusInTx(context context.Context, tx sqlExecutor, periodID string, status PeriodStatus, closingJournalIDs []string, author string) error {
+	var current PeriodStatus
+	err := pm.queryRow(context, tx, `SELECT status FROM acc_period WHERE period_id = ?`+pm.dialect.LockClause(), periodID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrPeriodNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !current.canTransit(status) {
+		logrus.Errorf("error changing period %s status. can not change from %s to %s", periodID, current.String(), status.String())
+		return ErrPeriodInvalidTransition
+	}
+	_, err = pm.exec(context, tx, `UPDATE acc_period SET status = ?, update_time = ?, update_by = ? WHERE period_id = ?`,
+		status, time.Now().UTC(), author, periodID)
+	if err != nil {
+		return err
+	}
+	for _, journalID := range closingJournalIDs {
+		_, err = pm.exec(context, tx, `INSERT INTO acc_period_closing_journal (period_id, journal_id) VALUES (?, ?)`, periodID, journalID)
+		if err != nil {
+			logrus.Errorf("error recording period %s closing journal %s. got %s", periodID, journalID, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPeriodByID retrieve a period identified by its ID.
+func (pm *SQLPeriodManager) GetPeriodByID(context context.Context, periodID string) (*Period, error) {
+	period, err := scanSQLPeriod(pm.queryRow(context, pm.executor(context), `SELECT `+sqlPeriodColumns+` FROM acc_period WHERE period_id = ?`, periodID))
+	if err == sql.ErrNoRows {
+		return nil, ErrPeriodNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return period, pm.loadClosingJournalIDs(context, period)
+}
+
+// GetPeriodAt retrieve the period containing the specified time, ErrPeriodNotFound if there is none.
+func (pm *SQLPeriodManager) GetPeriodAt(context context.Context, at time.Time) (*Period, error) {
+	period, err := scanSQLPeriod(pm.queryRow(context, pm.executor(context), `SELECT `+sqlPeriodColumns+` FROM acc_period WHERE period_from <= ? AND period_until >= ?`, at.UTC(), at.UTC()))
+	if err == sql.ErrNoRows {
+		return nil, ErrPeriodNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return period, pm.loadClosingJournalIDs(context, period)
+}
+
+// ListPeriods list all periods ordered by their start time.
+func (pm *SQLPeriodManager) ListPeriods(context context.Context) ([]*Period, error) {
+	rows, err := pm.query(context, pm.executor(context), `SELECT `+sqlPeriodColumns+` FROM acc_period ORDER BY period_from`)
+	if err != nil {
+		return nil, err
+	}
+	periods := make([]*Period, 0)
+	for rows.Next() {
+		period, err := scanSQLPeriod(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, period := range periods {
+		if err := pm.loadClosingJournalIDs(context, period); err != nil {
+			return nil, err
+		}
+	}
+	return periods, nil
+}
+
+func (pm *SQLPeriodManager) loadClosingJournalIDs(context context.Context, period *Period) error {
+	rows, err := pm.query(context, pm.executor(context), `SELECT journal_id FROM acc_period_closing_journal WHERE period_id = ? ORDER BY journal_id`, period.PeriodID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var journalID string
+		if err := rows.Scan(&journalID); err != nil {
+			return err
+		}
+		period.ClosingJournalIDs = append(period.ClosingJournalIDs, journalID)
+	}
+	return rows.Err()
+}
+
+// NewSQLHoldManager creates a HoldManager backed by SQL database.
+// The database schema must be prepared using MigrateSQLSchema.
+func NewSQLHoldManager(db *sql.DB, dialect SQLDialect) HoldManager {
+	return &SQLHoldManager{sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLHoldManager implementation of HoldManager using database/sql
+type SQLHoldManager struct {
+	sqlBase
+}
+
+const sqlHoldColumns = `hold_id, account_number, amount, captured_amount, status, expiry_time, description, journal_id, create_time, create_by, update_time, update_by`
+
+func scanSQLHold(scanner sqlRowScanner) (*Hold, error) {
+	hold := &Hold{}
+	var journalID sql.NullString
+	err := scanner.Scan(&hold.HoldID, &hold.AccountNumber, &hold.Amount, &hold.CapturedAmount, &hold.Status, &hold.ExpiryTime,
+		&hold.Description, &journalID, &hold.CreateTime, &hold.CreateBy, &hold.UpdateTime, &hold.UpdateBy)
+	if err != nil {
+		return nil, err
+	}
+	hold.JournalID = journalID.String
+	return hold, nil
+}
+
+func scanSQLHolds(rows *sql.Rows) ([]*Hold, error) {
+	defer rows.Close()
+	holds := make([]*Hold, 0)
+	for rows.Next() {
+		hold, err := scanSQLHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+// sqlActiveHolds returns the holds of the account active at the time, ordered by their expiry time.
+func sqlActiveHolds(context context.Context, base *sqlBase, executor sqlExecutor, accountNumber string, at time.Time) ([]*Hold, error) {
+	rows, err := base.query(context, executor, `SELECT `+sqlHoldColumns+` FROM acc_hold WHERE account_number = ? AND status = ? AND expiry_time > ? ORDER BY expiry_time, hold_id`,
+		accountNumber, HoldActive, at.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanSQLHolds(rows)
+}
+
+// sqlHeldAmount returns the amount of the account holds active at the time.
+// The amounts are summed here rather than in SQL, as not every database stores decimals as numbers.
+func sqlHeldAmount(context context.Context, base *sqlBase, executor sqlExecutor, accountNumber string, at time.Time) (decimal.Decimal, error) {
+	holds, err := sqlActiveHolds(context, base, executor, accountNumber, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return heldAmount(holds, at), nil
+}
+
+// PlaceHold reserves the amount of the account balance until the expiry time.
+// The amount must be positive, the expiry in the future, and the account available balance must cover the amount.
+// The account is locked while checking, so concurrent holds and journals can not overdraw it.
+func (hm *SQLHoldManager) PlaceHold(context context.Context, holdID, accountNumber string, amount decimal.Decimal, expiry time.Time, description, author string) (*Hold, error) {
+	if len(holdID) == 0 {
+		return nil, ErrHoldMissingID
+	}
+	if !amount.IsPositive() {
+		return nil, ErrHoldAmountNotPositive
+	}
+	now := time.Now().UTC()
+	if !now.Before(expiry) {
+		return nil, ErrHoldExpiryPassed
+	}
+	err := hm.inTx(context, func(tx sqlExecutor) error {
+		count, err := hm.count(context, tx, `SELECT COUNT(*) FROM acc_hold WHERE hold_id = ?`, holdID)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrHoldAlreadyPersisted
+		}
+		account, err := scanSQLAccount(hm.queryRow(context, tx, `SELECT `+sqlAccountColumns+` FROM acc_account WHERE account_number = ?`+hm.dialect.LockClause(), accountNumber))
+		if err == sql.ErrNoRows {
+			return ErrAccountIDNotFound
+		}
+		if err != nil {
+			return err
+		}
+		held, err := sqlHeldAmount(context, &hm.sqlBase, tx, accountNumber, now)
+		if err != nil {
+			return err
+		}
+		if available := account.Balance.Sub(held); available.LessThan(amount) {
+			logrus.Errorf("error placing hold %s. account %s available balance %s does not cover %s", holdID, accountNumber, available.String(), amount.String())
+			return ErrHoldInsufficientBalance
+		}
+		_, err = hm.exec(context, tx, `INSERT INTO acc_hold (`+sqlHoldColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			holdID, accountNumber, amount, decimal.Zero, HoldActive, expiry.UTC(), description, sql.NullString{}, now, author, now, author)
+		if err != nil {
+			logrus.Errorf("error persisting hold %s. got %s", holdID, err.Error())
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hm.GetHold(context, holdID)
+}
+
+// CaptureHold marks an active, not expired hold captured by the journal, posting the amount out of the held amount.
+// The journal itself is persisted by the caller, within the same unit of work.
+func (hm *SQLHoldManager) CaptureHold(context context.Context, holdID string, amount decimal.Decimal, journalID, author string) error {
+	return hm.inTx(context, func(tx sqlExecutor) error {
+		hold, err := hm.lockHold(context, tx, holdID)
+		if err != nil {
+			return err
+		}
+		if !hold.IsActiveAt(time.Now()) {
+			logrus.Errorf("error capturing hold %s. hold is %s and expires at %s", holdID, hold.Status.String(), hold.ExpiryTime.String())
+			return ErrHoldNotActive
+		}
+		if !amount.IsPositive() || amount.GreaterThan(hold.Amount) {
+			return ErrHoldCaptureAmountInvalid
+		}
+		_, err = hm.exec(context, tx, `UPDATE acc_hold SET status = ?, captured_amount = ?, journal_id = ?, update_time = ?, update_by = ? WHERE hold_id = ?`,
+			HoldCaptured, amount, journalID, time.Now().UTC(), author, holdID)
+		return err
+	})
+}
+
+// ReleaseHold marks an active hold released.
+func (hm *SQLHoldManager) ReleaseHold(context context.Context, holdID, author string) error {
+	return hm.inTx(context, func(tx sqlExecutor) error {
+		hold, err := hm.lockHold(context, tx, holdID)
+		if err != nil {
+			return err
+		}
+		if !hold.Status.canTransit(HoldReleased) {
+			logrus.Errorf("error releasing hold %s. hold is %s", holdID, hold.Status.String())
+			return ErrHoldNotActive
+		}
+		_, err = hm.exec(context, tx, `UPDATE acc_hold SET status = ?, update_time = ?, update_by = ? WHERE hold_id = ?`,
+			HoldReleased, time.Now().UTC(), author, holdID)
+		return err
+	})
+}
+
+// ExpireHolds marks every active hold whose expiry time is not after `at` expired, returning them.
+func (hm *SQLHoldManager) ExpireHolds(context context.Context, at time.Time, author string) ([]*Hold, error) {
+	var expired []*Hold
+	err := hm.inTx(context, func(tx sqlExecutor) error {
+		rows, err := hm.query(context, tx, `SELECT `+sqlHoldColumns+` FROM acc_hold WHERE status = ? AND expiry_time <= ? ORDER BY expiry_time, hold_id`+hm.dialect.LockClause(),
+			HoldActive, at.UTC())
+		if err != nil {
+			return err
+		}
+		expired, err = scanSQLHolds(rows)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		for _, hold := range expired {
+			_, err = hm.exec(context, tx, `UPDATE acc_hold SET status = ?, update_time = ?, update_by = ? WHERE hold_id = ?`,
+				HoldExpired, now, author, hold.HoldID)
+			if err != nil {
+				return err
+			}
+			hold.Status, hold.UpdateTime, hold.UpdateBy = HoldExpired, now, author
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+func (hm *SQLHoldManager) lockHold(context context.Context, tx sqlExecutor, holdID string) (*Hold, error) {
+	hold, err := scanSQLHold(hm.queryRow(context, tx, `SELECT `+sqlHoldColumns+` FROM acc_hold WHERE hold_id = ?`+hm.dialect.LockClause(), holdID))
+	if err == sql.ErrNoRows {
+		return nil, ErrHoldNotFound
+	}
+	return hold, err
+}
+
+// GetHold retrieve a hold identified by its ID.
+func (hm *SQLHoldManager) GetHold(context context.Context, holdID string) (*Hold, error) {
+	hold, err := scanSQLHold(hm.queryRow(context, hm.executor(context), `SELECT `+sqlHoldColumns+` FROM acc_hold WHERE hold_id = ?`, holdID))
+	if err == sql.ErrNoRows {
+		return nil, ErrHoldNotFound
+	}
+	return hold, err
+}
+
+// ListActiveHolds list the holds of the account active at the time, ordered by their expiry time.
+func (hm *SQLHoldManager) ListActiveHolds(context context.Context, accountNumber string, at time.Time) ([]*Hold, error) {
+	executor := hm.executor(context)
+	count, err := hm.count(context, executor, `SELECT COUNT(*) FROM acc_account WHERE account_number = ?`, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrAccountIDNotFound
+	}
+	return sqlActiveHolds(context, &hm.sqlBase, executor, accountNumber, at)
+}
+
+// GetAvailableBalance returns the account balance minus the amount of its holds active at the time.
+func (hm *SQLHoldManager) GetAvailableBalance(context context.Context, accountNumber string, at time.Time) (decimal.Decimal, error) {
+	executor := hm.executor(context)
+	var balance decimal.Decimal
+	err := hm.queryRow(context, executor, `SELECT balance FROM acc_account WHERE account_number = ?`, accountNumber).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return decimal.Zero, ErrAccountIDNotFound
+	}
+	if err != nil {
+		return decimal.Zero, err
+	}
+	held, err := sqlHeldAmount(context, &hm.sqlBase, executor, accountNumber, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return balance.Sub(held), nil
+}
+
+// NewSQLScheduleManager creates a ScheduleManager backed by SQL database.
+// The database schema must be prepared using MigrateSQLSchema.
+func NewSQLScheduleManager(db *sql.DB, dialect SQLDialect) ScheduleManager {
+	return &SQLScheduleManager{sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLScheduleManager implementation of ScheduleManager using database/sql
+type SQLScheduleManager struct {
+	sqlBase
+}
+
+const sqlScheduleColumns = `schedule_id, description, frequency, interval_count, start_time, end_time, next_sequence, create_time, create_by, update_time, update_by`
+
+func scanSQLSchedule(scanner sqlRowScanner) (*JournalSchedule, error) {
+	schedule := &JournalSchedule{Transactions: make([]TransactionInfo, 0)}
+	var endTime sql.NullTime
+	err := scanner.Scan(&schedule.ScheduleID, &schedule.Description, &schedule.Recurrence.Frequency, &schedule.Recurrence.Interval,
+		&schedule.StartTime, &endTime, &schedule.NextSequence, &schedule.CreateTime, &schedule.CreateBy, &schedule.UpdateTime, &schedule.UpdateBy)
+	if err != nil {
+		return nil, err
+	}
+	schedule.EndTime = endTime.Time
+	return schedule, nil
+}
+
+// CreateSchedule creates a schedule posting the transactions on every occurrence of the recurrence between start and end inclusive.
+// A zero end never ends the schedule.
+func (sm *SQLScheduleManager) CreateSchedule(context context.Context, scheduleID, description string, transactions []TransactionInfo, recurrence Recurrence, start, end time.Time, author string) (*JournalSchedule, error) {
+	if err := validateSchedule(scheduleID, transactions, recurrence, start, end); err != nil {
+		return nil, err
+	}
+	var endTime sql.NullTime
+	if !end.IsZero() {
+		endTime = sql.NullTime{Time: end.UTC(), Valid: true}
+	}
+	err := sm.inTx(context, func(tx sqlExecutor) error {
+		count, err := sm.count(context, tx, `SELECT COUNT(*) FROM acc_schedule WHERE schedule_id = ?`, scheduleID)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrScheduleAlreadyPersisted
+		}
+		now := time.Now().UTC()
+		_, err = sm.exec(context, tx, `INSERT INTO acc_schedule (`+sqlScheduleColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			scheduleID, description, recurrence.Frequency, recurrence.Interval, start.UTC(), endTime, 0, now, author, now, author)
+		if err != nil {
+			logrus.Errorf("error persisting schedule %s. got %s", scheduleID, err.Error())
+			return err
+		}
+		for idx, txinfo := range transactions {
+			_, err = sm.exec(context, tx, `INSERT INTO acc_schedule_transaction (schedule_id, sequence, account_number, description, transaction_type, amount) VALUES (?, ?, ?, ?, ?, ?)`,
+				scheduleID, idx, txinfo.AccountNumber, txinfo.Description, txinfo.TxType, txinfo.Amount)
+			if err != nil {
+				logrus.Errorf("error persisting schedule %s transaction %d. got %s", scheduleID, idx, err.Error())
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sm.GetSchedule(context, scheduleID)
+}
+
+// GetSchedule retrieve a schedule identified by its ID.
+func (sm *SQLScheduleManager) GetSchedule(context context.Context, scheduleID string) (*JournalSchedule, error) {
+	schedule, err := scanSQLSchedule(sm.queryRow(context, sm.executor(context), `SELECT `+sqlScheduleColumns+` FROM acc_schedule WHERE schedule_id = ?`, scheduleID))
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schedule, sm.loadTransactions(context, schedule)
+}
+
+// ListSchedules list all schedules ordered by their start time.
+func (sm *SQLScheduleManager) ListSchedules(context context.Context) ([]*JournalSchedule, error) {
+	rows, err := sm.query(context, sm.executor(context), `SELECT `+sqlScheduleColumns+` FROM acc_schedule ORDER BY start_time, schedule_id`)
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]*JournalSchedule, 0)
+	for rows.Next() {
+		schedule, err := scanSQLSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		if err := sm.loadTransactions(context, schedule); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+func (sm *SQLScheduleManager) loadTransactions(context context.Context, schedule *JournalSchedule) error {
+	rows, err := sm.query(context, sm.executor(context), `SELECT account_number, description, transaction_type, amount FROM acc_schedule_transaction WHERE schedule_id = ? ORDER BY sequence`, schedule.ScheduleID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var txinfo TransactionInfo
+		if err := rows.Scan(&txinfo.AccountNumber, &txinfo.Description, &txinfo.TxType, &txinfo.Amount); err != nil {
+			return err
+		}
+		schedule.Transactions = append(schedule.Transactions, txinfo)
+	}
+	return rows.Err()
+}
+
+// RecordScheduleRun records the outcome of a schedule run. A posted run moves the schedule NextSequence past its occurrence.
+func (sm *SQLScheduleManager) RecordScheduleRun(context context.Context, run *ScheduleRun) error {
+	return sm.inTx(context, func(tx sqlExecutor) error {
+		var nextSequence int
+		err := sm.queryRow(context, tx, `SELECT next_sequence FROM acc_schedule WHERE schedule_id = ?`+sm.dialect.LockClause(), run.ScheduleID).Scan(&nextSequence)
+		if err == sql.ErrNoRows {
+			return ErrScheduleNotFound
+		}
+		if err != nil {
+			return err
+		}
+		runNumber, err := sm.count(context, tx, `SELECT COUNT(*) FROM acc_schedule_run WHERE schedule_id = ?`, run.ScheduleID)
+		if err != nil {
+			return err
+		}
+		var journalID sql.NullString
+		if len(run.JournalID) > 0 {
+			journalID = sql.NullString{String: run.JournalID, Valid: true}
+		}
+		_, err = sm.exec(context, tx, `INSERT INTO acc_schedule_run (schedule_id, run_number, sequence, due_time, run_time, status, journal_id, message, run_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			run.ScheduleID, runNumber+1, run.Sequence, run.DueTime.UTC(), run.RunTime.UTC(), run.Status, journalID, run.Message, run.RunBy)
+		if err != nil {
+			logrus.Errorf("error recording schedule %s run. got %s", run.ScheduleID, err.Error())
+			return err
+		}
+		if run.Status == ScheduleRunPosted && run.Sequence >= nextSequence {
+			_, err = sm.exec(context, tx, `UPDATE acc_schedule SET next_sequence = ?, update_time = ?, update_by = ? WHERE schedule_id = ?`,
+				run.Sequence+1, time.Now().UTC(), run.RunBy, run.ScheduleID)
+		}
+		return err
+	})
+}
+
+// ListScheduleRuns returns the runs of the schedule in the order they were recorded.
+func (sm *SQLScheduleManager) ListScheduleRuns(context context.Context, scheduleID string) ([]*ScheduleRun, error) {
+	executor := sm.executor(context)
+	count, err := sm.count(context, executor, `SELECT COUNT(*) FROM acc_schedule WHERE schedule_id = ?`, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrScheduleNotFound
+	}
+	rows, err := sm.query(context, executor, `SELECT schedule_id, sequence, due_time, run_time, status, journal_id, message, run_by FROM acc_schedule_run WHERE schedule_id = ? ORDER BY run_number`, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := make([]*ScheduleRun, 0)
+	for rows.Next() {
+		run := &ScheduleRun{}
+		var journalID sql.NullString
+		if err := rows.Scan(&run.ScheduleID, &run.Sequence, &run.DueTime, &run.RunTime, &run.Status, &journalID, &run.Message, &run.RunBy); err != nil {
+			return nil, err
+		}
+		run.JournalID = journalID.String
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// NewSQLLotManager creates a LotManager backed by SQL database.
+// The database schema must be prepared using MigrateSQLSchema.
+func NewSQLLotManager(db *sql.DB, dialect SQLDialect) LotManager {
+	return &SQLLotManager{sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLLotManager implementation of LotManager using database/sql
+type SQLLotManager struct {
+	sqlBase
+}
+
+const sqlLotColumns = `lot_id, account_number, journal_id, amount, remaining, status, expiry_time, expiry_journal_id, create_time, create_by, update_time, update_by`
+
+func scanSQLLots(rows *sql.Rows) ([]*Lot, error) {
+	defer rows.Close()
+	lots := make([]*Lot, 0)
+	for rows.Next() {
+		lot := &Lot{}
+		var expiryJournalID sql.NullString
+		err := rows.Scan(&lot.LotID, &lot.AccountNumber, &lot.JournalID, &lot.Amount, &lot.Remaining, &lot.Status, &lot.ExpiryTime,
+			&expiryJournalID, &lot.CreateTime, &lot.CreateBy, &lot.UpdateTime, &lot.UpdateBy)
+		if err != nil {
+			return nil, err
+		}
+		lot.ExpiryJournalID = expiryJournalID.String
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// CreateLot records a new open lot, its remaining amount must be the lot amount.
+func (lm *SQLLotManager) CreateLot(context context.Context, lot *Lot) error {
+	if !lot.Amount.IsPositive() {
+		return ErrLotAmountNotPositive
+	}
+	return lm.inTx(context, func(tx sqlExecutor) error {
+		count, err := lm.count(context, tx, `SELECT COUNT(*) FROM acc_lot WHERE lot_id = ?`, lot.LotID)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrLotAlreadyPersisted
+		}
+		count, err = lm.count(context, tx, `SELECT COUNT(*) FROM acc_account WHERE account_number = ?`, lot.AccountNumber)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrAccountIDNotFound
+		}
+		sequence, err := lm.count(context, tx, `SELECT COUNT(*) FROM acc_lot WHERE account_number = ?`, lot.AccountNumber)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		_, err = lm.exec(context, tx, `INSERT INTO acc_lot (sequence, `+sqlLotColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			sequence+1, lot.LotID, lot.AccountNumber, lot.JournalID, lot.Amount, lot.Amount, LotOpen, lot.ExpiryTime.UTC(), sql.NullString{}, now, lot.CreateBy, now, lot.CreateBy)
+		if err != nil {
+			logrus.Errorf("error persisting lot %s. got %s", lot.LotID, err.Error())
+		}
+		return err
+	})
+}
+
+// ConsumeLots takes the amount out of the account open lots not expired at the time, first in first out, returning the lots changed.
+// The open lots are locked while consuming them.
+func (lm *SQLLotManager) ConsumeLots(context context.Context, accountNumber string, amount decimal.Decimal, at time.Time, author string) ([]*Lot, error) {
+	var consumed []*Lot
+	err := lm.inTx(context, func(tx sqlExecutor) error {
+		lots, err := lm.openLots(context, tx, accountNumber)
+		if err != nil {
+			return err
+		}
+		consumed, err = consumeLots(lots, amount, at)
+		if err != nil {
+			logrus.Errorf("error consuming %s from account %s lots. got %s", amount.String(), accountNumber, err.Error())
+			return err
+		}
+		return lm.updateLots(context, tx, consumed, author)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return consumed, nil
+}
+
+// ExpireLots marks the account open lots whose expiry time is not after `at` expired by the expiry journal, returning them.
+func (lm *SQLLotManager) ExpireLots(context context.Context, accountNumber string, at time.Time, expiryJournalID, author string) ([]*Lot, error) {
+	expired := make([]*Lot, 0)
+	err := lm.inTx(context, func(tx sqlExecutor) error {
+		lots, err := lm.openLots(context, tx, accountNumber)
+		if err != nil {
+			return err
+		}
+		for _, lot := range lots {
+			if at.Before(lot.ExpiryTime) {
+				continue
+			}
+			lot.Status = LotExpired
+			lot.ExpiryJournalID = expiryJournalID
+			expired = append(expired, lot)
+		}
+		return lm.updateLots(context, tx, expired, author)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// openLots returns the account open lots first in first out, locking them.
+func (lm *SQLLotManager) openLots(context context.Context, tx sqlExecutor, accountNumber string) ([]*Lot, error) {
+	rows, err := lm.query(context, tx, `SELECT `+sqlLotColumns+` FROM acc_lot WHERE account_number = ? AND status = ? ORDER BY expiry_time, sequence`+lm.dialect.LockClause(),
+		accountNumber, LotOpen)
+	if err != nil {
+		return nil, err
+	}
+	return scanSQLLots(rows)
+}
+
+func (lm *SQLLotManager) updateLots(context context.Context, tx sqlExecutor, lots []*Lot, author string) error {
+	now := time.Now().UTC()
+	for _, lot := range lots {
+		var expiryJournalID sql.NullString
+		if len(lot.ExpiryJournalID) > 0 {
+			expiryJournalID = sql.NullString{String: lot.ExpiryJournalID, Valid: true}
+		}
+		_, err := lm.exec(context, tx, `UPDATE acc_lot SET remaining = ?, status = ?, expiry_journal_id = ?, update_time = ?, update_by = ? WHERE lot_id = ?`,
+			lot.Remaining, lot.Status, expiryJournalID, now, author, lot.LotID)
+		if err != nil {
+			logrus.Errorf("error updating lot %s. got %s", lot.LotID, err.Error())
+			return err
+		}
+		lot.UpdateTime, lot.UpdateBy = now, author
+	}
+	return nil
+}
+
+// ListLots list all lots of the account, first in first out.
+func (lm *SQLLotManager) ListLots(context context.Context, accountNumber string) ([]*Lot, error) {
+	return lm.listLots(context, accountNumber, `SELECT `+sqlLotColumns+` FROM acc_lot WHERE account_number = ? ORDER BY expiry_time, sequence`, accountNumber)
+}
+
+// ListLotsExpiringBefore list the account open lots expiring before the time, first in first out.
+func (lm *SQLLotManager) ListLotsExpiringBefore(context context.Context, accountNumber string, before time.Time) ([]*Lot, error) {
+	return lm.listLots(context, accountNumber, `SELECT `+sqlLotColumns+` FROM acc_lot WHERE account_number = ? AND status = ? AND expiry_time < ? ORDER BY expiry_time, sequence`,
+		accountNumber, LotOpen, before.UTC())
+}
+
+func (lm *SQLLotManager) listLots(context context.Context, accountNumber, query string, args ...interface{}) ([]*Lot, error) {
+	executor := lm.executor(context)
+	count, err := lm.count(context, executor, `SELECT COUNT(*) FROM acc_account WHERE account_number = ?`, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrAccountIDNotFound
+	}
+	rows, err := lm.query(context, executor, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanSQLLots(rows)
+}
+
+// NewSQLEventLog creates a new EventLog working on the database.
+func NewSQLEventLog(db *sql.DB, dialect SQLDialect) EventLog {
+	return &SQLEventLog{sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLEventLog implementation of EventLog using database/sql
+type SQLEventLog struct {
+	sqlBase
+}
+
+// AppendEvents appends the events at the end of the log in the given order, numbering them from the next sequence.
+// The sequence is the primary key, so a concurrent writer appending after the same sequence fails.
+func (el *SQLEventLog) AppendEvents(context context.Context, afterSequence int64, events []*LedgerEvent) error {
+	return el.inTx(context, func(tx sqlExecutor) error {
+		var lastSequence int64
+		err := el.queryRow(context, tx, `SELECT COALESCE(MAX(sequence), 0) FROM acc_ledger_event`).Scan(&lastSequence)
+		if err != nil {
+			return err
+		}
+		if lastSequence != afterSequence {
+			logrus.Errorf("error appending ledger events after %d. the log is at %d", afterSequence, lastSequence)
+			return ErrLedgerEventSequenceConflict
+		}
+		for _, event := range events {
+			_, err = el.exec(context, tx, `INSERT INTO acc_ledger_event (sequence, event_type, event_time, payload) VALUES (?, ?, ?, ?)`,
+				lastSequence+1, event.Type, event.EventTime.UTC(), string(event.Payload))
+			if err != nil {
+				logrus.Errorf("error appending ledger event %d. got %s", lastSequence+1, err.Error())
+				return err
+			}
+			lastSequence++
+		}
+		// the sequences are only set once all events are in
+		for i, event := range events {
+			event.Sequence = afterSequence + int64(i) + 1
+		}
+		return nil
+	})
+}
+
+// ReadEvents returns the events after the `afterSequence`, in sequence order.
+func (el *SQLEventLog) ReadEvents(context context.Context, afterSequence int64) ([]*LedgerEvent, error) {
+	rows, err := el.query(context, el.executor(context), `SELECT sequence, event_type, event_time, payload FROM acc_ledger_event WHERE sequence > ? ORDER BY sequence`, afterSequence)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := make([]*LedgerEvent, 0)
+	for rows.Next() {
+		event := &LedgerEvent{}
+		var payload string
+		if err := rows.Scan(&event.Sequence, &event.Type, &event.EventTime, &payload); err != nil {
+			return nil, err
+		}
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// SaveSnapshot records the snapshot of the projections as of its Sequence, replacing an older snapshot of the same Sequence.
+func (el *SQLEventLog) SaveSnapshot(context context.Context, snapshot *LedgerSnapshot) error {
+	return el.inTx(context, func(tx sqlExecutor) error {
+		_, err := el.exec(context, tx, `DELETE FROM acc_ledger_snapshot WHERE sequence = ?`, snapshot.Sequence)
+		if err != nil {
+			return err
+		}
+		_, err = el.exec(context, tx, `INSERT INTO acc_ledger_snapshot (sequence, snapshot_time, payload) VALUES (?, ?, ?)`,
+			snapshot.Sequence, snapshot.SnapshotTime.UTC(), string(snapshot.Payload))
+		if err != nil {
+			logrus.Errorf("error saving ledger snapshot %d. got %s", snapshot.Sequence, err.Error())
+		}
+		return err
+	})
+}
+
+// GetLatestSnapshot returns the snapshot with the highest Sequence.
+func (el *SQLEventLog) GetLatestSnapshot(context context.Context) (*LedgerSnapshot, error) {
+	snapshot := &LedgerSnapshot{}
+	var payload string
+	err := el.queryRow(context, el.executor(context), `SELECT sequence, snapshot_time, payload FROM acc_ledger_snapshot ORDER BY sequence DESC LIMIT 1`).
+		Scan(&snapshot.Sequence, &snapshot.SnapshotTime, &payload)
+	if err == sql.ErrNoRows {
+		return nil, ErrLedgerSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Payload = []byte(payload)
+	return snapshot, nil
+}
+
+// NewSQLOutboxManager creates a new OutboxManager working on the database.
+func NewSQLOutboxManager(db *sql.DB, dialect SQLDialect) OutboxManager {
+	return &SQLOutboxManager{sqlBase{db: db, dialect: dialect}}
+}
+
+// SQLOutboxManager implementation of OutboxManager using database/sql
+type SQLOutboxManager struct {
+	sqlBase
+}
+
+const sqlOutboxColumns = `event_offset, event_type, event_time, journal_id, account_number, transaction_id, alignment, amount, balance`
+
+// ReadOutbox returns at most `limit` events after the `afterOffset`, in offset order.
+func (om *SQLOutboxManager) ReadOutbox(context context.Context, afterOffset int64, limit int) ([]*OutboxEvent, error) {
+	rows, err := om.query(context, om.executor(context), `SELECT `+sqlOutboxColumns+` FROM acc_outbox WHERE event_offset > ? ORDER BY event_offset LIMIT ?`, afterOffset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := make([]*OutboxEvent, 0)
+	for rows.Next() {
+		event := &OutboxEvent{}
+		if err := rows.Scan(&event.Offset, &event.Type, &event.EventTime, &event.JournalID, &event.AccountNumber, &event.TransactionID,
+			&event.Alignment, &event.Amount, &event.Balance); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// GetSubscriberOffset returns the offset of the last event delivered to the subscriber, zero if none was delivered.
+func (om *SQLOutboxManager) GetSubscriberOffset(context context.Context, subscriber string) (int64, error) {
+	var offset int64
+	err := om.queryRow(context, om.executor(context), `SELECT last_offset FROM acc_outbox_subscriber WHERE subscriber = ?`, subscriber).Scan(&offset)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return offset, err
+}
+
+// SetSubscriberOffset records the offset of the last event delivered to the subscriber.
+func (om *SQLOutboxManager) SetSubscriberOffset(context context.Context, subscriber string, offset int64) error {
+	return om.inTx(context, func(tx sqlExecutor) error {
+		_, err := om.exec(context, tx, `DELETE FROM acc_outbox_subscriber WHERE subscriber = ?`, subscriber)
+		if err != nil {
+			return err
+		}
+		_, err = om.exec(context, tx, `INSERT INTO acc_outbox_subscriber (subscriber, last_offset) VALUES (?, ?)`, subscriber, offset)
+		if err != nil {
+			logrus.Errorf("error recording outbox subscriber %s offset %d. got %s", subscriber, offset, err.Error())
+		}
+		return err
+	})
+}
@@ -0,0 +1,88 @@
+package acccore
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openTestSQLDatabase opens a freshly migrated database. It uses PostgreSQL if the
+// ACCCORE_TEST_POSTGRES_DSN environment variable is set, otherwise an embedded SQLite file.
+func openTestSQLDatabase(t *testing.T) (*sql.DB, SQLDialect) {
+	var db *sql.DB
+	var dialect SQLDialect
+	var err error
+	if dsn := os.Getenv("ACCCORE_TEST_POSTGRES_DSN"); len(dsn) > 0 {
+		db, err = sql.Open("postgres", dsn)
+		dialect = &PostgreSQLDialect{}
+		if err == nil {
+			for _, table := range []string{"acc_outbox_subscriber", "acc_outbox", "acc_ledger_snapshot", "acc_ledger_event", "acc_journal_chain", "acc_lot", "acc_schedule_run", "acc_schedule_transaction", "acc_schedule", "acc_period_closing_journal", "acc_period", "acc_transaction", "acc_journal", "acc_account_status_change", "acc_hold", "acc_account", "acc_currency_rate", "acc_currency", "acc_schema_migration"} {
+				_, err = db.Exec("DROP TABLE IF EXISTS " + table)
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			err = MigrateSQLSchema(context.Background(), db, dialect)
+		}
+	} else {
+		db, err = sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "acccore.db")+"?_txlock=immediate")
+		dialect = &SQLiteDialect{}
+		if err == nil {
+			err = BootstrapSQLite(context.Background(), db)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, dialect
+}
+
+func TestSQLManagers_Behaviour(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testManagersBehaviour(t, NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect))
+}
+
+func TestSQLTransactionManager_BalanceHistory(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testBalanceHistory(t, NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect))
+}
+
+func TestSQLTxManager_UnitOfWork(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db))
+	testUnitOfWorkBehaviour(t, acc)
+}
+
+func TestSQLExchangeManager_CalculateExchange(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testExchangeManagerBehaviour(t, NewSQLExchangeManager(db, dialect))
+}
+
+func TestSQLExchangeManager_RateHistory(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testExchangeRateHistory(t, NewSQLExchangeManager(db, dialect))
+}
+
+func TestMigrateSQLSchema_Idempotent(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	assert.NoError(t, MigrateSQLSchema(context.Background(), db, dialect))
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM acc_schema_migration").Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, len(SQLMigrations), count)
+}
+
+func TestPostgreSQLDialect_Rebind(t *testing.T) {
+	dialect := &PostgreSQLDialect{}
+	assert.Equal(t, "SELECT * FROM acc_account WHERE coa = $1 LIMIT $2 OFFSET $3", dialect.Rebind("SELECT * FROM acc_account WHERE coa = ? LIMIT ? OFFSET ?"))
+}
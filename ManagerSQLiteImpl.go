@@ -0,0 +1,66 @@
+package acccore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/sirupsen/logrus"
+)
+
+// SQLiteDialect is the SQLDialect for embedded SQLite database.
+// SQLite has no row level lock, a write transaction locks the whole database file instead.
+type SQLiteDialect struct{}
+
+// Rebind returns the query as is, SQLite understand the `?` placeholders.
+func (d *SQLiteDialect) Rebind(query string) string {
+	return query
+}
+
+// LockClause returns empty string since SQLite do not support SELECT ... FOR UPDATE
+func (d *SQLiteDialect) LockClause() string {
+	return ""
+}
+
+// DecimalType returns the column type used for storing decimal values.
+// Decimals are stored as TEXT so SQLite never converts them into floating point.
+func (d *SQLiteDialect) DecimalType() string {
+	return "TEXT"
+}
+
+// TimestampType returns the column type used for storing timestamp values
+func (d *SQLiteDialect) TimestampType() string {
+	return "TIMESTAMP"
+}
+
+// BooleanType returns the column type used for storing boolean values
+func (d *SQLiteDialect) BooleanType() string {
+	return "BOOLEAN"
+}
+
+// SQLitePragmas are executed by BootstrapSQLite before migrating the schema.
+// WAL journal with FULL synchronous make every committed journal durable across crash and restart.
+var SQLitePragmas = []string{
+	"PRAGMA journal_mode = WAL",
+	"PRAGMA synchronous = FULL",
+	"PRAGMA foreign_keys = ON",
+	"PRAGMA busy_timeout = 5000",
+}
+
+// BootstrapSQLite prepares a SQLite database file to be used by the SQL managers using SQLiteDialect.
+// Since SQLite pragmas only apply to the connection they are executed on, and SQLite only allow one writer
+// at a time, the connection pool is limited to a single connection.
+// The driver is up to the caller, e.g. :
+//
+//	db, err := sql.Open("sqlite3", "/var/lib/ledger.db")
+//	err = BootstrapSQLite(ctx, db)
+//	accounting := NewAccounting(NewSQLAccountManager(db, &SQLiteDialect{}), NewSQLTransactionManager(db, &SQLiteDialect{}),
+//		NewSQLJournalManager(db, &SQLiteDialect{}), &UUIDUniqueIDGenerator{})
+func BootstrapSQLite(context context.Context, db *sql.DB) error {
+	db.SetMaxOpenConns(1)
+	for _, pragma := range SQLitePragmas {
+		if _, err := db.ExecContext(context, pragma); err != nil {
+			logrus.Errorf("error bootstrapping sqlite. %s got %s", pragma, err.Error())
+			return err
+		}
+	}
+	return MigrateSQLSchema(context, db, &SQLiteDialect{})
+}
@@ -0,0 +1,60 @@
+package acccore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func openTestSQLite(t *testing.T, path string) (*sql.DB, *Accounting) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := BootstrapSQLite(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	dialect := &SQLiteDialect{}
+	return db, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{})
+}
+
+func TestBootstrapSQLite_WAL(t *testing.T) {
+	db, _ := openTestSQLite(t, filepath.Join(t.TempDir(), "ledger.db"))
+	defer db.Close()
+
+	var mode string
+	assert.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+	var foreignKeys int
+	assert.NoError(t, db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys))
+	assert.Equal(t, 1, foreignKeys)
+}
+
+func TestSQLite_SurviveRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.db")
+
+	db, acc := openTestSQLite(t, path)
+	reserve, err := acc.CreateNewAccount(ctx, "", "Point Reserve", "Point issued by the system", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+	wallet, err := acc.CreateNewAccount(ctx, "", "User Point", "Point owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+	journal, err := acc.CreateNewJournal(ctx, "Point grant", []TransactionInfo{
+		{AccountNumber: reserve.GetAccountNumber(), Description: "Issue", TxType: DEBIT, Amount: decimal.NewFromInt(150)},
+		{AccountNumber: wallet.GetAccountNumber(), Description: "Grant", TxType: CREDIT, Amount: decimal.NewFromInt(150)},
+	}, "tester")
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	db, acc = openTestSQLite(t, path)
+	defer db.Close()
+	loaded, err := acc.GetAccountManager().GetAccountByID(ctx, wallet.GetAccountNumber())
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(150).Equal(loaded.GetBalance()))
+	persisted, err := acc.GetJournalManager().GetJournalByID(ctx, journal.GetJournalID())
+	assert.NoError(t, err)
+	assert.Len(t, persisted.GetTransactions(), 2)
+}
@@ -22,21 +22,90 @@ var (
 	ErrJournalIDNotFound                   = fmt.Errorf("journal with specified ID not in database")
 	ErrJournalLoadReversalInconsistent     = fmt.Errorf("reversed journal reverence to unexistent journal")
 	ErrJournalCanNotDoubleReverse          = fmt.Errorf("journal can only reversed once")
+	ErrJournalNotReversed                  = fmt.Errorf("journal has not been reversed")
+	ErrJournalNotCorrected                 = fmt.Errorf("journal has not been corrected")
+	ErrReversalTransactionNotFound         = fmt.Errorf("transaction to reverse is not part of the reversed journal")
+	ErrReversalAmountInvalid               = fmt.Errorf("reversal amount must be positive and not exceed the reversed journal amount")
+	ErrJournalPeriodClosed                 = fmt.Errorf("journal time falls in a closed accounting period")
+	ErrJournalAccountFrozen                = fmt.Errorf("journal Transactions refering to frozen account")
+	ErrJournalAccountClosed                = fmt.Errorf("journal Transactions refering to closed account")
+	ErrJournalIdempotencyKeyAlreadyExist   = fmt.Errorf("journal with the same idempotency key is already persisted")
+	ErrJournalIdempotencyKeyNotFound       = fmt.Errorf("journal with specified idempotency key not in database")
+	ErrJournalIdempotencyKeyConflict       = fmt.Errorf("idempotency key is already used by a journal with different content")
+	ErrJournalAvailableBalanceInsufficient = fmt.Errorf("journal Transactions bring account balance below its held amount")
 
-	ErrAccountAlreadyPersisted   = fmt.Errorf("account is already persisted")
-	ErrAccountIsNotPersisted     = fmt.Errorf("account is not persisted")
-	ErrAccountIDNotFound         = fmt.Errorf("account AccountNumber not in database")
-	ErrAccountMissingID          = fmt.Errorf("account AccountNumber or number is not provided")
-	ErrAccountMissingName        = fmt.Errorf("account Name is not provided")
-	ErrAccountMissingDescription = fmt.Errorf("account Description is not provided")
-	ErrAccountMissingCreator     = fmt.Errorf("account creator is not provided")
+	ErrAccountAlreadyPersisted    = fmt.Errorf("account is already persisted")
+	ErrAccountIsNotPersisted      = fmt.Errorf("account is not persisted")
+	ErrAccountIDNotFound          = fmt.Errorf("account AccountNumber not in database")
+	ErrAccountMissingID           = fmt.Errorf("account AccountNumber or number is not provided")
+	ErrAccountMissingName         = fmt.Errorf("account Name is not provided")
+	ErrAccountMissingDescription  = fmt.Errorf("account Description is not provided")
+	ErrAccountMissingCreator      = fmt.Errorf("account creator is not provided")
+	ErrAccountStatusInvalid       = fmt.Errorf("account can not change into the status")
+	ErrAccountStatusNoAuthor      = fmt.Errorf("account status change author is not provided")
+	ErrAccountCloseNonZero        = fmt.Errorf("account can only be closed with zero balance")
+	ErrAccountBalanceLimitInvalid = fmt.Errorf("account minimum balance is above its maximum balance")
 
 	ErrTransactionNotFound = fmt.Errorf("transaction AccountNumber not in database")
 
 	ErrCurrencyNotFound         = fmt.Errorf("currency not found")
 	ErrCurrencyAlreadyPersisted = fmt.Errorf("currency already persisted")
+	ErrCurrencyRateNotFound     = fmt.Errorf("currency has no exchange rate valid at the specified time")
+	ErrCurrencyRateAlreadyExist = fmt.Errorf("currency already have exchange rate valid from the specified time")
+
+	ErrPeriodNotFound          = fmt.Errorf("accounting period not found")
+	ErrPeriodAlreadyPersisted  = fmt.Errorf("accounting period already persisted")
+	ErrPeriodMissingID         = fmt.Errorf("accounting period ID is not provided")
+	ErrPeriodInvalidRange      = fmt.Errorf("accounting period must start before it ends")
+	ErrPeriodOverlap           = fmt.Errorf("accounting period overlaps with another period")
+	ErrPeriodInvalidTransition = fmt.Errorf("accounting period status can not change that way")
+
+	ErrHoldNotFound             = fmt.Errorf("hold not found")
+	ErrHoldAlreadyPersisted     = fmt.Errorf("hold already persisted")
+	ErrHoldMissingID            = fmt.Errorf("hold ID is not provided")
+	ErrHoldAmountNotPositive    = fmt.Errorf("hold amount must be positive")
+	ErrHoldExpiryPassed         = fmt.Errorf("hold expiry time has passed")
+	ErrHoldInsufficientBalance  = fmt.Errorf("account available balance does not cover the hold amount")
+	ErrHoldNotActive            = fmt.Errorf("hold is no longer active")
+	ErrHoldCaptureAmountInvalid = fmt.Errorf("hold capture amount must be positive and not exceed the held amount")
+
+	ErrScheduleNotFound          = fmt.Errorf("journal schedule not found")
+	ErrScheduleAlreadyPersisted  = fmt.Errorf("journal schedule already persisted")
+	ErrScheduleMissingID         = fmt.Errorf("journal schedule ID is not provided")
+	ErrScheduleNoTransaction     = fmt.Errorf("journal schedule contains no Transactions")
+	ErrScheduleInvalidRange      = fmt.Errorf("journal schedule must not end before it starts")
+	ErrScheduleInvalidRecurrence = fmt.Errorf("journal schedule recurrence needs a known frequency and a positive interval")
+
+	ErrLotAlreadyPersisted  = fmt.Errorf("lot already persisted")
+	ErrLotAmountNotPositive = fmt.Errorf("lot amount must be positive")
+	ErrLotInsufficient      = fmt.Errorf("unexpired lots of the account do not cover the debit")
+
+	ErrLedgerEventSequenceConflict = fmt.Errorf("ledger event log has events appended after the expected sequence")
+	ErrLedgerEventTypeUnknown      = fmt.Errorf("ledger event type is not known")
+	ErrLedgerSnapshotNotFound      = fmt.Errorf("ledger event log has no snapshot")
+
+	ErrUnitOfWorkFinished = fmt.Errorf("unit of work is already committed or rolled back")
+	ErrTxManagerNotSet    = fmt.Errorf("accounting has no TxManager to run a unit of work")
 )
 
+// UnitOfWork is a group of manager operations that will be committed or rolled back together.
+type UnitOfWork interface {
+	// Commit makes all changes done within this unit of work permanent.
+	Commit() error
+	// Rollback discards all changes done within this unit of work.
+	Rollback() error
+}
+
+// TxManager is interface used for beginning a unit of work across the managers.
+// The unit of work is carried by the returned context. Every manager operation called using that context
+// takes part in the unit of work, thus will be committed or rolled back together.
+type TxManager interface {
+	// Begin starts a new unit of work and returns the context carrying it.
+	// If the context already carries a unit of work, the returned unit of work join the existing one,
+	// its Commit and Rollback do nothing and leave the decision to the outermost unit of work.
+	Begin(context context.Context) (context.Context, UnitOfWork, error)
+}
+
 // JournalManager is interface used of managing journals
 type JournalManager interface {
 	// NewJournal will create new blank un-persisted journal
@@ -49,11 +118,22 @@ type JournalManager interface {
 	//    3.Each of this account must belong to the same Currency
 	//    4.Balanced. The total sum of DEBIT and total sum of CREDIT is equal.
 	//    5.No duplicate transaction that belongs to the same Account.
+	//    6.No other journal with the same idempotency key, if the journal has one.
+	//    7.Accepted by the account status, frozen accounts reject the frozen alignment and closed accounts reject all.
+	//    8.Keeping every account balance within its BalanceLimit, otherwise a *BalanceLimitError is returned.
+	//    9.Leaving enough balance for the active holds of every account it reduces.
+	// The persisted journal is chained after the last persisted journal, its PreviousHash and Hash are set.
+	// These are checked by the rules of the JournalValidator set with SetJournalValidator, followed by its registered rules.
+	// In the same unit, an OutboxJournalPosted event and an OutboxAccountBalanceChanged event for each transaction
+	// are written into the outbox read by the OutboxManager.
 	// If your database support 2 phased commit, you can make all Balance changes in
 	// accounts and Transactions. If your db do not support this, you can implement your own 2 phase commits mechanism
 	// on the CommitJournal and CancelJournal
 	PersistJournal(context context.Context, journalToPersist Journal) error
 
+	// SetJournalValidator sets the validator PersistJournal runs, nil restores the validator of the built-in rules.
+	SetJournalValidator(validator *JournalValidator)
+
 	// CommitJournal will commit the journal into the system
 	// Only non committed journal can be committed.
 	// use this if the implementation database do not support 2 phased commit.
@@ -71,6 +151,14 @@ type JournalManager interface {
 	// IsJournalIDReversed check if the journal with specified ID has been reversed
 	IsJournalIDReversed(context context.Context, journalID string) (bool, error)
 
+	// GetReversalOf retrieved the Journal reversing the journal with specified ID.
+	// ErrJournalIDNotFound should be returned if the journal is not exist, ErrJournalNotReversed if it has not been reversed.
+	GetReversalOf(context context.Context, journalID string) (Journal, error)
+
+	// GetCorrectionOf retrieved the Journal correcting the journal with specified ID.
+	// ErrJournalIDNotFound should be returned if the journal is not exist, ErrJournalNotCorrected if it has not been corrected.
+	GetCorrectionOf(context context.Context, journalID string) (Journal, error)
+
 	// IsJournalIDExist will check if an Journal ID/number is exist in the database.
 	IsJournalIDExist(context context.Context, journalID string) (bool, error)
 
@@ -78,14 +166,117 @@ type JournalManager interface {
 	// the provided ID must be exactly the same, not uses the LIKE select expression.
 	GetJournalByID(context context.Context, journalID string) (Journal, error)
 
+	// GetJournalByIdempotencyKey retrieved the Journal persisted with the idempotency key.
+	// ErrJournalIdempotencyKeyNotFound should be returned if there is none.
+	GetJournalByIdempotencyKey(context context.Context, idempotencyKey string) (Journal, error)
+
 	// ListJournals retrieve list of journals with transaction date between the `from` and `until` time range inclusive.
 	// This function uses pagination.
 	ListJournals(context context.Context, from time.Time, until time.Time, request PageRequest) (PageResult, []Journal, error)
 
+	// VerifyJournalChain walks the journals in persisting order, recomputing each journal hash from its content and transactions,
+	// and reports the first journal whose hash or link to the journal before it is broken.
+	VerifyJournalChain(context context.Context) (*JournalChainVerification, error)
+
 	// RenderJournal Render this journal into string for easy inspection
 	RenderJournal(context context.Context, journal Journal) string
 }
 
+// PeriodManager is interface used for managing accounting periods.
+// A period is OPEN when created, it can be CLOSED and then LOCKED. A closed period may be reopened, a locked one can not.
+// PersistJournal rejects journals whose JournalingTime falls in a CLOSED or LOCKED period.
+type PeriodManager interface {
+	// OpenPeriod creates a new open period between `from` and `until` time range inclusive.
+	// Error should be returned if the period overlaps with another period.
+	OpenPeriod(context context.Context, periodID, description string, from, until time.Time, author string) (*Period, error)
+
+	// ClosePeriod closes an open period, recording the closing journals posted for it.
+	ClosePeriod(context context.Context, periodID string, closingJournalIDs []string, author string) error
+
+	// ReopenPeriod opens back a closed period. Locked period can not be reopened.
+	ReopenPeriod(context context.Context, periodID, author string) error
+
+	// LockPeriod locks a closed period for good.
+	LockPeriod(context context.Context, periodID, author string) error
+
+	// GetPeriodByID retrieve a period identified by its ID.
+	GetPeriodByID(context context.Context, periodID string) (*Period, error)
+
+	// GetPeriodAt retrieve the period containing the specified time, ErrPeriodNotFound if there is none.
+	GetPeriodAt(context context.Context, at time.Time) (*Period, error)
+
+	// ListPeriods list all periods ordered by their start time.
+	ListPeriods(context context.Context) ([]*Period, error)
+}
+
+// HoldManager is interface used for managing holds on account balances.
+// A hold is ACTIVE when placed, it is then CAPTURED, RELEASED or EXPIRED. An active hold passed its expiry time
+// no longer reserves its amount, ExpireHolds marks it EXPIRED.
+// PersistJournal rejects journals reducing an account balance below the amount of its active holds.
+type HoldManager interface {
+	// PlaceHold reserves the amount of the account balance until the expiry time.
+	// The amount must be positive, the expiry in the future, and the account available balance must cover the amount.
+	PlaceHold(context context.Context, holdID, accountNumber string, amount decimal.Decimal, expiry time.Time, description, author string) (*Hold, error)
+
+	// CaptureHold marks an active, not expired hold captured by the journal, posting the amount out of the held amount.
+	// The journal itself is persisted by the caller, within the same unit of work.
+	CaptureHold(context context.Context, holdID string, amount decimal.Decimal, journalID, author string) error
+
+	// ReleaseHold marks an active hold released.
+	ReleaseHold(context context.Context, holdID, author string) error
+
+	// ExpireHolds marks every active hold whose expiry time is not after `at` expired, returning them.
+	ExpireHolds(context context.Context, at time.Time, author string) ([]*Hold, error)
+
+	// GetHold retrieve a hold identified by its ID.
+	GetHold(context context.Context, holdID string) (*Hold, error)
+
+	// ListActiveHolds list the holds of the account active at the time, ordered by their expiry time.
+	ListActiveHolds(context context.Context, accountNumber string, at time.Time) ([]*Hold, error)
+
+	// GetAvailableBalance returns the account balance minus the amount of its holds active at the time.
+	GetAvailableBalance(context context.Context, accountNumber string, at time.Time) (decimal.Decimal, error)
+}
+
+// ScheduleManager is interface used for managing journal schedules and the record of their runs.
+type ScheduleManager interface {
+	// CreateSchedule creates a schedule posting the transactions on every occurrence of the recurrence between start and end inclusive.
+	// A zero end never ends the schedule.
+	CreateSchedule(context context.Context, scheduleID, description string, transactions []TransactionInfo, recurrence Recurrence, start, end time.Time, author string) (*JournalSchedule, error)
+
+	// GetSchedule retrieve a schedule identified by its ID.
+	GetSchedule(context context.Context, scheduleID string) (*JournalSchedule, error)
+
+	// ListSchedules list all schedules ordered by their start time.
+	ListSchedules(context context.Context) ([]*JournalSchedule, error)
+
+	// RecordScheduleRun records the outcome of a schedule run. A posted run moves the schedule NextSequence past its occurrence.
+	RecordScheduleRun(context context.Context, run *ScheduleRun) error
+
+	// ListScheduleRuns returns the runs of the schedule in the order they were recorded.
+	ListScheduleRuns(context context.Context, scheduleID string) ([]*ScheduleRun, error)
+}
+
+// LotManager is interface used for managing the lots of lot tracked accounts.
+// Lots are consumed first in first out, ordered by their expiry time and then by the order they were created.
+type LotManager interface {
+	// CreateLot records a new open lot, its remaining amount must be the lot amount.
+	CreateLot(context context.Context, lot *Lot) error
+
+	// ConsumeLots takes the amount out of the account open lots not expired at the time, first in first out, returning the lots changed.
+	// ErrLotInsufficient should be returned if those lots do not cover the amount.
+	ConsumeLots(context context.Context, accountNumber string, amount decimal.Decimal, at time.Time, author string) ([]*Lot, error)
+
+	// ExpireLots marks the account open lots whose expiry time is not after `at` expired by the expiry journal, returning them.
+	ExpireLots(context context.Context, accountNumber string, at time.Time, expiryJournalID, author string) ([]*Lot, error)
+
+	// ListLots list all lots of the account, first in first out.
+	ListLots(context context.Context, accountNumber string) ([]*Lot, error)
+
+	// ListLotsExpiringBefore list the account open lots expiring before the time, first in first out.
+	ListLotsExpiringBefore(context context.Context, accountNumber string, before time.Time) ([]*Lot, error)
+}
+
 // TransactionManager is interface used for managing transaction data/table
 type TransactionManager interface {
 	// NewTransaction will create new blank un-persisted Transaction
@@ -98,17 +289,27 @@ type TransactionManager interface {
 	GetTransactionByID(context context.Context, id string) (Transaction, error)
 
 	// ListTransactionsWithAccount retrieves list of Transactions that belongs to this account
-	// that transaction happens between the `from` and `until` time range.
+	// that transaction happens between the `from` and `until` time range, in posting order.
 	// This function uses pagination
 	ListTransactionsOnAccount(context context.Context, from time.Time, until time.Time, account Account, request PageRequest) (PageResult, []Transaction, error)
 
 	// RenderTransactionsOnAccount Render list of transaction been down on an account in a time span
 	RenderTransactionsOnAccount(context context.Context, from time.Time, until time.Time, account Account, request PageRequest) (string, error)
+
+	// GetAccountBalanceAt returns the account balance as of the specified time, after all transactions up to and including that time.
+	// The balance is the current account balance with the transactions after that time taken back, thus the cost grows
+	// with the number of transactions posted on the account after that time. Unlike the posting order balances kept on each
+	// transaction, this stays right when a journal is posted back dated.
+	GetAccountBalanceAt(context context.Context, account Account, at time.Time) (decimal.Decimal, error)
+
+	// GetAccountBalanceRange returns the account opening balance, before any transaction in the `from` and `until` time range,
+	// and the closing balance, after all transactions in that time range.
+	GetAccountBalanceRange(context context.Context, account Account, from time.Time, until time.Time) (opening decimal.Decimal, closing decimal.Decimal, err error)
 }
 
 // AccountManager interface is used for managing Accounts
 type AccountManager interface {
-	// NewAccount will create a new blank un-persisted account.
+	// NewAccount will create a new blank un-persisted account, with UnlimitedBalance.
 	NewAccount(context context.Context) Account
 
 	// PersistAccount will save the account into database.
@@ -117,6 +318,7 @@ type AccountManager interface {
 
 	// UpdateAccount will update the account database to reflect to the provided account information.
 	// This update account function will fail if the account ID/number is not existing in the database.
+	// The account balance is only changed by journals, the balance of the provided account is ignored.
 	UpdateAccount(context context.Context, AccountToUpdate Account) error
 
 	// IsAccountIDExist will check if an account ID/number is exist in the database.
@@ -136,6 +338,14 @@ type AccountManager interface {
 	// FindAccounts returns list of accounts that have their Name contains a substring of specified parameter.
 	// this search should  be case insensitive.
 	FindAccounts(context context.Context, nameLike string, request PageRequest) (PageResult, []Account, error)
+
+	// ChangeAccountStatus changes the account into the new status, recording the reason and author of the change.
+	// Active and frozen accounts may change into each other. They may be closed if the account balance is zero.
+	// A closed account can not change anymore.
+	ChangeAccountStatus(context context.Context, accountNumber string, newStatus AccountStatus, reason, author string) error
+
+	// ListAccountStatusChanges returns the status changes of the account, oldest first.
+	ListAccountStatusChanges(context context.Context, accountNumber string) ([]*AccountStatusChange, error)
 }
 
 // ExchangeManager will define functions to be implemented for Currency exchanges.
@@ -170,4 +380,47 @@ type ExchangeManager interface {
 	// If any of the Currency is not exist, an error should be returned.
 	// if from and to Currency is equal, the returned Amount must be equal to the Amount in the argument.
 	CalculateExchange(context context.Context, fromCurrency, toCurrency string, amount decimal.Decimal) (decimal.Decimal, error)
+
+	// AddCurrencyRate records the currency exchange value effective from the validFrom time, keeping the other rates as history.
+	// CreateCurrency and UpdateCurrency record their exchange value as a rate valid from the time they are called.
+	// If the new rate is the latest one already in effect, it also becomes the currency exchange value.
+	AddCurrencyRate(context context.Context, code string, exchange decimal.Decimal, validFrom time.Time, author string) error
+	// ListCurrencyRates list the exchange rate history of the currency, ordered by their valid from time.
+	ListCurrencyRates(context context.Context, code string) ([]*CurrencyRate, error)
+	// CalculateExchangeRateAt is CalculateExchangeRate using the currency rates valid at the specified time.
+	// ErrCurrencyRateNotFound should be returned if any of the Currency have no rate valid at that time.
+	CalculateExchangeRateAt(context context.Context, fromCurrency, toCurrency string, at time.Time) (decimal.Decimal, error)
+	// CalculateExchangeAt is CalculateExchange using the currency rates valid at the specified time.
+	CalculateExchangeAt(context context.Context, fromCurrency, toCurrency string, amount decimal.Decimal, at time.Time) (decimal.Decimal, error)
+}
+
+// OutboxManager is interface used for reading the outbox written by JournalManager.PersistJournal,
+// and keeping the offset each outbox subscriber has been delivered up to.
+type OutboxManager interface {
+	// ReadOutbox returns at most `limit` events after the `afterOffset`, in offset order.
+	// Only the events of committed journals are returned, so a delivered offset is never taken by another event.
+	ReadOutbox(context context.Context, afterOffset int64, limit int) ([]*OutboxEvent, error)
+
+	// GetSubscriberOffset returns the offset of the last event delivered to the subscriber, zero if none was delivered.
+	GetSubscriberOffset(context context.Context, subscriber string) (int64, error)
+
+	// SetSubscriberOffset records the offset of the last event delivered to the subscriber.
+	SetSubscriberOffset(context context.Context, subscriber string, offset int64) error
+}
+
+// EventLog is interface used for keeping the append-only ledger event stream of an EventSourcedStore, and the snapshots of its projections.
+type EventLog interface {
+	// AppendEvents appends the events at the end of the log in the given order, numbering them from the next sequence.
+	// ErrLedgerEventSequenceConflict should be returned if the last event in the log is not at the `afterSequence`,
+	// so two writers can not append to the same log unknowingly.
+	AppendEvents(context context.Context, afterSequence int64, events []*LedgerEvent) error
+
+	// ReadEvents returns the events after the `afterSequence`, in sequence order.
+	ReadEvents(context context.Context, afterSequence int64) ([]*LedgerEvent, error)
+
+	// SaveSnapshot records the snapshot of the projections as of its Sequence.
+	SaveSnapshot(context context.Context, snapshot *LedgerSnapshot) error
+
+	// GetLatestSnapshot returns the snapshot with the highest Sequence, ErrLedgerSnapshotNotFound should be returned if there is none.
+	GetLatestSnapshot(context context.Context) (*LedgerSnapshot, error)
 }
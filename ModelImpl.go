@@ -8,38 +8,50 @@ import (
 
 // BaseJournal is the base implementation of Journal
 type BaseJournal struct {
-	JournalID       string          `json:"journal_id"`
-	JournalingTime  time.Time       `json:"journaling_time"`
-	Description     string          `json:"description"`
-	Reversal        bool            `json:"reversal"`
-	ReversedJournal Journal         `json:"reversed_journal"`
-	Amount          decimal.Decimal `json:"amount"`
-	Transactions    []Transaction   `json:"transactions"`
-	CreateTime      time.Time       `json:"create_time"`
-	CreatedBy       string          `json:"created_by"`
+	JournalID          string          `json:"journal_id"`
+	JournalingTime     time.Time       `json:"journaling_time"`
+	Description        string          `json:"description"`
+	Reversal           bool            `json:"reversal"`
+	ReversedJournal    Journal         `json:"reversed_journal"`
+	Amount             decimal.Decimal `json:"amount"`
+	Transactions       []Transaction   `json:"transactions"`
+	CreateTime         time.Time       `json:"create_time"`
+	CreatedBy          string          `json:"created_by"`
+	IdempotencyKey     string          `json:"idempotency_key"`
+	CorrectedJournalID string          `json:"corrected_journal_id"`
+	PreviousHash       string          `json:"previous_hash"`
+	Hash               string          `json:"hash"`
 }
 
 func (journal *BaseJournal) MarshalJSON() ([]byte, error) {
 	toMarshal := struct {
-		JournalID       string        `json:"journal_id"`
-		JournalingTime  time.Time     `json:"journaling_time"`
-		Description     string        `json:"description"`
-		Reversal        bool          `json:"reversal"`
-		ReversedJournal Journal       `json:"reversed_journal"`
-		Amount          float64       `json:"amount"`
-		Transactions    []Transaction `json:"transactions"`
-		CreateTime      time.Time     `json:"create_time"`
-		CreatedBy       string        `json:"created_by"`
+		JournalID          string        `json:"journal_id"`
+		JournalingTime     time.Time     `json:"journaling_time"`
+		Description        string        `json:"description"`
+		Reversal           bool          `json:"reversal"`
+		ReversedJournal    Journal       `json:"reversed_journal"`
+		Amount             float64       `json:"amount"`
+		Transactions       []Transaction `json:"transactions"`
+		CreateTime         time.Time     `json:"create_time"`
+		CreatedBy          string        `json:"created_by"`
+		IdempotencyKey     string        `json:"idempotency_key"`
+		CorrectedJournalID string        `json:"corrected_journal_id"`
+		PreviousHash       string        `json:"previous_hash"`
+		Hash               string        `json:"hash"`
 	}{
-		JournalID:       journal.JournalID,
-		JournalingTime:  journal.JournalingTime,
-		Description:     journal.Description,
-		Reversal:        journal.Reversal,
-		ReversedJournal: journal.ReversedJournal,
-		Amount:          journal.Amount.InexactFloat64(),
-		Transactions:    journal.Transactions,
-		CreateTime:      journal.CreateTime,
-		CreatedBy:       journal.CreatedBy,
+		JournalID:          journal.JournalID,
+		JournalingTime:     journal.JournalingTime,
+		Description:        journal.Description,
+		Reversal:           journal.Reversal,
+		ReversedJournal:    journal.ReversedJournal,
+		Amount:             journal.Amount.InexactFloat64(),
+		Transactions:       journal.Transactions,
+		CreateTime:         journal.CreateTime,
+		CreatedBy:          journal.CreatedBy,
+		IdempotencyKey:     journal.IdempotencyKey,
+		CorrectedJournalID: journal.CorrectedJournalID,
+		PreviousHash:       journal.PreviousHash,
+		Hash:               journal.Hash,
 	}
 	return json.Marshal(toMarshal)
 }
@@ -51,15 +63,19 @@ func (journal *BaseJournal) UnmarshalJSON(data []byte) error {
 	}
 
 	toMarshal := struct {
-		JournalID       string        `json:"journal_id"`
-		JournalingTime  time.Time     `json:"journaling_time"`
-		Description     string        `json:"description"`
-		Reversal        bool          `json:"reversal"`
-		ReversedJournal Journal       `json:"reversed_journal"`
-		Amount          float64       `json:"amount"`
-		Transactions    []Transaction `json:"transactions"`
-		CreateTime      time.Time     `json:"create_time"`
-		CreatedBy       string        `json:"created_by"`
+		JournalID          string        `json:"journal_id"`
+		JournalingTime     time.Time     `json:"journaling_time"`
+		Description        string        `json:"description"`
+		Reversal           bool          `json:"reversal"`
+		ReversedJournal    Journal       `json:"reversed_journal"`
+		Amount             float64       `json:"amount"`
+		Transactions       []Transaction `json:"transactions"`
+		CreateTime         time.Time     `json:"create_time"`
+		CreatedBy          string        `json:"created_by"`
+		IdempotencyKey     string        `json:"idempotency_key"`
+		CorrectedJournalID string        `json:"corrected_journal_id"`
+		PreviousHash       string        `json:"previous_hash"`
+		Hash               string        `json:"hash"`
 	}{}
 
 	err := json.Unmarshal(data, &toMarshal)
@@ -77,6 +93,10 @@ func (journal *BaseJournal) UnmarshalJSON(data []byte) error {
 	journal.Transactions = toMarshal.Transactions
 	journal.CreateTime = toMarshal.CreateTime
 	journal.CreatedBy = toMarshal.CreatedBy
+	journal.IdempotencyKey = toMarshal.IdempotencyKey
+	journal.CorrectedJournalID = toMarshal.CorrectedJournalID
+	journal.PreviousHash = toMarshal.PreviousHash
+	journal.Hash = toMarshal.Hash
 
 	return nil
 }
@@ -182,6 +202,50 @@ func (journal *BaseJournal) SetCreateBy(creator string) Journal {
 	return journal
 }
 
+// GetIdempotencyKey returns the key given by the journal creator so a retried journal is only posted once.
+func (journal *BaseJournal) GetIdempotencyKey() string {
+	return journal.IdempotencyKey
+}
+
+// SetIdempotencyKey will set the idempotency key
+func (journal *BaseJournal) SetIdempotencyKey(key string) Journal {
+	journal.IdempotencyKey = key
+	return journal
+}
+
+// GetCorrectedJournalID returns the ID of the journal this journal corrects, empty if it is not a correction.
+func (journal *BaseJournal) GetCorrectedJournalID() string {
+	return journal.CorrectedJournalID
+}
+
+// SetCorrectedJournalID will set the ID of the corrected journal
+func (journal *BaseJournal) SetCorrectedJournalID(journalID string) Journal {
+	journal.CorrectedJournalID = journalID
+	return journal
+}
+
+// GetPreviousHash returns the hash of the journal persisted right before this journal.
+func (journal *BaseJournal) GetPreviousHash() string {
+	return journal.PreviousHash
+}
+
+// SetPreviousHash will set the hash of the previous journal
+func (journal *BaseJournal) SetPreviousHash(hash string) Journal {
+	journal.PreviousHash = hash
+	return journal
+}
+
+// GetHash returns the hash of this journal content chained to the previous hash.
+func (journal *BaseJournal) GetHash() string {
+	return journal.Hash
+}
+
+// SetHash will set the journal hash
+func (journal *BaseJournal) SetHash(hash string) Journal {
+	journal.Hash = hash
+	return journal
+}
+
 // BaseTransaction is the base implementation of Transaction
 type BaseTransaction struct {
 	TransactionID   string          `json:"transaction_id"`
@@ -386,21 +450,25 @@ type BaseAccount struct {
 	CreateBy      string          `json:"create_by"`
 	UpdateTime    time.Time       `json:"update_time"`
 	UpdateBy      string          `json:"update_by"`
+	Status        AccountStatus   `json:"status"`
+	BalanceLimit  BalanceLimit    `json:"balance_limit"`
 }
 
 func (acc *BaseAccount) MarshalJSON() ([]byte, error) {
 	toMarshal := struct {
-		Currency      string    `json:"currency"`
-		AccountNumber string    `json:"account_number"`
-		Name          string    `json:"name"`
-		Description   string    `json:"description"`
-		Alignment     Alignment `json:"alignment"`
-		Balance       float64   `json:"balance"`
-		COA           string    `json:"coa"`
-		CreateTime    time.Time `json:"create_time"`
-		CreateBy      string    `json:"create_by"`
-		UpdateTime    time.Time `json:"update_time"`
-		UpdateBy      string    `json:"update_by"`
+		Currency      string        `json:"currency"`
+		AccountNumber string        `json:"account_number"`
+		Name          string        `json:"name"`
+		Description   string        `json:"description"`
+		Alignment     Alignment     `json:"alignment"`
+		Balance       float64       `json:"balance"`
+		COA           string        `json:"coa"`
+		CreateTime    time.Time     `json:"create_time"`
+		CreateBy      string        `json:"create_by"`
+		UpdateTime    time.Time     `json:"update_time"`
+		UpdateBy      string        `json:"update_by"`
+		Status        AccountStatus `json:"status"`
+		BalanceLimit  BalanceLimit  `json:"balance_limit"`
 	}{
 		Currency:      acc.Currency,
 		AccountNumber: acc.AccountNumber,
@@ -413,6 +481,8 @@ func (acc *BaseAccount) MarshalJSON() ([]byte, error) {
 		CreateBy:      acc.CreateBy,
 		UpdateTime:    acc.UpdateTime,
 		UpdateBy:      acc.UpdateBy,
+		Status:        acc.Status,
+		BalanceLimit:  acc.BalanceLimit,
 	}
 	return json.Marshal(toMarshal)
 }
@@ -424,17 +494,19 @@ func (acc *BaseAccount) UnmarshalJSON(data []byte) error {
 	}
 
 	toMarshal := struct {
-		Currency      string    `json:"currency"`
-		AccountNumber string    `json:"account_number"`
-		Name          string    `json:"name"`
-		Description   string    `json:"description"`
-		Alignment     Alignment `json:"alignment"`
-		Balance       float64   `json:"balance"`
-		COA           string    `json:"coa"`
-		CreateTime    time.Time `json:"create_time"`
-		CreateBy      string    `json:"create_by"`
-		UpdateTime    time.Time `json:"update_time"`
-		UpdateBy      string    `json:"update_by"`
+		Currency      string        `json:"currency"`
+		AccountNumber string        `json:"account_number"`
+		Name          string        `json:"name"`
+		Description   string        `json:"description"`
+		Alignment     Alignment     `json:"alignment"`
+		Balance       float64       `json:"balance"`
+		COA           string        `json:"coa"`
+		CreateTime    time.Time     `json:"create_time"`
+		CreateBy      string        `json:"create_by"`
+		UpdateTime    time.Time     `json:"update_time"`
+		UpdateBy      string        `json:"update_by"`
+		Status        AccountStatus `json:"status"`
+		BalanceLimit  BalanceLimit  `json:"balance_limit"`
 	}{}
 
 	err := json.Unmarshal(data, &toMarshal)
@@ -453,6 +525,8 @@ func (acc *BaseAccount) UnmarshalJSON(data []byte) error {
 	acc.CreateBy = toMarshal.CreateBy
 	acc.UpdateTime = toMarshal.UpdateTime
 	acc.UpdateBy = toMarshal.UpdateBy
+	acc.Status = toMarshal.Status
+	acc.BalanceLimit = toMarshal.BalanceLimit
 
 	return nil
 }
@@ -585,6 +659,28 @@ func (acc *BaseAccount) SetUpdateBy(editor string) Account {
 	return acc
 }
 
+// GetStatus returns the account lifecycle status
+func (acc *BaseAccount) GetStatus() AccountStatus {
+	return acc.Status
+}
+
+// SetStatus will set the account status
+func (acc *BaseAccount) SetStatus(newStatus AccountStatus) Account {
+	acc.Status = newStatus
+	return acc
+}
+
+// GetBalanceLimit returns the limit of the account balance
+func (acc *BaseAccount) GetBalanceLimit() BalanceLimit {
+	return acc.BalanceLimit
+}
+
+// SetBalanceLimit will set the balance limit
+func (acc *BaseAccount) SetBalanceLimit(limit BalanceLimit) Account {
+	acc.BalanceLimit = limit
+	return acc
+}
+
 // BaseCurrency is the currency object
 type BaseCurrency struct {
 	Code       string          `json:"code"`
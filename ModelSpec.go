@@ -19,7 +19,7 @@ type Alignment int
 // A journal depict an event where Transactions is happening.
 // Important to understand, that Journal don't have update or delete function, its due to accountability reason.
 // To delete a journal, one should create a Reversal journal.
-// To update a journal, one should create a Reversal journal and then followed with a correction journal.
+// To update a journal, one should create a Reversal journal and then followed with a correction journal, as Accounting.CorrectJournal does.
 // If your implementation database do not support 2 phased commit, you should maintain your own committed flag in
 // this journal table. When you want to select those journal, you only select those  that have committed flag status on.
 // Committing this journal, will propagate to commit the child Transactions
@@ -70,6 +70,30 @@ type Journal interface {
 	GetCreateBy() string
 	// SetCreateBy will set the creator Name
 	SetCreateBy(creator string) Journal
+
+	// GetIdempotencyKey returns the key given by the journal creator so a retried journal is only posted once.
+	// Empty if the journal has no idempotency key.
+	GetIdempotencyKey() string
+	// SetIdempotencyKey will set the idempotency key
+	SetIdempotencyKey(key string) Journal
+
+	// GetCorrectedJournalID returns the ID of the journal this journal corrects, empty if it is not a correction.
+	// A correction journal is posted right after the Reversal of the journal it corrects.
+	GetCorrectedJournalID() string
+	// SetCorrectedJournalID will set the ID of the corrected journal
+	SetCorrectedJournalID(journalID string) Journal
+
+	// GetPreviousHash returns the hash of the journal persisted right before this journal, empty for the first journal.
+	// It is set by PersistJournal, chaining every persisted journal to the one before it.
+	GetPreviousHash() string
+	// SetPreviousHash will set the hash of the previous journal
+	SetPreviousHash(hash string) Journal
+
+	// GetHash returns the hash of this journal content, including its transactions, chained to the previous hash.
+	// It is set by PersistJournal, JournalManager.VerifyJournalChain recomputes it to detect tampering.
+	GetHash() string
+	// SetHash will set the journal hash
+	SetHash(hash string) Journal
 }
 
 // Transaction interface define a base Transaction structure
@@ -172,6 +196,18 @@ type Account interface {
 	// SetCOA Will set new COA code
 	SetCOA(newCoa string) Account
 
+	// GetStatus returns the account lifecycle status. New accounts are AccountActive.
+	// The status is changed using AccountManager.ChangeAccountStatus, PersistAccount and UpdateAccount ignore it.
+	GetStatus() AccountStatus
+	// SetStatus will set the account status
+	SetStatus(newStatus AccountStatus) Account
+
+	// GetBalanceLimit returns the limit of the account balance, enforced when journals are persisted.
+	// AccountManager.NewAccount returns accounts with UnlimitedBalance.
+	GetBalanceLimit() BalanceLimit
+	// SetBalanceLimit will set the balance limit
+	SetBalanceLimit(limit BalanceLimit) Account
+
 	// GetCreateTime function should return the time when this account is created/recorded.
 	// this function serves as audit trail.
 	GetCreateTime() time.Time
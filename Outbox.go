@@ -0,0 +1,162 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"time"
+)
+
+const (
+	// OutboxJournalPosted is enum outbox event type of a persisted journal, Amount is the journal amount.
+	OutboxJournalPosted OutboxEventType = iota
+	// OutboxAccountBalanceChanged is enum outbox event type of an account balance moved by a journal transaction,
+	// Amount is the transaction amount and Balance the account balance after it.
+	OutboxAccountBalanceChanged
+)
+
+// outboxDispatchBatch is the number of events read from the outbox at a time.
+const outboxDispatchBatch = 100
+
+var (
+	ErrOutboxSubscriberUnknown = fmt.Errorf("outbox subscriber is not registered")
+)
+
+// OutboxEventType is the enum type of outbox event, OutboxJournalPosted and OutboxAccountBalanceChanged
+type OutboxEventType int
+
+// String returns the event type name
+func (eventType OutboxEventType) String() string {
+	switch eventType {
+	case OutboxJournalPosted:
+		return "JOURNAL_POSTED"
+	case OutboxAccountBalanceChanged:
+		return "ACCOUNT_BALANCE_CHANGED"
+	}
+	return fmt.Sprintf("OutboxEventType(%d)", int(eventType))
+}
+
+// OutboxEvent is a change written into the outbox by JournalManager.PersistJournal, in the same unit as the journal.
+// Only the fields relevant to the Type are filled, the journal itself can be loaded by its JournalID.
+type OutboxEvent struct {
+	// Offset is the position of the event in the outbox, starting from 1, in the order the journals were persisted.
+	Offset        int64           `json:"offset"`
+	Type          OutboxEventType `json:"type"`
+	EventTime     time.Time       `json:"event_time"`
+	JournalID     string          `json:"journal_id"`
+	AccountNumber string          `json:"account_number"`
+	TransactionID string          `json:"transaction_id"`
+	Alignment     Alignment       `json:"alignment"`
+	Amount        decimal.Decimal `json:"amount"`
+	Balance       decimal.Decimal `json:"balance"`
+}
+
+// journalOutboxEvents returns the outbox events of the persisted journal, the OutboxJournalPosted followed by
+// the OutboxAccountBalanceChanged of each transaction. The balances are the account balances after each transaction.
+func journalOutboxEvents(journal Journal, amount decimal.Decimal, balances map[string]decimal.Decimal, at time.Time) []*OutboxEvent {
+	events := make([]*OutboxEvent, 0, len(journal.GetTransactions())+1)
+	events = append(events, &OutboxEvent{
+		Type:      OutboxJournalPosted,
+		EventTime: at,
+		JournalID: journal.GetJournalID(),
+		Amount:    amount,
+	})
+	for _, trx := range journal.GetTransactions() {
+		events = append(events, &OutboxEvent{
+			Type:          OutboxAccountBalanceChanged,
+			EventTime:     at,
+			JournalID:     journal.GetJournalID(),
+			AccountNumber: trx.GetAccountNumber(),
+			TransactionID: trx.GetTransactionID(),
+			Alignment:     trx.GetAlignment(),
+			Amount:        trx.GetAmount(),
+			Balance:       balances[trx.GetTransactionID()],
+		})
+	}
+	return events
+}
+
+// OutboxSubscriber receives the outbox events delivered by the OutboxDispatcher. An event is delivered at least once,
+// it may be delivered again if the subscriber offset could not be recorded, so the subscriber should be idempotent.
+// Returning an error stops the delivery to the subscriber, the event is delivered again on the next dispatch.
+type OutboxSubscriber func(context context.Context, event *OutboxEvent) error
+
+// OutboxDispatcher delivers the outbox events to the registered subscribers, each at its own offset.
+type OutboxDispatcher struct {
+	outboxManager OutboxManager
+	subscribers   map[string]OutboxSubscriber
+}
+
+// NewOutboxDispatcher creates a dispatcher reading the outbox of the manager.
+func NewOutboxDispatcher(outboxManager OutboxManager) *OutboxDispatcher {
+	return &OutboxDispatcher{outboxManager: outboxManager, subscribers: make(map[string]OutboxSubscriber)}
+}
+
+// Subscribe registers the subscriber under the name. The name identifies its offset, a subscriber registered again under
+// the same name continues after the last event delivered to it.
+func (dispatcher *OutboxDispatcher) Subscribe(name string, subscriber OutboxSubscriber) *OutboxDispatcher {
+	dispatcher.subscribers[name] = subscriber
+	return dispatcher
+}
+
+// Dispatch delivers every event after each subscriber offset to it, in offset order, recording the offset after each delivery.
+// A failing subscriber does not stop the delivery to the others. The number of events delivered is returned,
+// along with the first error met.
+func (dispatcher *OutboxDispatcher) Dispatch(context context.Context) (int, error) {
+	names := make([]string, 0, len(dispatcher.subscribers))
+	for name := range dispatcher.subscribers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	delivered := 0
+	var firstErr error
+	for _, name := range names {
+		count, err := dispatcher.dispatchTo(context, name)
+		delivered += count
+		if err != nil {
+			logrus.Errorf("error dispatching outbox events to %s. got %s", name, err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return delivered, firstErr
+}
+
+// dispatchTo delivers the events after the subscriber offset to it.
+func (dispatcher *OutboxDispatcher) dispatchTo(context context.Context, name string) (int, error) {
+	subscriber := dispatcher.subscribers[name]
+	offset, err := dispatcher.outboxManager.GetSubscriberOffset(context, name)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for {
+		events, err := dispatcher.outboxManager.ReadOutbox(context, offset, outboxDispatchBatch)
+		if err != nil || len(events) == 0 {
+			return delivered, err
+		}
+		for _, event := range events {
+			if err := subscriber(context, event); err != nil {
+				return delivered, err
+			}
+			delivered++
+			offset = event.Offset
+			if err := dispatcher.outboxManager.SetSubscriberOffset(context, name, offset); err != nil {
+				return delivered, err
+			}
+		}
+	}
+}
+
+// ReplayFrom moves the registered subscriber back, so the next Dispatch delivers it every event after the offset again.
+// An offset of zero replays the whole outbox.
+func (dispatcher *OutboxDispatcher) ReplayFrom(context context.Context, name string, offset int64) error {
+	if _, ok := dispatcher.subscribers[name]; !ok {
+		return ErrOutboxSubscriberUnknown
+	}
+	return dispatcher.outboxManager.SetSubscriberOffset(context, name, offset)
+}
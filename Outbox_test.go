@@ -0,0 +1,180 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOutboxDispatcher(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager())
+	testOutboxDispatcher(t, acc, store.OutboxManager())
+}
+
+func TestOutboxDispatcherSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db))
+	testOutboxDispatcher(t, acc, NewSQLOutboxManager(db, dialect))
+}
+
+func TestOutboxDispatcherRollback(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager())
+	_, err := acc.CreateNewAccount(ctx, "1001", "Cash", "Cash", "1.1", "IDR", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "4001", "Sales", "Sales", "4.1", "IDR", CREDIT, "tester")
+	assert.NoError(t, err)
+	post := func(ctx context.Context) (Journal, error) {
+		return acc.CreateNewJournal(ctx, "Sales", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Cash", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+			{AccountNumber: "4001", Description: "Sales", TxType: CREDIT, Amount: decimal.NewFromInt(10)},
+		}, "tester")
+	}
+	first, err := post(ctx)
+	assert.NoError(t, err)
+
+	delivered := make([]*OutboxEvent, 0)
+	dispatcher := NewOutboxDispatcher(store.OutboxManager()).Subscribe("notifications", func(ctx context.Context, event *OutboxEvent) error {
+		delivered = append(delivered, event)
+		return nil
+	})
+	uowCtx, uow, err := store.TxManager().Begin(ctx)
+	assert.NoError(t, err)
+	_, err = post(uowCtx)
+	assert.NoError(t, err)
+	// the dispatcher runs while the unit of work is open, then it is rolled back
+	dispatched := make(chan error)
+	go func() {
+		_, err := dispatcher.Dispatch(ctx)
+		dispatched <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, uow.Rollback())
+	assert.NoError(t, <-dispatched)
+
+	second, err := post(ctx)
+	assert.NoError(t, err)
+	_, err = dispatcher.Dispatch(ctx)
+	assert.NoError(t, err)
+	journalIDs := make([]string, 0)
+	for i, event := range delivered {
+		assert.Equal(t, int64(i+1), event.Offset)
+		journalIDs = append(journalIDs, event.JournalID)
+	}
+	assert.Equal(t, []string{first.GetJournalID(), first.GetJournalID(), first.GetJournalID(),
+		second.GetJournalID(), second.GetJournalID(), second.GetJournalID()}, journalIDs)
+}
+
+func testOutboxDispatcher(t *testing.T, acc *Accounting, outboxManager OutboxManager) {
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Cash", "Cash", "1.1", "IDR", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "4001", "Sales", "Sales", "4.1", "IDR", CREDIT, "tester")
+	assert.NoError(t, err)
+	post := func(ctx context.Context, amount int64) (Journal, error) {
+		return acc.CreateNewJournal(ctx, "Sales", []TransactionInfo{
+			{AccountNumber: "1001", Description: "Cash", TxType: DEBIT, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "4001", Description: "Sales", TxType: CREDIT, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+	}
+	first, err := post(ctx, 100)
+	assert.NoError(t, err)
+	_, err = post(ctx, 50)
+	assert.NoError(t, err)
+	// a rolled back journal leaves nothing in the outbox
+	err = acc.InUnitOfWork(ctx, func(uowCtx context.Context) error {
+		if _, err := post(uowCtx, 10); err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	assert.EqualError(t, err, "abort")
+
+	events, err := outboxManager.ReadOutbox(ctx, 0, 100)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 6) {
+		for i, event := range events {
+			assert.Equal(t, int64(i+1), event.Offset)
+		}
+		assert.Equal(t, OutboxJournalPosted, events[0].Type)
+		assert.Equal(t, first.GetJournalID(), events[0].JournalID)
+		assert.True(t, decimal.NewFromInt(100).Equal(events[0].Amount))
+		balances := make(map[string]string)
+		for _, event := range events[3:] {
+			if event.Type == OutboxAccountBalanceChanged {
+				balances[event.AccountNumber] = event.Balance.String()
+			}
+		}
+		assert.Equal(t, map[string]string{"1001": "150", "4001": "150"}, balances)
+	}
+	events, err = outboxManager.ReadOutbox(ctx, 4, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, int64(5), events[0].Offset)
+	}
+
+	notified := make([]int64, 0)
+	analyzed := make([]int64, 0)
+	failing := true
+	dispatcher := NewOutboxDispatcher(outboxManager).
+		Subscribe("notifications", func(ctx context.Context, event *OutboxEvent) error {
+			notified = append(notified, event.Offset)
+			return nil
+		}).
+		Subscribe("analytics", func(ctx context.Context, event *OutboxEvent) error {
+			if event.Offset == 4 && failing {
+				failing = false
+				return fmt.Errorf("analytics unavailable")
+			}
+			analyzed = append(analyzed, event.Offset)
+			return nil
+		})
+
+	// a failing subscriber stops at the failed event without holding back the others
+	delivered, err := dispatcher.Dispatch(ctx)
+	assert.EqualError(t, err, "analytics unavailable")
+	assert.Equal(t, 9, delivered)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6}, notified)
+	assert.Equal(t, []int64{1, 2, 3}, analyzed)
+
+	// and is delivered the failed event again on the next dispatch
+	delivered, err = dispatcher.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, delivered)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6}, analyzed)
+
+	_, err = post(ctx, 25)
+	assert.NoError(t, err)
+	delivered, err = dispatcher.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, delivered)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9}, notified)
+
+	// the offsets are kept by the outbox manager, a new dispatcher continues where the last one stopped
+	restarted := NewOutboxDispatcher(outboxManager).Subscribe("notifications", func(ctx context.Context, event *OutboxEvent) error {
+		notified = append(notified, event.Offset)
+		return nil
+	})
+	delivered, err = restarted.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+
+	// replay delivers the events after the offset again
+	assert.NoError(t, restarted.ReplayFrom(ctx, "notifications", 6))
+	delivered, err = restarted.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, delivered)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 7, 8, 9}, notified)
+	offset, err := outboxManager.GetSubscriberOffset(ctx, "analytics")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), offset)
+	assert.Equal(t, ErrOutboxSubscriberUnknown, restarted.ReplayFrom(ctx, "analytics", 0))
+}
@@ -0,0 +1,234 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// PeriodOpen is enum period status of an open period, journals may be posted into it
+	PeriodOpen PeriodStatus = iota
+	// PeriodClosed is enum period status of a closed period, it may be reopened
+	PeriodClosed
+	// PeriodLocked is enum period status of a locked period, it can never be reopened
+	PeriodLocked
+)
+
+var (
+	ErrPeriodManagerNotSet           = fmt.Errorf("accounting has no PeriodManager")
+	ErrPeriodNotEnded                = fmt.Errorf("accounting period can only be closed after it ends")
+	ErrPeriodRetainedEarningsInvalid = fmt.Errorf("retained earnings account must not be a revenue or expense account")
+)
+
+// PeriodStatus is the enum type of accounting period status, PeriodOpen, PeriodClosed and PeriodLocked
+type PeriodStatus int
+
+// String returns the status name
+func (status PeriodStatus) String() string {
+	switch status {
+	case PeriodOpen:
+		return "OPEN"
+	case PeriodClosed:
+		return "CLOSED"
+	case PeriodLocked:
+		return "LOCKED"
+	}
+	return fmt.Sprintf("PeriodStatus(%d)", int(status))
+}
+
+// Period is an accounting period, spanning between From and Until inclusive.
+type Period struct {
+	PeriodID    string       `json:"period_id"`
+	Description string       `json:"description"`
+	From        time.Time    `json:"from"`
+	Until       time.Time    `json:"until"`
+	Status      PeriodStatus `json:"status"`
+	// ClosingJournalIDs are the journals posted when closing this period.
+	ClosingJournalIDs []string  `json:"closing_journal_ids"`
+	CreateTime        time.Time `json:"create_time"`
+	CreateBy          string    `json:"create_by"`
+	UpdateTime        time.Time `json:"update_time"`
+	UpdateBy          string    `json:"update_by"`
+}
+
+// Contains returns true if the time is within this period.
+func (period *Period) Contains(at time.Time) bool {
+	return !at.Before(period.From) && !at.After(period.Until)
+}
+
+// canTransit checks if a period in this status may change into the new status.
+func (status PeriodStatus) canTransit(newStatus PeriodStatus) bool {
+	switch status {
+	case PeriodOpen:
+		return newStatus == PeriodClosed
+	case PeriodClosed:
+		return newStatus == PeriodOpen || newStatus == PeriodLocked
+	}
+	return false
+}
+
+// GetPeriodManager returns period manager
+func (acc *Accounting) GetPeriodManager() PeriodManager {
+	return acc.periodManager
+}
+
+// SetPeriodManager sets the period manager, it must work on the same storage as the journal manager.
+func (acc *Accounting) SetPeriodManager(periodManager PeriodManager) *Accounting {
+	acc.periodManager = periodManager
+	return acc
+}
+
+// ClosePeriod closes an ended open period. Every REVENUE and EXPENSE account (according to the chart of accounts)
+// in the retained earnings account currency is brought to zero by a closing journal dated at the period end,
+// the net of it goes into the retained earnings account. The IncomeStatement leaves the closing journals out.
+// Only the balance built up until the end of the period and not yet closed by earlier closings is moved, so activities
+// posted after the period end stay in their accounts.
+// The closing journal is returned, nil if there was nothing to close.
+func (acc *Accounting) ClosePeriod(ctx context.Context, periodID, retainedEarningsAccountNumber, author string) (Journal, error) {
+	if acc.GetPeriodManager() == nil {
+		return nil, ErrPeriodManagerNotSet
+	}
+	chart := acc.GetChartOfAccounts()
+	if chart == nil {
+		return nil, ErrChartOfAccountsNotSet
+	}
+
+	var closingJournal Journal
+	err := acc.atomically(ctx, func(ctx context.Context) error {
+		period, err := acc.GetPeriodManager().GetPeriodByID(ctx, periodID)
+		if err != nil {
+			return err
+		}
+		if period.Status != PeriodOpen {
+			logrus.Errorf("error closing period %s. period is %s", periodID, period.Status.String())
+			return ErrPeriodInvalidTransition
+		}
+		if !acc.GetClock().Now().After(period.Until) {
+			return ErrPeriodNotEnded
+		}
+		retainedEarnings, err := acc.GetAccountManager().GetAccountByID(ctx, retainedEarningsAccountNumber)
+		if err != nil {
+			return err
+		}
+		if group, ok := chart.GroupOf(retainedEarnings.GetCOA()); ok && (group == REVENUE || group == EXPENSE) {
+			return ErrPeriodRetainedEarningsInvalid
+		}
+
+		closingLegs, err := acc.closingTransactions(ctx, chart, period.Until, retainedEarnings)
+		if err != nil {
+			return err
+		}
+		closingJournalIDs := make([]string, 0, 1)
+		if len(closingLegs) > 0 {
+			// the closing journal is dated at the period end, so it belongs to the period it closes.
+			journal := acc.newJournal(ctx, fmt.Sprintf("Closing of period %s", periodID), closingLegs, author)
+			journal.SetJournalingTime(period.Until)
+			for _, trx := range journal.GetTransactions() {
+				trx.SetTransactionTime(period.Until)
+			}
+			if err := acc.persistAndCommitJournal(ctx, journal); err != nil {
+				return err
+			}
+			closingJournal = journal
+			closingJournalIDs = append(closingJournalIDs, closingJournal.GetJournalID())
+		}
+		return acc.GetPeriodManager().ClosePeriod(ctx, periodID, closingJournalIDs, author)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closingJournal, nil
+}
+
+// closingTransactions builds the closing journal transactions bringing the REVENUE and EXPENSE accounts to zero
+// as of the specified time, balanced against the retained earnings account.
+func (acc *Accounting) closingTransactions(context context.Context, chart *ChartOfAccounts, until time.Time, retainedEarnings Account) ([]TransactionInfo, error) {
+	accounts, err := acc.listAllAccounts(context)
+	if err != nil {
+		return nil, err
+	}
+	legs := make([]TransactionInfo, 0)
+	net := decimal.Zero // positive is net debit of the closing legs
+	for _, account := range accounts {
+		if account.GetCurrency() != retainedEarnings.GetCurrency() {
+			continue
+		}
+		if group, ok := chart.GroupOf(account.GetCOA()); !ok || (group != REVENUE && group != EXPENSE) {
+			continue
+		}
+		// the closing journals are dated at their period end, so the balance at the end is what is not closed yet.
+		outstanding, err := acc.GetTransactionManager().GetAccountBalanceAt(context, account, until)
+		if err != nil {
+			return nil, err
+		}
+		if outstanding.IsZero() {
+			continue
+		}
+		leg := TransactionInfo{
+			AccountNumber: account.GetAccountNumber(),
+			Description:   "Period closing",
+			TxType:        account.GetAlignment(),
+			Amount:        outstanding.Abs(),
+		}
+		if outstanding.IsPositive() {
+			leg.TxType = DEBIT
+			if account.GetAlignment() == DEBIT {
+				leg.TxType = CREDIT
+			}
+		}
+		if leg.TxType == DEBIT {
+			net = net.Add(leg.Amount)
+		} else {
+			net = net.Sub(leg.Amount)
+		}
+		legs = append(legs, leg)
+	}
+	if !net.IsZero() {
+		leg := TransactionInfo{
+			AccountNumber: retainedEarnings.GetAccountNumber(),
+			Description:   "Period closing into retained earnings",
+			TxType:        CREDIT,
+			Amount:        net.Abs(),
+		}
+		if net.IsNegative() {
+			leg.TxType = DEBIT
+		}
+		legs = append(legs, leg)
+	}
+	return legs, nil
+}
+
+// closingMovements sums the transactions of the closing journals of the periods ending between `from` and `until`
+// by account number, debit positive. The movements are nil if the PeriodManager is not set.
+func (acc *Accounting) closingMovements(context context.Context, from, until time.Time) (map[string]decimal.Decimal, error) {
+	if acc.GetPeriodManager() == nil {
+		return nil, nil
+	}
+	periods, err := acc.GetPeriodManager().ListPeriods(context)
+	if err != nil {
+		return nil, err
+	}
+	movements := make(map[string]decimal.Decimal)
+	for _, period := range periods {
+		if period.Until.Before(from) || period.Until.After(until) {
+			continue
+		}
+		for _, journalID := range period.ClosingJournalIDs {
+			journal, err := acc.GetJournalManager().GetJournalByID(context, journalID)
+			if err != nil {
+				return nil, err
+			}
+			for _, trx := range journal.GetTransactions() {
+				if trx.GetAlignment() == DEBIT {
+					movements[trx.GetAccountNumber()] = movements[trx.GetAccountNumber()].Add(trx.GetAmount())
+				} else {
+					movements[trx.GetAccountNumber()] = movements[trx.GetAccountNumber()].Sub(trx.GetAmount())
+				}
+			}
+		}
+	}
+	return movements, nil
+}
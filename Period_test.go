@@ -0,0 +1,157 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAccounting_ClosePeriod(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager()).SetPeriodManager(store.PeriodManager())
+	testPeriodClosing(t, acc)
+}
+
+func TestAccounting_ClosePeriodSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db)).SetPeriodManager(NewSQLPeriodManager(db, dialect))
+	testPeriodClosing(t, acc)
+}
+
+func testPeriodClosing(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	acc.SetChartOfAccounts(newTestChartOfAccounts(t))
+	for _, account := range []struct {
+		number, coa string
+		alignment   Alignment
+	}{
+		{"1001", "1.1.1", DEBIT},
+		{"3100", "3.2", CREDIT},
+		{"4001", "4.1", CREDIT},
+		{"5001", "5.1", DEBIT},
+	} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, account.coa, "GOLD", account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+
+	from := time.Now().Add(-time.Hour)
+	_, err := acc.CreateNewJournal(ctx, "Sales", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Cash in", TxType: DEBIT, Amount: decimal.NewFromInt(400)},
+		{AccountNumber: "4001", Description: "Sales", TxType: CREDIT, Amount: decimal.NewFromInt(400)},
+	}, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewJournal(ctx, "Expense", []TransactionInfo{
+		{AccountNumber: "5001", Description: "Expense", TxType: DEBIT, Amount: decimal.NewFromInt(150)},
+		{AccountNumber: "1001", Description: "Cash out", TxType: CREDIT, Amount: decimal.NewFromInt(150)},
+	}, "tester")
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	until := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	pm := acc.GetPeriodManager()
+	period, err := pm.OpenPeriod(ctx, "2020-01", "January", from, until, "tester")
+	assert.NoError(t, err)
+	assert.Equal(t, PeriodOpen, period.Status)
+	_, err = pm.OpenPeriod(ctx, "2020-01b", "Overlapping", until.Add(-time.Minute), until.Add(time.Hour), "tester")
+	assert.Equal(t, ErrPeriodOverlap, err)
+	_, err = pm.OpenPeriod(ctx, "2020-02", "February", until.Add(time.Millisecond), until.Add(time.Hour), "tester")
+	assert.NoError(t, err)
+	_, err = pm.OpenPeriod(ctx, "2020-03", "Backward", until.Add(2*time.Hour), until.Add(time.Hour), "tester")
+	assert.Equal(t, ErrPeriodInvalidRange, err)
+
+	// sales after the period end stay in the account
+	_, err = acc.CreateNewJournal(ctx, "Late sales", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Cash in", TxType: DEBIT, Amount: decimal.NewFromInt(100)},
+		{AccountNumber: "4001", Description: "Sales", TxType: CREDIT, Amount: decimal.NewFromInt(100)},
+	}, "tester")
+	assert.NoError(t, err)
+
+	_, err = acc.ClosePeriod(ctx, "2020-02", "3100", "tester")
+	assert.Equal(t, ErrPeriodNotEnded, err)
+	_, err = acc.ClosePeriod(ctx, "2020-01", "4001", "tester")
+	assert.Equal(t, ErrPeriodRetainedEarningsInvalid, err)
+
+	// the period end is checked against the clock
+	acc.SetClock(&testClock{now: until.Add(-time.Millisecond)})
+	_, err = acc.ClosePeriod(ctx, "2020-01", "3100", "tester")
+	assert.Equal(t, ErrPeriodNotEnded, err)
+	acc.SetClock(&testClock{now: until.Add(time.Millisecond)})
+
+	journal, err := acc.ClosePeriod(ctx, "2020-01", "3100", "tester")
+	assert.NoError(t, err)
+	assert.NotNil(t, journal)
+	assert.Len(t, journal.GetTransactions(), 3)
+	for number, balance := range map[string]int64{"4001": 100, "5001": 0, "3100": 250, "1001": 350} {
+		account, err := acc.GetAccountManager().GetAccountByID(ctx, number)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(balance).Equal(account.GetBalance()), "account %s balance is %s", number, account.GetBalance())
+	}
+
+	// the closing journal belongs to the closed period, the income statements leave it out
+	assertIncomeStatement := func(from, until time.Time, revenue, expense int64) {
+		fs, err := acc.IncomeStatement(ctx, from, until, "GOLD")
+		if assert.NoError(t, err) {
+			assert.True(t, decimal.NewFromInt(revenue).Equal(fs.Section(REVENUE).Total), "revenue is %s", fs.Section(REVENUE).Total)
+			assert.True(t, decimal.NewFromInt(expense).Equal(fs.Section(EXPENSE).Total), "expense is %s", fs.Section(EXPENSE).Total)
+		}
+	}
+	assertIncomeStatement(from, until, 400, 150)
+	assertIncomeStatement(until.Add(time.Millisecond), until.Add(time.Hour), 100, 0)
+	bs, err := acc.BalanceSheet(ctx, until, "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, bs.NetIncome.IsZero(), "current earnings at the period end is %s", bs.NetIncome)
+	bs, err = acc.BalanceSheet(ctx, time.Now(), "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(bs.NetIncome), "current earnings is %s", bs.NetIncome)
+
+	period, err = pm.GetPeriodAt(ctx, from.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-01", period.PeriodID)
+	assert.Equal(t, PeriodClosed, period.Status)
+	assert.Equal(t, []string{journal.GetJournalID()}, period.ClosingJournalIDs)
+	_, err = pm.GetPeriodAt(ctx, from.Add(-time.Minute))
+	assert.Equal(t, ErrPeriodNotFound, err)
+
+	// journals can not be journaled into the closed period
+	backdated := acc.GetJournalManager().NewJournal(ctx).SetJournalID(acc.GetUniqueIDGenerator().NewUniqueID()).
+		SetDescription("Backdated").SetCreateBy("tester").SetJournalingTime(from.Add(time.Minute)).
+		SetTransactions([]Transaction{
+			acc.GetTransactionManager().NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber("1001").SetAlignment(DEBIT).SetAmount(decimal.NewFromInt(10)).SetCreateBy("tester"),
+			acc.GetTransactionManager().NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber("4001").SetAlignment(CREDIT).SetAmount(decimal.NewFromInt(10)).SetCreateBy("tester"),
+		})
+	assert.Equal(t, ErrJournalPeriodClosed, acc.GetJournalManager().PersistJournal(ctx, backdated))
+
+	_, err = acc.ClosePeriod(ctx, "2020-01", "3100", "tester")
+	assert.Equal(t, ErrPeriodInvalidTransition, err)
+	assert.NoError(t, pm.ReopenPeriod(ctx, "2020-01", "tester"))
+	assert.NoError(t, acc.GetJournalManager().PersistJournal(ctx, backdated))
+
+	// closing again only moves what is not closed yet, the backdated sales
+	journal, err = acc.ClosePeriod(ctx, "2020-01", "3100", "tester")
+	assert.NoError(t, err)
+	if assert.NotNil(t, journal) {
+		assert.Len(t, journal.GetTransactions(), 2)
+	}
+	for number, balance := range map[string]int64{"4001": 100, "3100": 260} {
+		account, err := acc.GetAccountManager().GetAccountByID(ctx, number)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(balance).Equal(account.GetBalance()), "account %s balance is %s", number, account.GetBalance())
+	}
+	assertIncomeStatement(from, until, 410, 150)
+	assert.NoError(t, pm.LockPeriod(ctx, "2020-01", "tester"))
+	assert.Equal(t, ErrPeriodInvalidTransition, pm.ReopenPeriod(ctx, "2020-01", "tester"))
+
+	periods, err := pm.ListPeriods(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, periods, 2)
+	assert.Equal(t, "2020-01", periods[0].PeriodID)
+	assert.Equal(t, PeriodLocked, periods[0].Status)
+	assert.Len(t, periods[0].ClosingJournalIDs, 2)
+}
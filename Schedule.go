@@ -0,0 +1,245 @@
+package acccore
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// RecurDaily is enum recurrence frequency of a schedule recurring every Interval days
+	RecurDaily RecurrenceFrequency = iota
+	// RecurWeekly is enum recurrence frequency of a schedule recurring every Interval weeks
+	RecurWeekly
+	// RecurMonthly is enum recurrence frequency of a schedule recurring every Interval months.
+	// A start day missing from a shorter month falls on that month's last day.
+	RecurMonthly
+	// RecurYearly is enum recurrence frequency of a schedule recurring every Interval years
+	RecurYearly
+)
+
+const (
+	// ScheduleRunPosted is enum schedule run status of a run that posted its journal
+	ScheduleRunPosted ScheduleRunStatus = iota
+	// ScheduleRunFailed is enum schedule run status of a run that failed posting its journal, it is retried on the next run
+	ScheduleRunFailed
+)
+
+var (
+	ErrScheduleManagerNotSet = fmt.Errorf("accounting has no ScheduleManager")
+)
+
+// RecurrenceFrequency is the enum type of schedule recurrence, RecurDaily, RecurWeekly, RecurMonthly and RecurYearly
+type RecurrenceFrequency int
+
+// String returns the frequency name
+func (frequency RecurrenceFrequency) String() string {
+	switch frequency {
+	case RecurDaily:
+		return "DAILY"
+	case RecurWeekly:
+		return "WEEKLY"
+	case RecurMonthly:
+		return "MONTHLY"
+	case RecurYearly:
+		return "YEARLY"
+	}
+	return fmt.Sprintf("RecurrenceFrequency(%d)", int(frequency))
+}
+
+// Recurrence is the rule of a schedule recurring every Interval of the Frequency, eg. every 1 month.
+type Recurrence struct {
+	Frequency RecurrenceFrequency `json:"frequency"`
+	Interval  int                 `json:"interval"`
+}
+
+// validate makes sure the recurrence has a known frequency and a positive interval.
+func (recurrence Recurrence) validate() error {
+	if recurrence.Frequency < RecurDaily || recurrence.Frequency > RecurYearly || recurrence.Interval < 1 {
+		return ErrScheduleInvalidRecurrence
+	}
+	return nil
+}
+
+// Occurrence returns the time of the n-th occurrence, counting from zero at the start time.
+// Each occurrence is counted from the start, so monthly occurrences do not drift after a short month.
+func (recurrence Recurrence) Occurrence(start time.Time, n int) time.Time {
+	steps := n * recurrence.Interval
+	switch recurrence.Frequency {
+	case RecurWeekly:
+		return start.AddDate(0, 0, 7*steps)
+	case RecurMonthly:
+		return addMonths(start, steps)
+	case RecurYearly:
+		return addMonths(start, 12*steps)
+	}
+	return start.AddDate(0, 0, steps)
+}
+
+// addMonths adds the months to the time, keeping the day within the resulting month.
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if lastDay := first.AddDate(0, 1, -1).Day(); day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// JournalSchedule is a journal template posted on every occurrence of its Recurrence between StartTime and EndTime inclusive.
+type JournalSchedule struct {
+	ScheduleID   string            `json:"schedule_id"`
+	Description  string            `json:"description"`
+	Transactions []TransactionInfo `json:"transactions"`
+	Recurrence   Recurrence        `json:"recurrence"`
+	StartTime    time.Time         `json:"start_time"`
+	// EndTime is the last time an occurrence may fall on, the schedule never ends if it is zero.
+	EndTime time.Time `json:"end_time"`
+	// NextSequence is the occurrence to be posted next, every occurrence before it is posted.
+	NextSequence int       `json:"next_sequence"`
+	CreateTime   time.Time `json:"create_time"`
+	CreateBy     string    `json:"create_by"`
+	UpdateTime   time.Time `json:"update_time"`
+	UpdateBy     string    `json:"update_by"`
+}
+
+// DueTime returns the time of the occurrence, false if it falls after the schedule EndTime.
+func (schedule *JournalSchedule) DueTime(sequence int) (time.Time, bool) {
+	due := schedule.Recurrence.Occurrence(schedule.StartTime, sequence)
+	if !schedule.EndTime.IsZero() && due.After(schedule.EndTime) {
+		return due, false
+	}
+	return due, true
+}
+
+// IdempotencyKey returns the idempotency key of the journal posted for the occurrence,
+// so an occurrence is never posted twice even if recording its run failed.
+func (schedule *JournalSchedule) IdempotencyKey(sequence int) string {
+	return fmt.Sprintf("SCHEDULE-%s-%d", schedule.ScheduleID, sequence)
+}
+
+// ScheduleRunStatus is the enum type of schedule run status, ScheduleRunPosted and ScheduleRunFailed
+type ScheduleRunStatus int
+
+// String returns the status name
+func (status ScheduleRunStatus) String() string {
+	switch status {
+	case ScheduleRunPosted:
+		return "POSTED"
+	case ScheduleRunFailed:
+		return "FAILED"
+	}
+	return fmt.Sprintf("ScheduleRunStatus(%d)", int(status))
+}
+
+// ScheduleRun is the outcome of posting one occurrence of a schedule.
+type ScheduleRun struct {
+	ScheduleID string            `json:"schedule_id"`
+	Sequence   int               `json:"sequence"`
+	DueTime    time.Time         `json:"due_time"`
+	RunTime    time.Time         `json:"run_time"`
+	Status     ScheduleRunStatus `json:"status"`
+	// JournalID is the journal posted by the run, empty if it failed.
+	JournalID string `json:"journal_id"`
+	// Message is the error of a failed run.
+	Message string `json:"message"`
+	RunBy   string `json:"run_by"`
+}
+
+// GetScheduleManager returns schedule manager
+func (acc *Accounting) GetScheduleManager() ScheduleManager {
+	return acc.scheduleManager
+}
+
+// SetScheduleManager sets the schedule manager, it must work on the same storage as the journal manager.
+func (acc *Accounting) SetScheduleManager(scheduleManager ScheduleManager) *Accounting {
+	acc.scheduleManager = scheduleManager
+	return acc
+}
+
+// CreateSchedule creates a schedule posting the transactions on every occurrence of the recurrence between start and end.
+// A zero end never ends the schedule.
+func (acc *Accounting) CreateSchedule(context context.Context, description string, transactions []TransactionInfo, recurrence Recurrence, start, end time.Time, author string) (*JournalSchedule, error) {
+	if acc.GetScheduleManager() == nil {
+		return nil, ErrScheduleManagerNotSet
+	}
+	return acc.GetScheduleManager().CreateSchedule(context, acc.GetUniqueIDGenerator().NewUniqueID(), description, transactions, recurrence, start, end, author)
+}
+
+// RunSchedules posts every occurrence due by the clock time that has not been posted yet, catching up on missed runs
+// oldest first, and records the outcome of each. Each journal is posted with the schedule IdempotencyKey of its occurrence.
+// A failing occurrence stops its schedule until the next run, so occurrences are always posted in order.
+// The runs done are returned, an error is only returned if a run could not be recorded.
+func (acc *Accounting) RunSchedules(context context.Context, author string) ([]*ScheduleRun, error) {
+	if acc.GetScheduleManager() == nil {
+		return nil, ErrScheduleManagerNotSet
+	}
+	now := acc.GetClock().Now()
+	schedules, err := acc.GetScheduleManager().ListSchedules(context)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*ScheduleRun, 0)
+	for _, schedule := range schedules {
+		for sequence := schedule.NextSequence; ; sequence++ {
+			due, ok := schedule.DueTime(sequence)
+			if !ok || due.After(now) {
+				break
+			}
+			run, err := acc.runSchedule(context, schedule, sequence, due, now, author)
+			if err != nil {
+				return runs, err
+			}
+			runs = append(runs, run)
+			if run.Status != ScheduleRunPosted {
+				break
+			}
+		}
+	}
+	return runs, nil
+}
+
+// runSchedule posts one occurrence of the schedule and records its run. Posting the journal and recording its run
+// happen within one unit of work if the TxManager is set.
+func (acc *Accounting) runSchedule(ctx context.Context, schedule *JournalSchedule, sequence int, due, now time.Time, author string) (*ScheduleRun, error) {
+	run := &ScheduleRun{
+		ScheduleID: schedule.ScheduleID,
+		Sequence:   sequence,
+		DueTime:    due,
+		RunTime:    now,
+		Status:     ScheduleRunPosted,
+		RunBy:      author,
+	}
+	err := acc.atomically(ctx, func(ctx context.Context) error {
+		description := fmt.Sprintf("%s (%s)", schedule.Description, due.Format("2006-01-02"))
+		journal, err := acc.CreateNewJournalWithIdempotencyKey(ctx, schedule.IdempotencyKey(sequence), description, schedule.Transactions, author)
+		if err != nil {
+			return err
+		}
+		run.JournalID = journal.GetJournalID()
+		return acc.GetScheduleManager().RecordScheduleRun(ctx, run)
+	})
+	if err != nil {
+		logrus.Errorf("error running schedule %s occurrence %d due %s. got %s", schedule.ScheduleID, sequence, due.String(), err.Error())
+		run.Status, run.JournalID, run.Message = ScheduleRunFailed, "", err.Error()
+		if err := acc.GetScheduleManager().RecordScheduleRun(ctx, run); err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+// validateSchedule checks the schedule to be created by a ScheduleManager.
+func validateSchedule(scheduleID string, transactions []TransactionInfo, recurrence Recurrence, start, end time.Time) error {
+	if len(scheduleID) == 0 {
+		return ErrScheduleMissingID
+	}
+	if len(transactions) == 0 {
+		return ErrScheduleNoTransaction
+	}
+	if !end.IsZero() && end.Before(start) {
+		return ErrScheduleInvalidRange
+	}
+	return recurrence.validate()
+}
@@ -0,0 +1,160 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// testClock is a Clock the test moves by hand
+type testClock struct {
+	now time.Time
+}
+
+func (clock *testClock) Now() time.Time {
+	return clock.now
+}
+
+func TestRecurrence_Occurrence(t *testing.T) {
+	start := time.Date(2026, time.January, 31, 8, 0, 0, 0, time.UTC)
+	monthly := Recurrence{Frequency: RecurMonthly, Interval: 1}
+	assert.Equal(t, time.Date(2026, time.February, 28, 8, 0, 0, 0, time.UTC), monthly.Occurrence(start, 1))
+	assert.Equal(t, time.Date(2026, time.March, 31, 8, 0, 0, 0, time.UTC), monthly.Occurrence(start, 2))
+	assert.Equal(t, time.Date(2026, time.April, 30, 8, 0, 0, 0, time.UTC), monthly.Occurrence(start, 3))
+	assert.Equal(t, time.Date(2026, time.February, 14, 8, 0, 0, 0, time.UTC), Recurrence{Frequency: RecurWeekly, Interval: 2}.Occurrence(start, 1))
+	assert.Equal(t, time.Date(2026, time.February, 3, 8, 0, 0, 0, time.UTC), Recurrence{Frequency: RecurDaily, Interval: 3}.Occurrence(start, 1))
+	leap := time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC), Recurrence{Frequency: RecurYearly, Interval: 1}.Occurrence(leap, 1))
+	assert.Equal(t, time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC), Recurrence{Frequency: RecurYearly, Interval: 1}.Occurrence(leap, 4))
+}
+
+func TestAccounting_RunSchedules(t *testing.T) {
+	store := NewInMemoryStore()
+	acc := NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}).
+		SetTxManager(store.TxManager()).SetScheduleManager(store.ScheduleManager())
+	testRunSchedules(t, acc)
+}
+
+func TestAccounting_RunSchedulesSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	acc := NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}).
+		SetTxManager(NewSQLTxManager(db)).SetScheduleManager(NewSQLScheduleManager(db, dialect))
+	testRunSchedules(t, acc)
+}
+
+func testRunSchedules(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	for _, account := range []struct {
+		number    string
+		alignment Alignment
+	}{
+		{"5001", DEBIT},
+		{"2001", CREDIT},
+	} {
+		_, err := acc.CreateNewAccount(ctx, account.number, "Account "+account.number, "Account "+account.number, "1.1", "POINT", account.alignment, "tester")
+		assert.NoError(t, err)
+	}
+	bonus := []TransactionInfo{
+		{AccountNumber: "5001", Description: "Loyalty expense", TxType: DEBIT, Amount: decimal.NewFromInt(10)},
+		{AccountNumber: "2001", Description: "Loyalty bonus", TxType: CREDIT, Amount: decimal.NewFromInt(10)},
+	}
+	monthly := Recurrence{Frequency: RecurMonthly, Interval: 1}
+	start := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, time.May, 31, 0, 0, 0, 0, time.UTC)
+
+	_, err := acc.CreateSchedule(ctx, "Monthly bonus", nil, monthly, start, end, "tester")
+	assert.Equal(t, ErrScheduleNoTransaction, err)
+	_, err = acc.CreateSchedule(ctx, "Monthly bonus", bonus, Recurrence{Frequency: RecurMonthly}, start, end, "tester")
+	assert.Equal(t, ErrScheduleInvalidRecurrence, err)
+	_, err = acc.CreateSchedule(ctx, "Monthly bonus", bonus, monthly, end, start, "tester")
+	assert.Equal(t, ErrScheduleInvalidRange, err)
+
+	schedule, err := acc.CreateSchedule(ctx, "Monthly bonus", bonus, monthly, start, end, "tester")
+	assert.NoError(t, err)
+	assert.Len(t, schedule.Transactions, 2)
+	failing, err := acc.CreateSchedule(ctx, "Subscription fee", []TransactionInfo{
+		{AccountNumber: "2001", Description: "Subscription", TxType: DEBIT, Amount: decimal.NewFromInt(1)},
+		{AccountNumber: "4001", Description: "Subscription fee", TxType: CREDIT, Amount: decimal.NewFromInt(1)},
+	}, Recurrence{Frequency: RecurWeekly, Interval: 1}, start.AddDate(0, 0, 1), time.Time{}, "tester")
+	assert.NoError(t, err)
+
+	assertBalance := func(accountNumber string, expected int64) {
+		account, err := acc.GetAccountManager().GetAccountByID(ctx, accountNumber)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(expected).Equal(account.GetBalance()), "account %s balance is %s", accountNumber, account.GetBalance())
+	}
+
+	// nothing is due before the start
+	clock := &testClock{now: time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC)}
+	acc.SetClock(clock)
+	runs, err := acc.RunSchedules(ctx, "runner")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 0)
+
+	// missed runs are caught up, the failing schedule stops at its first occurrence
+	clock.now = time.Date(2026, time.March, 31, 10, 0, 0, 0, time.UTC)
+	runs, err = acc.RunSchedules(ctx, "runner")
+	assert.NoError(t, err)
+	if assert.Len(t, runs, 4) {
+		assert.Equal(t, schedule.ScheduleID, runs[0].ScheduleID)
+		assert.Equal(t, time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC), runs[1].DueTime.UTC())
+		assert.Equal(t, ScheduleRunFailed, runs[3].Status)
+		assert.Equal(t, failing.ScheduleID, runs[3].ScheduleID)
+		assert.Equal(t, ErrJournalTransactionAccountNotPersist.Error(), runs[3].Message)
+	}
+	assertBalance("2001", 30)
+
+	// running again at the same time posts nothing new, the failing occurrence is retried
+	runs, err = acc.RunSchedules(ctx, "runner")
+	assert.NoError(t, err)
+	if assert.Len(t, runs, 1) {
+		assert.Equal(t, ScheduleRunFailed, runs[0].Status)
+		assert.Equal(t, 0, runs[0].Sequence)
+	}
+	assertBalance("2001", 30)
+
+	// an occurrence already posted under its idempotency key, eg. before a crash, is not posted twice
+	posted, err := acc.CreateNewJournalWithIdempotencyKey(ctx, schedule.IdempotencyKey(3), "Monthly bonus (2026-04-30)", bonus, "runner")
+	assert.NoError(t, err)
+	assertBalance("2001", 40)
+	_, err = acc.CreateNewAccount(ctx, "4001", "Account 4001", "Account 4001", "1.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+
+	clock.now = time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC)
+	runs, err = acc.RunSchedules(ctx, "runner")
+	assert.NoError(t, err)
+	bonusRuns, feeRuns := 0, 0
+	for _, run := range runs {
+		assert.Equal(t, ScheduleRunPosted, run.Status)
+		if run.ScheduleID == schedule.ScheduleID {
+			bonusRuns++
+		} else {
+			feeRuns++
+		}
+	}
+	assert.Equal(t, 2, bonusRuns)
+	assert.Equal(t, 44, feeRuns)
+	assertBalance("2001", 50-44)
+	assertBalance("4001", 44)
+
+	recorded, err := acc.GetScheduleManager().ListScheduleRuns(ctx, schedule.ScheduleID)
+	assert.NoError(t, err)
+	if assert.Len(t, recorded, 5) {
+		assert.Equal(t, posted.GetJournalID(), recorded[3].JournalID)
+		assert.Equal(t, "runner", recorded[4].RunBy)
+	}
+	schedule, err = acc.GetScheduleManager().GetSchedule(ctx, schedule.ScheduleID)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, schedule.NextSequence)
+	journal, err := acc.GetJournalManager().GetJournalByIdempotencyKey(ctx, schedule.IdempotencyKey(4))
+	assert.NoError(t, err)
+	assert.Equal(t, "Monthly bonus (2026-05-31)", journal.GetDescription())
+
+	recorded, err = acc.GetScheduleManager().ListScheduleRuns(ctx, failing.ScheduleID)
+	assert.NoError(t, err)
+	assert.Len(t, recorded, 46)
+	_, err = acc.GetScheduleManager().ListScheduleRuns(ctx, "unknown")
+	assert.Equal(t, ErrScheduleNotFound, err)
+}
@@ -0,0 +1,148 @@
+package acccore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shopspring/decimal"
+	"sort"
+	"time"
+)
+
+// StatementEntry is a transaction in an account Statement, along with the account balance right after it.
+type StatementEntry struct {
+	TransactionID   string          `json:"transaction_id"`
+	TransactionTime time.Time       `json:"transaction_time"`
+	JournalID       string          `json:"journal_id"`
+	Description     string          `json:"description"`
+	Alignment       Alignment       `json:"alignment"`
+	Debit           decimal.Decimal `json:"debit"`
+	Credit          decimal.Decimal `json:"credit"`
+	RunningBalance  decimal.Decimal `json:"running_balance"`
+}
+
+// Statement is the history of an account between From and Until, starting from the opening balance,
+// going through each transaction with its running balance, and ending with the closing balance.
+type Statement struct {
+	AccountNumber  string           `json:"account_number"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description"`
+	Currency       string           `json:"currency"`
+	COA            string           `json:"coa"`
+	Alignment      Alignment        `json:"alignment"`
+	From           time.Time        `json:"from"`
+	Until          time.Time        `json:"until"`
+	OpeningBalance decimal.Decimal  `json:"opening_balance"`
+	Entries        []StatementEntry `json:"entries"`
+	TotalDebit     decimal.Decimal  `json:"total_debit"`
+	TotalCredit    decimal.Decimal  `json:"total_credit"`
+	ClosingBalance decimal.Decimal  `json:"closing_balance"`
+}
+
+// Render will render this statement into string for easy inspection
+func (statement *Statement) Render() string {
+	var buff bytes.Buffer
+	table := tablewriter.NewWriter(&buff)
+	table.SetHeader([]string{"TRX ID", "TIME", "JOURNAL ID", "Description", "DEBIT", "CREDIT", "BALANCE"})
+	table.SetFooter([]string{"", "", "", "CLOSING BALANCE", statement.TotalDebit.String(), statement.TotalCredit.String(), statement.ClosingBalance.String()})
+
+	table.Append([]string{"", statement.From.String(), "", "OPENING BALANCE", "", "", statement.OpeningBalance.String()})
+	for _, entry := range statement.Entries {
+		debit, credit := "", ""
+		if entry.Alignment == DEBIT {
+			debit = entry.Debit.String()
+		} else {
+			credit = entry.Credit.String()
+		}
+		table.Append([]string{entry.TransactionID, entry.TransactionTime.String(), entry.JournalID, entry.Description, debit, credit, entry.RunningBalance.String()})
+	}
+
+	buff.WriteString(fmt.Sprintf("Account Number    : %s\n", statement.AccountNumber))
+	buff.WriteString(fmt.Sprintf("Account Name      : %s\n", statement.Name))
+	buff.WriteString(fmt.Sprintf("Description       : %s\n", statement.Description))
+	buff.WriteString(fmt.Sprintf("Currency          : %s\n", statement.Currency))
+	buff.WriteString(fmt.Sprintf("COA               : %s\n", statement.COA))
+	buff.WriteString(fmt.Sprintf("Statement From    : %s\n", statement.From.String()))
+	buff.WriteString(fmt.Sprintf("          To      : %s\n", statement.Until.String()))
+	buff.WriteString(fmt.Sprintf("#Transactions     : %d\n", len(statement.Entries)))
+	table.Render()
+	return buff.String()
+}
+
+// ToJSON exports this statement into JSON
+func (statement *Statement) ToJSON() ([]byte, error) {
+	return json.Marshal(statement)
+}
+
+// AccountStatement builds the statement of the account for the transactions between `from` and `until` time range inclusive.
+// The entries are in transaction time order, each running balance is the opening balance moved by the entries up to it,
+// so a back dated posting is placed where it happened.
+func (acc *Accounting) AccountStatement(context context.Context, accountNumber string, from, until time.Time) (*Statement, error) {
+	account, err := acc.GetAccountManager().GetAccountByID(context, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	opening, closing, err := acc.GetTransactionManager().GetAccountBalanceRange(context, account, from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{
+		AccountNumber:  account.GetAccountNumber(),
+		Name:           account.GetName(),
+		Description:    account.GetDescription(),
+		Currency:       account.GetCurrency(),
+		COA:            account.GetCOA(),
+		Alignment:      account.GetAlignment(),
+		From:           from,
+		Until:          until,
+		OpeningBalance: opening,
+		Entries:        make([]StatementEntry, 0),
+		TotalDebit:     decimal.Zero,
+		TotalCredit:    decimal.Zero,
+		ClosingBalance: closing,
+	}
+	transactions := make([]Transaction, 0)
+	request := PageRequest{PageNo: 1, ItemSize: 100}
+	for {
+		page, pageTransactions, err := acc.GetTransactionManager().ListTransactionsOnAccount(context, from, until, account, request)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, pageTransactions...)
+		if !page.HaveNext {
+			break
+		}
+		request.PageNo = page.NextPage
+	}
+	// the transactions are listed in posting order, the ones posted at the same time keep it.
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].GetTransactionTime().Before(transactions[j].GetTransactionTime())
+	})
+
+	running := opening
+	for _, transaction := range transactions {
+		running = balanceAfter(account.GetAlignment(), transaction.GetAlignment(), transaction.GetAmount(), running)
+		entry := StatementEntry{
+			TransactionID:   transaction.GetTransactionID(),
+			TransactionTime: transaction.GetTransactionTime(),
+			JournalID:       transaction.GetJournalID(),
+			Description:     transaction.GetDescription(),
+			Alignment:       transaction.GetAlignment(),
+			Debit:           decimal.Zero,
+			Credit:          decimal.Zero,
+			RunningBalance:  running,
+		}
+		if transaction.GetAlignment() == DEBIT {
+			entry.Debit = transaction.GetAmount()
+			statement.TotalDebit = statement.TotalDebit.Add(entry.Debit)
+		} else {
+			entry.Credit = transaction.GetAmount()
+			statement.TotalCredit = statement.TotalCredit.Add(entry.Credit)
+		}
+		statement.Entries = append(statement.Entries, entry)
+	}
+	return statement, nil
+}
@@ -0,0 +1,100 @@
+package acccore
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAccounting_AccountStatement(t *testing.T) {
+	store := NewInMemoryStore()
+	testAccountStatement(t, NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}))
+}
+
+func TestAccounting_AccountStatementSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testAccountStatement(t, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}))
+}
+
+func testAccountStatement(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Point Reserve", "Point reserve", "1.1", "POINT", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2001", "User Points", "Points owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+
+	post := func(alignment Alignment, amount int64) {
+		reserve := DEBIT
+		if alignment == DEBIT {
+			reserve = CREDIT
+		}
+		_, err := acc.CreateNewJournal(ctx, "Points", []TransactionInfo{
+			{AccountNumber: "2001", Description: "Points", TxType: alignment, Amount: decimal.NewFromInt(amount)},
+			{AccountNumber: "1001", Description: "Reserve", TxType: reserve, Amount: decimal.NewFromInt(amount)},
+		}, "tester")
+		assert.NoError(t, err)
+	}
+
+	post(CREDIT, 500)
+	time.Sleep(5 * time.Millisecond)
+	from := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	post(CREDIT, 200)
+	post(DEBIT, 120)
+	post(CREDIT, 20)
+	until := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	post(DEBIT, 100)
+
+	statement, err := acc.AccountStatement(ctx, "2001", from, until)
+	assert.NoError(t, err)
+	assert.Equal(t, "User Points", statement.Name)
+	assert.True(t, decimal.NewFromInt(500).Equal(statement.OpeningBalance), "opening is %s", statement.OpeningBalance)
+	assert.Len(t, statement.Entries, 3)
+	for idx, balance := range []int64{700, 580, 600} {
+		assert.True(t, decimal.NewFromInt(balance).Equal(statement.Entries[idx].RunningBalance), "entry %d running balance is %s", idx, statement.Entries[idx].RunningBalance)
+	}
+	assert.True(t, decimal.NewFromInt(120).Equal(statement.TotalDebit))
+	assert.True(t, decimal.NewFromInt(220).Equal(statement.TotalCredit))
+	assert.True(t, decimal.NewFromInt(600).Equal(statement.ClosingBalance), "closing is %s", statement.ClosingBalance)
+	t.Log(statement.Render())
+
+	data, err := statement.ToJSON()
+	assert.NoError(t, err)
+	loaded := &Statement{}
+	assert.NoError(t, json.Unmarshal(data, loaded))
+	assert.True(t, statement.ClosingBalance.Equal(loaded.ClosingBalance))
+	assert.Len(t, loaded.Entries, 3)
+
+	statement, err = acc.AccountStatement(ctx, "2001", until.Add(time.Millisecond), until.Add(2*time.Millisecond))
+	assert.NoError(t, err)
+	assert.Len(t, statement.Entries, 0)
+	assert.True(t, decimal.NewFromInt(600).Equal(statement.ClosingBalance), "closing is %s", statement.ClosingBalance)
+
+	// a back dated posting takes its place in the statement, the entries after it carry it along
+	backdated := acc.GetJournalManager().NewJournal(ctx).SetJournalID(acc.GetUniqueIDGenerator().NewUniqueID()).
+		SetDescription("Backdated points").SetCreateBy("tester").SetJournalingTime(from.Add(time.Millisecond)).
+		SetTransactions([]Transaction{
+			acc.GetTransactionManager().NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber("2001").SetAlignment(CREDIT).SetAmount(decimal.NewFromInt(50)).SetCreateBy("tester"),
+			acc.GetTransactionManager().NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber("1001").SetAlignment(DEBIT).SetAmount(decimal.NewFromInt(50)).SetCreateBy("tester"),
+		})
+	assert.NoError(t, acc.GetJournalManager().PersistJournal(ctx, backdated))
+	statement, err = acc.AccountStatement(ctx, "2001", from, until)
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(500).Equal(statement.OpeningBalance), "opening is %s", statement.OpeningBalance)
+	if assert.Len(t, statement.Entries, 4) {
+		assert.Equal(t, backdated.GetJournalID(), statement.Entries[0].JournalID)
+		for idx, balance := range []int64{550, 750, 630, 650} {
+			assert.True(t, decimal.NewFromInt(balance).Equal(statement.Entries[idx].RunningBalance), "entry %d running balance is %s", idx, statement.Entries[idx].RunningBalance)
+		}
+	}
+	assert.True(t, decimal.NewFromInt(650).Equal(statement.ClosingBalance), "closing is %s", statement.ClosingBalance)
+
+	_, err = acc.AccountStatement(ctx, "9999", from, until)
+	assert.Equal(t, ErrAccountIDNotFound, err)
+}
@@ -0,0 +1,134 @@
+package acccore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+var (
+	ErrTrialBalanceNotBalance = fmt.Errorf("trial balance's total debit and total credit do not Balance")
+)
+
+// TrialBalanceLine is the balance of one account in a TrialBalance.
+// Only one of Debit or Credit is filled, depending on which side the account balance is at.
+type TrialBalanceLine struct {
+	AccountNumber string          `json:"account_number"`
+	Name          string          `json:"name"`
+	COA           string          `json:"coa"`
+	Alignment     Alignment       `json:"alignment"`
+	Balance       decimal.Decimal `json:"balance"`
+	Debit         decimal.Decimal `json:"debit"`
+	Credit        decimal.Decimal `json:"credit"`
+}
+
+// TrialBalance lists every account balance of a Currency at a point in time.
+// In a healthy ledger TotalDebit always equals TotalCredit.
+type TrialBalance struct {
+	AsOf        time.Time          `json:"as_of"`
+	Currency    string             `json:"currency"`
+	Lines       []TrialBalanceLine `json:"lines"`
+	TotalDebit  decimal.Decimal    `json:"total_debit"`
+	TotalCredit decimal.Decimal    `json:"total_credit"`
+}
+
+// IsBalanced returns true if the total debit equals to the total credit.
+func (tb *TrialBalance) IsBalanced() bool {
+	return tb.TotalDebit.Equal(tb.TotalCredit)
+}
+
+// Render will render this trial balance into string for easy inspection
+func (tb *TrialBalance) Render() string {
+	var buff bytes.Buffer
+	table := tablewriter.NewWriter(&buff)
+	table.SetHeader([]string{"Account", "Name", "COA", "DEBIT", "CREDIT"})
+	table.SetFooter([]string{"", "", "", tb.TotalDebit.String(), tb.TotalCredit.String()})
+	for _, line := range tb.Lines {
+		debit, credit := "", ""
+		if !line.Debit.IsZero() {
+			debit = line.Debit.String()
+		}
+		if !line.Credit.IsZero() {
+			credit = line.Credit.String()
+		}
+		table.Append([]string{line.AccountNumber, line.Name, line.COA, debit, credit})
+	}
+	buff.WriteString(fmt.Sprintf("Trial Balance : %s\n", tb.Currency))
+	buff.WriteString(fmt.Sprintf("As Of         : %s\n", tb.AsOf.String()))
+	table.Render()
+	return buff.String()
+}
+
+// TrialBalance lists every account of the currency with its debit or credit balance at the asOf time.
+// The balance at asOf is the current account balance with the transactions after asOf taken back, by their transaction time,
+// see TransactionManager.GetAccountBalanceAt. The account balance kept on each transaction is not used, it follows the
+// posting order and would be wrong for a journal posted back dated.
+// If the total debit and total credit do not balance, the trial balance is returned along with ErrTrialBalanceNotBalance.
+func (acc *Accounting) TrialBalance(context context.Context, asOf time.Time, currency string) (*TrialBalance, error) {
+	accounts, err := acc.listAllAccounts(context)
+	if err != nil {
+		return nil, err
+	}
+
+	tb := &TrialBalance{
+		AsOf:        asOf,
+		Currency:    currency,
+		Lines:       make([]TrialBalanceLine, 0),
+		TotalDebit:  decimal.Zero,
+		TotalCredit: decimal.Zero,
+	}
+	for _, account := range accounts {
+		if account.GetCurrency() != currency || account.GetCreateTime().After(asOf) {
+			continue
+		}
+		balance, err := acc.GetTransactionManager().GetAccountBalanceAt(context, account, asOf)
+		if err != nil {
+			return nil, err
+		}
+		line := TrialBalanceLine{
+			AccountNumber: account.GetAccountNumber(),
+			Name:          account.GetName(),
+			COA:           account.GetCOA(),
+			Alignment:     account.GetAlignment(),
+			Balance:       balance,
+			Debit:         decimal.Zero,
+			Credit:        decimal.Zero,
+		}
+		// a negative balance sits on the opposite side of the account alignment
+		if (account.GetAlignment() == DEBIT) == !balance.IsNegative() {
+			line.Debit = balance.Abs()
+		} else {
+			line.Credit = balance.Abs()
+		}
+		tb.TotalDebit = tb.TotalDebit.Add(line.Debit)
+		tb.TotalCredit = tb.TotalCredit.Add(line.Credit)
+		tb.Lines = append(tb.Lines, line)
+	}
+
+	if !tb.IsBalanced() {
+		logrus.Errorf("error building trial balance of %s as of %s. debit (%s) != credit (%s)", currency, asOf.String(), tb.TotalDebit.String(), tb.TotalCredit.String())
+		return tb, ErrTrialBalanceNotBalance
+	}
+	return tb, nil
+}
+
+// listAllAccounts walks through all pages of AccountManager.ListAccounts
+func (acc *Accounting) listAllAccounts(context context.Context) ([]Account, error) {
+	accounts := make([]Account, 0)
+	request := PageRequest{PageNo: 1, ItemSize: 100}
+	for {
+		page, pageAccounts, err := acc.GetAccountManager().ListAccounts(context, request)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, pageAccounts...)
+		if !page.HaveNext {
+			return accounts, nil
+		}
+		request.PageNo = page.NextPage
+	}
+}
@@ -0,0 +1,117 @@
+package acccore
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAccounting_TrialBalance(t *testing.T) {
+	store := NewInMemoryStore()
+	testTrialBalance(t, NewAccounting(store.AccountManager(), store.TransactionManager(), store.JournalManager(), &UUIDUniqueIDGenerator{}))
+}
+
+func TestAccounting_TrialBalanceSQL(t *testing.T) {
+	db, dialect := openTestSQLDatabase(t)
+	testTrialBalance(t, NewAccounting(NewSQLAccountManager(db, dialect), NewSQLTransactionManager(db, dialect), NewSQLJournalManager(db, dialect), &UUIDUniqueIDGenerator{}))
+}
+
+func testTrialBalance(t *testing.T, acc *Accounting) {
+	ctx := context.Background()
+	_, err := acc.CreateNewAccount(ctx, "1001", "Gold Cash", "Gold cash reserve", "1.1", "GOLD", DEBIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "3001", "Gold Equity", "Gold owner equity", "3.1", "GOLD", CREDIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2001", "User Gold Wallet", "Gold owned by user", "2.1", "GOLD", CREDIT, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "2002", "User Point Wallet", "Point owned by user", "2.1", "POINT", CREDIT, "tester")
+	assert.NoError(t, err)
+
+	_, err = acc.CreateNewJournal(ctx, "Initial capital", []TransactionInfo{
+		{AccountNumber: "1001", Description: "Cash in", TxType: DEBIT, Amount: decimal.NewFromInt(1000)},
+		{AccountNumber: "3001", Description: "Capital", TxType: CREDIT, Amount: decimal.NewFromInt(1000)},
+	}, "tester")
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	_, err = acc.CreateNewJournal(ctx, "Sell gold to user", []TransactionInfo{
+		{AccountNumber: "2001", Description: "Gold to wallet", TxType: CREDIT, Amount: decimal.NewFromInt(300)},
+		{AccountNumber: "3001", Description: "Capital used", TxType: DEBIT, Amount: decimal.NewFromInt(300)},
+	}, "tester")
+	assert.NoError(t, err)
+	_, err = acc.CreateNewAccount(ctx, "1002", "Gold Vault", "Gold opened later", "1.1", "GOLD", DEBIT, "tester")
+	assert.NoError(t, err)
+
+	tb, err := acc.TrialBalance(ctx, asOf, "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, tb.IsBalanced())
+	assert.True(t, decimal.NewFromInt(1000).Equal(tb.TotalDebit), "total debit is %s", tb.TotalDebit)
+	assert.Len(t, tb.Lines, 3)
+	for _, line := range tb.Lines {
+		switch line.AccountNumber {
+		case "3001":
+			assert.True(t, decimal.NewFromInt(1000).Equal(line.Credit), "equity credit is %s", line.Credit)
+		case "2001":
+			assert.True(t, line.Balance.IsZero())
+		}
+	}
+
+	tb, err = acc.TrialBalance(ctx, time.Now(), "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1000).Equal(tb.TotalCredit), "total credit is %s", tb.TotalCredit)
+	assert.Len(t, tb.Lines, 4)
+	t.Log(tb.Render())
+
+	tb, err = acc.TrialBalance(ctx, time.Now(), "POINT")
+	assert.NoError(t, err)
+	assert.Len(t, tb.Lines, 1)
+	assert.True(t, tb.TotalDebit.IsZero())
+
+	// a backdated journal transactions happen at its journaling time
+	journalingTime := asOf.Add(-time.Millisecond)
+	backdated := acc.GetJournalManager().NewJournal(ctx).SetJournalID(acc.GetUniqueIDGenerator().NewUniqueID()).
+		SetDescription("Backdated capital").SetCreateBy("tester").SetJournalingTime(journalingTime).
+		SetTransactions([]Transaction{
+			acc.GetTransactionManager().NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber("1001").SetAlignment(DEBIT).SetAmount(decimal.NewFromInt(50)).SetCreateBy("tester"),
+			acc.GetTransactionManager().NewTransaction(ctx).SetTransactionID(acc.GetUniqueIDGenerator().NewUniqueID()).
+				SetAccountNumber("3001").SetAlignment(CREDIT).SetAmount(decimal.NewFromInt(50)).SetCreateBy("tester"),
+		})
+	assert.NoError(t, acc.GetJournalManager().PersistJournal(ctx, backdated))
+	account, err := acc.GetAccountManager().GetAccountByID(ctx, "1001")
+	assert.NoError(t, err)
+	_, transactions, err := acc.GetTransactionManager().ListTransactionsOnAccount(ctx, journalingTime, journalingTime, account, PageRequest{PageNo: 1, ItemSize: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, transactions, 1) {
+		assert.Equal(t, backdated.GetTransactions()[0].GetTransactionID(), transactions[0].GetTransactionID())
+		assert.True(t, journalingTime.Equal(transactions[0].GetTransactionTime()))
+	}
+	tb, err = acc.TrialBalance(ctx, asOf, "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, tb.IsBalanced())
+	assert.True(t, decimal.NewFromInt(1050).Equal(tb.TotalDebit), "total debit is %s", tb.TotalDebit)
+
+	// the backdated journal is left out before its journaling time and counted in every later trial balance
+	tb, err = acc.TrialBalance(ctx, journalingTime.Add(-time.Millisecond), "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, tb.IsBalanced())
+	assert.True(t, decimal.NewFromInt(1000).Equal(tb.TotalDebit), "total debit is %s", tb.TotalDebit)
+	tb, err = acc.TrialBalance(ctx, journalingTime, "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1050).Equal(tb.TotalDebit), "total debit is %s", tb.TotalDebit)
+	tb, err = acc.TrialBalance(ctx, time.Now(), "GOLD")
+	assert.NoError(t, err)
+	assert.True(t, tb.IsBalanced())
+	assert.True(t, decimal.NewFromInt(1050).Equal(tb.TotalCredit), "total credit is %s", tb.TotalCredit)
+	for _, line := range tb.Lines {
+		switch line.AccountNumber {
+		case "1001":
+			assert.True(t, decimal.NewFromInt(1050).Equal(line.Debit), "cash debit is %s", line.Debit)
+		case "3001":
+			assert.True(t, decimal.NewFromInt(750).Equal(line.Credit), "equity credit is %s", line.Credit)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package acccore
+
+// joinedUnitOfWork is returned by TxManager.Begin when the context already carries a unit of work.
+// The outermost unit of work decides whether everything is committed or rolled back.
+type joinedUnitOfWork struct{}
+
+// Commit does nothing, the outermost unit of work will commit.
+func (uow joinedUnitOfWork) Commit() error {
+	return nil
+}
+
+// Rollback does nothing, the error causing the rollback should be returned to the outermost unit of work.
+func (uow joinedUnitOfWork) Rollback() error {
+	return nil
+}
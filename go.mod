@@ -4,6 +4,8 @@ go 1.20
 
 require (
 	github.com/google/uuid v1.3.1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/shopspring/decimal v1.3.1
 	github.com/sirupsen/logrus v1.9.3